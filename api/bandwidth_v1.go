@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/anacrolix/missinggo/perf"
+	"github.com/gin-gonic/gin"
+
+	"github.com/elgatito/elementum/bittorrent"
+)
+
+// APIBandwidthOverrideRequest describes the body of the bandwidth override request,
+// limits are in bytes per second, zero duration keeps override until it is cleared
+type APIBandwidthOverrideRequest struct {
+	Name          string `json:"name" form:"name"`
+	DownloadLimit int    `json:"download_rate_limit" form:"download_rate_limit"`
+	UploadLimit   int    `json:"upload_rate_limit" form:"upload_rate_limit"`
+	Duration      int    `json:"duration" form:"duration"`
+}
+
+// APIGetBandwidth returns active bandwidth profile, applied limits and schedule
+func APIGetBandwidth(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		if s.Closer.IsSet() {
+			apiAbort(ctx, http.StatusServiceUnavailable, apiErrorServiceClosing, "Service is shutting down")
+			return
+		}
+
+		ctx.JSON(http.StatusOK, s.GetBandwidthStatus())
+	}
+}
+
+// APISetBandwidthOverride manually sets session limits until cleared or expired
+func APISetBandwidthOverride(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		if s.Closer.IsSet() {
+			apiAbort(ctx, http.StatusServiceUnavailable, apiErrorServiceClosing, "Service is shutting down")
+			return
+		}
+
+		var req APIBandwidthOverrideRequest
+		if !apiBind(ctx, &req) {
+			return
+		}
+		if req.DownloadLimit < 0 || req.UploadLimit < 0 || req.Duration < 0 {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, "Limits and duration should not be negative")
+			return
+		}
+
+		s.SetBandwidthOverride(req.Name, req.DownloadLimit, req.UploadLimit, time.Duration(req.Duration)*time.Second)
+
+		ctx.JSON(http.StatusOK, s.GetBandwidthStatus())
+	}
+}
+
+// APIClearBandwidthOverride returns to scheduled or default limits
+func APIClearBandwidthOverride(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		if s.Closer.IsSet() {
+			apiAbort(ctx, http.StatusServiceUnavailable, apiErrorServiceClosing, "Service is shutting down")
+			return
+		}
+
+		s.ClearBandwidthOverride()
+
+		ctx.JSON(http.StatusOK, s.GetBandwidthStatus())
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/anacrolix/missinggo/perf"
+	"github.com/gin-gonic/gin"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/util"
+)
+
+// APISetCategoryRequest describes the body of the torrent category request
+type APISetCategoryRequest struct {
+	Category string `json:"category" form:"category"`
+}
+
+// APIListCategories returns all categories
+func APIListCategories(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	ctx.JSON(http.StatusOK, database.GetStorm().GetCategories())
+}
+
+// APIGetCategory returns a single category
+func APIGetCategory(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	name := ctx.Params.ByName("name")
+	category := database.GetStorm().GetCategory(name)
+	if category == nil {
+		apiAbort(ctx, http.StatusNotFound, apiErrorCategoryNotFound, fmt.Sprintf("Category %s not found", name))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, category)
+}
+
+// APISaveCategory creates or replaces a category
+func APISaveCategory(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		var category database.Category
+		if err := ctx.ShouldBindJSON(&category); err != nil {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, err.Error())
+			return
+		}
+
+		category.Name = strings.TrimSpace(category.Name)
+		if category.Name == "" {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, "Category name is empty")
+			return
+		}
+		if category.SeedTimeLimit < 0 || category.SeedTimeRatioLimit < 0 || category.ShareRatioLimit < 0 ||
+			category.DownloadRateLimit < 0 || category.UploadRateLimit < 0 {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, "Limits should not be negative")
+			return
+		}
+		for _, path := range []string{category.DownloadPath, category.CompletedPath} {
+			if path == "" {
+				continue
+			}
+			if err := util.IsWritablePath(path); err != nil {
+				apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, err.Error())
+				return
+			}
+		}
+
+		if err := database.GetStorm().SaveCategory(&category); err != nil {
+			apiAbort(ctx, http.StatusInternalServerError, apiErrorInvalidRequest, err.Error())
+			return
+		}
+
+		// Assigned torrents keep the category cached, so caps and limits need to be refreshed
+		for _, t := range s.GetTorrents() {
+			if t != nil && t.GetCategoryName() == category.Name {
+				t.ResetCategory()
+				t.ApplyRateLimits(s.IsStreaming())
+			}
+		}
+
+		ctx.JSON(http.StatusOK, category)
+	}
+}
+
+// APIDeleteCategory removes a category and unassigns it from torrents
+func APIDeleteCategory(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		name := ctx.Params.ByName("name")
+		if database.GetStorm().GetCategory(name) == nil {
+			apiAbort(ctx, http.StatusNotFound, apiErrorCategoryNotFound, fmt.Sprintf("Category %s not found", name))
+			return
+		}
+
+		var assigned []*bittorrent.Torrent
+		for _, t := range s.GetTorrents() {
+			if t != nil && t.GetCategoryName() == name {
+				assigned = append(assigned, t)
+			}
+		}
+
+		if err := database.GetStorm().DeleteCategory(name); err != nil {
+			apiAbort(ctx, http.StatusInternalServerError, apiErrorInvalidRequest, err.Error())
+			return
+		}
+
+		// Bandwidth caps are stored in libtorrent, so they need to be removed explicitly
+		for _, t := range assigned {
+			t.FetchDBItem()
+			t.ApplyRateLimits(s.IsStreaming())
+		}
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// APISetTorrentCategory assigns torrent to a category, empty category removes the assignment
+func APISetTorrentCategory(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+
+		var req APISetCategoryRequest
+		if !apiBind(ctx, &req) {
+			return
+		}
+
+		if err := t.SetCategory(strings.TrimSpace(req.Category)); err != nil {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorCategoryNotFound, err.Error())
+			return
+		}
+
+		ctx.JSON(http.StatusOK, newAPITorrent(t, false))
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/elgatito/elementum/bittorrent"
+)
+
+const eventsHeartbeatInterval = 15 * time.Second
+
+// Events streams torrent, session and player state changes as Server-Sent Events.
+// Optional "types" (comma separated event types) and "infohash" query params filter the stream.
+func Events(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if s.Closer.IsSet() {
+			apiAbort(ctx, http.StatusServiceUnavailable, apiErrorServiceClosing, "Service is shutting down")
+			return
+		}
+
+		types := map[string]bool{}
+		for _, t := range strings.Split(ctx.Query("types"), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types[t] = true
+			}
+		}
+		infoHash := strings.ToLower(ctx.Query("infohash"))
+
+		events, done := s.Events()
+		defer close(done)
+
+		heartbeat := time.NewTicker(eventsHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		closing := s.Closer.C()
+		clientGone := ctx.Request.Context().Done()
+
+		ctx.Header("Cache-Control", "no-cache")
+		ctx.Header("Connection", "keep-alive")
+		ctx.Header("X-Accel-Buffering", "no")
+
+		// Send initial event so that clients know the stream is established
+		ctx.SSEvent("ping", time.Now().Unix())
+
+		ctx.Stream(func(w io.Writer) bool {
+			select {
+			case <-closing:
+				return false
+			case <-clientGone:
+				return false
+			case <-heartbeat.C:
+				ctx.SSEvent("ping", time.Now().Unix())
+				return true
+			case e, ok := <-events:
+				if !ok {
+					return false
+				}
+				if len(types) > 0 && !types[e.Type] {
+					return true
+				}
+				if infoHash != "" && e.InfoHash != infoHash {
+					return true
+				}
+
+				ctx.SSEvent(e.Type, e)
+				return true
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/anacrolix/missinggo/perf"
+	"github.com/gin-gonic/gin"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/feeds"
+)
+
+const apiFeedGrabsLimit = 50
+
+// APIFeed is a feed together with latest torrents, added from it
+type APIFeed struct {
+	*database.Feed
+	Grabs []database.FeedGrab `json:"grabs"`
+}
+
+// APICheckFeedResponse describes results of a feed check
+type APICheckFeedResponse struct {
+	Feed    string          `json:"feed"`
+	DryRun  bool            `json:"dry_run"`
+	Results []*feeds.Result `json:"results"`
+}
+
+// APIListFeeds returns all feeds
+func APIListFeeds(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	ctx.JSON(http.StatusOK, database.GetStorm().GetFeeds())
+}
+
+// APIGetFeed returns a single feed with its latest grabs
+func APIGetFeed(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	feed := apiFeedFromParam(ctx)
+	if feed == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, APIFeed{
+		Feed:  feed,
+		Grabs: database.GetStorm().GetFeedGrabs(feed.Name, apiFeedGrabsLimit),
+	})
+}
+
+// APISaveFeed creates or replaces a feed
+func APISaveFeed(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	var feed database.Feed
+	if err := ctx.ShouldBindJSON(&feed); err != nil {
+		apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, err.Error())
+		return
+	}
+
+	feed.Name = strings.TrimSpace(feed.Name)
+	feed.URL = strings.TrimSpace(feed.URL)
+	feed.Category = strings.TrimSpace(feed.Category)
+	if err := validateFeed(&feed); err != nil {
+		apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, err.Error())
+		return
+	}
+	if feed.Category != "" && database.GetStorm().GetCategory(feed.Category) == nil {
+		apiAbort(ctx, http.StatusBadRequest, apiErrorCategoryNotFound, fmt.Sprintf("Category %s not found", feed.Category))
+		return
+	}
+
+	// Check status is not editable
+	if existing := database.GetStorm().GetFeed(feed.Name); existing != nil {
+		feed.LastChecked = existing.LastChecked
+		feed.LastError = existing.LastError
+	}
+
+	if err := database.GetStorm().SaveFeed(&feed); err != nil {
+		apiAbort(ctx, http.StatusInternalServerError, apiErrorInvalidRequest, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, feed)
+}
+
+// APIDeleteFeed removes a feed, torrents that were added from it are kept
+func APIDeleteFeed(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	feed := apiFeedFromParam(ctx)
+	if feed == nil {
+		return
+	}
+
+	if err := database.GetStorm().DeleteFeed(feed.Name); err != nil {
+		apiAbort(ctx, http.StatusInternalServerError, apiErrorInvalidRequest, err.Error())
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// APICheckFeed checks a feed right away, with ?dry_run=true it only shows which torrents would be added
+func APICheckFeed(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		if s.Closer.IsSet() {
+			apiAbort(ctx, http.StatusServiceUnavailable, apiErrorServiceClosing, "Service is shutting down")
+			return
+		}
+
+		feed := apiFeedFromParam(ctx)
+		if feed == nil {
+			return
+		}
+
+		dryRun := ctx.DefaultQuery("dry_run", "false") == "true"
+		results, err := feeds.Check(s, feed, dryRun)
+		if err != nil {
+			apiAbort(ctx, http.StatusBadGateway, apiErrorFeedFailed, err.Error())
+			return
+		}
+
+		ctx.JSON(http.StatusOK, APICheckFeedResponse{
+			Feed:    feed.Name,
+			DryRun:  dryRun,
+			Results: results,
+		})
+	}
+}
+
+func apiFeedFromParam(ctx *gin.Context) *database.Feed {
+	name := ctx.Params.ByName("name")
+	feed := database.GetStorm().GetFeed(name)
+	if feed == nil {
+		apiAbort(ctx, http.StatusNotFound, apiErrorFeedNotFound, fmt.Sprintf("Feed %s not found", name))
+		return nil
+	}
+	return feed
+}
+
+func validateFeed(feed *database.Feed) error {
+	if feed.Name == "" {
+		return fmt.Errorf("Feed name is empty")
+	}
+	if u, err := url.Parse(feed.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Feed URL should be an http or https link")
+	}
+	if feed.Interval < 0 || (feed.Interval > 0 && feed.Interval < feeds.MinInterval) {
+		return fmt.Errorf("Interval should be at least %d minutes", feeds.MinInterval)
+	}
+	if feed.MinSize < 0 || feed.MaxSize < 0 || feed.MinSeeds < 0 {
+		return fmt.Errorf("Limits should not be negative")
+	}
+	if feed.MaxSize > 0 && feed.MinSize > feed.MaxSize {
+		return fmt.Errorf("Minimal size is bigger than maximal")
+	}
+	for _, r := range []int{feed.MinResolution, feed.MaxResolution} {
+		if r < 0 || r >= len(bittorrent.Resolutions) {
+			return fmt.Errorf("Unknown resolution: %d", r)
+		}
+	}
+	if feed.MaxResolution > 0 && feed.MinResolution > feed.MaxResolution {
+		return fmt.Errorf("Minimal resolution is higher than maximal")
+	}
+	for _, c := range feed.VideoCodecs {
+		if c < 0 || c >= len(bittorrent.Codecs) {
+			return fmt.Errorf("Unknown video codec: %d", c)
+		}
+	}
+	for _, r := range feed.RipTypes {
+		if r < 0 || r >= len(bittorrent.Rips) {
+			return fmt.Errorf("Unknown rip type: %d", r)
+		}
+	}
+
+	_, err := feeds.NewFilter(feed)
+	return err
+}
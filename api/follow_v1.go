@@ -0,0 +1,139 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/anacrolix/missinggo/perf"
+	"github.com/gin-gonic/gin"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/follow"
+)
+
+// APIFollowedShow is a followed show together with downloaded episodes
+type APIFollowedShow struct {
+	*database.FollowedShow
+	Grabs []database.EpisodeGrab `json:"grabs"`
+}
+
+// APIFollowShowRequest describes the body of the follow request
+type APIFollowShowRequest struct {
+	Category string `json:"category" form:"category"`
+}
+
+// APICheckFollowedShowResponse describes results of a followed show check
+type APICheckFollowedShowResponse struct {
+	ID      int              `json:"id"`
+	Results []*follow.Result `json:"results"`
+}
+
+// APIListFollowedShows returns all followed shows
+func APIListFollowedShows(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	ctx.JSON(http.StatusOK, database.GetStorm().GetFollowedShows())
+}
+
+// APIGetFollowedShow returns a followed show with downloaded episodes
+func APIGetFollowedShow(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	followed := apiFollowedShowFromParam(ctx)
+	if followed == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, APIFollowedShow{
+		FollowedShow: followed,
+		Grabs:        database.GetStorm().GetEpisodeGrabs(followed.ID),
+	})
+}
+
+// APIFollowShow starts following a show by TMDB id, or changes its category
+func APIFollowShow(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	showID, err := strconv.Atoi(ctx.Params.ByName("showId"))
+	if err != nil || showID <= 0 {
+		apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, "Show id should be a TMDB id")
+		return
+	}
+
+	var req APIFollowShowRequest
+	if !apiBind(ctx, &req) {
+		return
+	}
+
+	category := strings.TrimSpace(req.Category)
+	if category != "" && database.GetStorm().GetCategory(category) == nil {
+		apiAbort(ctx, http.StatusBadRequest, apiErrorCategoryNotFound, fmt.Sprintf("Category %s not found", category))
+		return
+	}
+
+	followed, err := follow.Follow(showID, category)
+	if err != nil {
+		apiAbort(ctx, http.StatusNotFound, apiErrorShowNotFound, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, followed)
+}
+
+// APIUnfollowShow stops following a show, downloaded episodes are kept
+func APIUnfollowShow(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	followed := apiFollowedShowFromParam(ctx)
+	if followed == nil {
+		return
+	}
+
+	if err := database.GetStorm().UnfollowShow(followed.ID); err != nil {
+		apiAbort(ctx, http.StatusInternalServerError, apiErrorInvalidRequest, err.Error())
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// APICheckFollowedShow searches for aired episodes of a followed show right away
+func APICheckFollowedShow(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		if s.Closer.IsSet() {
+			apiAbort(ctx, http.StatusServiceUnavailable, apiErrorServiceClosing, "Service is shutting down")
+			return
+		}
+
+		followed := apiFollowedShowFromParam(ctx)
+		if followed == nil {
+			return
+		}
+
+		results, err := follow.Check(s, followed)
+		if err != nil && len(results) == 0 {
+			apiAbort(ctx, http.StatusBadGateway, apiErrorSearchFailed, err.Error())
+			return
+		}
+
+		ctx.JSON(http.StatusOK, APICheckFollowedShowResponse{
+			ID:      followed.ID,
+			Results: results,
+		})
+	}
+}
+
+func apiFollowedShowFromParam(ctx *gin.Context) *database.FollowedShow {
+	showID, _ := strconv.Atoi(ctx.Params.ByName("showId"))
+	followed := database.GetStorm().GetFollowedShow(showID)
+	if followed == nil {
+		apiAbort(ctx, http.StatusNotFound, apiErrorShowNotFound, fmt.Sprintf("Show %s is not followed", ctx.Params.ByName("showId")))
+		return nil
+	}
+	return followed
+}
@@ -77,10 +77,28 @@ func Notification(s *bittorrent.Service) gin.HandlerFunc {
 				return
 			}
 			p.Params().Seeked = true
+
+			var request struct {
+				Player struct {
+					Time struct {
+						Hours        int `json:"hours"`
+						Minutes      int `json:"minutes"`
+						Seconds      int `json:"seconds"`
+						Milliseconds int `json:"milliseconds"`
+					} `json:"time"`
+				} `json:"player"`
+			}
+			if err := json.Unmarshal(jsonData, &request); err != nil {
+				log.Error(err)
+			}
+			position := request.Player.Time
+			seconds := float64(position.Hours*3600+position.Minutes*60+position.Seconds) + float64(position.Milliseconds)/1000
+
 			// Run prioritization over Player's torrent
 			go func() {
 				// TODO: Do we need to clear deadlines? It can be just few pieces in the waitlist.
 				// p.GetTorrent().ClearDeadlines()
+				s.PlayerSeek(seconds)
 				p.GetTorrent().PrioritizePieces()
 			}()
 
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/util/metrics"
+)
+
+// Metrics exports session, torrent, player and database statistics in Prometheus text format
+func Metrics(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		w := &bytes.Buffer{}
+
+		if !s.Closer.IsSet() {
+			writeTorrentMetrics(w, s)
+			writeServiceMetrics(w, s)
+		}
+		writeDatabaseMetrics(w)
+		metrics.WriteRegistered(w)
+
+		ctx.Data(200, "text/plain; version=0.0.4; charset=utf-8", w.Bytes())
+	}
+}
+
+func writeTorrentMetrics(w *bytes.Buffer, s *bittorrent.Service) {
+	var (
+		downloadRate   []metrics.Sample
+		uploadRate     []metrics.Sample
+		progress       []metrics.Sample
+		bufferProgress []metrics.Sample
+		seeds          []metrics.Sample
+		seedsTotal     []metrics.Sample
+		peers          []metrics.Sample
+		peersTotal     []metrics.Sample
+		states         []metrics.Sample
+		memorySize     []metrics.Sample
+	)
+
+	for _, t := range s.GetTorrents() {
+		if t == nil || t.Closer.IsSet() || !t.HasMetadata() {
+			continue
+		}
+
+		labels := metrics.Labels{"infohash": t.InfoHash(), "name": t.Name()}
+
+		down, up := t.GetSpeeds()
+		downloadRate = append(downloadRate, metrics.Sample{Labels: labels, Value: float64(down)})
+		uploadRate = append(uploadRate, metrics.Sample{Labels: labels, Value: float64(up)})
+
+		progress = append(progress, metrics.Sample{Labels: labels, Value: t.GetProgress()})
+		if t.IsBuffering {
+			bufferProgress = append(bufferProgress, metrics.Sample{Labels: labels, Value: t.GetBufferProgress()})
+		}
+
+		connectedSeeds, totalSeeds, connectedPeers, totalPeers := t.GetConnections()
+		seeds = append(seeds, metrics.Sample{Labels: labels, Value: float64(connectedSeeds)})
+		seedsTotal = append(seedsTotal, metrics.Sample{Labels: labels, Value: float64(totalSeeds)})
+		peers = append(peers, metrics.Sample{Labels: labels, Value: float64(connectedPeers)})
+		peersTotal = append(peersTotal, metrics.Sample{Labels: labels, Value: float64(totalPeers)})
+
+		states = append(states, metrics.Sample{
+			Labels: metrics.Labels{"infohash": t.InfoHash(), "state": bittorrent.StatusName(t.GetSmartState())},
+			Value:  1,
+		})
+
+		if t.IsMemoryStorage() {
+			memorySize = append(memorySize, metrics.Sample{Labels: labels, Value: float64(t.MemorySize)})
+		}
+	}
+
+	metrics.WriteFamily(w, "elementum_torrents", "Number of active torrents.", metrics.TypeGauge, []metrics.Sample{{Value: float64(len(progress))}})
+	metrics.WriteFamily(w, "elementum_torrent_download_rate_bytes", "Torrent payload download rate in bytes per second.", metrics.TypeGauge, downloadRate)
+	metrics.WriteFamily(w, "elementum_torrent_upload_rate_bytes", "Torrent payload upload rate in bytes per second.", metrics.TypeGauge, uploadRate)
+	metrics.WriteFamily(w, "elementum_torrent_progress_percent", "Torrent download progress.", metrics.TypeGauge, progress)
+	metrics.WriteFamily(w, "elementum_torrent_buffer_progress_percent", "Buffering progress of torrents that are currently buffering.", metrics.TypeGauge, bufferProgress)
+	metrics.WriteFamily(w, "elementum_torrent_seeds", "Connected seeds.", metrics.TypeGauge, seeds)
+	metrics.WriteFamily(w, "elementum_torrent_seeds_total", "Known seeds in the swarm.", metrics.TypeGauge, seedsTotal)
+	metrics.WriteFamily(w, "elementum_torrent_peers", "Connected peers.", metrics.TypeGauge, peers)
+	metrics.WriteFamily(w, "elementum_torrent_peers_total", "Known peers in the swarm.", metrics.TypeGauge, peersTotal)
+	metrics.WriteFamily(w, "elementum_torrent_state", "Current torrent state, value is always 1.", metrics.TypeGauge, states)
+	metrics.WriteFamily(w, "elementum_torrent_memory_storage_bytes", "Memory storage size allocated for a torrent.", metrics.TypeGauge, memorySize)
+}
+
+func writeServiceMetrics(w *bytes.Buffer, s *bittorrent.Service) {
+	stats := s.GetMemoryStats()
+	metrics.WriteFamily(w, "elementum_system_memory_bytes", "System memory, as used for memory storage decisions.", metrics.TypeGauge, []metrics.Sample{
+		{Labels: metrics.Labels{"type": "total"}, Value: float64(stats.Total)},
+		{Labels: metrics.Labels{"type": "free"}, Value: float64(stats.Free)},
+	})
+
+	if spillover := stats.Spillover; spillover != nil {
+		metrics.WriteFamily(w, "elementum_spillover_cache_bytes", "Spillover cache of memory storage.", metrics.TypeGauge, []metrics.Sample{
+			{Labels: metrics.Labels{"type": "capacity"}, Value: float64(spillover.Capacity)},
+			{Labels: metrics.Labels{"type": "used"}, Value: float64(spillover.Size)},
+		})
+		metrics.WriteFamily(w, "elementum_spillover_cache_pieces", "Pieces in spillover cache of memory storage.", metrics.TypeGauge, []metrics.Sample{{Value: float64(spillover.Pieces)}})
+		metrics.WriteFamily(w, "elementum_spillover_cache_reads_total", "Reads of evicted pieces from spillover cache.", metrics.TypeCounter, []metrics.Sample{
+			{Labels: metrics.Labels{"result": "hit"}, Value: float64(spillover.Hits)},
+			{Labels: metrics.Labels{"result": "miss"}, Value: float64(spillover.Misses)},
+		})
+		metrics.WriteFamily(w, "elementum_spillover_cache_pieces_total", "Pieces stored in and evicted from spillover cache.", metrics.TypeCounter, []metrics.Sample{
+			{Labels: metrics.Labels{"event": "stored"}, Value: float64(spillover.Stored)},
+			{Labels: metrics.Labels{"event": "evicted"}, Value: float64(spillover.Evicted)},
+		})
+	}
+
+	paused := 0.0
+	if s.Session != nil && s.Session.IsPaused() {
+		paused = 1
+	}
+	metrics.WriteFamily(w, "elementum_session_paused", "Whether the whole session is paused.", metrics.TypeGauge, []metrics.Sample{{Value: paused}})
+
+	players := 0.0
+	if s.GetActivePlayer() != nil {
+		players = 1
+	}
+	metrics.WriteFamily(w, "elementum_player_active", "Whether there is an active player.", metrics.TypeGauge, []metrics.Sample{{Value: players}})
+}
+
+func writeDatabaseMetrics(w *bytes.Buffer) {
+	sizes := []metrics.Sample{}
+	if db := database.GetStorm(); db != nil {
+		sizes = append(sizes, metrics.Sample{Labels: metrics.Labels{"database": "app"}, Value: databaseFileSize(db.GetFilename())})
+	}
+	if db := database.GetCache(); db != nil {
+		sizes = append(sizes, metrics.Sample{Labels: metrics.Labels{"database": "cache"}, Value: databaseFileSize(db.GetFilename())})
+	}
+	metrics.WriteFamily(w, "elementum_database_size_bytes", "Size of database files.", metrics.TypeGauge, sizes)
+}
+
+func databaseFileSize(name string) float64 {
+	fi, err := os.Stat(filepath.Join(config.Get().Info.Profile, name))
+	if err != nil {
+		return 0
+	}
+	return float64(fi.Size())
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/anacrolix/missinggo/perf"
+	"github.com/gin-gonic/gin"
+
+	"github.com/elgatito/elementum/bittorrent"
+)
+
+const (
+	m3uContentType  = "audio/x-mpegurl"
+	xspfContentType = "application/xspf+xml"
+)
+
+// xspfPlaylist is a minimal XSPF document
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Version string      `xml:"version,attr"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title"`
+}
+
+// TorrentPlaylistM3U returns extended M3U playlist with media files of the torrent
+func TorrentPlaylistM3U(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t, files := torrentPlaylist(s, ctx)
+		if t == nil {
+			return
+		}
+
+		var b bytes.Buffer
+		b.WriteString("#EXTM3U\n")
+		fmt.Fprintf(&b, "#PLAYLIST:%s\n", t.Name())
+		for _, f := range files {
+			fmt.Fprintf(&b, "#EXTINF:-1,%s\n", filepath.Base(f.Path))
+			b.WriteString(playlistFileURL(t, f) + "\n")
+		}
+
+		ctx.Data(200, m3uContentType, b.Bytes())
+	}
+}
+
+// TorrentPlaylistXSPF returns XSPF playlist with media files of the torrent
+func TorrentPlaylistXSPF(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t, files := torrentPlaylist(s, ctx)
+		if t == nil {
+			return
+		}
+
+		playlist := xspfPlaylist{
+			Version: "1",
+			XMLNS:   "http://xspf.org/ns/0/",
+			Title:   t.Name(),
+			Tracks:  make([]xspfTrack, 0, len(files)),
+		}
+		for _, f := range files {
+			playlist.Tracks = append(playlist.Tracks, xspfTrack{
+				Location: playlistFileURL(t, f),
+				Title:    filepath.Base(f.Path),
+			})
+		}
+
+		out, err := xml.MarshalIndent(playlist, "", "  ")
+		if err != nil {
+			ctx.String(500, err.Error())
+			return
+		}
+
+		ctx.Data(200, xspfContentType, append([]byte(xml.Header), out...))
+	}
+}
+
+// torrentPlaylist returns torrent with its media files in playback order,
+// and remembers the order, so that next file is preloaded during playback
+func torrentPlaylist(s *bittorrent.Service, ctx *gin.Context) (*bittorrent.Torrent, []*bittorrent.File) {
+	t, err := GetTorrentFromParam(s, ctx.Params.ByName("torrentId"))
+	if err != nil {
+		ctx.String(404, err.Error())
+		return nil, nil
+	} else if !t.HasMetadata() {
+		ctx.String(409, "Torrent metadata is not downloaded yet")
+		return nil, nil
+	}
+
+	files := t.PlaylistFiles()
+	if len(files) == 0 {
+		ctx.String(404, "No media files in torrent")
+		return nil, nil
+	}
+
+	t.SetPlaylist(files)
+	return t, files
+}
+
+// playlistFileURL returns Kodi play URL for a specific torrent file
+func playlistFileURL(t *bittorrent.Torrent, f *bittorrent.File) string {
+	return t.GetPlayURL("", "oindex", strconv.Itoa(f.Index))
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/anacrolix/missinggo/perf"
+	"github.com/gin-gonic/gin"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/quality"
+)
+
+// APIListQualityProfiles returns all quality profiles
+func APIListQualityProfiles(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	ctx.JSON(http.StatusOK, database.GetStorm().GetQualityProfiles())
+}
+
+// APIGetQualityProfile returns a single quality profile
+func APIGetQualityProfile(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	name := ctx.Params.ByName("name")
+	profile := database.GetStorm().GetQualityProfile(name)
+	if profile == nil {
+		apiAbort(ctx, http.StatusNotFound, apiErrorProfileNotFound, fmt.Sprintf("Quality profile %s not found", name))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, profile)
+}
+
+// APISaveQualityProfile creates or replaces a quality profile
+func APISaveQualityProfile(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	var profile database.QualityProfile
+	if err := ctx.ShouldBindJSON(&profile); err != nil {
+		apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, err.Error())
+		return
+	}
+
+	profile.Name = strings.TrimSpace(profile.Name)
+	if err := validateQualityProfile(&profile); err != nil {
+		apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, err.Error())
+		return
+	}
+
+	if err := database.GetStorm().SaveQualityProfile(&profile); err != nil {
+		apiAbort(ctx, http.StatusInternalServerError, apiErrorInvalidRequest, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, profile)
+}
+
+// APIDeleteQualityProfile removes a quality profile
+func APIDeleteQualityProfile(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	name := ctx.Params.ByName("name")
+	if database.GetStorm().GetQualityProfile(name) == nil {
+		apiAbort(ctx, http.StatusNotFound, apiErrorProfileNotFound, fmt.Sprintf("Quality profile %s not found", name))
+		return
+	}
+
+	if err := database.GetStorm().DeleteQualityProfile(name); err != nil {
+		apiAbort(ctx, http.StatusInternalServerError, apiErrorInvalidRequest, err.Error())
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// APIListQualityUpgrades returns upgrades that are being downloaded
+func APIListQualityUpgrades(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	ctx.JSON(http.StatusOK, database.GetStorm().GetQualityUpgrades())
+}
+
+// APIRunQualityUpgrades looks for upgrades right away, in background
+func APIRunQualityUpgrades(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		if s.Closer.IsSet() {
+			apiAbort(ctx, http.StatusServiceUnavailable, apiErrorServiceClosing, "Service is shutting down")
+			return
+		}
+
+		go quality.Run(s)
+
+		ctx.Status(http.StatusAccepted)
+	}
+}
+
+func validateQualityProfile(p *database.QualityProfile) error {
+	if p.Name == "" {
+		return fmt.Errorf("Quality profile name is empty")
+	}
+	for _, r := range append([]int{p.CutoffResolution}, p.Resolutions...) {
+		if r < 0 || r >= len(bittorrent.Resolutions) {
+			return fmt.Errorf("Unknown resolution: %d", r)
+		}
+	}
+	for _, c := range p.VideoCodecs {
+		if c < 0 || c >= len(bittorrent.Codecs) {
+			return fmt.Errorf("Unknown video codec: %d", c)
+		}
+	}
+	for _, r := range append([]int{p.CutoffRipType}, p.RipTypes...) {
+		if r < 0 || r >= len(bittorrent.Rips) {
+			return fmt.Errorf("Unknown rip type: %d", r)
+		}
+	}
+	if p.MinSizePerMinute < 0 || p.MaxSizePerMinute < 0 {
+		return fmt.Errorf("Sizes should not be negative")
+	}
+	if p.MaxSizePerMinute > 0 && p.MinSizePerMinute > p.MaxSizePerMinute {
+		return fmt.Errorf("Minimal size is bigger than maximal")
+	}
+
+	return nil
+}
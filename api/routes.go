@@ -22,7 +22,7 @@ func CORS() gin.HandlerFunc {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Header("Access-Control-Allow-Methods", "POST, HEAD, PATCH, OPTIONS, GET, PUT")
+		c.Header("Access-Control-Allow-Methods", "POST, HEAD, PATCH, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -49,7 +49,7 @@ func Auth() gin.HandlerFunc {
 func Routes(s *bittorrent.Service, shutdown func(code int)) *gin.Engine {
 	r := gin.New()
 	r.Use(gin.Recovery())
-	r.Use(gin.LoggerWithWriter(gin.DefaultWriter, "/torrents/list", "/notification"))
+	r.Use(gin.LoggerWithWriter(gin.DefaultWriter, "/torrents/list", "/notification", "/metrics"))
 	r.Use(CORS())
 	r.Use(Auth())
 
@@ -66,6 +66,8 @@ func Routes(s *bittorrent.Service, shutdown func(code int)) *gin.Engine {
 	r.Any("/info", s.ClientInfo)
 	r.Any("/info/*ident", s.ClientInfo)
 
+	r.GET("/metrics", Metrics(s))
+
 	r.Any("/debug/all", bittorrent.DebugAll(s))
 	r.Any("/debug/bundle", bittorrent.DebugBundle(s))
 
@@ -110,6 +112,7 @@ func Routes(s *bittorrent.Service, shutdown func(code int)) *gin.Engine {
 		torrents.GET("/pause", PauseSession(s))
 		torrents.GET("/resume", ResumeSession(s))
 		torrents.GET("/move/:torrentId", MoveTorrent(s))
+		torrents.GET("/category/:torrentId", SetTorrentCategory(s))
 		torrents.GET("/pause/:torrentId", PauseTorrent(s))
 		torrents.GET("/resume/:torrentId", ResumeTorrent(s))
 		torrents.GET("/delete/:torrentId", RemoveTorrent(s))
@@ -118,11 +121,87 @@ func Routes(s *bittorrent.Service, shutdown func(code int)) *gin.Engine {
 		torrents.GET("/selectfile/:torrentId", SelectFileTorrent(s, true))
 		torrents.GET("/downloadfile/:torrentId", SelectFileTorrent(s, false))
 		torrents.GET("/assign/:torrentId/:tmdbId", AssignTorrent(s))
+		torrents.GET("/:torrentId/playlist.m3u8", TorrentPlaylistM3U(s))
+		torrents.GET("/:torrentId/playlist.xspf", TorrentPlaylistXSPF(s))
 
 		// Web UI json
 		torrents.GET("/list", ListTorrentsWeb(s))
 	}
 
+	apiV1 := r.Group("/api/v1")
+	{
+		apiV1.GET("/events", Events(s))
+
+		bandwidth := apiV1.Group("/bandwidth")
+		{
+			bandwidth.GET("", APIGetBandwidth(s))
+			bandwidth.POST("/override", APISetBandwidthOverride(s))
+			bandwidth.DELETE("/override", APIClearBandwidthOverride(s))
+		}
+
+		categories := apiV1.Group("/categories")
+		{
+			categories.GET("", APIListCategories)
+			categories.POST("", APISaveCategory(s))
+			categories.GET("/:name", APIGetCategory)
+			categories.DELETE("/:name", APIDeleteCategory(s))
+		}
+
+		feeds := apiV1.Group("/feeds")
+		{
+			feeds.GET("", APIListFeeds)
+			feeds.POST("", APISaveFeed)
+			feeds.GET("/:name", APIGetFeed)
+			feeds.DELETE("/:name", APIDeleteFeed)
+			feeds.POST("/:name/check", APICheckFeed(s))
+		}
+
+		followed := apiV1.Group("/follow")
+		{
+			followed.GET("", APIListFollowedShows)
+			followed.GET("/:showId", APIGetFollowedShow)
+			followed.POST("/:showId", APIFollowShow)
+			followed.DELETE("/:showId", APIUnfollowShow)
+			followed.POST("/:showId/check", APICheckFollowedShow(s))
+		}
+
+		quality := apiV1.Group("/quality")
+		{
+			quality.GET("/profiles", APIListQualityProfiles)
+			quality.POST("/profiles", APISaveQualityProfile)
+			quality.GET("/profiles/:name", APIGetQualityProfile)
+			quality.DELETE("/profiles/:name", APIDeleteQualityProfile)
+			quality.GET("/upgrades", APIListQualityUpgrades)
+			quality.POST("/upgrades/run", APIRunQualityUpgrades(s))
+		}
+
+		apiV1.GET("/trackers", APIListTrackers)
+
+		torrents := apiV1.Group("/torrents")
+		{
+			torrents.GET("", APIListTorrents(s))
+			torrents.POST("", APIAddTorrent(s))
+			torrents.POST("/create", APICreateTorrent(s))
+			torrents.GET("/:infohash", APIGetTorrent(s))
+			torrents.DELETE("/:infohash", APIDeleteTorrent(s))
+			torrents.POST("/:infohash/pause", APIPauseTorrent(s))
+			torrents.POST("/:infohash/resume", APIResumeTorrent(s))
+			torrents.GET("/:infohash/files", APIListTorrentFiles(s))
+			torrents.GET("/:infohash/files/:index", APIGetTorrentFile(s))
+			torrents.POST("/:infohash/files/:index", APISetTorrentFilePriority(s))
+			torrents.DELETE("/:infohash/files/:index", APIUnselectTorrentFile(s))
+			torrents.GET("/:infohash/files/:index/hls/index.m3u8", APIStreamPlaylist(s))
+			torrents.GET("/:infohash/files/:index/hls/:segment", APIStreamSegment(s))
+			torrents.POST("/:infohash/category", APISetTorrentCategory(s))
+			torrents.POST("/:infohash/limits", APISetTorrentLimits(s))
+			torrents.GET("/:infohash/trackers", APIListTorrentTrackers(s))
+			torrents.POST("/:infohash/trackers", APIAddTorrentTracker(s))
+			torrents.PUT("/:infohash/trackers", APISetTorrentTrackers(s))
+			torrents.DELETE("/:infohash/trackers", APIDeleteTorrentTracker(s))
+			torrents.POST("/:infohash/trackers/reannounce", APIReannounceTorrent(s))
+		}
+	}
+
 	movies := r.Group("/movies")
 	{
 		movies.GET("/", MoviesIndex)
@@ -262,6 +341,8 @@ func Routes(s *bittorrent.Service, shutdown func(code int)) *gin.Engine {
 		show.GET("/:showId/unwatched", ToggleWatched("show", false))
 		show.GET("/:showId/unwatched/*ident", ToggleWatched("show", false))
 		show.GET("/:showId/seasons", ShowSeasons)
+		show.GET("/:showId/follow", FollowShow)
+		show.GET("/:showId/unfollow", UnfollowShow)
 		show.GET("/:showId/season/:season/download", ShowSeasonRun("download", s))
 		show.GET("/:showId/season/:season/download/*ident", ShowSeasonRun("download", s))
 		show.GET("/:showId/season/:season/links", ShowSeasonRun("links", s))
@@ -16,6 +16,7 @@ import (
 	"github.com/elgatito/elementum/bittorrent"
 	"github.com/elgatito/elementum/config"
 	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/follow"
 	"github.com/elgatito/elementum/library"
 	"github.com/elgatito/elementum/library/uid"
 	"github.com/elgatito/elementum/providers"
@@ -330,11 +331,17 @@ func renderShows(ctx *gin.Context, shows tmdb.Shows, page int, total int, query
 				collectionAction = []string{"LOCALIZE[30259]", fmt.Sprintf("RunPlugin(%s)", URLForXBMC("/show/%d/collection/remove", show.ID))}
 			}
 
+			followAction := []string{"LOCALIZE[30691]", fmt.Sprintf("RunPlugin(%s)", URLForXBMC("/show/%d/follow", show.ID))}
+			if database.GetStorm().GetFollowedShow(show.ID) != nil {
+				followAction = []string{"LOCALIZE[30692]", fmt.Sprintf("RunPlugin(%s)", URLForXBMC("/show/%d/unfollow", show.ID))}
+			}
+
 			item.ContextMenu = [][]string{
 				{"LOCALIZE[30619];;LOCALIZE[30215]", fmt.Sprintf("Container.Update(%s)", URLForXBMC("/shows/"))},
 				toggleWatchedAction,
 				watchlistAction,
 				collectionAction,
+				followAction,
 				{"LOCALIZE[30035]", fmt.Sprintf("RunPlugin(%s)", URLForXBMC("/setviewmode/tvshows"))},
 			}
 			item.ContextMenu = append(libraryActions, item.ContextMenu...)
@@ -1046,3 +1053,58 @@ func ShowEpisodeLinks(action string, s *bittorrent.Service) gin.HandlerFunc {
 		}
 	}
 }
+
+// FollowShow enables automatic downloading of new episodes of a show
+func FollowShow(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	xbmcHost, _ := xbmc.GetXBMCHostWithContext(ctx)
+	showID, _ := strconv.Atoi(ctx.Params.ByName("showId"))
+
+	category := ""
+	if categories := database.GetStorm().GetCategories(); len(categories) > 0 {
+		items := make([]string, 0, len(categories)+1)
+		items = append(items, xbmcHost.GetLocalizedString(30690))
+		for _, c := range categories {
+			items = append(items, c.Name)
+		}
+
+		choice := xbmcHost.ListDialog("LOCALIZE[30688]", items...)
+		if choice < 0 {
+			ctx.String(200, "")
+			return
+		} else if choice > 0 {
+			category = categories[choice-1].Name
+		}
+	}
+
+	followed, err := follow.Follow(showID, category)
+	if err != nil {
+		xbmcHost.Notify("Elementum", err.Error(), config.AddonIcon())
+		ctx.String(200, "")
+		return
+	}
+	if !config.Get().FollowShowsEnabled {
+		log.Warningf("Following %s, but automatic downloading of followed shows is disabled in settings", followed.Title)
+	}
+
+	xbmcHost.Notify("Elementum", fmt.Sprintf("LOCALIZE[30693];;%s", followed.Title), config.AddonIcon())
+	library.ClearPageCache(xbmcHost)
+	ctx.String(200, "")
+}
+
+// UnfollowShow disables automatic downloading of new episodes of a show
+func UnfollowShow(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	xbmcHost, _ := xbmc.GetXBMCHostWithContext(ctx)
+	showID, _ := strconv.Atoi(ctx.Params.ByName("showId"))
+
+	if err := database.GetStorm().UnfollowShow(showID); err != nil {
+		xbmcHost.Notify("Elementum", err.Error(), config.AddonIcon())
+	} else {
+		xbmcHost.Notify("Elementum", "LOCALIZE[30694]", config.AddonIcon())
+		library.ClearPageCache(xbmcHost)
+	}
+	ctx.String(200, "")
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/missinggo/perf"
+	"github.com/gin-gonic/gin"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/hls"
+)
+
+// Parsed file indexes are kept for a few recently streamed files, as each segment request needs them
+const streamMediaCacheSize = 4
+
+type streamMedia struct {
+	media    *hls.Media
+	lastUsed time.Time
+}
+
+// streamMediaCall is a reading of file index, that concurrent requests of the same file wait for,
+// instead of reading the index again
+type streamMediaCall struct {
+	done   chan struct{}
+	media  *hls.Media
+	status int
+	code   string
+	err    error
+}
+
+var (
+	streamMediaCache   = map[string]*streamMedia{}
+	streamMediaCalls   = map[string]*streamMediaCall{}
+	streamMediaCacheMu sync.Mutex
+)
+
+// APIStreamPlaylist returns HLS playlist for a torrent file, remuxed into MPEG-TS segments
+func APIStreamPlaylist(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+		f := apiFileFromParam(t, ctx)
+		if f == nil {
+			return
+		}
+
+		media := apiStreamMedia(s, ctx, t, f)
+		if media == nil {
+			return
+		}
+
+		ctx.Data(http.StatusOK, hls.PlaylistContentType, media.Playlist())
+	}
+}
+
+// APIStreamSegment remuxes a single HLS segment, pieces of the segment are prioritized
+// as a reader window, so they are downloaded before pieces, that are further in the file
+func APIStreamSegment(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+		f := apiFileFromParam(t, ctx)
+		if f == nil {
+			return
+		}
+
+		index, err := strconv.Atoi(strings.TrimSuffix(ctx.Params.ByName("segment"), ".ts"))
+		if err != nil {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, "Segment should be a number")
+			return
+		}
+
+		media := apiStreamMedia(s, ctx, t, f)
+		if media == nil {
+			return
+		} else if index < 0 || index >= len(media.Segments) {
+			apiAbort(ctx, http.StatusNotFound, apiErrorSegmentNotFound, fmt.Sprintf("Segment %d not found", index))
+			return
+		}
+
+		entry, err := bittorrent.NewTorrentFS(s, ctx.Request.Method).OpenTorrentFile(t, f)
+		if err != nil {
+			apiAbort(ctx, http.StatusConflict, apiErrorFileNotReady, err.Error())
+			return
+		}
+		defer entry.Close()
+
+		seg := media.Segments[index]
+		if err := entry.PrioritizeRange(seg.Offset, seg.Length); err != nil {
+			apiAbort(ctx, http.StatusInternalServerError, apiErrorRemuxFailed, err.Error())
+			return
+		}
+
+		ctx.Header("Content-Type", hls.SegmentContentType)
+		if err := media.WriteSegment(ctx.Writer, entry, index); err != nil {
+			log.Warningf("Could not remux segment %d of %s: %s", index, f.Path, err)
+			if !ctx.Writer.Written() {
+				apiAbort(ctx, http.StatusInternalServerError, apiErrorRemuxFailed, err.Error())
+			}
+		}
+	}
+}
+
+// apiStreamMedia returns cached file index, or reads it from the file,
+// requests, that come while the index is read, wait for the same result
+func apiStreamMedia(s *bittorrent.Service, ctx *gin.Context, t *bittorrent.Torrent, f *bittorrent.File) *hls.Media {
+	key := fmt.Sprintf("%s:%d", t.InfoHash(), f.Index)
+
+	streamMediaCacheMu.Lock()
+	if cached, ok := streamMediaCache[key]; ok {
+		cached.lastUsed = time.Now()
+		streamMediaCacheMu.Unlock()
+		return cached.media
+	}
+	call, reading := streamMediaCalls[key]
+	if !reading {
+		call = &streamMediaCall{done: make(chan struct{})}
+		streamMediaCalls[key] = call
+	}
+	streamMediaCacheMu.Unlock()
+
+	if reading {
+		select {
+		case <-call.done:
+		case <-ctx.Request.Context().Done():
+			return nil
+		}
+	} else {
+		readStreamMedia(s, ctx.Request.Method, t, f, key, call)
+	}
+
+	if call.err != nil {
+		apiAbort(ctx, call.status, call.code, call.err.Error())
+		return nil
+	}
+	return call.media
+}
+
+// readStreamMedia reads file index and puts it into the cache, waiting requests are released in any case
+func readStreamMedia(s *bittorrent.Service, method string, t *bittorrent.Torrent, f *bittorrent.File, key string, call *streamMediaCall) {
+	defer func() {
+		// Reading is interrupted by a panic
+		if call.media == nil && call.err == nil {
+			call.status, call.code, call.err = http.StatusInternalServerError, apiErrorRemuxFailed, errors.New("Could not read file index")
+		}
+
+		streamMediaCacheMu.Lock()
+		delete(streamMediaCalls, key)
+		if call.err == nil {
+			cacheStreamMedia(key, call.media)
+		}
+		streamMediaCacheMu.Unlock()
+
+		close(call.done)
+	}()
+
+	entry, err := bittorrent.NewTorrentFS(s, method).OpenTorrentFile(t, f)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("File %s is not created yet, it should be selected for download first", f.Path)
+		}
+		call.status, call.code, call.err = http.StatusConflict, apiErrorFileNotReady, err
+		return
+	}
+	defer entry.Close()
+
+	call.media, err = hls.Open(entry, f.Size)
+	switch err {
+	case nil:
+	case hls.ErrUnsupportedContainer, hls.ErrUnsupportedCodec, hls.ErrNoIndex:
+		call.status, call.code, call.err = http.StatusUnsupportedMediaType, apiErrorUnsupportedMedia, err
+	default:
+		call.status, call.code, call.err = http.StatusInternalServerError, apiErrorRemuxFailed, err
+	}
+}
+
+// cacheStreamMedia adds file index to the cache, replacing the least recently used one, cache lock should be held
+func cacheStreamMedia(key string, media *hls.Media) {
+	if len(streamMediaCache) >= streamMediaCacheSize {
+		oldest := ""
+		for k, v := range streamMediaCache {
+			if oldest == "" || v.lastUsed.Before(streamMediaCache[oldest].lastUsed) {
+				oldest = k
+			}
+		}
+		delete(streamMediaCache, oldest)
+	}
+	streamMediaCache[key] = &streamMedia{
+		media:    media,
+		lastUsed: time.Now(),
+	}
+}
@@ -42,6 +42,7 @@ type TorrentsWeb struct {
 	SeedingTime   string  `json:"seeding_time"`
 	SeedTime      float64 `json:"seed_time"`
 	SeedTimeLimit int     `json:"seed_time_limit"`
+	Category      string  `json:"category"`
 	DownloadRate  float64 `json:"download_rate"`
 	UploadRate    float64 `json:"upload_rate"`
 	TotalDownload float64 `json:"total_download"`
@@ -221,6 +222,8 @@ func ListTorrents(s *bittorrent.Service) gin.HandlerFunc {
 
 		xbmcHost, _ := xbmc.GetXBMCHostWithContext(ctx)
 
+		categoryFilter := ctx.Query("category")
+
 		items := make(xbmc.ListItems, 0, len(s.GetTorrents()))
 		if len(s.GetTorrents()) == 0 {
 			ctx.JSON(200, xbmc.NewView("", items))
@@ -232,7 +235,15 @@ func ListTorrents(s *bittorrent.Service) gin.HandlerFunc {
 				continue
 			}
 
+			category := t.GetCategoryName()
+			if categoryFilter != "" && category != categoryFilter {
+				continue
+			}
+
 			torrentName := t.Name()
+			if category != "" {
+				torrentName = fmt.Sprintf("[%s] %s", category, torrentName)
+			}
 			progress := t.GetProgress()
 			statusCode := t.GetSmartState()
 			status := xbmcHost.Translate(bittorrent.StatusStrings[statusCode])
@@ -284,6 +295,7 @@ func ListTorrents(s *bittorrent.Service) gin.HandlerFunc {
 				{"LOCALIZE[30232]", fmt.Sprintf("RunPlugin(%s)", URLForXBMC("/torrents/delete/%s", t.InfoHash()))},
 				{"LOCALIZE[30276]", fmt.Sprintf("RunPlugin(%s)", URLForXBMC("/torrents/delete/%s?files=true", t.InfoHash()))},
 				{"LOCALIZE[30308]", fmt.Sprintf("RunPlugin(%s)", URLForXBMC("/torrents/move/%s", t.InfoHash()))},
+				{"LOCALIZE[30688]", fmt.Sprintf("RunPlugin(%s)", URLForXBMC("/torrents/category/%s", t.InfoHash()))},
 				sessionAction,
 			}
 
@@ -317,20 +329,26 @@ func ListTorrentsWeb(s *bittorrent.Service) gin.HandlerFunc {
 
 		xbmcHost, _ := xbmc.GetXBMCHostWithContext(ctx)
 
+		categoryFilter := ctx.Query("category")
+
 		items := make([]*TorrentsWeb, 0, len(s.GetTorrents()))
 		if len(s.GetTorrents()) == 0 {
 			ctx.JSON(200, items)
 			return
 		}
 
-		seedTimeLimit := config.Get().SeedTimeLimit
-
 		for _, t := range s.GetTorrents() {
 			th := t.GetHandle()
 			if th == nil || !th.IsValid() || !t.HasMetadata() || t.Closer.IsSet() || s.Closer.IsSet() {
 				continue
 			}
 
+			category := t.GetCategoryName()
+			if categoryFilter != "" && category != categoryFilter {
+				continue
+			}
+			seedTimeLimit, _, _ := t.GetSeedLimits()
+
 			torrentStatus := t.GetLastStatus(false)
 
 			torrentName := torrentStatus.GetName()
@@ -382,6 +400,7 @@ func ListTorrentsWeb(s *bittorrent.Service) gin.HandlerFunc {
 				SeedingTime:   seedingTime.String(),
 				SeedTime:      seedingTime.Seconds(),
 				SeedTimeLimit: seedTimeLimit,
+				Category:      category,
 				DownloadRate:  downloadRate,
 				UploadRate:    uploadRate,
 				TotalDownload: allTimeDownload,
@@ -436,6 +455,13 @@ func AddTorrent(s *bittorrent.Service) gin.HandlerFunc {
 		uri := ctx.Request.FormValue("uri")
 		file, header, fileError := ctx.Request.FormFile("file")
 		allFiles := ctx.Request.FormValue("all")
+		category := ctx.Request.FormValue("category")
+
+		if category != "" && database.GetStorm().GetCategory(category) == nil {
+			torrentsLog.Errorf("Category %s not found", category)
+			ctx.String(404, "Category not found")
+			return
+		}
 
 		if file != nil && header != nil && fileError == nil {
 			t, err := saveTorrentFile(file, header)
@@ -467,11 +493,15 @@ func AddTorrent(s *bittorrent.Service) gin.HandlerFunc {
 
 		if t == nil {
 			var err error
-			t, err = s.AddTorrent(xbmcHost, uri, false, config.Get().DownloadStorage, true, time.Now())
+			t, err = s.AddTorrentWithCategory(xbmcHost, uri, category, false, config.Get().DownloadStorage, true, time.Now())
 			if err != nil {
 				ctx.String(404, err.Error())
 				return
 			}
+		} else if category != "" {
+			if err := t.SetCategory(category); err != nil {
+				torrentsLog.Errorf("Could not set category for %s: %s", t.InfoHash(), err)
+			}
 		}
 
 		// Create initial BTItem entry
@@ -541,6 +571,58 @@ func MoveTorrent(s *bittorrent.Service) gin.HandlerFunc {
 	}
 }
 
+// SetTorrentCategory asks user to choose a category for a torrent
+func SetTorrentCategory(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		xbmcHost, _ := xbmc.GetXBMCHostWithContext(ctx)
+
+		torrentID := ctx.Params.ByName("torrentId")
+		torrent, err := GetTorrentFromParam(s, torrentID)
+		if err != nil {
+			ctx.Error(fmt.Errorf("Unable to set category for torrent with index %s", torrentID))
+			return
+		}
+
+		categories := database.GetStorm().GetCategories()
+		if len(categories) == 0 {
+			xbmcHost.Notify("Elementum", "LOCALIZE[30689]", config.AddonIcon())
+			ctx.String(200, "")
+			return
+		}
+
+		current := torrent.GetCategoryName()
+		items := make([]string, 0, len(categories)+1)
+		items = append(items, xbmcHost.GetLocalizedString(30690))
+		for _, c := range categories {
+			if c.Name == current {
+				items = append(items, fmt.Sprintf("[B]%s[/B]", c.Name))
+			} else {
+				items = append(items, c.Name)
+			}
+		}
+
+		choice := xbmcHost.ListDialog("LOCALIZE[30688]", items...)
+		if choice < 0 {
+			ctx.String(200, "")
+			return
+		}
+
+		name := ""
+		if choice > 0 {
+			name = categories[choice-1].Name
+		}
+		if err := torrent.SetCategory(name); err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		xbmcHost.Refresh()
+		ctx.String(200, "")
+	}
+}
+
 // PauseTorrent ...
 func PauseTorrent(s *bittorrent.Service) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
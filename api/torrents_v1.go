@@ -0,0 +1,888 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/missinggo/perf"
+	"github.com/gin-gonic/gin"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/metainfo"
+)
+
+// API error codes, returned in the "code" field of an error body
+const (
+	apiErrorInvalidRequest     = "invalid_request"
+	apiErrorTorrentNotFound    = "torrent_not_found"
+	apiErrorFileNotFound       = "file_not_found"
+	apiErrorMetadataMissing    = "metadata_missing"
+	apiErrorUnsupportedStorage = "unsupported_storage"
+	apiErrorAddFailed          = "add_failed"
+	apiErrorCreateFailed       = "create_failed"
+	apiErrorServiceClosing     = "service_closing"
+	apiErrorCategoryNotFound   = "category_not_found"
+	apiErrorFeedNotFound       = "feed_not_found"
+	apiErrorFeedFailed         = "feed_failed"
+	apiErrorShowNotFound       = "show_not_found"
+	apiErrorSearchFailed       = "search_failed"
+	apiErrorProfileNotFound    = "profile_not_found"
+	apiErrorFileNotReady       = "file_not_ready"
+	apiErrorUnsupportedMedia   = "unsupported_media"
+	apiErrorSegmentNotFound    = "segment_not_found"
+	apiErrorRemuxFailed        = "remux_failed"
+	apiErrorTrackerNotFound    = "tracker_not_found"
+	apiErrorTrackerExists      = "tracker_exists"
+)
+
+// APIError is a structured error body for the JSON API
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// APIErrorResponse wraps APIError into a JSON object
+type APIErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// APITorrent is a JSON representation of an active torrent
+type APITorrent struct {
+	InfoHash       string            `json:"info_hash"`
+	Name           string            `json:"name"`
+	State          int               `json:"state"`
+	StateName      string            `json:"state_name"`
+	Progress       float64           `json:"progress"`
+	BufferProgress float64           `json:"buffer_progress"`
+	IsBuffering    bool              `json:"is_buffering"`
+	IsPlaying      bool              `json:"is_playing"`
+	Paused         bool              `json:"paused"`
+	Storage        string            `json:"storage"`
+	Category       string            `json:"category"`
+	PriorityClass  string            `json:"priority_class"`
+	DownloadLimit  int               `json:"download_rate_limit"`
+	UploadLimit    int               `json:"upload_rate_limit"`
+	AddedTime      int64             `json:"added_time"`
+	Size           int64             `json:"size"`
+	SelectedSize   int64             `json:"selected_size"`
+	DownloadRate   int               `json:"download_rate"`
+	UploadRate     int               `json:"upload_rate"`
+	Seeders        int               `json:"seeders"`
+	SeedersTotal   int               `json:"seeders_total"`
+	Peers          int               `json:"peers"`
+	PeersTotal     int               `json:"peers_total"`
+	HasMetadata    bool              `json:"has_metadata"`
+	Files          []*APITorrentFile `json:"files,omitempty"`
+}
+
+// APITorrentFile is a JSON representation of a single file inside a torrent
+type APITorrentFile struct {
+	Index           int     `json:"index"`
+	Name            string  `json:"name"`
+	Path            string  `json:"path"`
+	Size            int64   `json:"size"`
+	Selected        bool    `json:"selected"`
+	Priority        int     `json:"priority"`
+	PiecesCompleted int     `json:"pieces_completed"`
+	PiecesTotal     int     `json:"pieces_total"`
+	Progress        float64 `json:"progress"`
+}
+
+// APIAddTorrentRequest describes the body of the add torrent request
+type APIAddTorrentRequest struct {
+	URI      string `json:"uri" form:"uri"`
+	Paused   bool   `json:"paused" form:"paused"`
+	Storage  string `json:"storage" form:"storage"`
+	Category string `json:"category" form:"category"`
+	All      bool   `json:"all" form:"all"`
+	Files    []int  `json:"files" form:"files"`
+}
+
+// APICreateTorrentRequest describes the body of the create torrent request
+type APICreateTorrentRequest struct {
+	Path        string   `json:"path" form:"path"`
+	PieceLength int64    `json:"piece_length" form:"piece_length"`
+	Trackers    []string `json:"trackers" form:"trackers"`
+	WebSeeds    []string `json:"web_seeds" form:"web_seeds"`
+	Private     bool     `json:"private" form:"private"`
+	Comment     string   `json:"comment" form:"comment"`
+	Hybrid      bool     `json:"hybrid" form:"hybrid"`
+	Output      string   `json:"output" form:"output"`
+	Seed        bool     `json:"seed" form:"seed"`
+}
+
+// APICreatedTorrent is a JSON representation of a created torrent file
+type APICreatedTorrent struct {
+	InfoHash  string      `json:"info_hash"`
+	Name      string      `json:"name"`
+	IsPrivate bool        `json:"is_private"`
+	URI       string      `json:"uri"`
+	Output    string      `json:"output,omitempty"`
+	Torrent   *APITorrent `json:"torrent,omitempty"`
+}
+
+// APIRateLimitsRequest describes the body of the torrent limits request,
+// omitted fields keep their current values
+type APIRateLimitsRequest struct {
+	DownloadLimit *int   `json:"download_rate_limit" form:"download_rate_limit"`
+	UploadLimit   *int   `json:"upload_rate_limit" form:"upload_rate_limit"`
+	PriorityClass string `json:"priority_class" form:"priority_class"`
+}
+
+// APIFilePriorityRequest describes the body of the file priority request
+type APIFilePriorityRequest struct {
+	Priority *int `json:"priority" form:"priority"`
+}
+
+// APITracker is a JSON representation of a torrent tracker state, times are unix timestamps, 0 if unknown
+type APITracker struct {
+	URL          string `json:"url"`
+	Tier         int    `json:"tier"`
+	IsWorking    bool   `json:"is_working"`
+	Updating     bool   `json:"updating"`
+	Message      string `json:"message"`
+	LastError    string `json:"last_error"`
+	LastErrorAt  int64  `json:"last_error_at"`
+	LastAnnounce int64  `json:"last_announce"`
+	NextAnnounce int64  `json:"next_announce"`
+	Seeds        int    `json:"seeds"`
+	Peers        int    `json:"peers"`
+	NumPeers     int    `json:"num_peers"`
+}
+
+// APITrackerRequest describes the body of add, remove and reannounce tracker requests,
+// tier, that is out of range, adds a new tier
+type APITrackerRequest struct {
+	URL  string `json:"url" form:"url"`
+	Tier *int   `json:"tier" form:"tier"`
+}
+
+// APITrackersRequest describes the body of the replace trackers request, trackers are listed in tiers
+type APITrackersRequest struct {
+	Tiers [][]string `json:"tiers"`
+}
+
+func apiAbort(ctx *gin.Context, status int, code string, message string) {
+	ctx.AbortWithStatusJSON(status, APIErrorResponse{
+		Error: APIError{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+// apiBind decodes request body into obj, empty body is not an error
+func apiBind(ctx *gin.Context, obj interface{}) bool {
+	if err := ctx.ShouldBind(obj); err != nil && err != io.EOF {
+		apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, err.Error())
+		return false
+	}
+
+	return true
+}
+
+func apiTorrentFromParam(s *bittorrent.Service, ctx *gin.Context) *bittorrent.Torrent {
+	if s.Closer.IsSet() {
+		apiAbort(ctx, http.StatusServiceUnavailable, apiErrorServiceClosing, "Service is shutting down")
+		return nil
+	}
+
+	infoHash := strings.ToLower(ctx.Params.ByName("infohash"))
+	t, err := GetTorrentFromParam(s, infoHash)
+	if err != nil || t == nil || t.Closer.IsSet() {
+		apiAbort(ctx, http.StatusNotFound, apiErrorTorrentNotFound, fmt.Sprintf("Torrent %s not found", infoHash))
+		return nil
+	}
+
+	return t
+}
+
+func apiFileFromParam(t *bittorrent.Torrent, ctx *gin.Context) *bittorrent.File {
+	if !t.HasMetadata() {
+		apiAbort(ctx, http.StatusConflict, apiErrorMetadataMissing, "Torrent metadata is not yet available")
+		return nil
+	}
+
+	index, err := strconv.Atoi(ctx.Params.ByName("index"))
+	if err != nil {
+		apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, "File index should be a number")
+		return nil
+	}
+
+	f := t.GetFileByIndex(index)
+	if f == nil {
+		apiAbort(ctx, http.StatusNotFound, apiErrorFileNotFound, fmt.Sprintf("File with index %d not found", index))
+		return nil
+	}
+
+	return f
+}
+
+func newAPITorrent(t *bittorrent.Torrent, withFiles bool) *APITorrent {
+	state := t.GetSmartState()
+
+	storage := ""
+	if t.DownloadStorage >= 0 && t.DownloadStorage < len(config.Storages) {
+		storage = strings.ToLower(config.Storages[t.DownloadStorage])
+	}
+
+	ret := &APITorrent{
+		InfoHash:    t.InfoHash(),
+		Name:        t.Name(),
+		State:       state,
+		StateName:   bittorrent.StatusName(state),
+		IsBuffering: t.IsBuffering,
+		IsPlaying:   t.IsPlaying,
+		Paused:      t.GetPaused(),
+		Storage:     storage,
+		Category:    t.GetCategoryName(),
+		AddedTime:   t.GetAddedTime().Unix(),
+		HasMetadata: t.HasMetadata(),
+	}
+
+	ret.PriorityClass = t.GetPriorityClass()
+	ret.DownloadLimit, ret.UploadLimit = t.GetRateLimits()
+
+	if !ret.HasMetadata {
+		return ret
+	}
+
+	ret.Progress = t.GetProgress()
+	ret.BufferProgress = t.GetBufferProgress()
+	ret.Size = t.Length()
+	ret.SelectedSize = t.GetSelectedSize()
+	ret.DownloadRate, ret.UploadRate = t.GetSpeeds()
+	ret.Seeders, ret.SeedersTotal, ret.Peers, ret.PeersTotal = t.GetConnections()
+
+	if withFiles {
+		ret.Files = newAPITorrentFiles(t)
+	}
+
+	return ret
+}
+
+func newAPITorrentFiles(t *bittorrent.Torrent) []*APITorrentFile {
+	priorities := t.GetFilePriorities()
+
+	ret := make([]*APITorrentFile, 0, len(t.GetFiles()))
+	for _, f := range t.GetFiles() {
+		ret = append(ret, newAPITorrentFile(t, f, priorities))
+	}
+
+	return ret
+}
+
+func newAPITorrentFile(t *bittorrent.Torrent, f *bittorrent.File, priorities []int) *APITorrentFile {
+	ret := &APITorrentFile{
+		Index:    f.Index,
+		Name:     f.Name,
+		Path:     f.Path,
+		Size:     f.Size,
+		Selected: f.Selected,
+	}
+	if f.Index >= 0 && f.Index < len(priorities) {
+		ret.Priority = priorities[f.Index]
+	}
+
+	ret.PiecesCompleted, ret.PiecesTotal = t.GetFilePiecesProgress(f)
+	if ret.PiecesTotal > 0 {
+		ret.Progress = 100 * float64(ret.PiecesCompleted) / float64(ret.PiecesTotal)
+	}
+
+	return ret
+}
+
+// APIListTorrents returns all active torrents as JSON resources
+func APIListTorrents(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		if s.Closer.IsSet() {
+			apiAbort(ctx, http.StatusServiceUnavailable, apiErrorServiceClosing, "Service is shutting down")
+			return
+		}
+
+		withFiles := ctx.DefaultQuery("files", "false") == "true"
+
+		items := make([]*APITorrent, 0, len(s.GetTorrents()))
+		for _, t := range s.GetTorrents() {
+			if t == nil || t.Closer.IsSet() {
+				continue
+			}
+
+			items = append(items, newAPITorrent(t, withFiles))
+		}
+
+		ctx.JSON(http.StatusOK, items)
+	}
+}
+
+// APIGetTorrent returns a single torrent, including its files
+func APIGetTorrent(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+
+		ctx.JSON(http.StatusOK, newAPITorrent(t, true))
+	}
+}
+
+// APIAddTorrent adds a torrent from uri or uploaded file,
+// responds with 201 for new torrents and 200 for existing ones.
+func APIAddTorrent(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		if s.Closer.IsSet() {
+			apiAbort(ctx, http.StatusServiceUnavailable, apiErrorServiceClosing, "Service is shutting down")
+			return
+		}
+
+		var req APIAddTorrentRequest
+		if !apiBind(ctx, &req) {
+			return
+		}
+
+		if file, header, err := ctx.Request.FormFile("file"); err == nil && file != nil && header != nil {
+			path, err := saveTorrentFile(file, header)
+			if err != nil {
+				apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, err.Error())
+				return
+			}
+			req.URI = path
+		}
+
+		req.URI = strings.TrimSpace(req.URI)
+		if req.URI == "" {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, "Missing torrent URI")
+			return
+		}
+
+		storage := config.Get().DownloadStorage
+		switch strings.ToLower(req.Storage) {
+		case "":
+		case "file":
+			storage = config.StorageFile
+		case "memory":
+			storage = config.StorageMemory
+		default:
+			apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, fmt.Sprintf("Unknown storage type: %s", req.Storage))
+			return
+		}
+
+		if req.Category != "" && database.GetStorm().GetCategory(req.Category) == nil {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorCategoryNotFound, fmt.Sprintf("Category %s not found", req.Category))
+			return
+		}
+
+		t := s.GetTorrentByURI(req.URI)
+		if t == nil {
+			torrent := bittorrent.NewTorrentFile(req.URI)
+			if err := torrent.Resolve(); err == nil && torrent.InfoHash != "" {
+				t = s.GetTorrentByHash(torrent.InfoHash)
+			}
+		}
+
+		status := http.StatusOK
+		if t == nil {
+			torrentsLog.Infof("Adding torrent from %s via API", req.URI)
+
+			var err error
+			t, err = s.AddTorrentWithCategory(nil, req.URI, req.Category, req.Paused, storage, true, time.Now())
+			if err != nil || t == nil {
+				message := "Could not add torrent"
+				if err != nil {
+					message = err.Error()
+				}
+				apiAbort(ctx, http.StatusUnprocessableEntity, apiErrorAddFailed, message)
+				return
+			}
+
+			database.GetStorm().UpdateBTItem(t.InfoHash(), 0, "", []string{}, t.Name(), 0, 0, 0)
+			status = http.StatusCreated
+		} else if req.Category != "" {
+			if err := t.SetCategory(req.Category); err != nil {
+				apiAbort(ctx, http.StatusBadRequest, apiErrorCategoryNotFound, err.Error())
+				return
+			}
+		}
+
+		if t.HasMetadata() {
+			if req.All {
+				t.DownloadAllFiles()
+			} else if len(req.Files) > 0 {
+				for _, idx := range req.Files {
+					if f := t.GetFileByIndex(idx); f != nil {
+						t.DownloadFile(f)
+					}
+				}
+			} else if status == http.StatusCreated && len(t.ChosenFiles) == 0 {
+				// Without explicit selection we download the biggest file,
+				// same as non-interactive selection in ChooseFile.
+				var biggest *bittorrent.File
+				for _, f := range t.GetFiles() {
+					if biggest == nil || f.Size > biggest.Size {
+						biggest = f
+					}
+				}
+				if biggest != nil {
+					t.DownloadFile(biggest)
+				}
+			}
+			t.SaveDBFiles()
+		}
+
+		ctx.JSON(status, newAPITorrent(t, true))
+	}
+}
+
+// APICreateTorrent creates a torrent file from a local path, and optionally starts seeding it.
+// Output is a file name in torrents directory. Files are hashed before the response is sent,
+// so the request takes as long as reading the whole path.
+func APICreateTorrent(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		if s.Closer.IsSet() {
+			apiAbort(ctx, http.StatusServiceUnavailable, apiErrorServiceClosing, "Service is shutting down")
+			return
+		}
+
+		var req APICreateTorrentRequest
+		if !apiBind(ctx, &req) {
+			return
+		}
+
+		req.Path = strings.TrimSpace(req.Path)
+		if req.Path == "" {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, "Missing path")
+			return
+		}
+
+		torrentsLog.Infof("Creating torrent from %s via API", req.Path)
+		tf, b, err := bittorrent.CreateTorrentFile(req.Path, metainfo.Options{
+			PieceLength: req.PieceLength,
+			Trackers:    req.Trackers,
+			WebSeeds:    req.WebSeeds,
+			Private:     req.Private,
+			Comment:     req.Comment,
+			Hybrid:      req.Hybrid,
+		})
+		if err != nil {
+			apiAbort(ctx, http.StatusUnprocessableEntity, apiErrorCreateFailed, err.Error())
+			return
+		}
+
+		ret := &APICreatedTorrent{
+			InfoHash:  tf.InfoHash,
+			Name:      tf.Name,
+			IsPrivate: tf.IsPrivate,
+			URI:       tf.URI,
+		}
+
+		if req.Output != "" {
+			output, err := writeCreatedTorrent(req.Output, b)
+			if err != nil {
+				apiAbort(ctx, http.StatusUnprocessableEntity, apiErrorCreateFailed, err.Error())
+				return
+			}
+			ret.Output = output
+		}
+
+		if req.Seed {
+			t, err := s.SeedTorrentFile(tf, req.Path)
+			if err != nil {
+				apiAbort(ctx, http.StatusUnprocessableEntity, apiErrorAddFailed, err.Error())
+				return
+			}
+			ret.Torrent = newAPITorrent(t, false)
+		}
+
+		ctx.JSON(http.StatusCreated, ret)
+	}
+}
+
+// writeCreatedTorrent saves torrent file into torrents directory, existing files are not overwritten
+func writeCreatedTorrent(name string, b []byte) (string, error) {
+	name = strings.TrimSpace(name)
+	if name != filepath.Base(name) || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("Output should be a file name, without directories")
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ".torrent") {
+		name += ".torrent"
+	}
+
+	path := filepath.Join(config.Get().TorrentsPath, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// APIDeleteTorrent removes a torrent, optionally with downloaded data
+func APIDeleteTorrent(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+
+		deleteFiles := ctx.DefaultQuery("files", "false") == "true"
+		if !s.RemoveTorrent(nil, t, true, deleteFiles, false) {
+			apiAbort(ctx, http.StatusNotFound, apiErrorTorrentNotFound, fmt.Sprintf("Torrent %s not found", t.InfoHash()))
+			return
+		}
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// APIPauseTorrent pauses a torrent
+func APIPauseTorrent(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+
+		t.Pause()
+		ctx.JSON(http.StatusOK, newAPITorrent(t, false))
+	}
+}
+
+// APIResumeTorrent resumes a torrent
+func APIResumeTorrent(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+
+		t.Resume()
+		ctx.JSON(http.StatusOK, newAPITorrent(t, false))
+	}
+}
+
+// APISetTorrentLimits sets per-torrent bandwidth caps, in bytes per second, and priority class
+func APISetTorrentLimits(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+
+		var req APIRateLimitsRequest
+		if !apiBind(ctx, &req) {
+			return
+		}
+
+		downloadLimit, uploadLimit := 0, 0
+		if item := database.GetStorm().GetBTItem(t.InfoHash()); item != nil {
+			downloadLimit, uploadLimit = item.DownloadRateLimit, item.UploadRateLimit
+		}
+		if req.DownloadLimit != nil {
+			downloadLimit = *req.DownloadLimit
+		}
+		if req.UploadLimit != nil {
+			uploadLimit = *req.UploadLimit
+		}
+		priorityClass := strings.ToLower(strings.TrimSpace(req.PriorityClass))
+		if priorityClass == "" {
+			priorityClass = t.GetPriorityClass()
+		}
+
+		if err := t.SetRateLimits(downloadLimit, uploadLimit, priorityClass); err != nil {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, err.Error())
+			return
+		}
+
+		ctx.JSON(http.StatusOK, newAPITorrent(t, false))
+	}
+}
+
+// APIListTorrentFiles returns files of a torrent with priorities and pieces progress
+func APIListTorrentFiles(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+		if !t.HasMetadata() {
+			apiAbort(ctx, http.StatusConflict, apiErrorMetadataMissing, "Torrent metadata is not yet available")
+			return
+		}
+
+		ctx.JSON(http.StatusOK, newAPITorrentFiles(t))
+	}
+}
+
+// APIGetTorrentFile returns a single file of a torrent
+func APIGetTorrentFile(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+		f := apiFileFromParam(t, ctx)
+		if f == nil {
+			return
+		}
+
+		ctx.JSON(http.StatusOK, newAPITorrentFile(t, f, t.GetFilePriorities()))
+	}
+}
+
+// APISetTorrentFilePriority selects a file for download with given priority (1-7),
+// priority 0 deselects the file.
+func APISetTorrentFilePriority(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+		f := apiFileFromParam(t, ctx)
+		if f == nil {
+			return
+		}
+
+		var req APIFilePriorityRequest
+		if !apiBind(ctx, &req) {
+			return
+		}
+
+		priority := 1
+		if req.Priority != nil {
+			priority = *req.Priority
+		}
+		if priority < 0 || priority > 7 {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, "Priority should be in range 0-7")
+			return
+		}
+		if t.IsMemoryStorage() && priority > 1 {
+			apiAbort(ctx, http.StatusConflict, apiErrorUnsupportedStorage, "File priorities are not supported for memory storage")
+			return
+		}
+
+		if priority == 0 {
+			t.UnDownloadFile(f)
+		} else {
+			t.DownloadFileWithPriority(f, priority)
+		}
+		t.SaveDBFiles()
+
+		ctx.JSON(http.StatusOK, newAPITorrentFile(t, f, t.GetFilePriorities()))
+	}
+}
+
+// APIUnselectTorrentFile deselects a file from download
+func APIUnselectTorrentFile(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+		f := apiFileFromParam(t, ctx)
+		if f == nil {
+			return
+		}
+
+		t.UnDownloadFile(f)
+		t.SaveDBFiles()
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func newAPITrackers(t *bittorrent.Torrent) []*APITracker {
+	statuses := t.TrackerStatuses()
+	ret := make([]*APITracker, 0, len(statuses))
+	for _, st := range statuses {
+		ret = append(ret, &APITracker{
+			URL:          st.URL,
+			Tier:         st.Tier,
+			IsWorking:    st.IsWorking,
+			Updating:     st.Updating,
+			Message:      st.Message,
+			LastError:    st.LastError,
+			LastErrorAt:  unixTime(st.LastErrorAt),
+			LastAnnounce: unixTime(st.LastAnnounce),
+			NextAnnounce: unixTime(st.NextAnnounce),
+			Seeds:        st.Seeds,
+			Peers:        st.Peers,
+			NumPeers:     st.NumPeers,
+		})
+	}
+	return ret
+}
+
+func apiAbortTracker(ctx *gin.Context, err error) {
+	switch err {
+	case bittorrent.ErrTrackerNotFound:
+		apiAbort(ctx, http.StatusNotFound, apiErrorTrackerNotFound, err.Error())
+	case bittorrent.ErrTrackerExists:
+		apiAbort(ctx, http.StatusConflict, apiErrorTrackerExists, err.Error())
+	default:
+		apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, err.Error())
+	}
+}
+
+// APIListTorrentTrackers returns trackers of a torrent with announce and scrape state
+func APIListTorrentTrackers(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+
+		ctx.JSON(http.StatusOK, newAPITrackers(t))
+	}
+}
+
+// APIAddTorrentTracker adds a tracker to the tier of a torrent, or to a new tier if tier is omitted
+func APIAddTorrentTracker(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+
+		var req APITrackerRequest
+		if !apiBind(ctx, &req) {
+			return
+		}
+
+		tier := -1
+		if req.Tier != nil {
+			tier = *req.Tier
+		}
+		if err := t.AddTracker(req.URL, tier); err != nil {
+			apiAbortTracker(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, newAPITrackers(t))
+	}
+}
+
+// APISetTorrentTrackers replaces trackers of a torrent, it is used to reorder trackers and tiers
+func APISetTorrentTrackers(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+
+		var req APITrackersRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, err.Error())
+			return
+		}
+
+		if err := t.SetTrackers(req.Tiers); err != nil {
+			apiAbortTracker(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, newAPITrackers(t))
+	}
+}
+
+// APIDeleteTorrentTracker removes a tracker from a torrent
+func APIDeleteTorrentTracker(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+
+		var req APITrackerRequest
+		if !apiBind(ctx, &req) {
+			return
+		}
+		if req.URL == "" {
+			apiAbort(ctx, http.StatusBadRequest, apiErrorInvalidRequest, "Tracker URL is required")
+			return
+		}
+
+		if err := t.RemoveTracker(req.URL); err != nil {
+			apiAbortTracker(ctx, err)
+			return
+		}
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// APIReannounceTorrent forces announce to a tracker of a torrent, or to all trackers if URL is omitted
+func APIReannounceTorrent(s *bittorrent.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer perf.ScopeTimer()()
+
+		t := apiTorrentFromParam(s, ctx)
+		if t == nil {
+			return
+		}
+
+		var req APITrackerRequest
+		if !apiBind(ctx, &req) {
+			return
+		}
+
+		if err := t.Reannounce(req.URL); err != nil {
+			apiAbortTracker(ctx, err)
+			return
+		}
+
+		ctx.Status(http.StatusAccepted)
+	}
+}
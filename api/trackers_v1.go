@@ -0,0 +1,17 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/anacrolix/missinggo/perf"
+	"github.com/gin-gonic/gin"
+
+	"github.com/elgatito/elementum/bittorrent"
+)
+
+// APIListTrackers returns extra trackers and pruned trackers with the reason, why each of them is kept or dropped
+func APIListTrackers(ctx *gin.Context) {
+	defer perf.ScopeTimer()()
+
+	ctx.JSON(http.StatusOK, bittorrent.TrackerDecisions())
+}
@@ -0,0 +1,213 @@
+package bittorrent
+
+import (
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elgatito/elementum/config"
+)
+
+// Sources of an active bandwidth profile
+const (
+	// BandwidthSourceDefault means that limits come from addon settings
+	BandwidthSourceDefault = "default"
+	// BandwidthSourceSchedule means that limits come from active schedule window
+	BandwidthSourceSchedule = "schedule"
+	// BandwidthSourceOverride means that limits are set manually
+	BandwidthSourceOverride = "override"
+)
+
+const bandwidthSchedulerInterval = 30 * time.Second
+
+// BandwidthProfile describes session-wide speed limits, in bytes per second, 0 means unlimited
+type BandwidthProfile struct {
+	Name              string     `json:"name"`
+	Source            string     `json:"source"`
+	DownloadRateLimit int        `json:"download_rate_limit"`
+	UploadRateLimit   int        `json:"upload_rate_limit"`
+	Until             *time.Time `json:"until,omitempty"`
+}
+
+// BandwidthStatus describes active profile and limits that are currently applied to the session
+type BandwidthStatus struct {
+	Profile              BandwidthProfile         `json:"profile"`
+	LimitsLifted         bool                     `json:"limits_lifted"`
+	LimitsBuffering      bool                     `json:"limits_buffering"`
+	AppliedDownloadLimit int                      `json:"applied_download_rate_limit"`
+	AppliedUploadLimit   int                      `json:"applied_upload_rate_limit"`
+	Schedule             []config.BandwidthWindow `json:"schedule"`
+}
+
+// GetBandwidthProfile returns profile that is active now
+func (s *Service) GetBandwidthProfile() BandwidthProfile {
+	s.muBandwidth.Lock()
+	defer s.muBandwidth.Unlock()
+
+	return s.bandwidthProfileLocked(time.Now())
+}
+
+func (s *Service) bandwidthProfileLocked(now time.Time) BandwidthProfile {
+	if o := s.bandwidthOverride; o != nil {
+		if o.Until == nil || now.Before(*o.Until) {
+			return *o
+		}
+		log.Infof("Bandwidth override '%s' expired", o.Name)
+		s.bandwidthOverride = nil
+	}
+
+	if s.config.BandwidthScheduleEnabled {
+		if w := config.ActiveBandwidthWindow(s.config.BandwidthSchedule, now); w != nil {
+			return BandwidthProfile{
+				Name:              w.Name,
+				Source:            BandwidthSourceSchedule,
+				DownloadRateLimit: w.DownloadRateLimit,
+				UploadRateLimit:   w.UploadRateLimit,
+			}
+		}
+	}
+
+	return BandwidthProfile{
+		Name:              BandwidthSourceDefault,
+		Source:            BandwidthSourceDefault,
+		DownloadRateLimit: s.config.DownloadRateLimit,
+		UploadRateLimit:   s.config.UploadRateLimit,
+	}
+}
+
+// GetBandwidthStatus returns active profile together with applied limits
+func (s *Service) GetBandwidthStatus() BandwidthStatus {
+	s.muBandwidth.Lock()
+	defer s.muBandwidth.Unlock()
+
+	schedule := []config.BandwidthWindow{}
+	if s.config.BandwidthScheduleEnabled {
+		schedule = s.config.BandwidthSchedule
+	}
+
+	return BandwidthStatus{
+		Profile:              s.bandwidthProfileLocked(time.Now()),
+		LimitsLifted:         s.limitsLifted,
+		LimitsBuffering:      s.limitsBuffering,
+		AppliedDownloadLimit: s.appliedDownloadLimit,
+		AppliedUploadLimit:   s.appliedUploadLimit,
+		Schedule:             schedule,
+	}
+}
+
+// SetBandwidthOverride manually sets session limits, ignoring the schedule,
+// zero duration keeps override until it is cleared
+func (s *Service) SetBandwidthOverride(name string, downloadLimit, uploadLimit int, duration time.Duration) BandwidthProfile {
+	if name == "" {
+		name = BandwidthSourceOverride
+	}
+
+	profile := &BandwidthProfile{
+		Name:              name,
+		Source:            BandwidthSourceOverride,
+		DownloadRateLimit: downloadLimit,
+		UploadRateLimit:   uploadLimit,
+	}
+	if duration > 0 {
+		until := time.Now().Add(duration)
+		profile.Until = &until
+	}
+
+	s.muBandwidth.Lock()
+	s.bandwidthOverride = profile
+	s.muBandwidth.Unlock()
+
+	log.Infof("Setting bandwidth override '%s'", name)
+	s.applyBandwidthLimits()
+
+	return *profile
+}
+
+// ClearBandwidthOverride returns to scheduled or default limits
+func (s *Service) ClearBandwidthOverride() {
+	s.muBandwidth.Lock()
+	s.bandwidthOverride = nil
+	s.muBandwidth.Unlock()
+
+	log.Info("Clearing bandwidth override")
+	s.applyBandwidthLimits()
+}
+
+// applyBandwidthLimits sets session limits from the active profile.
+// With LimitAfterBuffering, limits are lifted during buffering, and default limits
+// are also lifted outside of playback, while scheduled and manual limits are kept.
+func (s *Service) applyBandwidthLimits() {
+	if s.Closer.IsSet() || s.Session == nil || s.Session.Swigcptr() == 0 {
+		return
+	}
+
+	s.muBandwidth.Lock()
+	defer s.muBandwidth.Unlock()
+
+	profile := s.bandwidthProfileLocked(time.Now())
+	downloadLimit := profile.DownloadRateLimit
+	uploadLimit := profile.UploadRateLimit
+	if s.limitsBuffering || (s.limitsLifted && profile.Source == BandwidthSourceDefault) {
+		downloadLimit = 0
+		uploadLimit = 0
+	}
+
+	if downloadLimit != s.appliedDownloadLimit {
+		if downloadLimit > 0 {
+			log.Infof("Rate limiting download to %s (%s)", humanize.Bytes(uint64(downloadLimit)), profile.Name)
+		} else {
+			log.Infof("Resetting download rate limit (%s)", profile.Name)
+		}
+		s.SetDownloadLimit(downloadLimit)
+		s.appliedDownloadLimit = downloadLimit
+	}
+	if uploadLimit != s.appliedUploadLimit {
+		if uploadLimit > 0 {
+			log.Infof("Rate limiting upload to %s (%s)", humanize.Bytes(uint64(uploadLimit)), profile.Name)
+		} else {
+			log.Infof("Resetting upload rate limit (%s)", profile.Name)
+		}
+		s.SetUploadLimit(uploadLimit)
+		s.appliedUploadLimit = uploadLimit
+	}
+}
+
+// setLimitsLifted switches LimitAfterBuffering state and re-applies limits
+func (s *Service) setLimitsLifted(lifted, buffering bool) {
+	s.muBandwidth.Lock()
+	s.limitsLifted = lifted
+	s.limitsBuffering = buffering
+	s.muBandwidth.Unlock()
+
+	s.applyBandwidthLimits()
+}
+
+// resetBandwidthLimits forgets applied limits, used when session settings are re-created
+func (s *Service) resetBandwidthLimits() {
+	s.muBandwidth.Lock()
+	s.limitsLifted = s.config.LimitAfterBuffering
+	s.limitsBuffering = false
+	s.appliedDownloadLimit = -1
+	s.appliedUploadLimit = -1
+	s.muBandwidth.Unlock()
+}
+
+// bandwidthScheduler re-applies limits when schedule window or override changes
+func (s *Service) bandwidthScheduler() {
+	defer s.wg.Done()
+
+	closing := s.Closer.C()
+	ticker := time.NewTicker(bandwidthSchedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closing:
+			log.Info("Closing bandwidth scheduler ...")
+			return
+
+		case <-ticker.C:
+			s.applyBandwidthLimits()
+		}
+	}
+}
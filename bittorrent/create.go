@@ -0,0 +1,68 @@
+package bittorrent
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/metainfo"
+	"github.com/elgatito/elementum/util/ident"
+)
+
+// CreateTorrentFile creates torrent from local files and validates it by loading as a regular torrent file.
+// Loaded torrent is saved to temporary path, so its URI can be added to the session.
+func CreateTorrentFile(path string, opts metainfo.Options) (*TorrentFile, []byte, error) {
+	if opts.CreatedBy == "" {
+		opts.CreatedBy = "Elementum " + ident.GetVersion()
+	}
+
+	now := time.Now()
+	b, err := metainfo.Create(path, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t := &TorrentFile{}
+	if err := t.LoadFromBytes(b); err != nil {
+		return nil, nil, fmt.Errorf("Created torrent is not valid: %s", err)
+	}
+	if abs, _ := filepath.Abs(path); t.Name != filepath.Base(abs) || t.IsPrivate != opts.Private {
+		return nil, nil, fmt.Errorf("Created torrent is not valid: loaded as %s", t.Name)
+	}
+
+	log.Infof("Created torrent %s (%s) from %s in %s", t.Name, t.InfoHash, path, time.Since(now))
+	return t, b, nil
+}
+
+// SeedTorrentFile adds created torrent to the session to seed its files. Files are looked up in the download path,
+// so only torrents, created from its direct children, can be seeded.
+func (s *Service) SeedTorrentFile(tf *TorrentFile, path string) (*Torrent, error) {
+	if t := s.GetTorrentByHash(tf.InfoHash); t != nil {
+		return t, nil
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	downloadPath, err := filepath.Abs(s.config.DownloadPath)
+	if err != nil || s.config.DownloadPath == "." || filepath.Dir(abs) != downloadPath {
+		return nil, fmt.Errorf("Only files in download path %s can be seeded", s.config.DownloadPath)
+	}
+
+	t, err := s.AddTorrent(nil, tf.URI, false, config.StorageFile, true, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	database.GetStorm().UpdateBTItem(t.InfoHash(), 0, "", []string{}, t.Name(), 0, 0, 0)
+
+	// Files are already in place, so they are seeded as soon as libtorrent checks them
+	t.DownloadAllFiles()
+	t.SaveDBFiles()
+
+	log.Infof("Seeding %s from %s", t.Name(), abs)
+	return t, nil
+}
@@ -0,0 +1,156 @@
+package bittorrent
+
+import (
+	"time"
+
+	lt "github.com/ElementumOrg/libtorrent-go"
+)
+
+// Event types, published to Service.Events() subscribers
+const (
+	EventTorrentState    = "torrent_state"
+	EventTorrentProgress = "torrent_progress"
+	EventSessionProgress = "session_progress"
+	EventPlayerAttached  = "player_attached"
+	EventPlayerDetached  = "player_detached"
+	EventBuffering       = "buffering"
+)
+
+// Event is a single state change notification of a torrent, session or player
+type Event struct {
+	Type     string      `json:"type"`
+	InfoHash string      `json:"info_hash,omitempty"`
+	Time     int64       `json:"time"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// TorrentStateEvent is sent when libtorrent reports a torrent state change
+type TorrentStateEvent struct {
+	Name      string `json:"name"`
+	Alert     string `json:"alert"`
+	State     int    `json:"state"`
+	StateName string `json:"state_name"`
+}
+
+// TorrentProgressEvent is sent on every download progress tick
+type TorrentProgressEvent struct {
+	Name         string  `json:"name"`
+	State        int     `json:"state"`
+	StateName    string  `json:"state_name"`
+	Progress     float64 `json:"progress"`
+	DownloadRate int     `json:"download_rate"`
+	UploadRate   int     `json:"upload_rate"`
+	Paused       bool    `json:"paused"`
+}
+
+// SessionProgressEvent is sent on every download progress tick with session totals
+type SessionProgressEvent struct {
+	DownloadRate int  `json:"download_rate"`
+	UploadRate   int  `json:"upload_rate"`
+	Torrents     int  `json:"torrents"`
+	Paused       bool `json:"paused"`
+}
+
+// PlayerEvent is sent when player is attached to or detached from a torrent
+type PlayerEvent struct {
+	Name        string `json:"name"`
+	File        string `json:"file,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	TMDBId      int    `json:"tmdb_id,omitempty"`
+	ShowID      int    `json:"show_id,omitempty"`
+	Season      int    `json:"season,omitempty"`
+	Episode     int    `json:"episode,omitempty"`
+}
+
+// BufferingEvent is sent on every buffer tick while torrent is buffering
+type BufferingEvent struct {
+	Name         string  `json:"name"`
+	Progress     float64 `json:"progress"`
+	DownloadRate int     `json:"download_rate"`
+	UploadRate   int     `json:"upload_rate"`
+	Seeds        int     `json:"seeds"`
+	Peers        int     `json:"peers"`
+	Finished     bool    `json:"finished"`
+}
+
+// Events returns a channel with all published events,
+// subscriber should close done channel when finished.
+func (s *Service) Events() (<-chan *Event, chan<- interface{}) {
+	c, listenerDone := s.eventsBroadcaster.Listen()
+	ec := make(chan *Event)
+	done := make(chan interface{})
+	go func() {
+		defer func() {
+			close(listenerDone)
+			// Drain listener until it notices closed done channel
+			for range c {
+			}
+		}()
+
+		for {
+			select {
+			case <-done:
+				return
+			case v, ok := <-c:
+				if !ok {
+					return
+				}
+
+				select {
+				case ec <- v.(*Event):
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+	return ec, done
+}
+
+// PublishEvent sends event to all subscribers
+func (s *Service) PublishEvent(eventType, infoHash string, data interface{}) {
+	if s.Closer.IsSet() {
+		return
+	}
+
+	s.eventsBroadcaster.Broadcast(&Event{
+		Type:     eventType,
+		InfoHash: infoHash,
+		Time:     time.Now().Unix(),
+		Data:     data,
+	})
+}
+
+func (s *Service) publishStateEvent(th lt.TorrentHandle, what string) {
+	for _, t := range s.q.All() {
+		if t.th == nil || !th.Equal(t.th) {
+			continue
+		}
+
+		state := t.GetSmartState()
+		s.PublishEvent(EventTorrentState, t.InfoHash(), &TorrentStateEvent{
+			Name:      t.Name(),
+			Alert:     what,
+			State:     state,
+			StateName: StatusName(state),
+		})
+	}
+}
+
+func (s *Service) publishPlayerEvent(eventType string, p *Player) {
+	e := &PlayerEvent{
+		Name: p.t.Name(),
+	}
+	if p.chosenFile != nil {
+		e.File = p.chosenFile.Path
+	}
+	if p.p != nil {
+		e.ContentType = p.p.ContentType
+		e.TMDBId = p.p.TMDBId
+		e.ShowID = p.p.ShowID
+		e.Season = p.p.Season
+		e.Episode = p.p.Episode
+	}
+
+	s.PublishEvent(eventType, p.t.InfoHash(), e)
+}
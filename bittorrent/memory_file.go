@@ -15,6 +15,7 @@ import (
 // MemoryFile ...
 type MemoryFile struct {
 	tf   *TorrentFS
+	t    *Torrent
 	s    lt.MemoryStorage
 	f    *File
 	path string
@@ -23,16 +24,22 @@ type MemoryFile struct {
 	mu   sync.Mutex
 
 	pos int64
+
+	// Piece, that is being read sequentially, is collected for spillover cache
+	piece     int
+	pieceData []byte
 }
 
 // NewMemoryFile ...
-func NewMemoryFile(tf *TorrentFS, storage lt.MemoryStorage, file *File, path string) *MemoryFile {
+func NewMemoryFile(tf *TorrentFS, t *Torrent, storage lt.MemoryStorage, file *File, path string) *MemoryFile {
 	// log.Debugf("New memory file: %v", path)
 	return &MemoryFile{
-		tf:   tf,
-		s:    storage,
-		f:    file,
-		path: path,
+		tf:    tf,
+		t:     t,
+		s:     storage,
+		f:     file,
+		path:  path,
+		piece: -1,
 	}
 }
 
@@ -58,9 +65,19 @@ func (mf *MemoryFile) ReadPiece(b []byte, piece int, pieceOffset int) (n int, er
 	n = mf.s.Read(b, len(b), piece, pieceOffset)
 
 	if n == -1 {
-		err = io.ErrShortBuffer
-		return
-	} else if len(b) != n {
+		// Evicted pieces are taken from spillover cache, before downloading them again
+		if c := mf.t.Service.spillover.Load(); c != nil {
+			n = c.ReadAt(mf.t.InfoHash(), piece, b, pieceOffset)
+		}
+		if n == -1 {
+			err = io.ErrShortBuffer
+			return
+		}
+	} else {
+		mf.collectPiece(b[:n], piece, pieceOffset)
+	}
+
+	if len(b) != n {
 		err = io.ErrUnexpectedEOF
 		return
 	}
@@ -79,6 +96,37 @@ func (mf *MemoryFile) ReadPiece(b []byte, piece int, pieceOffset int) (n int, er
 	return
 }
 
+// collectPiece accumulates piece data, that is read sequentially, and stores complete pieces in spillover cache
+func (mf *MemoryFile) collectPiece(b []byte, piece int, pieceOffset int) {
+	c := mf.t.Service.spillover.Load()
+	if c == nil || mf.t.ti == nil || c.Has(mf.t.InfoHash(), piece) {
+		return
+	}
+
+	if mf.piece != piece || pieceOffset != len(mf.pieceData) {
+		mf.piece = -1
+		mf.pieceData = mf.pieceData[:0]
+		if pieceOffset != 0 {
+			return
+		}
+		mf.piece = piece
+	}
+
+	mf.pieceData = append(mf.pieceData, b...)
+	if size := mf.t.ti.PieceSize(piece); len(mf.pieceData) >= size {
+		data := make([]byte, size)
+		copy(data, mf.pieceData)
+		mf.piece = -1
+		mf.pieceData = mf.pieceData[:0]
+
+		go func() {
+			if err := c.Store(mf.t.InfoHash(), piece, data, mf.t.ti.HashForPiece(piece).ToString()); err != nil {
+				log.Warningf("Could not store piece %d in spillover cache: %s", piece, err)
+			}
+		}()
+	}
+}
+
 // Seek ...
 func (mf *MemoryFile) Seek(off int64, whence int) (ret int64, err error) {
 	mf.opMu.Lock()
@@ -68,6 +68,7 @@ type Player struct {
 	scrobble             bool
 	overlayStatusEnabled bool
 	chosenFile           *File
+	rarFile              *RarFile
 	subtitlesFile        *File
 	subtitlesLoaded      []string
 	fileSize             int64
@@ -126,6 +127,8 @@ type NextEpisode struct {
 	started    bool
 	done       bool
 	bufferSize int64
+	// File is taken from the torrent playlist, so it is played without show or search context
+	fromPlaylist bool
 }
 
 // CandidateFile ...
@@ -223,7 +226,9 @@ func (btp *Player) resumeTorrent() error {
 
 // PlayURL ...
 func (btp *Player) PlayURL() string {
-	if btp.t.IsRarArchive {
+	if btp.rarFile != nil {
+		return util.EncodeFileURL(btp.rarFile.Path)
+	} else if btp.t.IsRarArchive {
 		extractedPath := filepath.Join(filepath.Dir(btp.chosenFile.Path), "extracted", btp.extracted)
 		return util.EncodeFileURL(extractedPath)
 	}
@@ -323,6 +328,10 @@ func (btp *Player) processMetadata() {
 	btp.hasChosenFile = true
 	btp.fileSize = btp.chosenFile.Size
 	btp.fileName = btp.chosenFile.Name
+	if btp.rarFile != nil {
+		btp.fileSize = btp.rarFile.Size
+		btp.fileName = btp.rarFile.Name
+	}
 	btp.subtitlesFile = btp.findSubtitlesFile()
 
 	log.Infof("Chosen file: %s", btp.fileName)
@@ -355,7 +364,12 @@ func (btp *Player) processMetadata() {
 	}
 
 	files := []string{}
-	if btp.chosenFile != nil {
+	if btp.rarFile != nil {
+		for _, v := range btp.rarFile.Volumes() {
+			btp.t.DownloadFileWithPriority(v, 2)
+			files = append(files, v.Path)
+		}
+	} else if btp.chosenFile != nil {
 		btp.t.DownloadFileWithPriority(btp.chosenFile, 2)
 		files = append(files, btp.chosenFile.Path)
 	}
@@ -384,7 +398,11 @@ func (btp *Player) processMetadata() {
 		filePriorities := btp.t.th.FilePriorities()
 		defer lt.DeleteStdVectorInt(filePriorities)
 
-		if btp.chosenFile != nil {
+		if btp.rarFile != nil {
+			for _, v := range btp.rarFile.Volumes() {
+				filePriorities.Set(v.Index, 4)
+			}
+		} else if btp.chosenFile != nil {
 			filePriorities.Set(btp.chosenFile.Index, 4)
 		}
 		if btp.subtitlesFile != nil {
@@ -398,7 +416,11 @@ func (btp *Player) processMetadata() {
 	log.Info("Setting piece priorities")
 
 	if !btp.p.Background {
-		go btp.t.Buffer(btp.chosenFile, btp.p.ResumeHash == "")
+		if btp.rarFile != nil {
+			go btp.t.BufferRar(btp.rarFile, btp.p.ResumeHash == "")
+		} else {
+			go btp.t.Buffer(btp.chosenFile, btp.p.ResumeHash == "")
+		}
 	}
 }
 
@@ -596,8 +618,8 @@ func (btp *Player) updateBufferDialog() (bool, error) {
 		}
 
 		if btp.t.IsRarArchive && progress >= 100 {
-			archivePath := filepath.Join(btp.s.config.DownloadPath, btp.chosenFile.Path)
-			destPath := filepath.Join(btp.s.config.DownloadPath, filepath.Dir(btp.chosenFile.Path), "extracted")
+			archivePath := filepath.Join(btp.t.GetSavePath(), btp.chosenFile.Path)
+			destPath := filepath.Join(btp.t.GetSavePath(), filepath.Dir(btp.chosenFile.Path), "extracted")
 
 			if _, err := os.Stat(destPath); err == nil {
 				btp.findExtracted(destPath)
@@ -698,6 +720,7 @@ func (btp *Player) updateWatchTimes() {
 	btp.p.VideoDuration, _ = strconv.ParseFloat(ret["videoDuration"], 64)
 	if btp.p.VideoDuration > 0 {
 		btp.p.WatchedProgress = btp.p.WatchedTime / btp.p.VideoDuration * 100
+		btp.t.SetFileDuration(btp.chosenFile, btp.p.VideoDuration)
 	}
 }
 
@@ -998,23 +1021,12 @@ func (btp *Player) GetIdent() {
 
 func (btp *Player) setRateLimiting(enable bool) {
 	if btp.s.config.LimitAfterBuffering {
-		settings := btp.s.PackSettings
 		if enable {
-			if btp.s.config.DownloadRateLimit > 0 {
-				log.Infof("Buffer filled, rate limiting download to %s", humanize.Bytes(uint64(btp.s.config.DownloadRateLimit)))
-				settings.SetInt("download_rate_limit", btp.s.config.UploadRateLimit)
-			}
-			if btp.s.config.UploadRateLimit > 0 {
-				// If we have an upload rate, use the nicer bittyrant choker
-				log.Infof("Buffer filled, rate limiting upload to %s", humanize.Bytes(uint64(btp.s.config.UploadRateLimit)))
-				settings.SetInt("upload_rate_limit", btp.s.config.UploadRateLimit)
-			}
+			log.Info("Buffer filled, restoring rate limits")
+			btp.s.RestoreLimits()
 		} else {
-			log.Info("Resetting rate limiting")
-			settings.SetInt("download_rate_limit", 0)
-			settings.SetInt("upload_rate_limit", 0)
+			btp.s.LiftLimits()
 		}
-		btp.s.Session.ApplySettings(settings)
 	}
 }
 
@@ -1055,7 +1067,7 @@ func (btp *Player) onStateChanged(stateAlert lt.StateChangedAlert) {
 }
 
 func (btp *Player) startNextFile() {
-	if (btp.p.ShowID == 0 && btp.p.Query == "") || !btp.t.HasNextFile || !btp.next.done || btp.next.f == nil || btp.t.IsBuffering || btp.next.started {
+	if (btp.p.ShowID == 0 && btp.p.Query == "" && !btp.next.fromPlaylist) || !btp.t.HasNextFile || !btp.next.done || btp.next.f == nil || btp.t.IsBuffering || btp.next.started {
 		return
 	}
 
@@ -1064,14 +1076,25 @@ func (btp *Player) startNextFile() {
 }
 
 func (btp *Player) findNextFile() {
-	if (btp.p.ShowID == 0 && btp.p.Query == "") || btp.next.done || !config.Get().SmartEpisodeStart {
+	if btp.next.done {
+		return
+	}
+
+	// Torrent, opened as a playlist, is played in the playlist order
+	if btp.next.f == nil {
+		btp.next.f = btp.t.NextPlaylistFile(btp.chosenFile)
+		btp.next.fromPlaylist = btp.next.f != nil
+	}
+	if btp.next.f == nil && ((btp.p.ShowID == 0 && btp.p.Query == "") || !config.Get().SmartEpisodeStart) {
 		return
 	}
 
 	// Set mark to avoid more than once
 	btp.next.done = true
 
-	if btp.p.ShowID != 0 {
+	if btp.next.f != nil {
+		log.Debugf("Next file is taken from the torrent playlist")
+	} else if btp.p.ShowID != 0 {
 		// Searching if we have next episode in the torrent
 		if btp.next.f == nil {
 			btp.next.f = btp.t.GetNextEpisodeFile(btp.p.Season, btp.p.Episode+1)
@@ -0,0 +1,159 @@
+package bittorrent
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/util"
+)
+
+// Season and episode numbers, like in "S01E02", "S01.E02" or "1x02"
+var episodeOrderRegex = regexp.MustCompile(`(?i)(?:^|\W|_)(?:S(\d{1,3})[\W_]?E(\d{1,4})|(\d{1,2})x(\d{1,3}))(?:\W|_|$)`)
+
+// PlaylistFiles returns video files of the torrent, or audio files if there are no videos,
+// ordered by season and episode numbers, or by name for files without them
+func (t *Torrent) PlaylistFiles() []*File {
+	reSkip := regexp.MustCompile(skipFileRegex)
+
+	videos := []*File{}
+	audios := []*File{}
+	for _, f := range t.files {
+		name := filepath.Base(f.Path)
+		ext := strings.ToLower(filepath.Ext(name))
+		if reSkip.MatchString(name) || util.IsSubtitlesExt(ext) {
+			continue
+		}
+
+		if util.IsAudioExt(ext) {
+			audios = append(audios, f)
+		} else if f.Size >= config.Get().MinCandidateSize {
+			videos = append(videos, f)
+		}
+	}
+
+	ret := videos
+	if len(ret) == 0 {
+		ret = audios
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return playlistLess(ret[i], ret[j])
+	})
+
+	return ret
+}
+
+// SetPlaylist remembers files order, next file of the playlist is preloaded
+// when readers of the current file reach its end
+func (t *Torrent) SetPlaylist(files []*File) {
+	t.muPlaylist.Lock()
+	defer t.muPlaylist.Unlock()
+
+	t.playlist = files
+}
+
+// NextPlaylistFile returns file, that follows given one in the playlist
+func (t *Torrent) NextPlaylistFile(f *File) *File {
+	if f == nil {
+		return nil
+	}
+
+	t.muPlaylist.RLock()
+	defer t.muPlaylist.RUnlock()
+
+	for i, pf := range t.playlist {
+		if pf.Index == f.Index && i+1 < len(t.playlist) {
+			return t.playlist[i+1]
+		}
+	}
+	return nil
+}
+
+// nextFilePieces returns starting pieces of playlist files, which follow files,
+// that readers have read up to the end. Should be called with muReaders locked.
+func (t *Torrent) nextFilePieces() []int {
+	// Memory storage keeps only reader pieces, there is no room for the next file
+	if t.IsMemoryStorage() {
+		return nil
+	}
+
+	ret := []int{}
+	for _, r := range t.readers {
+		next := t.NextPlaylistFile(r.f)
+		if next == nil || r.ReaderPiecesRange().End < r.f.PieceEnd {
+			continue
+		}
+
+		startPiece, endPiece, _, _ := t.getBufferSize(next.Offset, 0, t.Service.GetBufferSize())
+		for piece := startPiece; piece <= endPiece && piece <= next.PieceEnd; piece++ {
+			ret = append(ret, piece)
+		}
+	}
+	return ret
+}
+
+// playlistLess compares files by season and episode, then by path in natural order
+func playlistLess(a, b *File) bool {
+	as, ae, aok := episodeNumbers(filepath.Base(a.Path))
+	bs, be, bok := episodeNumbers(filepath.Base(b.Path))
+	if aok && bok && (as != bs || ae != be) {
+		if as != bs {
+			return as < bs
+		}
+		return ae < be
+	}
+
+	return naturalLess(a.Path, b.Path)
+}
+
+func episodeNumbers(name string) (season, episode int, ok bool) {
+	m := episodeOrderRegex.FindStringSubmatch(name)
+	if m == nil {
+		return 0, 0, false
+	}
+
+	if m[1] != "" {
+		season, _ = strconv.Atoi(m[1])
+		episode, _ = strconv.Atoi(m[2])
+	} else {
+		season, _ = strconv.Atoi(m[3])
+		episode, _ = strconv.Atoi(m[4])
+	}
+	return season, episode, true
+}
+
+// naturalLess compares strings case-insensitively, with digit sequences compared as numbers,
+// so that "Track 2" goes before "Track 10"
+func naturalLess(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	for a != "" && b != "" {
+		ca, cb := naturalChunk(a), naturalChunk(b)
+		a, b = a[len(ca):], b[len(cb):]
+		if ca == cb {
+			continue
+		}
+
+		na, errA := strconv.ParseUint(ca, 10, 64)
+		nb, errB := strconv.ParseUint(cb, 10, 64)
+		if errA == nil && errB == nil && na != nb {
+			return na < nb
+		}
+		return ca < cb
+	}
+	return len(a) < len(b)
+}
+
+// naturalChunk returns leading run of digits or non-digits
+func naturalChunk(s string) string {
+	isDigit := s[0] >= '0' && s[0] <= '9'
+	for i := 1; i < len(s); i++ {
+		if (s[i] >= '0' && s[i] <= '9') != isDigit {
+			return s[:i]
+		}
+	}
+	return s
+}
@@ -0,0 +1,493 @@
+package bittorrent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elgatito/elementum/rar"
+)
+
+const (
+	// Headers of all volumes should be downloaded within this time, otherwise archive is extracted after full download
+	rarHeaderTimeout = 30 * time.Second
+	// Smaller archives, like subtitles, are not played
+	rarMinVolumeSize = 10 * 1024 * 1024
+)
+
+var (
+	errNotRarVolume     = errors.New("File is not a RAR volume")
+	errNoStreamableFile = errors.New("Archive has no files, stored without compression")
+)
+
+// RarFile is a file of RAR archive in the torrent, that is stored without compression,
+// so it is read directly from volumes, while they are downloaded
+type RarFile struct {
+	Path string
+	Name string
+	Size int64
+
+	volumes  []*File
+	segments []rar.Segment
+	starts   []int64
+}
+
+// RarFSEntry reads RAR file through volume readers, so volume pieces are prioritized like for normal files
+type RarFSEntry struct {
+	tfs *TorrentFS
+	t   *Torrent
+	rf  *RarFile
+
+	mu  sync.Mutex
+	pos int64
+
+	entry  *TorrentFSEntry
+	volume int
+	// Reader of the next volume is opened in advance, when current volume is almost read
+	next       *TorrentFSEntry
+	nextVolume int
+}
+
+// locate returns segment, that contains file position, and position inside of it
+func (rf *RarFile) locate(pos int64) (int, int64) {
+	i := sort.Search(len(rf.starts), func(i int) bool {
+		return rf.starts[i] > pos
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+	return i, pos - rf.starts[i]
+}
+
+// dataRange returns offset of file data in the torrent, and its length with volume headers in between
+func (rf *RarFile) dataRange() (offset, size int64) {
+	first := rf.segments[0]
+	last := rf.segments[len(rf.segments)-1]
+
+	offset = rf.volumes[first.Volume].Offset + first.Offset
+	return offset, rf.volumes[last.Volume].Offset + last.Offset + last.Size - offset
+}
+
+// Volumes returns torrent files of archive volumes
+func (rf *RarFile) Volumes() []*File {
+	return rf.volumes
+}
+
+// chooseRarFile looks for RAR archive in the torrent. The biggest stored file of the archive is streamed
+// from volumes, and first volume is returned as chosen file. Otherwise archive is extracted after full download,
+// if user agrees, and nil is returned, so that file is chosen as usual.
+func (t *Torrent) chooseRarFile(btp *Player) (*File, error) {
+	f := t.rarVolume()
+	if f == nil {
+		return nil, nil
+	}
+
+	rf, err := t.RarFile(f)
+	if err == nil {
+		btp.rarFile = rf
+		return rf.Volumes()[0], nil
+	}
+	log.Infof("Archive %s can not be streamed: %s", f.Path, err)
+
+	t.IsRarArchive = true
+	if !btp.xbmcHost.DialogConfirm("Elementum", "LOCALIZE[30303]") {
+		btp.notEnoughSpace = true
+		return nil, errors.New("RAR archive detected and download was cancelled")
+	}
+	return nil, nil
+}
+
+// rarVolume returns the first RAR volume of the torrent, that can hold media
+func (t *Torrent) rarVolume() *File {
+	reRar := regexp.MustCompile(rarMatchRegex)
+	reSkip := regexp.MustCompile(skipFileRegex)
+	for _, f := range t.files {
+		fileName := filepath.Base(f.Path)
+		if reRar.MatchString(fileName) && !reSkip.MatchString(fileName) && !strings.Contains(f.Path, "BDMV/STREAM/") && f.Size > rarMinVolumeSize {
+			return f
+		}
+	}
+	return nil
+}
+
+// RarFile returns the biggest file of the archive, given volume belongs to,
+// if it is stored without compression. Volume headers are waited for, if they are not downloaded yet.
+func (t *Torrent) RarFile(f *File) (*RarFile, error) {
+	files, err := t.rarArchive(t.rarVolumes(f))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret *RarFile
+	for _, rf := range files {
+		if ret == nil || rf.Size > ret.Size {
+			ret = rf
+		}
+	}
+	if ret == nil {
+		return nil, errNoStreamableFile
+	}
+	return ret, nil
+}
+
+// findRarFile looks for a stored file with given path in archives of the torrent
+func (t *Torrent) findRarFile(name string) *RarFile {
+	for _, f := range t.files {
+		if _, number, ok := rar.VolumeNumber(filepath.Base(f.Path)); !ok || number != 0 {
+			continue
+		}
+		if dir := filepath.Dir(f.Path); dir != "." && !strings.HasPrefix(name, dir+string(filepath.Separator)) {
+			continue
+		}
+
+		// Archives, that are not parsed yet, are read only if there is no need to wait for headers
+		volumes := t.rarVolumes(f)
+		if _, ok := t.rarArchives.Load(f.Path); !ok && !t.hasRarHeaders(volumes) {
+			continue
+		}
+
+		files, err := t.rarArchive(volumes)
+		if err != nil {
+			continue
+		}
+		for _, rf := range files {
+			if rf.Path == name {
+				return rf
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasRarHeaders tells whether first pieces of all volumes are downloaded
+func (t *Torrent) hasRarHeaders(volumes []*File) bool {
+	for _, v := range volumes {
+		if !t.hasPiece(v.PieceStart) {
+			return false
+		}
+	}
+	return len(volumes) > 0
+}
+
+// rarVolumes returns volumes of the archive, given file belongs to, in volume order
+func (t *Torrent) rarVolumes(f *File) []*File {
+	base, _, ok := rar.VolumeNumber(filepath.Base(f.Path))
+	if !ok {
+		return nil
+	}
+
+	dir := filepath.Dir(f.Path)
+	volumes := map[int]*File{}
+	for _, tf := range t.files {
+		if filepath.Dir(tf.Path) != dir {
+			continue
+		}
+		if b, number, ok := rar.VolumeNumber(filepath.Base(tf.Path)); ok && strings.EqualFold(b, base) {
+			volumes[number] = tf
+		}
+	}
+
+	ret := []*File{}
+	for i := 0; volumes[i] != nil; i++ {
+		ret = append(ret, volumes[i])
+	}
+	return ret
+}
+
+// rarArchive reads headers of all volumes and returns stored files of the archive, parsed archives are cached
+func (t *Torrent) rarArchive(volumes []*File) ([]*RarFile, error) {
+	if len(volumes) == 0 {
+		return nil, errNotRarVolume
+	}
+	if cached, ok := t.rarArchives.Load(volumes[0].Path); ok {
+		return cached.([]*RarFile), nil
+	}
+
+	// Headers are at the start of volumes, so they are requested all together
+	if t.th != nil && !t.IsMemoryStorage() {
+		for _, v := range volumes {
+			t.th.PiecePriority(v.PieceStart, 7)
+			t.th.SetPieceDeadline(v.PieceStart, 0, 0)
+		}
+	}
+
+	a := &rar.Archive{}
+	tfs := NewTorrentFS(t.Service, "HEAD")
+	deadline := time.Now().Add(rarHeaderTimeout)
+	for _, v := range volumes {
+		if err := t.readRarVolume(a, tfs, v, deadline); err != nil {
+			return nil, fmt.Errorf("Could not read %s: %s", v.Path, err)
+		}
+		if a.Complete() {
+			break
+		}
+	}
+
+	ret := []*RarFile{}
+	dir := filepath.Dir(volumes[0].Path)
+	for _, f := range a.Files {
+		if !f.Streamable() {
+			continue
+		}
+
+		rf := &RarFile{
+			Path:     filepath.Join(dir, filepath.FromSlash(f.Name)),
+			Name:     filepath.Base(filepath.FromSlash(f.Name)),
+			Size:     f.Size,
+			volumes:  volumes,
+			segments: f.Segments,
+			starts:   make([]int64, len(f.Segments)),
+		}
+		pos := int64(0)
+		for i, s := range f.Segments {
+			rf.starts[i] = pos
+			pos += s.Size
+		}
+
+		log.Infof("Found stored file %s (%s) in %d volumes of %s", rf.Path, humanize.Bytes(uint64(rf.Size)), len(f.Segments), volumes[0].Path)
+		ret = append(ret, rf)
+	}
+
+	t.rarArchives.Store(volumes[0].Path, ret)
+	return ret, nil
+}
+
+// readRarVolume adds volume headers to the archive, waiting for them until deadline, that is common for all volumes
+func (t *Torrent) readRarVolume(a *rar.Archive, tfs *TorrentFS, v *File, deadline time.Time) error {
+	if time.Now().After(deadline) {
+		return errors.New("Timeout waiting for volume header")
+	}
+
+	// Files of disk storage are created, when first piece is written
+	if !t.IsMemoryStorage() && !t.hasPiece(v.PieceStart) {
+		ticker := time.NewTicker(piecesRefreshDuration)
+		defer ticker.Stop()
+		timeout := time.After(time.Until(deadline))
+
+		for !t.hasPiece(v.PieceStart) {
+			select {
+			case <-t.Closer.C():
+				return errors.New("Torrent closed")
+			case <-timeout:
+				return errors.New("Timeout waiting for volume header")
+			case <-ticker.C:
+			}
+		}
+	}
+
+	entry, err := tfs.OpenTorrentFile(t, v)
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+
+	// Closing the reader interrupts waiting for pieces
+	timer := time.AfterFunc(time.Until(deadline), func() { entry.removed.Set() })
+	defer timer.Stop()
+
+	return a.AddVolume(entry, v.Size)
+}
+
+// BufferRar sets buffer for the start and the end of RAR file data, that are in the first and the last volumes
+func (t *Torrent) BufferRar(rf *RarFile, isStartup bool) {
+	if rf == nil {
+		t.bufferFinishedEvent()
+		return
+	}
+
+	offset, size := rf.dataRange()
+	t.bufferRange(rf.volumes[rf.segments[0].Volume], offset, size, isStartup)
+}
+
+// OpenRarFile opens stored file of RAR archive for reading
+func (tfs *TorrentFS) OpenRarFile(t *Torrent, rf *RarFile) (*RarFSEntry, error) {
+	re := &RarFSEntry{
+		tfs: tfs,
+		t:   t,
+		rf:  rf,
+	}
+
+	// Opening the first volume right away, so that its pieces are prioritized before reading
+	s := rf.segments[0]
+	if err := re.openVolume(s.Volume, s.Offset); err != nil {
+		return nil, err
+	}
+	return re, nil
+}
+
+// openVolume makes sure current reader is opened for the volume and positioned at given offset
+func (re *RarFSEntry) openVolume(volume int, offset int64) error {
+	if re.entry == nil || re.volume != volume {
+		if re.entry != nil {
+			re.entry.Close()
+			re.entry = nil
+		}
+
+		if re.next != nil && re.nextVolume == volume {
+			re.entry, re.next = re.next, nil
+		} else {
+			entry, err := re.tfs.OpenTorrentFile(re.t, re.rf.volumes[volume])
+			if err != nil {
+				return err
+			}
+			re.entry = entry
+		}
+		re.volume = volume
+	}
+
+	if pos, err := re.entry.Pos(); err != nil {
+		return err
+	} else if pos == offset {
+		return nil
+	}
+
+	_, err := re.entry.Seek(offset, io.SeekStart)
+	return err
+}
+
+// preloadNext opens reader at the start of the next segment, when current reader's readahead gets there
+func (re *RarFSEntry) preloadNext(segment int, left int64) {
+	if re.next != nil || segment+1 >= len(re.rf.segments) || left > re.entry.readahead {
+		return
+	}
+
+	s := re.rf.segments[segment+1]
+	entry, err := re.tfs.OpenTorrentFile(re.t, re.rf.volumes[s.Volume])
+	if err != nil {
+		log.Debugf("Could not open next volume %s: %s", re.rf.volumes[s.Volume].Path, err)
+		return
+	}
+
+	// Relative seek does not deactivate other readers, so current volume keeps its priorities
+	if _, err := entry.Seek(s.Offset, io.SeekCurrent); err != nil {
+		entry.Close()
+		return
+	}
+
+	re.next = entry
+	re.nextVolume = s.Volume
+}
+
+// Read reads file data from current volume, switching volumes on segment boundaries
+func (re *RarFSEntry) Read(data []byte) (n int, err error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	if re.pos >= re.rf.Size {
+		return 0, io.EOF
+	}
+
+	segment, segmentPos := re.rf.locate(re.pos)
+	s := re.rf.segments[segment]
+	if err = re.openVolume(s.Volume, s.Offset+segmentPos); err != nil {
+		return 0, err
+	}
+
+	left := s.Size - segmentPos
+	if int64(len(data)) > left {
+		data = data[:left]
+	}
+
+	n, err = re.entry.Read(data)
+	re.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	re.preloadNext(segment, left-int64(n))
+	return
+}
+
+// Seek ...
+func (re *RarFSEntry) Seek(offset int64, whence int) (int64, error) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	pos := offset
+	switch whence {
+	case io.SeekCurrent:
+		pos += re.pos
+	case io.SeekEnd:
+		pos += re.rf.Size
+	}
+	if pos < 0 {
+		return re.pos, os.ErrInvalid
+	}
+	re.pos = pos
+
+	if pos < re.rf.Size {
+		segment, segmentPos := re.rf.locate(pos)
+		s := re.rf.segments[segment]
+		if err := re.openVolume(s.Volume, s.Offset+segmentPos); err != nil {
+			return pos, err
+		}
+	}
+	return pos, nil
+}
+
+// Close ...
+func (re *RarFSEntry) Close() error {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	if re.next != nil {
+		re.next.Close()
+		re.next = nil
+	}
+	if re.entry != nil {
+		err := re.entry.Close()
+		re.entry = nil
+		return err
+	}
+	return nil
+}
+
+// Readdir ...
+func (re *RarFSEntry) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, nil
+}
+
+// Stat ...
+func (re *RarFSEntry) Stat() (os.FileInfo, error) {
+	return re, nil
+}
+
+// Name ...
+func (re *RarFSEntry) Name() string {
+	return re.rf.Name
+}
+
+// Size ...
+func (re *RarFSEntry) Size() int64 {
+	return re.rf.Size
+}
+
+// Mode ...
+func (re *RarFSEntry) Mode() os.FileMode {
+	return 0444
+}
+
+// ModTime ...
+func (re *RarFSEntry) ModTime() time.Time {
+	return time.Now()
+}
+
+// IsDir ...
+func (re *RarFSEntry) IsDir() bool {
+	return false
+}
+
+// Sys ...
+func (re *RarFSEntry) Sys() interface{} {
+	return nil
+}
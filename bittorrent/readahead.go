@@ -0,0 +1,269 @@
+package bittorrent
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/tmdb"
+)
+
+const (
+	// Readahead should keep this much of playback ahead of the reader
+	readaheadDuration = 90 * time.Second
+	// Pieces, needed within this time, get the highest priorities
+	urgentDuration = 10 * time.Second
+
+	// Read rate is measured over intervals and smoothed, as players read in bursts
+	readRateInterval  = 3 * time.Second
+	readRateSmoothing = 0.3
+	// Reader, that did not read for this long, gives its readahead back to active readers
+	readerIdleTimeout = 20 * time.Second
+
+	// Disk storage is not limited by memory, but too long readahead spreads the download too much
+	maxDiskReadahead = 512 * 1024 * 1024
+
+	// Piece counts of the priority tiers for pieces, that follow reader position
+	urgentTierPieces = 9
+)
+
+// updateReadRate accounts bytes, read by a consumer, and returns true when new rate is measured
+func (tf *TorrentFSEntry) updateReadRate(n int) bool {
+	tf.rateMu.Lock()
+	defer tf.rateMu.Unlock()
+
+	now := time.Now()
+	if tf.rateStart.IsZero() {
+		tf.rateStart = now
+	}
+	tf.rateBytes += int64(n)
+
+	elapsed := now.Sub(tf.rateStart)
+	if elapsed < readRateInterval {
+		return false
+	}
+
+	rate := float64(tf.rateBytes) / elapsed.Seconds()
+	if tf.readRate == 0 {
+		tf.readRate = rate
+	} else {
+		tf.readRate = tf.readRate*(1-readRateSmoothing) + rate*readRateSmoothing
+	}
+	tf.rateBytes = 0
+	tf.rateStart = now
+
+	return true
+}
+
+// ReadRate returns smoothed rate in bytes per second, the consumer reads the file with
+func (tf *TorrentFSEntry) ReadRate() float64 {
+	tf.rateMu.Lock()
+	defer tf.rateMu.Unlock()
+
+	if tf.rateStart.IsZero() || time.Since(tf.lastUsed) > readerIdleTimeout {
+		return 0
+	}
+	return tf.readRate
+}
+
+// isReading tells whether reader is actively consumed, inactive and stalled readers are not
+func (tf *TorrentFSEntry) isReading() bool {
+	return tf.IsActive() && time.Since(tf.lastUsed) <= readerIdleTimeout
+}
+
+// demand returns readahead, that covers readaheadDuration of media for this reader,
+// or 0 if neither read rate nor media bitrate is known yet
+func (tf *TorrentFSEntry) demand() int64 {
+	rate := tf.ReadRate()
+	if bitrate := tf.t.FileBitrate(tf.f); bitrate > rate {
+		rate = bitrate
+	}
+
+	return int64(rate * readaheadDuration.Seconds())
+}
+
+// priorityScale stretches priority tiers for fast readers, so that pieces,
+// which are needed in the next few seconds, are always in the highest tiers
+func (tf *TorrentFSEntry) priorityScale() int {
+	rate := tf.ReadRate()
+	if bitrate := tf.t.FileBitrate(tf.f); bitrate > rate {
+		rate = bitrate
+	}
+	if rate <= 0 || tf.pieceLength <= 0 {
+		return 1
+	}
+
+	urgent := int(rate * urgentDuration.Seconds() / float64(tf.pieceLength))
+	if urgent <= urgentTierPieces {
+		return 1
+	}
+	return (urgent + urgentTierPieces - 1) / urgentTierPieces
+}
+
+// SetFileDuration stores playback duration of a file in seconds, as reported by the player
+func (t *Torrent) SetFileDuration(f *File, seconds float64) {
+	if f == nil || seconds <= 0 {
+		return
+	}
+
+	t.fileDurations.Store(f.Index, seconds)
+}
+
+// FileBitrate returns average media bitrate of the file in bytes per second, calculated
+// from file size and media runtime. Runtime is looked up in background, so 0 is returned until it is known.
+func (t *Torrent) FileBitrate(f *File) float64 {
+	if f == nil {
+		return 0
+	}
+
+	v, loaded := t.fileDurations.LoadOrStore(f.Index, float64(0))
+	if !loaded {
+		go t.loadFileDuration(f)
+	}
+
+	if duration := v.(float64); duration > 0 {
+		return float64(f.Size) / duration
+	}
+	return 0
+}
+
+// loadFileDuration takes runtime of the media, assigned to the torrent, from TMDB
+func (t *Torrent) loadFileDuration(f *File) {
+	if t.DBItem == nil || f.Size < config.Get().MinCandidateSize {
+		return
+	}
+
+	runtime := 0
+	switch t.DBItem.Type {
+	case movieType:
+		if movie := tmdb.GetMovie(t.DBItem.ID, config.Get().Language); movie != nil {
+			runtime = movie.Runtime
+		}
+	case showType, episodeType:
+		if show := tmdb.GetShow(t.DBItem.ShowID, config.Get().Language); show != nil && len(show.EpisodeRunTime) > 0 {
+			runtime = show.EpisodeRunTime[len(show.EpisodeRunTime)-1]
+		}
+	}
+	if runtime <= 0 {
+		return
+	}
+
+	// Duration, reported by the player, is more precise, so it is not overwritten
+	t.fileDurations.CompareAndSwap(f.Index, float64(0), float64(runtime*60))
+}
+
+// readerSizes distributes readahead between readers: head requests get a piece,
+// readers, that are not consumed, keep just a few pieces, and active readers share the rest by their demand
+func (t *Torrent) readerSizes() map[int64]int64 {
+	total := t.GetReadaheadSize()
+	minSize := 2 * t.pieceLength
+
+	ret := map[int64]int64{}
+	active := []*TorrentFSEntry{}
+	for _, r := range t.readers {
+		switch {
+		case r.IsHead():
+			ret[r.id] = t.pieceLength
+		case r.IsIdle() || !r.isReading():
+			ret[r.id] = minSize
+		default:
+			active = append(active, r)
+		}
+	}
+	if len(active) == 0 {
+		return ret
+	}
+
+	if !t.IsMemoryStorage() {
+		for _, r := range active {
+			size := r.demand()
+			if size < total {
+				size = total
+			} else if size > maxDiskReadahead {
+				size = maxDiskReadahead
+			}
+			ret[r.id] = size
+		}
+		return ret
+	}
+
+	// Memory storage has a fixed size, everything, that is not used by other readers, goes to active ones
+	left := total
+	for _, size := range ret {
+		left -= size
+	}
+	if left < minSize*int64(len(active)) {
+		left = minSize * int64(len(active))
+	}
+
+	demands := make([]int64, len(active))
+	known := int64(0)
+	sum := int64(0)
+	for i, r := range active {
+		demands[i] = r.demand()
+		if demands[i] > 0 {
+			known++
+			sum += demands[i]
+		}
+	}
+	// Readers with unknown demand are treated as average ones
+	for i := range demands {
+		if demands[i] == 0 {
+			if known > 0 {
+				demands[i] = sum / known
+			} else {
+				demands[i] = 1
+			}
+		}
+	}
+
+	// Each reader gets a fair share, so that a reader of another file does not break buffering of the first one
+	for i, size := range fairShares(left, demands, minSize) {
+		ret[active[i].id] = size
+	}
+	return ret
+}
+
+// ReadersInfo writes readahead state of active readers
+func (t *Torrent) ReadersInfo(w io.Writer) {
+	t.muReaders.Lock()
+	readers := make([]*TorrentFSEntry, 0, len(t.readers))
+	for _, r := range t.readers {
+		readers = append(readers, r)
+	}
+	policies := t.readerPolicies()
+	t.muReaders.Unlock()
+
+	if len(readers) == 0 {
+		return
+	}
+	sort.Slice(readers, func(i, j int) bool {
+		return readers[i].id < readers[j].id
+	})
+
+	fmt.Fprint(w, "    Readers:\n")
+	for _, r := range readers {
+		pos, _ := r.Pos()
+		state := "idle"
+		if r.IsHead() {
+			state = "head"
+		} else if r.isReading() {
+			state = "active"
+		}
+
+		fmt.Fprintf(w, "        %d: %s (%s), %s at %s, rate: %s/s, bitrate: %s/s, readahead: %s\n",
+			r.id,
+			state,
+			readerClassNames[policies[r.id].class],
+			r.f.Path,
+			humanize.Bytes(uint64(pos)),
+			humanize.Bytes(uint64(r.ReadRate())),
+			humanize.Bytes(uint64(t.FileBitrate(r.f))),
+			humanize.Bytes(uint64(r.Readahead())))
+	}
+	fmt.Fprint(w, "\n")
+}
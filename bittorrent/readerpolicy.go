@@ -0,0 +1,138 @@
+package bittorrent
+
+import (
+	"sort"
+)
+
+type readerClass int
+
+const (
+	// Demoted readers are HEAD requests and readers, that are not consumed
+	readerDemoted readerClass = iota
+	// Secondary readers are active readers, that started after the primary one
+	readerSecondary
+	// Primary reader is the earliest active reader of the torrent
+	readerPrimary
+)
+
+const (
+	// Deadlines of other readers are postponed, so that time critical requests of the primary reader go first
+	secondaryDeadlineDelay = 1000
+	demotedDeadlineDelay   = 3000
+	deadlineStep           = 100
+)
+
+var readerClassNames = map[readerClass]string{
+	readerDemoted:   "demoted",
+	readerSecondary: "secondary",
+	readerPrimary:   "primary",
+}
+
+// readerPolicy is a scheduling decision for a single reader
+type readerPolicy struct {
+	class readerClass
+
+	// Priority of the piece at reader position, following pieces get lower tiers
+	maxPriority int
+	// Budget of pieces, reader may set deadlines for, and delay of those deadlines in milliseconds
+	deadlinePieces int
+	deadlineDelay  int
+}
+
+var readerPolicies = map[readerClass]readerPolicy{
+	readerPrimary:   {class: readerPrimary, maxPriority: 6, deadlinePieces: 3},
+	readerSecondary: {class: readerSecondary, maxPriority: 5, deadlinePieces: 2, deadlineDelay: secondaryDeadlineDelay},
+	readerDemoted:   {class: readerDemoted, maxPriority: 1, deadlinePieces: 1, deadlineDelay: demotedDeadlineDelay},
+}
+
+// readerPolicies classifies readers, so that a client, that started reading first, keeps its buffering,
+// when other clients read different files or distant offsets of the same torrent.
+// Should be called with muReaders locked.
+func (t *Torrent) readerPolicies() map[int64]readerPolicy {
+	ret := make(map[int64]readerPolicy, len(t.readers))
+
+	active := []*TorrentFSEntry{}
+	for _, r := range t.readers {
+		if r.IsHead() || r.IsIdle() || !r.isReading() {
+			ret[r.id] = readerPolicies[readerDemoted]
+		} else {
+			active = append(active, r)
+		}
+	}
+
+	// Reader ids are creation times, so the earliest active reader is the primary one
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].id < active[j].id
+	})
+	for i, r := range active {
+		if i == 0 {
+			ret[r.id] = readerPolicies[readerPrimary]
+		} else {
+			ret[r.id] = readerPolicies[readerSecondary]
+		}
+	}
+
+	return ret
+}
+
+// readerPolicy returns current scheduling policy of the reader
+func (t *Torrent) readerPolicy(tf *TorrentFSEntry) readerPolicy {
+	t.muReaders.Lock()
+	defer t.muReaders.Unlock()
+
+	if policy, ok := t.readerPolicies()[tf.id]; ok {
+		return policy
+	}
+	return readerPolicies[readerDemoted]
+}
+
+// piecePriority returns priority of the piece at pos pieces after reader position,
+// tiers are stretched by scale for fast readers
+func (p readerPolicy) piecePriority(pos, scale int) int {
+	tier := 4
+	switch {
+	case pos <= 0:
+		tier = 0
+	case pos <= 2*scale:
+		tier = 1
+	case pos <= 5*scale:
+		tier = 2
+	case pos <= urgentTierPieces*scale:
+		tier = 3
+	}
+
+	if priority := p.maxPriority - tier; priority > 1 {
+		return priority
+	}
+	return 1
+}
+
+// fairShares splits readahead between active readers: each gets an equal part of the half of it,
+// and the rest is distributed by demand, so a reader of high bitrate media does not starve others
+func fairShares(total int64, demands []int64, minSize int64) []int64 {
+	ret := make([]int64, len(demands))
+	if len(demands) == 0 {
+		return ret
+	}
+
+	floor := total / 2 / int64(len(demands))
+	if floor < minSize {
+		floor = minSize
+	}
+	left := total - floor*int64(len(demands))
+	if left < 0 {
+		left = 0
+	}
+
+	sum := int64(0)
+	for _, d := range demands {
+		sum += d
+	}
+	for i, d := range demands {
+		ret[i] = floor
+		if sum > 0 {
+			ret[i] += int64(float64(left) * float64(d) / float64(sum))
+		}
+	}
+	return ret
+}
@@ -0,0 +1,192 @@
+package bittorrent
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elgatito/elementum/hls"
+)
+
+// Containers, that keep keyframe index in a separate place of the file
+var indexedExtensions = map[string]bool{
+	".mkv":  true,
+	".webm": true,
+	".mp4":  true,
+	".m4v":  true,
+	".mov":  true,
+}
+
+// Index pieces are not waited for longer, as the torrent can be too slow or the player stopped
+const indexWaitTimeout = 5 * time.Minute
+
+// seekIndex keeps parsed keyframe index of the file, that is being played
+type seekIndex struct {
+	mu    sync.Mutex
+	file  *File
+	media *hls.Media
+}
+
+// prefetchIndex locates container index from the file header and adds its pieces to the startup buffer,
+// so that the player does not stall on reading it. When index pieces are downloaded, the index is parsed
+// to map player seeks to file offsets.
+func (t *Torrent) prefetchIndex(file *File) {
+	if !indexedExtensions[strings.ToLower(filepath.Ext(file.Path))] {
+		return
+	}
+
+	t.index.mu.Lock()
+	t.index.file = file
+	t.index.media = nil
+	t.index.mu.Unlock()
+
+	entry, err := NewTorrentFS(t.Service, "HEAD").OpenTorrentFile(t, file)
+	if err != nil {
+		log.Debugf("Could not open %s to locate index: %s", file.Path, err)
+		return
+	}
+	defer entry.Close()
+
+	offset, length, err := hls.LocateIndex(entry, file.Size)
+	if err != nil {
+		log.Debugf("Could not locate index of %s: %s", file.Path, err)
+		return
+	}
+
+	startPiece, endPiece, _, size := t.getBufferSize(file.Offset, offset, length)
+	log.Infof("Index of %s is at %s (%s), pieces %d-%d", file.Path, humanize.Bytes(uint64(offset)), humanize.Bytes(uint64(length)), startPiece, endPiece)
+
+	t.bufferIndexPieces(startPiece, endPiece, size)
+
+	// Index is parsed when its pieces are downloaded, as reading it would wait for pieces otherwise
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	timeout := time.NewTimer(indexWaitTimeout)
+	defer timeout.Stop()
+	for piece := startPiece; piece <= endPiece; {
+		if t.hasPiece(piece) {
+			piece++
+			continue
+		}
+
+		select {
+		case <-t.Closer.C():
+			return
+		case <-timeout.C:
+			log.Debugf("Index pieces of %s are not downloaded in %s", file.Path, indexWaitTimeout)
+			return
+		case <-ticker.C:
+			// Another file is played, so this index is not needed anymore
+			if !t.isIndexFile(file) {
+				return
+			}
+		}
+	}
+
+	if _, err := entry.Seek(0, 0); err != nil {
+		return
+	}
+	media, err := hls.Open(entry, file.Size)
+	if err != nil {
+		log.Debugf("Could not read index of %s: %s", file.Path, err)
+		return
+	}
+
+	t.index.mu.Lock()
+	defer t.index.mu.Unlock()
+
+	if t.index.file == file {
+		t.index.media = media
+	}
+}
+
+// isIndexFile checks whether index of the file is still expected
+func (t *Torrent) isIndexFile(file *File) bool {
+	t.index.mu.Lock()
+	defer t.index.mu.Unlock()
+
+	return t.index.file == file
+}
+
+// bufferIndexPieces adds index pieces to the running buffer, or prioritizes them if buffering is over
+func (t *Torrent) bufferIndexPieces(startPiece, endPiece int, size int64) {
+	t.muBuffer.Lock()
+	defer t.muBuffer.Unlock()
+
+	if t.Closer.IsSet() || t.th == nil {
+		return
+	}
+
+	added := int64(0)
+	for piece := startPiece; piece <= endPiece; piece++ {
+		if _, ok := t.BufferPiecesProgress[piece]; ok {
+			continue
+		}
+
+		if t.IsBuffering {
+			t.BufferPiecesProgress[piece] = 0
+			t.BufferPiecesLength += t.pieceLength
+		}
+		// Memory storage keeps reserved pieces, so index stays available for seeks
+		if t.IsMemoryStorage() {
+			t.reservedPieces = append(t.reservedPieces, piece)
+		}
+		added += t.pieceLength
+	}
+	if added == 0 {
+		return
+	}
+
+	if t.IsMemoryStorage() && t.BufferLength+added > t.MemorySize {
+		t.AdjustMemorySize(t.BufferLength + added + t.pieceLength)
+	}
+	if t.IsBuffering {
+		t.BufferLength += size
+	}
+
+	for piece := startPiece; piece <= endPiece; piece++ {
+		t.th.PiecePriority(piece, 7)
+		if !t.IsMemoryStorage() {
+			t.th.SetPieceDeadline(piece, 0, 0)
+		}
+	}
+}
+
+// PrioritizeSeek sets deadlines for pieces, that follow the keyframe before the seek position in seconds,
+// so that they are requested before the player reads them
+func (t *Torrent) PrioritizeSeek(file *File, seconds float64) {
+	t.index.mu.Lock()
+	media := t.index.media
+	if t.index.file != file {
+		media = nil
+	}
+	t.index.mu.Unlock()
+
+	if media == nil || file == nil || t.th == nil || t.Closer.IsSet() {
+		return
+	}
+
+	size := int64(t.FileBitrate(file) * urgentDuration.Seconds())
+	if size < 2*t.pieceLength {
+		size = 2 * t.pieceLength
+	}
+
+	offset := media.KeyframeOffset(seconds)
+	startPiece, endPiece, _, _ := t.getBufferSize(file.Offset, offset, size)
+	log.Infof("Seeking to %.1fs of %s, keyframe at %s, pieces %d-%d", seconds, file.Path, humanize.Bytes(uint64(offset)), startPiece, endPiece)
+
+	t.muAwaitingPieces.Lock()
+	defer t.muAwaitingPieces.Unlock()
+
+	for piece := startPiece; piece <= endPiece; piece++ {
+		if t.hasPiece(piece) {
+			continue
+		}
+
+		t.awaitingPieces.AddInt(piece)
+		t.th.SetPieceDeadline(piece, (piece-startPiece)*100, 0)
+	}
+}
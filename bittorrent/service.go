@@ -15,6 +15,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/anacrolix/missinggo/perf"
@@ -70,7 +71,22 @@ type Service struct {
 
 	MarkedToMove string
 
+	muBandwidth          sync.Mutex
+	bandwidthOverride    *BandwidthProfile
+	limitsLifted         bool
+	limitsBuffering      bool
+	appliedDownloadLimit int
+	appliedUploadLimit   int
+
+	muWatchFolders     sync.Mutex
+	folderWatcher      *watcher.Watcher
+	watchFolderImports map[string]bool
+
+	// spillover is swapped on reconfigure, while memory files read it
+	spillover atomic.Pointer[SpilloverCache]
+
 	alertsBroadcaster *broadcast.Broadcaster
+	eventsBroadcaster *broadcast.Broadcaster
 	Closer            event.Event
 	CloserNotifier    event.Event
 	isShutdown        bool
@@ -96,7 +112,10 @@ func NewService() *Service {
 		SpaceChecked: map[string]bool{},
 		Players:      map[string]*Player{},
 
+		watchFolderImports: map[string]bool{},
+
 		alertsBroadcaster: broadcast.NewBroadcaster(),
+		eventsBroadcaster: broadcast.NewBroadcaster(),
 	}
 
 	s.q = NewQueue(s)
@@ -125,6 +144,9 @@ func NewService() *Service {
 	}()
 	go s.onDownloadProgress()
 
+	s.wg.Add(1)
+	go s.bandwidthScheduler()
+
 	return s
 }
 
@@ -402,6 +424,7 @@ func (s *Service) configure() {
 	}
 
 	log.Infof("DownloadStorage: %s", config.Storages[s.config.DownloadStorage])
+	s.configureSpillover()
 	if s.IsMemoryStorage() {
 		needSize := s.config.BufferSize + int(s.config.EndBufferSize) + 8*1024*1024
 
@@ -509,9 +532,8 @@ func (s *Service) configure() {
 
 	// s.Session.GetHandle().ApplySettings(s.PackSettings)
 
-	if !s.config.LimitAfterBuffering {
-		s.RestoreLimits()
-	}
+	s.resetBandwidthLimits()
+	s.applyBandwidthLimits()
 
 	s.applyCustomSettings()
 }
@@ -543,9 +565,13 @@ func (s *Service) startServices() {
 		s.mappedPorts[p] = s.Session.AddPortMapping(lt.WrappedSessionHandleTcp, port, port)
 		log.Infof("Adding port mapping %v: %v", port, s.mappedPorts[p])
 	}
+
+	s.startWatchFolders()
 }
 
 func (s *Service) stopServices() {
+	s.stopWatchFolders()
+
 	if s.InternalProxy != nil && !s.InternalProxy.IsErrored && s.InternalProxy.Server != nil {
 		log.Infof("Stopping internal proxy")
 		s.InternalProxy.Server.Shutdown(context.Background())
@@ -655,6 +681,12 @@ func (s *Service) checkAvailableSpace(xbmcHost *xbmc.XBMCHost, t *Torrent) bool
 
 // AddTorrent ...
 func (s *Service) AddTorrent(xbmcHost *xbmc.XBMCHost, uri string, paused bool, downloadStorage int, firstTime bool, addedTime time.Time) (*Torrent, error) {
+	return s.AddTorrentWithCategory(xbmcHost, uri, "", paused, downloadStorage, firstTime, addedTime)
+}
+
+// AddTorrentWithCategory adds torrent and assigns it to a category.
+// Empty category keeps the category previously saved for this torrent, if any.
+func (s *Service) AddTorrentWithCategory(xbmcHost *xbmc.XBMCHost, uri string, categoryName string, paused bool, downloadStorage int, firstTime bool, addedTime time.Time) (*Torrent, error) {
 	defer perf.ScopeTimer()()
 
 	// To make sure no spaces coming from Web UI
@@ -751,8 +783,36 @@ func (s *Service) AddTorrent(xbmcHost *xbmc.XBMCHost, uri string, paused bool, d
 		infoHash = hex.EncodeToString([]byte(shaHash))
 	}
 
-	log.Infof("Setting save path to %s", s.config.DownloadPath)
-	torrentParams.SetSavePath(s.config.DownloadPath)
+	storedSavePath := ""
+	if item := database.GetStorm().GetBTItem(infoHash); item != nil {
+		if categoryName == "" {
+			categoryName = item.Category
+		}
+		storedSavePath = item.SavePath
+	}
+	category := database.GetStorm().GetCategory(categoryName)
+	if categoryName != "" && category == nil {
+		log.Warningf("Category %s does not exist, ignoring it", categoryName)
+		categoryName = ""
+	}
+
+	// Libtorrent prefers save path of add_torrent_params over the one in resume data,
+	// so reloaded torrents get the path, they were added to, and category path is used for new torrents only
+	savePath := s.config.DownloadPath
+	if downloadStorage != config.StorageMemory {
+		if storedSavePath != "" {
+			savePath = storedSavePath
+		} else if firstTime && category != nil && category.DownloadPath != "" {
+			if err := util.IsWritablePath(category.DownloadPath); err != nil {
+				log.Warningf("Cannot use download path of category %s: %s", categoryName, err)
+			} else {
+				savePath = category.DownloadPath
+			}
+		}
+	}
+
+	log.Infof("Setting save path to %s", savePath)
+	torrentParams.SetSavePath(savePath)
 
 	skipPriorities := false
 	if downloadStorage != config.StorageMemory {
@@ -811,7 +871,7 @@ func (s *Service) AddTorrent(xbmcHost *xbmc.XBMCHost, uri string, paused bool, d
 			trackers := lt.NewStdVectorAnnounceEntry()
 			defer lt.DeleteStdVectorAnnounceEntry(trackers)
 
-			for _, tracker := range originalTrackers {
+			for _, tracker := range filterTrackers(originalTrackers) {
 				announceEntry := lt.NewAnnounceEntry(tracker)
 				defer lt.DeleteAnnounceEntry(announceEntry)
 				trackers.Add(announceEntry)
@@ -820,7 +880,7 @@ func (s *Service) AddTorrent(xbmcHost *xbmc.XBMCHost, uri string, paused bool, d
 			th.ReplaceTrackers(trackers)
 		}
 
-		if len(extraTrackers) > 0 && config.Get().AddExtraTrackers != addExtraTrackersNone {
+		if extraTrackers := getExtraTrackers(); len(extraTrackers) > 0 && config.Get().AddExtraTrackers != addExtraTrackersNone {
 			for _, tracker := range extraTrackers {
 				if tracker == "" {
 					continue
@@ -848,6 +908,20 @@ func (s *Service) AddTorrent(xbmcHost *xbmc.XBMCHost, uri string, paused bool, d
 	}
 
 	t.addedTime = addedTime
+	t.savePath = savePath
+	if categoryName != "" {
+		if err := database.GetStorm().UpdateBTItemCategory(infoHash, categoryName); err != nil {
+			log.Warningf("Could not save category for %s: %s", infoHash, err)
+		}
+	}
+	if downloadStorage != config.StorageMemory && savePath != storedSavePath {
+		if err := database.GetStorm().UpdateBTItemSavePath(infoHash, savePath); err != nil {
+			log.Warningf("Could not save save path for %s: %s", infoHash, err)
+		}
+	}
+	t.FetchDBItem()
+	t.ApplyRateLimits(s.IsStreaming())
+	t.restoreTrackers()
 	s.q.Add(t)
 
 	if !t.HasMetadata() {
@@ -926,6 +1000,10 @@ func (s *Service) RemoveTorrent(xbmcHost *xbmc.XBMCHost, t *Torrent, forceDrop,
 		s.q.Delete(t)
 
 		t.Drop(deleteTorrentFiles, deleteTorrentData)
+
+		if c := s.spillover.Load(); c != nil {
+			c.RemoveTorrent(t.InfoHash())
+		}
 	}
 
 	return true
@@ -1090,18 +1168,71 @@ func (s *Service) onAlertsConsumer() {
 							t.gotMetainfo.Set()
 						}
 					}
+					s.publishStateEvent(metadataAlert.GetHandle(), ltAlert.What())
+				case lt.StateChangedAlertAlertType:
+					s.publishStateEvent(lt.SwigcptrStateChangedAlert(alertPtr).GetHandle(), ltAlert.What())
+				case lt.TorrentPausedAlertAlertType:
+					s.publishStateEvent(lt.SwigcptrTorrentPausedAlert(alertPtr).GetHandle(), ltAlert.What())
+				case lt.TorrentResumedAlertAlertType:
+					s.publishStateEvent(lt.SwigcptrTorrentResumedAlert(alertPtr).GetHandle(), ltAlert.What())
 				case lt.TrackerReplyAlertAlertType:
 					ta := lt.SwigcptrTrackerReplyAlert(alertPtr)
 					for _, t := range s.q.All() {
 						if t.th != nil && ta.GetHandle().Equal(t.th) {
-							t.trackers.Store(ta.TrackerUrl(), ta.GetNumPeers())
+							trackerAnnounced(ta.TrackerUrl())
+							t.updateTrackerState(ta.TrackerUrl(), func(st *trackerState) {
+								st.lastAnnounce = time.Now()
+								st.numPeers = ta.GetNumPeers()
+								st.lastError = ""
+								st.warning = ""
+							})
+						}
+					}
+				case lt.TrackerErrorAlertAlertType:
+					ta := lt.SwigcptrTrackerErrorAlert(alertPtr)
+					infoHash := ""
+					for _, t := range s.q.All() {
+						if t.th != nil && ta.GetHandle().Equal(t.th) {
+							infoHash = t.infoHash
+							t.updateTrackerState(ta.TrackerUrl(), func(st *trackerState) {
+								st.lastError = alertMessage
+								st.lastErrorAt = time.Now()
+							})
+						}
+					}
+					// Failure reason, like "unregistered torrent", is a reply of working tracker
+					if ta.ErrorMessage() != "" {
+						trackerAnnounced(ta.TrackerUrl())
+					} else if trackerFailed(ta.TrackerUrl(), infoHash, alertMessage) {
+						s.pruneTracker(ta.TrackerUrl())
+					}
+				case lt.TrackerWarningAlertAlertType:
+					ta := lt.SwigcptrTrackerWarningAlert(alertPtr)
+					for _, t := range s.q.All() {
+						if t.th != nil && ta.GetHandle().Equal(t.th) {
+							t.updateTrackerState(ta.TrackerUrl(), func(st *trackerState) {
+								st.warning = alertMessage
+							})
+						}
+					}
+				case lt.ScrapeReplyAlertAlertType:
+					ta := lt.SwigcptrScrapeReplyAlert(alertPtr)
+					for _, t := range s.q.All() {
+						if t.th != nil && ta.GetHandle().Equal(t.th) {
+							t.updateTrackerState(ta.TrackerUrl(), func(st *trackerState) {
+								st.seeds = ta.GetComplete()
+								st.peers = ta.GetIncomplete()
+							})
 						}
 					}
 				case lt.DhtReplyAlertAlertType:
 					ta := lt.SwigcptrDhtReplyAlert(alertPtr)
 					for _, t := range s.q.All() {
 						if t.th != nil && ta.GetHandle().Equal(t.th) {
-							t.trackers.Store("DHT", ta.GetNumPeers())
+							t.updateTrackerState(dhtTrackerName, func(st *trackerState) {
+								st.lastAnnounce = time.Now()
+								st.numPeers = ta.GetNumPeers()
+							})
 						}
 					}
 				case lt.TorrentFinishedAlertAlertType:
@@ -1111,6 +1242,7 @@ func (s *Service) onAlertsConsumer() {
 							go t.AlertFinished()
 						}
 					}
+					s.publishStateEvent(ta.GetHandle(), ltAlert.What())
 				}
 
 				alert := &Alert{
@@ -1295,9 +1427,12 @@ func (s *Service) onDownloadProgress() {
 				return
 			}
 
+			s.applyRateLimits()
+
 			var totalDownloadRate float64
 			var totalUploadRate float64
 			var totalProgress int
+			var totalTorrents int
 
 			activeTorrents := make([]*activeTorrent, 0)
 			torrentsVector := s.Session.GetTorrents()
@@ -1324,21 +1459,31 @@ func (s *Service) onDownloadProgress() {
 				isPaused := ts.GetPaused()
 
 				t := s.GetTorrentByHash(infoHash)
-				if t != nil {
-					statusCode := t.GetSmartState()
-					status = StatusStrings[statusCode]
-				} else {
+				if t == nil {
 					continue
 				}
+				statusCode := t.GetSmartState()
+				status = StatusStrings[statusCode]
 
 				downloadRate := float64(ts.GetDownloadPayloadRate())
 				uploadRate := float64(ts.GetUploadPayloadRate())
 				totalDownloadRate += downloadRate
 				totalUploadRate += uploadRate
+				totalTorrents++
 
 				torrentName := ts.GetName()
 				progress := int(float64(ts.GetProgress()) * 100)
 
+				s.PublishEvent(EventTorrentProgress, infoHash, &TorrentProgressEvent{
+					Name:         torrentName,
+					State:        statusCode,
+					StateName:    StatusName(statusCode),
+					Progress:     float64(ts.GetProgress()) * 100,
+					DownloadRate: int(downloadRate),
+					UploadRate:   int(uploadRate),
+					Paused:       isPaused,
+				})
+
 				if progress < 100 && !isPaused {
 					activeTorrents = append(activeTorrents, &activeTorrent{
 						torrentName:  torrentName,
@@ -1356,8 +1501,9 @@ func (s *Service) onDownloadProgress() {
 					seedingTime = finishedTime
 				}
 
-				if !t.IsMemoryStorage() && s.config.SeedTimeLimit > 0 && !s.config.SeedForever {
-					if seedingTime >= s.config.SeedTimeLimit {
+				seedTimeLimit, seedTimeRatioLimit, shareRatioLimit := t.GetSeedLimits()
+				if !t.IsMemoryStorage() && seedTimeLimit > 0 && !s.config.SeedForever {
+					if seedingTime >= seedTimeLimit {
 						if !isPaused {
 							log.Warningf("Seeding time limit reached, pausing %s", torrentName)
 							torrentHandle.AutoManaged(false)
@@ -1367,13 +1513,13 @@ func (s *Service) onDownloadProgress() {
 						status = StatusStrings[StatusSeeding]
 					}
 				}
-				if !t.IsMemoryStorage() && s.config.SeedTimeRatioLimit > 0 && !s.config.SeedForever {
+				if !t.IsMemoryStorage() && seedTimeRatioLimit > 0 && !s.config.SeedForever {
 					timeRatio := 0
 					downloadTime := ts.GetActiveTime() - seedingTime
 					if downloadTime > 1 {
 						timeRatio = seedingTime * 100 / downloadTime
 					}
-					if timeRatio >= s.config.SeedTimeRatioLimit {
+					if timeRatio >= seedTimeRatioLimit {
 						if !isPaused {
 							log.Warningf("Seeding time ratio reached, pausing %s", torrentName)
 							torrentHandle.AutoManaged(false)
@@ -1383,13 +1529,13 @@ func (s *Service) onDownloadProgress() {
 						status = StatusStrings[StatusSeeding]
 					}
 				}
-				if !t.IsMemoryStorage() && s.config.ShareRatioLimit > 0 && !s.config.SeedForever {
+				if !t.IsMemoryStorage() && shareRatioLimit > 0 && !s.config.SeedForever {
 					ratio := int64(0)
 					allTimeDownload := ts.GetAllTimeDownload()
 					if allTimeDownload > 0 {
 						ratio = ts.GetAllTimeUpload() * 100 / allTimeDownload
 					}
-					if ratio >= int64(s.config.ShareRatioLimit) {
+					if ratio >= int64(shareRatioLimit) {
 						if !isPaused {
 							log.Warningf("Share ratio reached, pausing %s", torrentName)
 							torrentHandle.AutoManaged(false)
@@ -1425,15 +1571,30 @@ func (s *Service) onDownloadProgress() {
 						return fmt.Errorf("Torrent not found with infohash: %s", infoHash)
 					}
 
+					category := database.GetStorm().GetCategory(item.Category)
+					completedPath := ""
+					if category != nil && category.CompletedPath != "" {
+						completedPath = category.CompletedPath
+					}
+
 					errMsg := fmt.Sprintf("Missing item type to move files to completed folder for %s", torrentName)
-					if item.Type == "" {
+					if item.Type == "" && completedPath == "" {
 						log.Error(errMsg)
 						return errors.New(errMsg)
 					}
 					log.Warning(torrentName, "finished seeding, moving files...")
 
 					// Check paths are valid and writable, and only once
-					if _, exists := pathChecked[item.Type]; !exists {
+					if completedPath != "" {
+						if _, exists := pathChecked[completedPath]; !exists {
+							pathChecked[completedPath] = true
+							if err := util.IsWritablePath(completedPath); err != nil {
+								warnedMissing[infoHash] = true
+								log.Error(err)
+								return err
+							}
+						}
+					} else if _, exists := pathChecked[item.Type]; !exists {
 						if item.Type == "movie" {
 							if err := util.IsWritablePath(s.config.CompletedMoviesPath); err != nil {
 								warnedMissing[infoHash] = true
@@ -1455,10 +1616,11 @@ func (s *Service) onDownloadProgress() {
 
 					log.Info("Removing the torrent without deleting files after Completed move ...")
 					t := s.GetTorrentByHash(infoHash)
+					savePath := t.GetSavePath()
 					s.RemoveTorrent(xbmcHost, t, false, false, false)
 
 					// Delete leftover .parts file if any
-					partsFile := filepath.Join(config.Get().DownloadPath, fmt.Sprintf(".%s.parts", infoHash))
+					partsFile := filepath.Join(savePath, fmt.Sprintf(".%s.parts", infoHash))
 					os.Remove(partsFile)
 
 					// Delete fast resume data
@@ -1495,7 +1657,7 @@ func (s *Service) onDownloadProgress() {
 						extracted := ""
 						re := regexp.MustCompile(`(?i).*\.rar$`)
 						if re.MatchString(fileName) {
-							extractedPath := filepath.Join(s.config.DownloadPath, filepath.Dir(filePath), "extracted")
+							extractedPath := filepath.Join(savePath, filepath.Dir(filePath), "extracted")
 							files, err := os.ReadDir(extractedPath)
 							if err != nil {
 								return err
@@ -1522,8 +1684,16 @@ func (s *Service) onDownloadProgress() {
 						var dstPath string
 						if item.Type == "movie" {
 							dstPath = filepath.Dir(s.config.CompletedMoviesPath)
+							if completedPath != "" {
+								dstPath = completedPath
+							}
+						} else if item.Type == "" {
+							dstPath = completedPath
 						} else {
 							dstPath = filepath.Dir(s.config.CompletedShowsPath)
+							if completedPath != "" {
+								dstPath = completedPath
+							}
 							if item.ShowID > 0 {
 								show := tmdb.GetShow(item.ShowID, config.Get().Language)
 								if show != nil {
@@ -1540,7 +1710,7 @@ func (s *Service) onDownloadProgress() {
 
 						go func() {
 							log.Infof("Moving %s to %s", fileName, dstPath)
-							srcPath := filepath.Join(s.config.DownloadPath, filePath)
+							srcPath := filepath.Join(savePath, filePath)
 							if dst, err := util.Move(srcPath, dstPath); err != nil {
 								log.Error(err)
 							} else {
@@ -1549,7 +1719,7 @@ func (s *Service) onDownloadProgress() {
 									os.RemoveAll(filepath.Dir(srcPath))
 									if extracted != "" {
 										parentPath := filepath.Clean(filepath.Join(filepath.Dir(srcPath), ".."))
-										if parentPath != "." && parentPath != s.config.DownloadPath {
+										if parentPath != "." && parentPath != savePath {
 											os.RemoveAll(parentPath)
 										}
 									}
@@ -1565,6 +1735,13 @@ func (s *Service) onDownloadProgress() {
 				}()
 			}
 
+			s.PublishEvent(EventSessionProgress, "", &SessionProgressEvent{
+				DownloadRate: int(totalDownloadRate),
+				UploadRate:   int(totalUploadRate),
+				Torrents:     totalTorrents,
+				Paused:       s.Session.IsPaused(),
+			})
+
 			totalActive := len(activeTorrents)
 			if totalActive > 0 {
 				showProgress := totalProgress / totalActive
@@ -1596,6 +1773,27 @@ func (s *Service) onDownloadProgress() {
 	}
 }
 
+// IsStreaming returns true if any torrent is currently read by a player or any other client
+func (s *Service) IsStreaming() bool {
+	for _, t := range s.q.All() {
+		if t != nil && !t.Closer.IsSet() && t.HasActiveReaders() {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRateLimits refreshes per-torrent caps, so that background torrents
+// do not take bandwidth from torrents that are currently streamed
+func (s *Service) applyRateLimits() {
+	streaming := s.IsStreaming()
+	for _, t := range s.q.All() {
+		if t != nil {
+			t.ApplyRateLimits(streaming)
+		}
+	}
+}
+
 // SetDownloadLimit ...
 func (s *Service) SetDownloadLimit(i int) {
 	settings := s.PackSettings
@@ -1612,32 +1810,24 @@ func (s *Service) SetUploadLimit(i int) {
 	s.Session.ApplySettings(settings)
 }
 
-// RestoreLimits ...
+// RestoreLimits applies limits of the active bandwidth profile
 func (s *Service) RestoreLimits() {
-	if s.config.DownloadRateLimit > 0 {
-		s.SetDownloadLimit(s.config.DownloadRateLimit)
-		log.Infof("Rate limiting download to %s", humanize.Bytes(uint64(s.config.DownloadRateLimit)))
-	} else {
-		s.SetDownloadLimit(0)
-	}
+	s.setLimitsLifted(false, false)
+}
 
-	// if s.config.DisableUpload {
-	// 	s.SetUploadLimit(1)
-	// 	log.Infof("Rate limiting upload to %d byte, due to disabled upload", 1)
-	// } else if s.config.UploadRateLimit > 0 {
-	if s.config.UploadRateLimit > 0 {
-		s.SetUploadLimit(s.config.UploadRateLimit)
-		log.Infof("Rate limiting upload to %s", humanize.Bytes(uint64(s.config.UploadRateLimit)))
-	} else {
-		s.SetUploadLimit(0)
+// SetBufferingLimits lifts limits for buffering, if LimitAfterBuffering is enabled
+func (s *Service) SetBufferingLimits() {
+	if s.config.LimitAfterBuffering {
+		log.Info("Resetting rate limits for buffering")
+		s.setLimitsLifted(true, true)
 	}
 }
 
-// SetBufferingLimits ...
-func (s *Service) SetBufferingLimits() {
+// LiftLimits lifts default limits after playback, if LimitAfterBuffering is enabled
+func (s *Service) LiftLimits() {
 	if s.config.LimitAfterBuffering {
-		s.SetDownloadLimit(0)
-		log.Info("Resetting rate limited download for buffering")
+		log.Info("Resetting rate limits after playback")
+		s.setLimitsLifted(true, false)
 	}
 }
 
@@ -1673,9 +1863,16 @@ func (s *Service) PlayerStop() {
 	log.Debugf("PlayerStop")
 }
 
-// PlayerSeek ...
-func (s *Service) PlayerSeek() {
-	log.Debugf("PlayerSeek")
+// PlayerSeek prioritizes pieces at the keyframe before the seek position of the active player
+func (s *Service) PlayerSeek(position float64) {
+	log.Debugf("PlayerSeek to %.1fs", position)
+
+	p := s.GetActivePlayer()
+	if p == nil || p.t == nil {
+		return
+	}
+
+	p.t.PrioritizeSeek(p.chosenFile, position)
 }
 
 // ClientInfo ...
@@ -1715,6 +1912,9 @@ func (s *Service) AttachPlayer(p *Player) {
 	}
 
 	s.Players[p.t.InfoHash()] = p
+
+	go s.publishPlayerEvent(EventPlayerAttached, p)
+	go s.applyRateLimits()
 }
 
 // DetachPlayer removes Player instance
@@ -1733,6 +1933,9 @@ func (s *Service) DetachPlayer(p *Player) {
 	}
 
 	delete(s.Players, p.t.InfoHash())
+
+	go s.publishPlayerEvent(EventPlayerDetached, p)
+	go s.applyRateLimits()
 }
 
 // GetPlayer searches for player with desired TMDB id
@@ -1920,13 +2123,20 @@ func (s *Service) GetListenIP(network string) string {
 	return s.ListenIP
 }
 
-// GetMemoryStats returns total and free memory sizes for this OS
-func (s *Service) GetMemoryStats() (int64, int64) {
+// GetMemoryStats returns total and free memory sizes for this OS,
+// and usage of spillover cache, if it is enabled for memory storage
+func (s *Service) GetMemoryStats() MemoryStats {
+	ret := MemoryStats{}
 	if v, err := mem.VirtualMemory(); v != nil && err == nil {
-		return int64(v.Total), int64(v.Free)
+		ret.Total = int64(v.Total)
+		ret.Free = int64(v.Free)
+	}
+	if c := s.spillover.Load(); c != nil {
+		stats := c.Stats()
+		ret.Spillover = &stats
 	}
 
-	return 0, 0
+	return ret
 }
 
 // IsMemoryStorage is a shortcut for checking whether we run memory storage
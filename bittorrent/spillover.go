@@ -0,0 +1,260 @@
+package bittorrent
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+)
+
+var errPieceHashMismatch = errors.New("Piece hash does not match")
+
+// SpilloverCache keeps pieces, read from memory storage, in files on disk, limited by size
+// and evicted in LRU order. Memory storage drops pieces, that are behind readers, so after rewinding
+// they are taken from this cache, instead of downloading them again.
+type SpilloverCache struct {
+	dir      string
+	capacity int64
+
+	mu    sync.Mutex
+	size  int64
+	lru   *list.List
+	items map[spilloverKey]*list.Element
+
+	hits    int64
+	misses  int64
+	stored  int64
+	evicted int64
+}
+
+// MemoryStats describes system memory and spillover cache usage, Spillover is nil if the cache is disabled
+type MemoryStats struct {
+	Total     int64
+	Free      int64
+	Spillover *SpilloverStats
+}
+
+// SpilloverStats describes spillover cache usage
+type SpilloverStats struct {
+	Capacity int64
+	Size     int64
+	Pieces   int
+	Hits     int64
+	Misses   int64
+	Stored   int64
+	Evicted  int64
+}
+
+type spilloverKey struct {
+	infoHash string
+	piece    int
+}
+
+type spilloverItem struct {
+	key  spilloverKey
+	size int64
+}
+
+// NewSpilloverCache creates cache in given directory, pieces, left from previous runs, are removed
+func NewSpilloverCache(dir string, capacity int64) *SpilloverCache {
+	if err := os.RemoveAll(dir); err != nil {
+		log.Warningf("Could not clean spillover cache in %s: %s", dir, err)
+	}
+
+	log.Infof("Using spillover cache of %s in %s", humanize.Bytes(uint64(capacity)), dir)
+	return &SpilloverCache{
+		dir:      dir,
+		capacity: capacity,
+		lru:      list.New(),
+		items:    map[spilloverKey]*list.Element{},
+	}
+}
+
+// configureSpillover creates cache for memory storage, existing cache is kept on reconfigure,
+// as creating it again removes stored pieces
+func (s *Service) configureSpillover() {
+	if !s.IsMemoryStorage() || s.config.SpilloverCacheSize <= 0 {
+		s.spillover.Store(nil)
+		return
+	}
+
+	dir := filepath.Join(s.config.TemporaryPath, "elementum_spillover")
+	capacity := int64(s.config.SpilloverCacheSize)
+	if c := s.spillover.Load(); c != nil && c.dir == dir {
+		c.SetCapacity(capacity)
+		return
+	}
+
+	s.spillover.Store(NewSpilloverCache(dir, capacity))
+}
+
+func (c *SpilloverCache) path(key spilloverKey) string {
+	return filepath.Join(c.dir, key.infoHash, strconv.Itoa(key.piece))
+}
+
+// Has tells whether piece of the torrent is in the cache
+func (c *SpilloverCache) Has(infoHash string, piece int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.items[spilloverKey{infoHash, piece}]
+	return ok
+}
+
+// ReadAt reads piece data from given offset, -1 is returned if piece is not cached
+func (c *SpilloverCache) ReadAt(infoHash string, piece int, b []byte, offset int) int {
+	key := spilloverKey{infoHash, piece}
+
+	c.mu.Lock()
+	e, ok := c.items[key]
+	if !ok {
+		c.misses++
+		c.mu.Unlock()
+		return -1
+	}
+	c.lru.MoveToFront(e)
+	c.hits++
+	c.mu.Unlock()
+
+	f, err := os.Open(c.path(key))
+	if err != nil {
+		c.remove(key)
+		return -1
+	}
+	defer f.Close()
+
+	n, err := f.ReadAt(b, int64(offset))
+	if err != nil && n < len(b) && int64(offset+n) < e.Value.(*spilloverItem).size {
+		log.Warningf("Could not read piece %d from spillover cache: %s", piece, err)
+		c.remove(key)
+		return -1
+	}
+	return n
+}
+
+// Store verifies piece data by its hash from torrent metadata, and writes it to the cache
+func (c *SpilloverCache) Store(infoHash string, piece int, data []byte, hash string) error {
+	if sum := sha1.Sum(data); string(sum[:]) != hash {
+		return errPieceHashMismatch
+	}
+
+	key := spilloverKey{infoHash, piece}
+	if c.Has(infoHash, piece) || int64(len(data)) > c.getCapacity() {
+		return nil
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Piece is written to a temporary file, so that readers never see partially written pieces
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		os.Remove(path + ".tmp")
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[key]; ok {
+		return nil
+	}
+	c.items[key] = c.lru.PushFront(&spilloverItem{key: key, size: int64(len(data))})
+	c.size += int64(len(data))
+	c.stored++
+
+	for c.size > c.capacity {
+		c.removeElement(c.lru.Back())
+		c.evicted++
+	}
+	return nil
+}
+
+// RemoveTorrent drops all pieces of the torrent
+func (c *SpilloverCache) RemoveTorrent(infoHash string) {
+	c.mu.Lock()
+	for key, e := range c.items {
+		if key.infoHash == infoHash {
+			c.lru.Remove(e)
+			delete(c.items, key)
+			c.size -= e.Value.(*spilloverItem).size
+		}
+	}
+	c.mu.Unlock()
+
+	os.RemoveAll(filepath.Join(c.dir, infoHash))
+}
+
+// SetCapacity changes cache size, pieces over the new size are evicted
+func (c *SpilloverCache) SetCapacity(capacity int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.capacity == capacity {
+		return
+	}
+
+	log.Infof("Resizing spillover cache to %s", humanize.Bytes(uint64(capacity)))
+	c.capacity = capacity
+	for c.size > c.capacity && c.lru.Len() > 0 {
+		c.removeElement(c.lru.Back())
+		c.evicted++
+	}
+}
+
+// Stats returns current cache usage
+func (c *SpilloverCache) Stats() SpilloverStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return SpilloverStats{
+		Capacity: c.capacity,
+		Size:     c.size,
+		Pieces:   len(c.items),
+		Hits:     c.hits,
+		Misses:   c.misses,
+		Stored:   c.stored,
+		Evicted:  c.evicted,
+	}
+}
+
+func (c *SpilloverCache) getCapacity() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.capacity
+}
+
+func (c *SpilloverCache) remove(key spilloverKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.removeElement(e)
+	}
+}
+
+// removeElement drops cached piece, should be called with mu locked
+func (c *SpilloverCache) removeElement(e *list.Element) {
+	item := e.Value.(*spilloverItem)
+
+	c.lru.Remove(e)
+	delete(c.items, item.key)
+	c.size -= item.size
+
+	os.Remove(c.path(item.key))
+}
+
+// hasSpilledPiece tells whether memory storage torrent has piece in spillover cache
+func (t *Torrent) hasSpilledPiece(piece int) bool {
+	c := t.Service.spillover.Load()
+	return c != nil && t.IsMemoryStorage() && c.Has(t.InfoHash(), piece)
+}
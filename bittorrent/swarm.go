@@ -0,0 +1,209 @@
+package bittorrent
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/missinggo/perf"
+
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/proxy"
+	"github.com/elgatito/elementum/tracker"
+	"github.com/elgatito/elementum/util/ident"
+)
+
+const (
+	swarmHealthKey = "swarm.%s"
+	// Swarm numbers change slowly, so they are reused by following searches for this many seconds
+	swarmHealthTTL = 30 * 60
+
+	// Torrents can have dozens of extra trackers, only first of them are asked
+	maxScrapedTrackers = 10
+	maxScrapeWorkers   = 16
+	// Trackers without scrape support are announced for a few torrents only
+	maxAnnouncedHashes = 5
+)
+
+// SwarmHealth is a number of seeds and peers of a torrent, reported by its trackers
+type SwarmHealth struct {
+	Seeds    int64 `json:"seeds"`
+	Peers    int64 `json:"peers"`
+	Trackers int   `json:"trackers"`
+}
+
+func (h *SwarmHealth) merge(seeds, peers int64) {
+	// Trackers share peers, so the biggest numbers are taken instead of summing them
+	if seeds > h.Seeds {
+		h.Seeds = seeds
+	}
+	if peers > h.Peers {
+		h.Peers = peers
+	}
+	h.Trackers++
+}
+
+func (h *SwarmHealth) apply(t *TorrentFile) {
+	if h.Seeds > t.Seeds {
+		t.Seeds = h.Seeds
+	}
+	if h.Peers > t.Peers {
+		t.Peers = h.Peers
+	}
+}
+
+// ScrapeTorrents raises seeds and peers of torrent files to numbers, reported by their UDP and HTTP trackers.
+// Numbers are cached by info hash, trackers, that did not respond within timeout, are ignored.
+// Numbers of providers are never lowered, as trackers can not know DHT peers.
+func ScrapeTorrents(torrents []*TorrentFile, timeout time.Duration) {
+	if !config.Get().ScrapeTrackers || len(torrents) == 0 {
+		return
+	}
+
+	defer perf.ScopeTimer()()
+
+	cacheDB := database.GetCache()
+
+	byHash := map[[20]byte][]*TorrentFile{}
+	byTracker := map[string][][20]byte{}
+	for _, t := range torrents {
+		b, err := hex.DecodeString(t.InfoHash)
+		if err != nil || len(b) != 20 {
+			continue
+		}
+
+		var infoHash [20]byte
+		copy(infoHash[:], b)
+
+		health := &SwarmHealth{}
+		if err := cacheDB.GetCachedObject(database.CommonBucket, fmt.Sprintf(swarmHealthKey, t.InfoHash), health); err == nil && health.Trackers > 0 {
+			health.apply(t)
+			continue
+		}
+
+		if _, ok := byHash[infoHash]; !ok {
+			trackers := 0
+			for _, tr := range t.Trackers {
+				if tr == "" || trackers >= maxScrapedTrackers {
+					continue
+				}
+
+				byTracker[tr] = append(byTracker[tr], infoHash)
+				trackers++
+			}
+		}
+		byHash[infoHash] = append(byHash[infoHash], t)
+	}
+	if len(byTracker) == 0 {
+		return
+	}
+
+	var mu sync.Mutex
+	results := map[[20]byte]*SwarmHealth{}
+
+	report := func(infoHash [20]byte, seeds, peers int64) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// UDP trackers report zeros for torrents they do not track
+		if results == nil || (seeds == 0 && peers == 0) {
+			return
+		}
+		if _, ok := results[infoHash]; !ok {
+			results[infoHash] = &SwarmHealth{}
+		}
+		results[infoHash].merge(seeds, peers)
+	}
+
+	peerID := [20]byte{}
+	copy(peerID[:], ident.PeerIDRandom(ident.DefaultPeerID()))
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < maxScrapeWorkers && i < len(byTracker); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for url := range queue {
+				scrapeTracker(url, byTracker[url], peerID, timeout, report)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for url := range byTracker {
+			queue <- url
+		}
+		close(queue)
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Debugf("Scraping trackers timed out after %s", timeout)
+	}
+
+	mu.Lock()
+	scraped := results
+	// Trackers, that respond later, are not reported anymore
+	results = nil
+	mu.Unlock()
+
+	for infoHash, health := range scraped {
+		for _, t := range byHash[infoHash] {
+			health.apply(t)
+		}
+
+		if err := cacheDB.SetCachedObject(database.CommonBucket, swarmHealthTTL, fmt.Sprintf(swarmHealthKey, hex.EncodeToString(infoHash[:])), health); err != nil {
+			log.Debugf("Could not cache swarm health: %s", err)
+		}
+	}
+
+	log.Infof("Scraped %d of %d torrents from %d trackers", len(scraped), len(byHash), len(byTracker))
+}
+
+// scrapeTracker scrapes info hashes, or announces them for trackers, that do not support scrape
+func scrapeTracker(url string, infoHashes [][20]byte, peerID [20]byte, timeout time.Duration, report func([20]byte, int64, int64)) {
+	tr, err := tracker.New(url, proxy.GetClient(), timeout)
+	if err != nil {
+		return
+	}
+	defer tr.Close()
+
+	entries, err := tr.Scrape(infoHashes)
+	for infoHash, entry := range entries {
+		report(infoHash, int64(entry.Seeders), int64(entry.Leechers))
+	}
+	if err == nil {
+		return
+	} else if err != tracker.ErrScrapeNotSupported {
+		log.Debugf("Could not scrape %s: %s", url, err)
+		return
+	}
+
+	for i, infoHash := range infoHashes {
+		if i >= maxAnnouncedHashes {
+			break
+		}
+
+		// Stopped event gets swarm numbers without adding us to the swarm
+		resp, err := tr.Announce(&tracker.AnnounceRequest{
+			InfoHash: infoHash,
+			PeerID:   peerID,
+			Left:     1,
+			Event:    tracker.EventStopped,
+			Port:     uint16(config.Get().ListenPortMin),
+		})
+		if err != nil {
+			log.Debugf("Could not announce to %s: %s", url, err)
+			return
+		}
+		report(infoHash, int64(resp.Seeders), int64(resp.Leechers))
+	}
+}
@@ -49,8 +49,12 @@ type Torrent struct {
 	partsFile         string
 	memoryStorageFile string
 	fileStorageFile   string
-	addedTime         time.Time
-	DownloadStorage   int
+	savePath          string
+
+	appliedDownloadLimit int
+	appliedUploadLimit   int
+	addedTime            time.Time
+	DownloadStorage      int
 
 	title              string
 	name               string
@@ -59,12 +63,18 @@ type Torrent struct {
 	reservedPieces     []int
 	lastPrioritization string
 	trackers           sync.Map
+	fileDurations      sync.Map
+	index              seekIndex
+	rarArchives        sync.Map
 
 	awaitingPieces   *roaring.Bitmap
 	demandPieces     *roaring.Bitmap
 	muAwaitingPieces *sync.RWMutex
 	muDemandPieces   *sync.RWMutex
 
+	playlist   []*File
+	muPlaylist *sync.RWMutex
+
 	ChosenFiles []*File
 
 	Service *Service
@@ -89,6 +99,9 @@ type Torrent struct {
 
 	DBItem *database.BTItem
 
+	category   *database.Category
+	muCategory *sync.Mutex
+
 	mu        *sync.Mutex
 	muBuffer  *sync.RWMutex
 	muReaders *sync.Mutex
@@ -131,6 +144,10 @@ func NewTorrent(service *Service, handle lt.TorrentHandle, info lt.TorrentInfo,
 		torrentFile:     path,
 		DownloadStorage: downloadStorage,
 
+		// Force applying rate limits, as resume data can have own limits
+		appliedDownloadLimit: -1,
+		appliedUploadLimit:   -1,
+
 		readers:        map[int64]*TorrentFSEntry{},
 		reservedPieces: []int{},
 
@@ -146,6 +163,8 @@ func NewTorrent(service *Service, handle lt.TorrentHandle, info lt.TorrentInfo,
 		muAwaitingPieces: &sync.RWMutex{},
 		muDemandPieces:   &sync.RWMutex{},
 		muStatus:         &sync.Mutex{},
+		muPlaylist:       &sync.RWMutex{},
+		muCategory:       &sync.Mutex{},
 	}
 
 	return t
@@ -294,6 +313,17 @@ func (t *Torrent) bufferTickerEvent() {
 			t.BufferProgress = thisProgress
 		}
 
+		downRate, upRate := t.GetSpeeds()
+		t.Service.PublishEvent(EventBuffering, t.InfoHash(), &BufferingEvent{
+			Name:         t.Name(),
+			Progress:     math.Min(t.BufferProgress, 100),
+			DownloadRate: downRate,
+			UploadRate:   upRate,
+			Seeds:        seeds,
+			Peers:        peers,
+			Finished:     t.BufferProgress >= 100,
+		})
+
 		if t.BufferProgress >= 100 {
 			t.bufferFinished <- struct{}{}
 		} else {
@@ -359,6 +389,16 @@ func (t *Torrent) bufferFinishedEvent() {
 // another for a piece of file from the end (probably to get codec descriptors and so on)
 // We set it as post-buffer and include in required buffer pieces array.
 func (t *Torrent) Buffer(file *File, isStartup bool) {
+	if file == nil {
+		t.bufferFinishedEvent()
+		return
+	}
+
+	t.bufferRange(file, file.Offset, file.Size, isStartup)
+}
+
+// bufferRange buffers start and end of media data, that takes size bytes from offset in the torrent
+func (t *Torrent) bufferRange(file *File, offset, size int64, isStartup bool) {
 	if file == nil || t.Closer.IsSet() {
 		t.bufferFinishedEvent()
 		return
@@ -369,8 +409,8 @@ func (t *Torrent) Buffer(file *File, isStartup bool) {
 	t.startBufferTicker()
 
 	startBufferSize := t.Service.GetBufferSize()
-	preBufferStart, preBufferEnd, preBufferOffset, preBufferSize := t.getBufferSize(file.Offset, 0, startBufferSize)
-	postBufferStart, postBufferEnd, postBufferOffset, postBufferSize := t.getBufferSize(file.Offset, file.Size-int64(config.Get().EndBufferSize), int64(config.Get().EndBufferSize))
+	preBufferStart, preBufferEnd, preBufferOffset, preBufferSize := t.getBufferSize(offset, 0, startBufferSize)
+	postBufferStart, postBufferEnd, postBufferOffset, postBufferSize := t.getBufferSize(offset, size-int64(config.Get().EndBufferSize), int64(config.Get().EndBufferSize))
 
 	// TODO: Remove this piece of buffer adjustment?
 	// if config.Get().AutoAdjustBufferSize && preBufferEnd-preBufferStart < 10 {
@@ -407,7 +447,7 @@ func (t *Torrent) Buffer(file *File, isStartup bool) {
 		// Try to increase memory size to at most 25 pieces to have more comfortable playback.
 		// Also check for free memory to avoid spending too much!
 		if config.Get().AutoAdjustMemorySize {
-			_, free := t.Service.GetMemoryStats()
+			free := t.Service.GetMemoryStats().Free
 
 			var newMemorySize int64
 			for i := 25; i >= 10; i -= 5 {
@@ -457,11 +497,11 @@ func (t *Torrent) Buffer(file *File, isStartup bool) {
 	t.muBuffer.Unlock()
 
 	log.Infof("Setting buffer for file: %s (%s / %s). Desired: %s. Pieces: %#v-%#v + %#v-%#v, PieceLength: %s, Pre: %s, Post: %s, WithOffset: %#v / %#v (%#v)",
-		file.Path, humanize.Bytes(uint64(file.Size)), humanize.Bytes(uint64(t.ti.TotalSize())),
+		file.Path, humanize.Bytes(uint64(size)), humanize.Bytes(uint64(t.ti.TotalSize())),
 		humanize.Bytes(uint64(t.Service.GetBufferSize())),
 		preBufferStart, preBufferEnd, postBufferStart, postBufferEnd,
 		humanize.Bytes(uint64(t.pieceLength)), humanize.Bytes(uint64(preBufferSize)), humanize.Bytes(uint64(postBufferSize)),
-		preBufferOffset, postBufferOffset, file.Offset)
+		preBufferOffset, postBufferOffset, offset)
 
 	t.Service.SetBufferingLimits()
 
@@ -520,6 +560,10 @@ func (t *Torrent) Buffer(file *File, isStartup bool) {
 			t.th.SetPieceDeadline(curPiece, 0, 0)
 		}
 	}
+
+	if isStartup {
+		go t.prefetchIndex(file)
+	}
 }
 
 // AdjustMemorySize ...
@@ -570,8 +614,17 @@ func (t *Torrent) getBufferSize(fileOffset int64, off, length int64) (startPiece
 	return
 }
 
-// PrioritizePiece ...
+// PrioritizePiece sets deadlines for the piece and pieces, that follow it
 func (t *Torrent) PrioritizePiece(piece int) {
+	t.prioritizePiece(piece, readerPolicies[readerPrimary])
+}
+
+// PrioritizeReaderPiece sets deadlines for pieces, the reader waits for, within deadline budget of the reader
+func (t *Torrent) PrioritizeReaderPiece(tf *TorrentFSEntry, piece int) {
+	t.prioritizePiece(piece, t.readerPolicy(tf))
+}
+
+func (t *Torrent) prioritizePiece(piece int, policy readerPolicy) {
 	t.muAwaitingPieces.Lock()
 	defer t.muAwaitingPieces.Unlock()
 
@@ -581,14 +634,14 @@ func (t *Torrent) PrioritizePiece(piece int) {
 
 	defer perf.ScopeTimer()()
 
-	for i := piece; i < piece+3; i++ {
+	for i := piece; i < piece+policy.deadlinePieces && i < t.pieceCount; i++ {
 		if t.awaitingPieces.ContainsInt(i) || t.hasPiece(i) {
 			continue
 		}
 
 		t.awaitingPieces.AddInt(i)
 
-		t.th.SetPieceDeadline(i, i-piece*100, 0)
+		t.th.SetPieceDeadline(i, policy.deadlineDelay+(i-piece)*deadlineStep, 0)
 	}
 }
 
@@ -606,7 +659,7 @@ func (t *Torrent) PrioritizePieces() {
 	t.muDemandPieces.RLock()
 	defer t.muDemandPieces.RUnlock()
 
-	if (t.IsBuffering && t.demandPieces.IsEmpty()) || t.IsSeeding || (!t.IsPlaying && !t.IsNextFile) || t.th == nil || t.Closer.IsSet() {
+	if (t.IsBuffering && t.demandPieces.IsEmpty()) || t.IsSeeding || (!t.IsPlaying && !t.IsNextFile && !t.IsStreaming()) || t.th == nil || t.Closer.IsSet() {
 		return
 	}
 
@@ -641,36 +694,50 @@ func (t *Torrent) PrioritizePieces() {
 	}
 
 	t.muAwaitingPieces.RLock()
+	policies := t.readerPolicies()
 	for _, r := range t.readers {
 		pr := r.ReaderPiecesRange()
-		log.Debugf("Reader range: %+v, last: %s", pr, r.lastUsed.Format(time.RFC3339))
+		policy := policies[r.id]
+		log.Debugf("Reader range: %+v, policy: %s, last: %s", pr, readerClassNames[policy.class], r.lastUsed.Format(time.RFC3339))
 
+		// Tiers are longer for readers, that consume more pieces per second
+		scale := r.priorityScale()
 		for curPiece := pr.Begin; curPiece <= pr.End; curPiece++ {
+			priority := policy.piecePriority(curPiece-pr.Begin, scale)
 			if t.awaitingPieces.ContainsInt(curPiece) {
-				readerPieces[curPiece] = 7
-			} else {
-				pos := curPiece - pr.Begin
-				switch {
-				case pos <= 0:
-					readerPieces[curPiece] = 6
-				case pos <= 2:
-					readerPieces[curPiece] = 5
-				case pos <= 5:
-					readerPieces[curPiece] = 4
-				case pos <= 9:
-					readerPieces[curPiece] = 3
-				default:
-					readerPieces[curPiece] = 2
-				}
+				priority = policy.maxPriority + 1
+			}
+
+			// Piece, shared by several readers, keeps the highest priority of them
+			if priority > readerPieces[curPiece] {
+				readerPieces[curPiece] = priority
+				priorities[priority] = append(priorities[priority], curPiece)
 			}
-			priorities[readerPieces[curPiece]] = append(priorities[readerPieces[curPiece]], curPiece)
 
 			readerProgress[curPiece] = 0
 		}
 	}
 	t.muAwaitingPieces.RUnlock()
+
+	// Preloading start of the next playlist file with the lowest priority
+	for _, piece := range t.nextFilePieces() {
+		if readerPieces[piece] == 0 {
+			readerPieces[piece] = 1
+			readerProgress[piece] = 0
+		}
+	}
 	t.muReaders.Unlock()
 
+	// Pieces from spillover cache are read from disk, so they are neither downloaded nor kept in memory
+	if t.IsMemoryStorage() && t.Service.spillover.Load() != nil {
+		for piece, priority := range readerPieces {
+			if priority > 0 && t.hasSpilledPiece(piece) {
+				readerPieces[piece] = 0
+				delete(readerProgress, piece)
+			}
+		}
+	}
+
 	// Update progress for piece completion
 	t.piecesProgress(readerProgress)
 
@@ -1262,6 +1329,200 @@ func (t *Torrent) FetchDBItem() *database.BTItem {
 	return t.DBItem
 }
 
+// GetSavePath returns directory where torrent files are stored
+func (t *Torrent) GetSavePath() string {
+	if t.savePath != "" {
+		return t.savePath
+	}
+	return t.Service.config.DownloadPath
+}
+
+// GetCategoryName returns name of the category torrent is assigned to
+func (t *Torrent) GetCategoryName() string {
+	if item := t.DBItem; item != nil {
+		return item.Category
+	}
+	return ""
+}
+
+// GetCategory returns category torrent is assigned to, or nil.
+// Category is cached and fetched again only when assignment changes.
+func (t *Torrent) GetCategory() *database.Category {
+	name := t.GetCategoryName()
+	if name == "" {
+		return nil
+	}
+
+	t.muCategory.Lock()
+	defer t.muCategory.Unlock()
+
+	if t.category == nil || t.category.Name != name {
+		t.category = database.GetStorm().GetCategory(name)
+	}
+	return t.category
+}
+
+// ResetCategory drops cached category, so that changed caps and limits are used
+func (t *Torrent) ResetCategory() {
+	t.muCategory.Lock()
+	t.category = nil
+	t.muCategory.Unlock()
+}
+
+// SetCategory assigns torrent to a category, empty name removes the assignment.
+// Download path of a category is only used for torrents added after assignment.
+func (t *Torrent) SetCategory(name string) error {
+	var category *database.Category
+	if name != "" {
+		if category = database.GetStorm().GetCategory(name); category == nil {
+			return fmt.Errorf("Category %s not found", name)
+		}
+	}
+
+	if err := database.GetStorm().UpdateBTItemCategory(t.infoHash, name); err != nil {
+		return err
+	}
+	t.FetchDBItem()
+
+	t.ApplyRateLimits(t.Service.IsStreaming())
+	return nil
+}
+
+// GetPriorityClass returns priority class of a torrent, normal by default
+func (t *Torrent) GetPriorityClass() string {
+	if item := t.DBItem; item != nil && IsValidPriorityClass(item.PriorityClass) {
+		return item.PriorityClass
+	}
+	return PriorityClassNormal
+}
+
+// GetRateLimits returns configured download and upload caps in bytes per second,
+// own torrent caps take precedence over category caps, zero means unlimited
+func (t *Torrent) GetRateLimits() (downloadLimit, uploadLimit int) {
+	if item := t.DBItem; item != nil {
+		downloadLimit = item.DownloadRateLimit
+		uploadLimit = item.UploadRateLimit
+	}
+
+	if downloadLimit == 0 || uploadLimit == 0 {
+		if category := t.GetCategory(); category != nil {
+			if downloadLimit == 0 {
+				downloadLimit = category.DownloadRateLimit
+			}
+			if uploadLimit == 0 {
+				uploadLimit = category.UploadRateLimit
+			}
+		}
+	}
+	return
+}
+
+// SetRateLimits saves per-torrent caps and priority class and applies them
+func (t *Torrent) SetRateLimits(downloadLimit, uploadLimit int, priorityClass string) error {
+	if downloadLimit < 0 || uploadLimit < 0 {
+		return errors.New("Rate limits should not be negative")
+	}
+	if priorityClass == "" {
+		priorityClass = PriorityClassNormal
+	} else if !IsValidPriorityClass(priorityClass) {
+		return fmt.Errorf("Unknown priority class: %s", priorityClass)
+	}
+
+	if err := database.GetStorm().UpdateBTItemLimits(t.infoHash, downloadLimit, uploadLimit, priorityClass); err != nil {
+		return err
+	}
+	t.FetchDBItem()
+
+	t.ApplyRateLimits(t.Service.IsStreaming())
+	return nil
+}
+
+// ApplyRateLimits sets effective per-torrent caps in libtorrent.
+// Streaming torrents are not capped while they are read, background torrents,
+// that are not read themselves, are throttled while streaming is active.
+func (t *Torrent) ApplyRateLimits(streaming bool) {
+	if t.th == nil || t.th.Swigcptr() == 0 || t.Closer.IsSet() {
+		return
+	}
+
+	downloadLimit, uploadLimit := t.GetRateLimits()
+	switch t.GetPriorityClass() {
+	case PriorityClassStreaming:
+		if t.HasActiveReaders() {
+			downloadLimit, uploadLimit = 0, 0
+		}
+	case PriorityClassBackground:
+		if streaming && !t.HasActiveReaders() {
+			backgroundLimit := t.Service.config.BackgroundRateLimit
+			if backgroundLimit <= 0 {
+				backgroundLimit = defaultBackgroundRateLimit
+			}
+			downloadLimit = minRateLimit(downloadLimit, backgroundLimit)
+			uploadLimit = minRateLimit(uploadLimit, backgroundLimit)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if downloadLimit != t.appliedDownloadLimit {
+		log.Debugf("Setting download limit of %s to %d", t.infoHash, downloadLimit)
+		t.th.SetDownloadLimit(downloadLimit)
+		t.appliedDownloadLimit = downloadLimit
+	}
+	if uploadLimit != t.appliedUploadLimit {
+		log.Debugf("Setting upload limit of %s to %d", t.infoHash, uploadLimit)
+		t.th.SetUploadLimit(uploadLimit)
+		t.appliedUploadLimit = uploadLimit
+	}
+}
+
+// HasActiveReaders returns true if torrent is currently read by a player or any other client
+func (t *Torrent) HasActiveReaders() bool {
+	if t.IsPlaying || t.IsBuffering {
+		return true
+	}
+
+	t.muReaders.Lock()
+	defer t.muReaders.Unlock()
+
+	for _, r := range t.readers {
+		if r.IsActive() {
+			return true
+		}
+	}
+	return false
+}
+
+// minRateLimit returns the lower of two limits, where zero means unlimited
+func minRateLimit(a, b int) int {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// GetSeedLimits returns seed time, seed time ratio and share ratio limits,
+// taking category overrides into account
+func (t *Torrent) GetSeedLimits() (seedTimeLimit, seedTimeRatioLimit, shareRatioLimit int) {
+	seedTimeLimit = t.Service.config.SeedTimeLimit
+	seedTimeRatioLimit = t.Service.config.SeedTimeRatioLimit
+	shareRatioLimit = t.Service.config.ShareRatioLimit
+
+	if category := t.GetCategory(); category != nil {
+		if category.SeedTimeLimit > 0 {
+			seedTimeLimit = category.SeedTimeLimit
+		}
+		if category.SeedTimeRatioLimit > 0 {
+			seedTimeRatioLimit = category.SeedTimeRatioLimit
+		}
+		if category.ShareRatioLimit > 0 {
+			shareRatioLimit = category.ShareRatioLimit
+		}
+	}
+	return
+}
+
 // SaveMetainfo ...
 func (t *Torrent) SaveMetainfo(path string) (string, error) {
 	defer perf.ScopeTimer()()
@@ -1330,62 +1591,29 @@ func (t *Torrent) ResetReaders() {
 		return
 	}
 
-	perReaderSize := t.GetReadaheadSize()
-	countActive := float64(0)
-	countIdle := float64(0)
-	countHead := float64(0)
+	sizes := t.readerSizes()
 	for _, r := range t.readers {
-		if r.IsHead() {
-			countHead++
-		} else if r.IsActive() {
-			countActive++
-		} else {
-			countIdle++
+		size, ok := sizes[r.id]
+		if !ok || r.readahead == size {
+			continue
 		}
-	}
 
-	sizeActive := int64(0)
-	sizeIdle := int64(0)
-	sizeHead := int64(t.pieceLength)
-
-	if countIdle > 1 {
-		countIdle = 2
-	}
-	if countActive > 1 {
-		countActive = 2
-	}
-	if countHead > 1 {
-		countHead = 2
-	}
-
-	if countIdle > 0 {
-		sizeIdle = int64(float64(perReaderSize) * 0.33)
-		if countActive > 0 {
-			sizeActive = perReaderSize - sizeIdle
-		}
-	} else if countActive > 0 {
-		sizeActive = int64(float64(perReaderSize) / countActive)
+		log.Infof("Setting readahead for reader %d as %s", r.id, humanize.Bytes(uint64(size)))
+		r.readahead = size
 	}
+}
 
-	if countActive == 0 && countIdle == 0 && countHead == 0 {
-		return
-	}
+// IsStreaming checks if any reader streams the torrent outside of Kodi player
+func (t *Torrent) IsStreaming() bool {
+	t.muReaders.Lock()
+	defer t.muReaders.Unlock()
 
 	for _, r := range t.readers {
-		size := sizeActive
-		if r.IsHead() {
-			size = sizeHead
-		} else if !r.IsActive() {
-			size = sizeIdle
-		}
-
-		if r.readahead == size {
-			continue
+		if r.IsStreaming() {
+			return true
 		}
-
-		log.Infof("Setting readahead for reader %d as %s", r.id, humanize.Bytes(uint64(size)))
-		r.readahead = size
 	}
+	return false
 }
 
 // ReadersReadaheadSum ...
@@ -1521,7 +1749,7 @@ func (t *Torrent) onMetadataReceived() {
 	// Reset fastResumeFile
 	infoHash := t.InfoHash()
 	t.fastResumeFile = filepath.Join(t.Service.config.TorrentsPath, fmt.Sprintf("%s.fastresume", infoHash))
-	t.partsFile = filepath.Join(t.Service.config.DownloadPath, fmt.Sprintf(".%s.parts", infoHash))
+	t.partsFile = filepath.Join(t.GetSavePath(), fmt.Sprintf(".%s.parts", infoHash))
 	t.memoryStorageFile = filepath.Join(t.Service.config.TorrentsPath, fmt.Sprintf(".%s.memory", infoHash))
 	t.fileStorageFile = filepath.Join(t.Service.config.TorrentsPath, fmt.Sprintf(".%s.file", infoHash))
 
@@ -1682,6 +1910,42 @@ func (t *Torrent) GetFiles() []*File {
 	return t.files
 }
 
+// GetFilePriorities returns libtorrent priorities of all torrent files, by file index
+func (t *Torrent) GetFilePriorities() []int {
+	ret := make([]int, len(t.files))
+	if t.Closer.IsSet() || t.th == nil || t.th.Swigcptr() == 0 {
+		return ret
+	}
+
+	filePriorities := t.th.FilePriorities()
+	defer lt.DeleteStdVectorInt(filePriorities)
+
+	size := int(filePriorities.Size())
+	for i := range ret {
+		if i < size {
+			ret[i] = filePriorities.Get(i)
+		}
+	}
+
+	return ret
+}
+
+// GetFilePiecesProgress returns number of completed pieces and total pieces of a file
+func (t *Torrent) GetFilePiecesProgress(f *File) (completed, total int) {
+	if f == nil || f.PieceEnd < f.PieceStart {
+		return
+	}
+
+	total = f.PieceEnd - f.PieceStart + 1
+	for piece := f.PieceStart; piece <= f.PieceEnd; piece++ {
+		if t.hasPiece(piece) {
+			completed++
+		}
+	}
+
+	return
+}
+
 // GetCandidateFileForIndex returns CandidateFile for specific int index
 func (t *Torrent) GetCandidateFileForIndex(idx int) *CandidateFile {
 	if idx < 0 {
@@ -1724,9 +1988,17 @@ func (t *Torrent) GetCandidateFiles(btp *Player) ([]*CandidateFile, int, error)
 		}
 	}
 
+	// Stored file of RAR archive is played from its volumes, see chooseRarFile
+	if btp != nil && btp.rarFile != nil {
+		for i, f := range files {
+			if f == btp.rarFile.Volumes()[0] {
+				return nil, i, nil
+			}
+		}
+	}
+
 	var candidateFiles []int
 
-	reRar := regexp.MustCompile(rarMatchRegex)
 	reSkip := regexp.MustCompile(skipFileRegex)
 	for i, f := range files {
 		size := f.Size
@@ -1746,16 +2018,6 @@ func (t *Torrent) GetCandidateFiles(btp *Player) ([]*CandidateFile, int, error)
 			isBluRay = true
 			continue
 		}
-
-		if reRar.MatchString(fileName) && size > 10*1024*1024 {
-			t.IsRarArchive = true
-			if !btp.xbmcHost.DialogConfirm("Elementum", "LOCALIZE[30303]") {
-				if btp != nil {
-					btp.notEnoughSpace = true
-				}
-				return nil, -1, errors.New("RAR archive detected and download was cancelled")
-			}
-		}
 	}
 
 	if isBluRay {
@@ -1885,6 +2147,15 @@ func (t *Torrent) ChooseFile(btp *Player) (*File, int, error) {
 		}
 	}
 
+	// Reading RAR headers waits for volume pieces, so archives are looked for only when player chooses a file
+	if btp != nil {
+		if f, err := t.chooseRarFile(btp); err != nil {
+			return nil, -1, err
+		} else if f != nil {
+			return f, -1, nil
+		}
+	}
+
 	files := t.files
 	choices, biggestFile, err := t.GetCandidateFiles(btp)
 	if err != nil {
@@ -2001,8 +2272,41 @@ func (t *Torrent) ChooseFile(btp *Player) (*File, int, error) {
 	return files[biggestFile], -1, nil
 }
 
-// GetPlayURL returns url ready for Kodi
-func (t *Torrent) GetPlayURL(fileIndex string) string {
+// ChooseEpisodeFile selects file of an episode without asking the user,
+// returns nil if there is no single file that matches the episode
+func (t *Torrent) ChooseEpisodeFile(season, episode int) *File {
+	files := t.files
+	reSkip := regexp.MustCompile(skipFileRegex)
+
+	choices := []*CandidateFile{}
+	for i, f := range files {
+		fileName := filepath.Base(f.Path)
+		if reSkip.MatchString(fileName) || f.Size < config.Get().MinCandidateSize {
+			continue
+		}
+
+		choices = append(choices, &CandidateFile{
+			Index:       i,
+			Filename:    fileName,
+			DisplayName: fileName,
+			Path:        f.Path,
+			Size:        f.Size,
+		})
+	}
+
+	if len(choices) == 1 {
+		return files[choices[0].Index]
+	}
+
+	if index, found := MatchEpisodeFilename(season, episode, false, season, nil, nil, nil, choices); found == 1 {
+		return files[choices[index].Index]
+	}
+
+	return nil
+}
+
+// GetPlayURL returns url ready for Kodi, extra query parameters are appended as key/value pairs
+func (t *Torrent) GetPlayURL(fileIndex string, extra ...string) string {
 	var (
 		tmdbID      string
 		show        string
@@ -2035,14 +2339,16 @@ func (t *Torrent) GetPlayURL(fileIndex string) string {
 	}
 
 	return URLQuery(fmt.Sprintf(URLForXBMC("/play")+"/%s", url.PathEscape(toBeAdded)),
-		"resume", t.InfoHash(),
-		"type", contentType,
-		"index", fileIndex,
-		"tmdb", tmdbID,
-		"show", show,
-		"season", season,
-		"episode", episode,
-		"query", query)
+		append([]string{
+			"resume", t.InfoHash(),
+			"type", contentType,
+			"index", fileIndex,
+			"tmdb", tmdbID,
+			"show", show,
+			"season", season,
+			"episode", episode,
+			"query", query,
+		}, extra...)...)
 }
 
 // TorrentInfo writes torrent status to io.Writer
@@ -2101,6 +2407,8 @@ func (t *Torrent) TorrentInfo(xbmcHost *xbmc.XBMCHost, w io.Writer, showTrackers
 	fmt.Fprintf(w, "        announcing_to_dht: %v \n", st.GetAnnouncingToDht())
 	fmt.Fprint(w, "\n")
 
+	t.ReadersInfo(w)
+
 	fmt.Fprint(w, "    Files (Priority):\n")
 	filePriorities := t.th.FilePriorities()
 	for _, f := range slices.Sort(t.files, byPath).([]*File) {
@@ -2134,7 +2442,7 @@ func (t *Torrent) TorrentInfo(xbmcHost *xbmc.XBMCHost, w io.Writer, showTrackers
 		fmt.Fprint(w, "    Invernal Trackers:\n")
 
 		t.trackers.Range(func(t, p interface{}) bool {
-			fmt.Fprintf(w, "        %-60s: %-3d peers\n", t, p)
+			fmt.Fprintf(w, "        %-60s: %-3d peers\n", t, p.(trackerState).numPeers)
 			return true
 		})
 		fmt.Fprint(w, "\n")
@@ -304,6 +304,19 @@ func NewTorrentFile(uri string) *TorrentFile {
 	return t
 }
 
+// NewTorrentFileWithName creates TorrentFile with a known name and size,
+// so that quality tags are parsed from the name before torrent is resolved
+func NewTorrentFileWithName(uri, name, size string) *TorrentFile {
+	t := &TorrentFile{
+		URI:   uri,
+		Name:  name,
+		Title: name,
+		Size:  size,
+	}
+	t.initialize()
+	return t
+}
+
 func (t *TorrentFile) initialize() {
 	if t.IsMagnet() {
 		t.initializeFromMagnet()
@@ -533,9 +546,10 @@ func (t *TorrentFile) Resolve() error {
 	return nil
 }
 
-// EnrichTrackers ...
+// EnrichTrackers removes blocked and pruned trackers and adds extra trackers
 func (t *TorrentFile) EnrichTrackers() {
-	for _, trackerURL := range extraTrackers {
+	t.Trackers = filterTrackers(t.Trackers)
+	for _, trackerURL := range getExtraTrackers() {
 		if !util.StringSliceContains(t.Trackers, trackerURL) {
 			t.Trackers = append(t.Trackers, trackerURL)
 		}
@@ -656,16 +670,23 @@ func (t *TorrentFile) parseSize() {
 	}
 }
 
-// UpdateTorrentTrackers updates raw torrent file trackers
+// UpdateTorrentTrackers updates raw torrent file trackers, blocked and pruned trackers are removed
 func (t *TorrentFile) UpdateTorrentTrackers() error {
 	if t.IsPrivate {
 		return nil
 	}
 
+	t.Trackers = filterTrackers(t.Trackers)
 	if t.IsMagnet() {
 		magnetURI, _ := url.Parse(t.URI)
 		vals := magnetURI.Query()
 		existing := vals["tr"]
+		if allowed := filterTrackers(existing); len(allowed) != len(existing) {
+			vals["tr"] = allowed
+			existing = allowed
+			magnetURI.RawQuery = vals.Encode()
+			t.URI = magnetURI.String()
+		}
 
 		for _, tracker := range t.Trackers {
 			if !util.StringSliceContains(existing, tracker) {
@@ -689,6 +710,17 @@ func (t *TorrentFile) UpdateTorrentTrackers() error {
 			return err
 		}
 
+		if torrentFile.Announce != "" && !isTrackerAllowed(torrentFile.Announce) {
+			torrentFile.Announce = ""
+		}
+		announceList := [][]string{}
+		for _, tier := range torrentFile.AnnounceList {
+			if tier = filterTrackers(tier); len(tier) > 0 {
+				announceList = append(announceList, tier)
+			}
+		}
+		torrentFile.AnnounceList = announceList
+
 		for _, tracker := range t.Trackers {
 			if !torrentFile.HasAnnounce(tracker) {
 				torrentFile.AnnounceList = append(torrentFile.AnnounceList, []string{tracker})
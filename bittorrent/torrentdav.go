@@ -0,0 +1,318 @@
+package bittorrent
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// TorrentDAV exposes files of active torrents as a read-only WebDAV file system.
+// Files are opened as TorrentFS entries, so pieces are downloaded as clients read them.
+type TorrentDAV struct {
+	tfs *TorrentFS
+}
+
+// NewTorrentDAV ...
+func NewTorrentDAV(service *Service, method string) *TorrentDAV {
+	return &TorrentDAV{
+		tfs: NewTorrentFS(service, method),
+	}
+}
+
+// NewTorrentDAVHandler returns WebDAV handler, mounted at prefix, that only allows reading
+func NewTorrentDAVHandler(service *Service, prefix string) http.Handler {
+	locks := webdav.NewMemLS()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodOptions, "PROPFIND", "LOCK", "UNLOCK":
+		case http.MethodGet, http.MethodHead:
+			w.Header().Set("Connection", "close")
+		default:
+			w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND, LOCK, UNLOCK")
+			http.Error(w, "Read-only file system", http.StatusMethodNotAllowed)
+			return
+		}
+
+		handler := &webdav.Handler{
+			Prefix:     prefix,
+			FileSystem: NewTorrentDAV(service, r.Method),
+			LockSystem: locks,
+			Logger: func(r *http.Request, err error) {
+				if err != nil {
+					log.Debugf("WebDAV %s %s failed: %s", r.Method, r.URL.Path, err)
+				}
+			},
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Mkdir ...
+func (d *TorrentDAV) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return os.ErrPermission
+}
+
+// RemoveAll ...
+func (d *TorrentDAV) RemoveAll(ctx context.Context, name string) error {
+	return os.ErrPermission
+}
+
+// Rename ...
+func (d *TorrentDAV) Rename(ctx context.Context, oldName, newName string) error {
+	return os.ErrPermission
+}
+
+// Stat returns file info without opening the file, so listing does not affect pieces prioritization
+func (d *TorrentDAV) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	name = davClean(name)
+	if t, f := d.find(name); f != nil {
+		return newDavFileInfo(t, f), nil
+	}
+	if d.isDir(name) {
+		return &davFileInfo{name: path.Base(name), dir: true}, nil
+	}
+
+	return nil, os.ErrNotExist
+}
+
+// OpenFile opens torrent file for reading, or a directory for listing
+func (d *TorrentDAV) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, os.ErrPermission
+	}
+
+	name = davClean(name)
+	if t, f := d.find(name); f != nil {
+		entry, err := d.tfs.OpenTorrentFile(t, f)
+		if err != nil {
+			return nil, err
+		}
+
+		return &davFile{TorrentFSEntry: entry, info: newDavFileInfo(t, f)}, nil
+	}
+	if d.isDir(name) {
+		return &davDir{name: name, d: d}, nil
+	}
+
+	return nil, os.ErrNotExist
+}
+
+// find returns torrent file by its slash separated path
+func (d *TorrentDAV) find(name string) (*Torrent, *File) {
+	for _, t := range d.tfs.s.GetTorrents() {
+		if !t.HasMetadata() {
+			continue
+		}
+
+		for _, f := range t.GetFiles() {
+			if "/"+filepath.ToSlash(f.Path) == name {
+				return t, f
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func (d *TorrentDAV) isDir(name string) bool {
+	if name == "/" {
+		return true
+	}
+
+	for _, t := range d.tfs.s.GetTorrents() {
+		if !t.HasMetadata() {
+			continue
+		}
+
+		for _, f := range t.GetFiles() {
+			if strings.HasPrefix("/"+filepath.ToSlash(f.Path), name+"/") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// children returns entries of a directory, that is built from paths of torrent files
+func (d *TorrentDAV) children(name string) []os.FileInfo {
+	prefix := name + "/"
+	if name == "/" {
+		prefix = "/"
+	}
+
+	seen := map[string]bool{}
+	ret := []os.FileInfo{}
+	for _, t := range d.tfs.s.GetTorrents() {
+		if !t.HasMetadata() {
+			continue
+		}
+
+		for _, f := range t.GetFiles() {
+			p := "/" + filepath.ToSlash(f.Path)
+			if !strings.HasPrefix(p, prefix) {
+				continue
+			}
+
+			rest := p[len(prefix):]
+			child := rest
+			dir := false
+			if i := strings.Index(rest, "/"); i >= 0 {
+				child = rest[:i]
+				dir = true
+			}
+			if child == "" || seen[child] {
+				continue
+			}
+			seen[child] = true
+
+			if dir {
+				ret = append(ret, &davFileInfo{name: child, dir: true, modTime: t.GetAddedTime()})
+			} else {
+				ret = append(ret, newDavFileInfo(t, f))
+			}
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name() < ret[j].Name()
+	})
+	return ret
+}
+
+func davClean(name string) string {
+	return path.Clean("/" + name)
+}
+
+// davFile is a torrent file, opened for reading
+type davFile struct {
+	*TorrentFSEntry
+	info *davFileInfo
+}
+
+// Stat returns size of the torrent file, as files on disk can be not allocated yet
+func (f *davFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// Readdir ...
+func (f *davFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+// Write ...
+func (f *davFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+// davDir is a virtual directory, that contains torrent files with common path prefix
+type davDir struct {
+	name string
+	d    *TorrentDAV
+
+	entries []os.FileInfo
+	pos     int
+}
+
+// Close ...
+func (dd *davDir) Close() error {
+	return nil
+}
+
+// Read ...
+func (dd *davDir) Read(p []byte) (int, error) {
+	return 0, os.ErrInvalid
+}
+
+// Seek ...
+func (dd *davDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, os.ErrInvalid
+}
+
+// Write ...
+func (dd *davDir) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+// Stat ...
+func (dd *davDir) Stat() (os.FileInfo, error) {
+	return &davFileInfo{name: path.Base(dd.name), dir: true}, nil
+}
+
+// Readdir follows os.File semantics, entries are read in chunks if count is positive
+func (dd *davDir) Readdir(count int) ([]os.FileInfo, error) {
+	if dd.entries == nil {
+		dd.entries = dd.d.children(dd.name)
+	}
+
+	left := dd.entries[dd.pos:]
+	if count <= 0 {
+		dd.pos = len(dd.entries)
+		return left, nil
+	}
+	if len(left) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(left) {
+		count = len(left)
+	}
+	dd.pos += count
+	return left[:count], nil
+}
+
+// davFileInfo describes torrent file or virtual directory
+type davFileInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func newDavFileInfo(t *Torrent, f *File) *davFileInfo {
+	return &davFileInfo{
+		name:    path.Base(filepath.ToSlash(f.Path)),
+		size:    f.Size,
+		modTime: t.GetAddedTime(),
+	}
+}
+
+// Name ...
+func (fi *davFileInfo) Name() string { return fi.name }
+
+// Size ...
+func (fi *davFileInfo) Size() int64 { return fi.size }
+
+// ModTime ...
+func (fi *davFileInfo) ModTime() time.Time { return fi.modTime }
+
+// IsDir ...
+func (fi *davFileInfo) IsDir() bool { return fi.dir }
+
+// Sys ...
+func (fi *davFileInfo) Sys() interface{} { return nil }
+
+// Mode ...
+func (fi *davFileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+// ContentType is detected by extension only, as sniffing would wait for the first piece of every listed file
+func (fi *davFileInfo) ContentType(ctx context.Context) (string, error) {
+	if ctype := mime.TypeByExtension(path.Ext(fi.name)); ctype != "" {
+		return ctype, nil
+	}
+	return "application/octet-stream", nil
+}
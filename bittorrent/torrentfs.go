@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	lt "github.com/ElementumOrg/libtorrent-go"
@@ -45,11 +46,18 @@ type TorrentFSEntry struct {
 
 	id          int64
 	readahead   int64
+	window      int64
 	storageType int
 
-	lastUsed time.Time
-	isActive bool
-	isHead   bool
+	lastUsed    time.Time
+	isActive    bool
+	isHead      bool
+	isStreaming bool
+
+	rateMu    sync.Mutex
+	rateBytes int64
+	rateStart time.Time
+	readRate  float64
 }
 
 // PieceRange ...
@@ -70,9 +78,6 @@ func NewTorrentFS(service *Service, method string) *TorrentFS {
 func (tfs *TorrentFS) Open(uname string) (http.File, error) {
 	name := util.DecodeFileURL(uname)
 
-	var file http.File
-	var err error
-
 	log.Infof("Opening %s", name)
 
 	for _, t := range tfs.s.q.All() {
@@ -80,31 +85,48 @@ func (tfs *TorrentFS) Open(uname string) (http.File, error) {
 			if name[1:] == f.Path {
 				log.Noticef("%s belongs to torrent %s", name, t.Name())
 
-				if !t.IsMemoryStorage() {
-					file, err = os.Open(filepath.Join(string(tfs.Dir), name))
-					if err != nil {
-						return nil, err
-					}
-
-					// make sure we don't open a file that's locked, as it can happen
-					// on BSD systems (darwin included)
-					if err := unlockFile(file.(*os.File)); err != nil {
-						log.Errorf("Unable to unlock file because: %s", err)
-					}
+				entry, err := tfs.OpenTorrentFile(t, f)
+				if err != nil {
+					return nil, err
 				}
-
-				return NewTorrentFSEntry(file, tfs, t, f, name)
+				return entry, nil
 			}
 		}
+
+		if rf := t.findRarFile(name[1:]); rf != nil {
+			log.Noticef("%s is stored in archive of torrent %s", name, t.Name())
+
+			return tfs.OpenRarFile(t, rf)
+		}
+	}
+
+	return nil, fmt.Errorf("Could not open file: %s", name)
+}
+
+// OpenTorrentFile opens a file of known torrent, reads are waiting for pieces to be downloaded
+func (tfs *TorrentFS) OpenTorrentFile(t *Torrent, f *File) (*TorrentFSEntry, error) {
+	var file http.File
+	if !t.IsMemoryStorage() {
+		osFile, err := os.Open(filepath.Join(t.GetSavePath(), f.Path))
+		if err != nil {
+			return nil, err
+		}
+
+		// make sure we don't open a file that's locked, as it can happen
+		// on BSD systems (darwin included)
+		if err := unlockFile(osFile); err != nil {
+			log.Errorf("Unable to unlock file because: %s", err)
+		}
+		file = osFile
 	}
 
-	return file, fmt.Errorf("Could not open file: %s", name)
+	return NewTorrentFSEntry(file, tfs, t, f, "/"+f.Path)
 }
 
 // NewTorrentFSEntry ...
 func NewTorrentFSEntry(file http.File, tfs *TorrentFS, t *Torrent, f *File, name string) (*TorrentFSEntry, error) {
 	if file == nil {
-		file = NewMemoryFile(tfs, t.th.GetMemoryStorage(), f, name)
+		file = NewMemoryFile(tfs, t, t.th.GetMemoryStorage(), f, name)
 	}
 
 	tf := &TorrentFSEntry{
@@ -172,7 +194,14 @@ func (tf *TorrentFSEntry) Close() error {
 // Read ...
 func (tf *TorrentFSEntry) Read(data []byte) (n int, err error) {
 	defer perf.ScopeTimer()()
-	tf.SetActive(true)
+	if tf.SetActive(true) {
+		tf.t.ResetReaders()
+	}
+	defer func() {
+		if n > 0 && tf.updateReadRate(n) {
+			tf.t.ResetReaders()
+		}
+	}()
 
 	currentOffset, err := tf.File.Seek(0, io.SeekCurrent)
 	if err != nil {
@@ -270,7 +299,7 @@ func (tf *TorrentFSEntry) Seek(offset int64, whence int) (int64, error) {
 }
 
 func (tf *TorrentFSEntry) waitForPiece(piece int) error {
-	if tf.t.hasPiece(piece) {
+	if tf.t.hasPiece(piece) || tf.t.hasSpilledPiece(piece) {
 		return nil
 	}
 
@@ -284,7 +313,7 @@ func (tf *TorrentFSEntry) waitForPiece(piece int) error {
 		tf.t.muAwaitingPieces.Unlock()
 	}()
 
-	tf.t.PrioritizePiece(piece)
+	tf.t.PrioritizeReaderPiece(tf, piece)
 
 	pieceRefreshTicker := time.NewTicker(piecesRefreshDuration)
 	defer pieceRefreshTicker.Stop()
@@ -333,9 +362,12 @@ func (tf *TorrentFSEntry) ReaderPiecesRange() (ret PieceRange) {
 	return tf.byteRegionPieces(tf.torrentOffset(pos), ra)
 }
 
-// Readahead returns current reader readahead
+// Readahead returns current reader readahead, that covers prioritized range, if it is set
 func (tf *TorrentFSEntry) Readahead() int64 {
 	ra := tf.readahead
+	if tf.window > ra {
+		ra = tf.window
+	}
 	if ra < 1 {
 		// Needs to be at least 1, because [x, x) means we don't want
 		// anything.
@@ -405,3 +437,18 @@ func (tf *TorrentFSEntry) SetActive(is bool) (res bool) {
 
 	return
 }
+
+// IsStreaming ...
+func (tf *TorrentFSEntry) IsStreaming() bool {
+	return tf.isStreaming
+}
+
+// PrioritizeRange seeks to the start of a byte range, that is going to be read completely,
+// like a remuxed segment, and keeps the whole range in reader pieces, even without Kodi player
+func (tf *TorrentFSEntry) PrioritizeRange(off, size int64) error {
+	tf.isStreaming = true
+	tf.window = size
+
+	_, err := tf.Seek(off, io.SeekStart)
+	return err
+}
@@ -0,0 +1,312 @@
+package bittorrent
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	lt "github.com/ElementumOrg/libtorrent-go"
+
+	"github.com/elgatito/elementum/database"
+)
+
+const dhtTrackerName = "DHT"
+
+var (
+	// ErrTrackerExists is returned when added tracker is already in the torrent
+	ErrTrackerExists = errors.New("Tracker already exists")
+	// ErrTrackerNotFound is returned for tracker, that is not in the torrent
+	ErrTrackerNotFound = errors.New("Tracker not found")
+)
+
+// trackerState keeps what libtorrent reported in tracker alerts, it is replaced on every alert
+type trackerState struct {
+	lastAnnounce time.Time
+	numPeers     int
+	lastError    string
+	lastErrorAt  time.Time
+	warning      string
+	seeds        int
+	peers        int
+}
+
+// TrackerStatus is a state of a single torrent tracker
+type TrackerStatus struct {
+	URL          string
+	Tier         int
+	IsWorking    bool
+	Updating     bool
+	Message      string
+	LastError    string
+	LastErrorAt  time.Time
+	LastAnnounce time.Time
+	NextAnnounce time.Time
+	// Seeds and Peers are swarm numbers, reported by the tracker, -1 if unknown
+	Seeds int
+	Peers int
+	// NumPeers is a number of peers, returned by the last announce
+	NumPeers int
+}
+
+// updateTrackerState applies alert to the tracker state, alerts are handled by a single goroutine
+func (t *Torrent) updateTrackerState(trackerURL string, update func(st *trackerState)) {
+	st := trackerState{seeds: -1, peers: -1}
+	if v, ok := t.trackers.Load(trackerURL); ok {
+		st = v.(trackerState)
+	}
+
+	update(&st)
+	t.trackers.Store(trackerURL, st)
+}
+
+// Trackers returns tiers of torrent trackers, edited by the user, or tiers, reported by libtorrent
+func (t *Torrent) Trackers() [][]string {
+	if item := t.DBItem; item != nil && item.Trackers != nil {
+		return item.Trackers
+	}
+
+	ret := [][]string{}
+	if t.th == nil || t.th.Swigcptr() == 0 {
+		return ret
+	}
+
+	trackers := t.th.Trackers()
+	for i := 0; i < int(trackers.Size()); i++ {
+		tracker := trackers.Get(i)
+		tier := int(tracker.GetTier())
+		for len(ret) <= tier {
+			ret = append(ret, []string{})
+		}
+		ret[tier] = append(ret[tier], tracker.GetUrl())
+	}
+	return ret
+}
+
+// SetTrackers validates, saves and applies tiers of trackers
+func (t *Torrent) SetTrackers(tiers [][]string) error {
+	cleaned := [][]string{}
+	seen := map[string]bool{}
+	for _, tier := range tiers {
+		urls := []string{}
+		for _, tracker := range tier {
+			tracker = strings.TrimSpace(tracker)
+			if err := validateTrackerURL(tracker); err != nil {
+				return err
+			}
+			if seen[tracker] {
+				continue
+			}
+
+			seen[tracker] = true
+			urls = append(urls, tracker)
+		}
+		if len(urls) > 0 {
+			cleaned = append(cleaned, urls)
+		}
+	}
+
+	if err := database.GetStorm().UpdateBTItemTrackers(t.infoHash, cleaned); err != nil {
+		return err
+	}
+	t.FetchDBItem()
+
+	t.applyTrackers(cleaned)
+	return nil
+}
+
+// AddTracker adds tracker to the tier, tier, that is out of range, adds a new one after existing tiers
+func (t *Torrent) AddTracker(trackerURL string, tier int) error {
+	trackerURL = strings.TrimSpace(trackerURL)
+	if err := validateTrackerURL(trackerURL); err != nil {
+		return err
+	}
+
+	tiers := t.Trackers()
+	for _, urls := range tiers {
+		for _, u := range urls {
+			if u == trackerURL {
+				return ErrTrackerExists
+			}
+		}
+	}
+
+	if tier < 0 || tier >= len(tiers) {
+		tiers = append(tiers, []string{trackerURL})
+	} else {
+		tiers[tier] = append(tiers[tier], trackerURL)
+	}
+	return t.SetTrackers(tiers)
+}
+
+// RemoveTracker removes tracker from its tier
+func (t *Torrent) RemoveTracker(trackerURL string) error {
+	found := false
+	tiers := [][]string{}
+	for _, urls := range t.Trackers() {
+		tier := []string{}
+		for _, u := range urls {
+			if u == trackerURL {
+				found = true
+				continue
+			}
+			tier = append(tier, u)
+		}
+		tiers = append(tiers, tier)
+	}
+
+	if !found {
+		return ErrTrackerNotFound
+	}
+	return t.SetTrackers(tiers)
+}
+
+// Reannounce forces announce to the tracker, or to all trackers if URL is empty
+func (t *Torrent) Reannounce(trackerURL string) error {
+	if t.th == nil || t.th.Swigcptr() == 0 || t.Closer.IsSet() {
+		return errors.New("Torrent is closed")
+	}
+
+	if trackerURL == "" {
+		t.th.ForceReannounce()
+		return nil
+	}
+
+	trackers := t.th.Trackers()
+	for i := 0; i < int(trackers.Size()); i++ {
+		if trackers.Get(i).GetUrl() == trackerURL {
+			t.th.ForceReannounce(0, i)
+			return nil
+		}
+	}
+	return ErrTrackerNotFound
+}
+
+// TrackerStatuses returns state of torrent trackers in announce order
+func (t *Torrent) TrackerStatuses() []TrackerStatus {
+	ret := []TrackerStatus{}
+	if t.th == nil || t.th.Swigcptr() == 0 || t.Closer.IsSet() {
+		return ret
+	}
+
+	now := time.Now()
+	trackers := t.th.Trackers()
+	for i := 0; i < int(trackers.Size()); i++ {
+		tracker := trackers.Get(i)
+
+		status := TrackerStatus{
+			URL:       tracker.GetUrl(),
+			Tier:      int(tracker.GetTier()),
+			IsWorking: tracker.IsWorking(),
+			Updating:  tracker.GetUpdating(),
+			Message:   tracker.GetMessage(),
+			Seeds:     tracker.GetScrapeComplete(),
+			Peers:     tracker.GetScrapeIncomplete(),
+		}
+		if next := tracker.NextAnnounceIn(); next > 0 {
+			status.NextAnnounce = now.Add(time.Duration(next) * time.Second)
+		}
+
+		if v, ok := t.trackers.Load(status.URL); ok {
+			st := v.(trackerState)
+			status.LastAnnounce = st.lastAnnounce
+			status.NumPeers = st.numPeers
+			status.LastError = st.lastError
+			status.LastErrorAt = st.lastErrorAt
+			if status.Message == "" {
+				status.Message = st.warning
+			}
+			// Scrape replies can be newer than numbers, kept in announce entry
+			if status.Seeds < 0 {
+				status.Seeds = st.seeds
+			}
+			if status.Peers < 0 {
+				status.Peers = st.peers
+			}
+		}
+
+		ret = append(ret, status)
+	}
+	return ret
+}
+
+// restoreTrackers applies trackers, edited by the user, as they are replaced when torrent is added
+func (t *Torrent) restoreTrackers() {
+	if item := t.DBItem; item != nil && item.Trackers != nil {
+		log.Debugf("Restoring %d tiers of trackers for %s", len(item.Trackers), t.infoHash)
+		t.applyTrackers(item.Trackers)
+	}
+}
+
+// pruneTracker removes pruned tracker from public torrents, trackers, edited by the user, are kept as is
+func (s *Service) pruneTracker(trackerURL string) {
+	for _, t := range s.q.All() {
+		if t.isPrivate() {
+			continue
+		} else if item := t.DBItem; item != nil && item.Trackers != nil {
+			continue
+		}
+
+		found := false
+		tiers := [][]string{}
+		for _, urls := range t.Trackers() {
+			tier := []string{}
+			for _, u := range urls {
+				if u == trackerURL {
+					found = true
+					continue
+				}
+				tier = append(tier, u)
+			}
+			tiers = append(tiers, tier)
+		}
+		if found {
+			log.Debugf("Removing pruned tracker %s from %s", trackerURL, t.infoHash)
+			t.applyTrackers(tiers)
+		}
+	}
+}
+
+func (t *Torrent) isPrivate() bool {
+	if t.ti == nil || t.ti.Swigcptr() == 0 {
+		return false
+	}
+	return t.ti.Priv()
+}
+
+func (t *Torrent) applyTrackers(tiers [][]string) {
+	if t.th == nil || t.th.Swigcptr() == 0 || t.Closer.IsSet() {
+		return
+	}
+
+	trackers := lt.NewStdVectorAnnounceEntry()
+	defer lt.DeleteStdVectorAnnounceEntry(trackers)
+
+	for i, urls := range tiers {
+		for _, tracker := range urls {
+			announceEntry := lt.NewAnnounceEntry(tracker)
+			defer lt.DeleteAnnounceEntry(announceEntry)
+			announceEntry.SetTier(byte(i))
+			trackers.Add(announceEntry)
+		}
+	}
+
+	t.th.ReplaceTrackers(trackers)
+}
+
+func validateTrackerURL(trackerURL string) error {
+	u, err := url.Parse(trackerURL)
+	if err != nil {
+		return fmt.Errorf("Invalid tracker URL %s: %s", trackerURL, err)
+	}
+
+	switch u.Scheme {
+	case "udp", "http", "https":
+		if u.Host == "" {
+			return fmt.Errorf("Invalid tracker URL %s: host is missing", trackerURL)
+		}
+		return nil
+	}
+	return fmt.Errorf("Invalid tracker URL %s: only UDP and HTTP trackers are supported", trackerURL)
+}
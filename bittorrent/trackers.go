@@ -2,242 +2,348 @@ package bittorrent
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/binary"
-	"encoding/hex"
-	"errors"
 	"fmt"
 	"io"
-	"math/rand"
-	"net"
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/database"
 	"github.com/elgatito/elementum/proxy"
-	"github.com/elgatito/elementum/util"
 )
 
 const (
-	connectionRequestInitialID int64 = 0x041727101980
-	defaultTimeout                   = 3 * time.Second
-	defaultBufferSize                = 2048 // must be bigger than MTU, which is 1500 most of the time
-	maxScrapedHashes                 = 70
-)
+	trackerSourceDefault = "default"
 
-const (
-	// ActionConnect ...
-	ActionConnect Action = iota
-	// ActionAnnounce ...
-	ActionAnnounce
-	// ActionScrape ...
-	ActionScrape
-	// ActionError ...
-	ActionError = 50331648 // it's LittleEndian(3), in BigEndian, don't ask
-)
+	prunedTrackersKey = "trackers.pruned"
+	// Pruned trackers are tried again after a week, as dead trackers come back sometimes
+	prunedTrackersTTL = 7 * 24 * time.Hour
 
-// Action ...
-type Action int32
+	maxTrackerSourceSize = 1024 * 1024
+)
 
-// TrackerRequest ...
-type TrackerRequest struct {
-	ConnectionID  int64
-	Action        Action
-	TransactionID int32
+// TrackerDecision explains why a tracker is added to torrents or dropped
+type TrackerDecision struct {
+	URL       string `json:"url"`
+	Source    string `json:"source"`
+	Kept      bool   `json:"kept"`
+	Reason    string `json:"reason"`
+	Failures  int    `json:"failures"`
+	LastError string `json:"last_error,omitempty"`
+	PrunedAt  int64  `json:"pruned_at,omitempty"`
 }
 
-// TrackerResponse ...
-type TrackerResponse struct {
-	Action        Action
-	TransactionID int32
+type trackerCandidate struct {
+	url    string
+	source string
 }
 
-// ConnectionResponse ...
-type ConnectionResponse struct {
-	ConnectionID int64
+// trackerRule is a blocklist entry, plain entries match tracker host and its subdomains,
+// entries with regexp characters match the whole tracker URL
+type trackerRule struct {
+	rule string
+	host string
+	re   *regexp.Regexp
 }
 
-// AnnounceRequest ...
-type AnnounceRequest struct {
-	InfoHash   [20]byte
-	PeerID     [20]byte
-	Downloaded int64
-	Left       int64
-	Uploaded   int64
-	Event      int32
-	IPAddress  int32
-	Key        int32
-	NumWant    int32
-	Port       int16
-}
+type trackerBlocklistRules []trackerRule
 
-// Peer ...
-type Peer struct {
-	IPAddress int32
-	Port      int16
+type prunedTracker struct {
+	Failures  int       `json:"failures"`
+	LastError string    `json:"last_error"`
+	PrunedAt  time.Time `json:"pruned_at"`
 }
 
-// AnnounceResponse ...
-type AnnounceResponse struct {
-	Interval int32
-	Leechers int32
-	Seeders  int32
+// trackerFailure keeps consecutive failed announces of each torrent,
+// so a single outage is not counted once per every torrent, using the tracker
+type trackerFailure struct {
+	torrents  map[string]int
+	count     int
+	lastError string
 }
 
-// ScrapeResponseEntry ...
-type ScrapeResponseEntry struct {
-	Seeders   int32
-	Completed int32
-	Leechers  int32
-}
+var (
+	trackersMu        sync.RWMutex
+	trackerCandidates = []trackerCandidate{}
+	trackerBlocklist  = trackerBlocklistRules{}
+	trackerFailures   = map[string]*trackerFailure{}
+	prunedTrackers    = map[string]prunedTracker{}
+)
 
-// Tracker ...
-type Tracker struct {
-	connection   net.Conn
-	reader       *bufio.Reader
-	writer       *bufio.Writer
-	connectionID int64
-	URL          *url.URL
-}
+// UpdateDefaultTrackers fetches extra trackers from predefined page and configured sources,
+// trackers, that are blocked or pruned, are not added to torrents
+func UpdateDefaultTrackers() {
+	blocklist := newTrackerBlocklist(config.Get().TrackerBlocklist)
+	pruned := loadPrunedTrackers()
 
-// NewTracker ...
-func NewTracker(trackerURL string) (tracker *Tracker, err error) {
-	tURL, err := url.Parse(trackerURL)
-	if err != nil {
-		return
-	}
-	if tURL.Scheme != "udp" {
-		err = errors.New("Only UDP trackers are supported")
-		return
+	candidates := []trackerCandidate{}
+	if config.Get().AddExtraTrackers != addExtraTrackersNone {
+		// add Minimum set by default
+		for _, tracker := range defaultTrackers {
+			candidates = append(candidates, trackerCandidate{url: tracker, source: trackerSourceDefault})
+		}
+
+		sources := []string{}
+		if config.Get().AddExtraTrackers != addExtraTrackersMinimum {
+			sources = append(sources, fmt.Sprintf(extraTrackersURLTemplate, addExtraTrackersMap[config.Get().AddExtraTrackers]))
+		}
+		sources = append(sources, config.Get().TrackerSources...)
+
+		for _, source := range sources {
+			trackers, err := fetchTrackerSource(source)
+			if err != nil {
+				log.Warningf("Could not fetch trackers from %s: %s", source, err)
+				continue
+			}
+
+			for _, tracker := range trackers {
+				candidates = append(candidates, trackerCandidate{url: tracker, source: source})
+			}
+		}
 	}
-	tracker = &Tracker{
-		connectionID: connectionRequestInitialID,
-		URL:          tURL,
+
+	seen := map[string]bool{}
+	uniqueCandidates := []trackerCandidate{}
+	trackers := []string{}
+	for _, c := range candidates {
+		if seen[c.url] {
+			continue
+		}
+		seen[c.url] = true
+		uniqueCandidates = append(uniqueCandidates, c)
+
+		if _, ok := pruned[c.url]; ok {
+			continue
+		} else if blocklist.match(c.url) != nil {
+			continue
+		}
+		trackers = append(trackers, c.url)
 	}
-	return
+
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
+
+	trackerCandidates = uniqueCandidates
+	trackerBlocklist = blocklist
+	prunedTrackers = pruned
+	extraTrackers = trackers
+
+	log.Infof("Using %d of %d extra trackers", len(extraTrackers), len(trackerCandidates))
 }
 
-func (tracker *Tracker) sendRequest(action Action, request interface{}) error {
-	trackerRequest := TrackerRequest{
-		ConnectionID:  tracker.connectionID,
-		Action:        action,
-		TransactionID: rand.Int31(),
+func fetchTrackerSource(source string) ([]string, error) {
+	resp, err := proxy.GetClient().Get(source)
+	if err != nil {
+		return nil, err
 	}
-	binary.Write(tracker.writer, binary.BigEndian, trackerRequest)
-	if request != nil {
-		binary.Write(tracker.writer, binary.BigEndian, request)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Source responded with status %d", resp.StatusCode)
 	}
-	tracker.writer.Flush()
 
-	trackerResponse := TrackerResponse{}
+	ret := []string{}
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxTrackerSourceSize))
+	for scanner.Scan() {
+		tracker := strings.TrimSpace(scanner.Text())
+		if tracker == "" || strings.HasPrefix(tracker, "#") {
+			continue
+		} else if validateTrackerURL(tracker) != nil {
+			continue
+		}
+
+		ret = append(ret, tracker)
+	}
+	return ret, scanner.Err()
+}
 
-	result := make(chan error, 1)
-	go func() {
-		result <- binary.Read(tracker.reader, binary.BigEndian, &trackerResponse)
-	}()
-	select {
-	case <-time.After(defaultTimeout):
-		return errors.New("Request timed out")
-	case err := <-result:
+func newTrackerBlocklist(rules []string) trackerBlocklistRules {
+	ret := trackerBlocklistRules{}
+	for _, rule := range rules {
+		if !strings.ContainsAny(rule, `*?+^$|()[]{}\`) {
+			ret = append(ret, trackerRule{rule: rule, host: strings.ToLower(strings.TrimPrefix(rule, "."))})
+			continue
+		}
+
+		re, err := regexp.Compile(rule)
 		if err != nil {
-			return err
+			log.Warningf("Skipping invalid tracker blocklist rule %s: %s", rule, err)
+			continue
 		}
+		ret = append(ret, trackerRule{rule: rule, re: re})
 	}
+	return ret
+}
 
-	if trackerResponse.TransactionID != trackerRequest.TransactionID {
-		return errors.New("Request/Response transaction missmatch")
+// match returns the first rule, that blocks the tracker
+func (rules trackerBlocklistRules) match(trackerURL string) *trackerRule {
+	host := ""
+	if u, err := url.Parse(trackerURL); err == nil {
+		host = strings.ToLower(u.Hostname())
 	}
-	if trackerResponse.Action == ActionError {
-		msg, err := tracker.reader.ReadString(0)
-		if err != nil {
-			return err
+
+	for i, rule := range rules {
+		if rule.re != nil {
+			if rule.re.MatchString(trackerURL) {
+				return &rules[i]
+			}
+		} else if host != "" && (host == rule.host || strings.HasSuffix(host, "."+rule.host)) {
+			return &rules[i]
 		}
-		return errors.New(msg)
 	}
-
 	return nil
 }
 
-// Connect ...
-func (tracker *Tracker) Connect() error {
-	if !strings.Contains(tracker.URL.Host, ":") {
-		tracker.URL.Host += ":80"
-	}
-	var err error
-	tracker.connection, err = net.DialTimeout("udp", tracker.URL.Host, defaultTimeout)
-	if err != nil {
-		return err
-	}
-	tracker.reader = bufio.NewReaderSize(tracker.connection, defaultBufferSize)
-	tracker.writer = bufio.NewWriterSize(tracker.connection, defaultBufferSize)
-	if err := tracker.sendRequest(ActionConnect, nil); err != nil {
-		return err
+// getExtraTrackers returns trackers, that are added to public torrents
+func getExtraTrackers() []string {
+	trackersMu.RLock()
+	defer trackersMu.RUnlock()
+
+	return extraTrackers
+}
+
+// isTrackerAllowed checks tracker against the blocklist and pruned trackers
+func isTrackerAllowed(trackerURL string) bool {
+	trackersMu.RLock()
+	defer trackersMu.RUnlock()
+
+	if _, ok := prunedTrackers[trackerURL]; ok {
+		return false
 	}
-	return binary.Read(tracker.reader, binary.BigEndian, &tracker.connectionID)
+	return trackerBlocklist.match(trackerURL) == nil
 }
 
-func (tracker *Tracker) doScrape(infoHashes [][]byte) []ScrapeResponseEntry {
-	if err := tracker.sendRequest(ActionScrape, bytes.Join(infoHashes, nil)); err != nil {
-		return nil
+// filterTrackers removes blocked and pruned trackers from the list
+func filterTrackers(trackers []string) []string {
+	ret := make([]string, 0, len(trackers))
+	for _, tracker := range trackers {
+		if isTrackerAllowed(tracker) {
+			ret = append(ret, tracker)
+		}
 	}
+	return ret
+}
+
+// trackerAnnounced resets failures of the tracker, as any reply proves it is alive
+func trackerAnnounced(trackerURL string) {
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
 
-	entries := make([]ScrapeResponseEntry, len(infoHashes))
-	binary.Read(tracker.reader, binary.BigEndian, &entries)
-	return entries
+	delete(trackerFailures, trackerURL)
 }
 
-// Scrape ...
-func (tracker *Tracker) Scrape(torrents []*TorrentFile) []ScrapeResponseEntry {
-	entries := make([]ScrapeResponseEntry, 0, len(torrents))
+// trackerFailed counts failed announce of the torrent and returns true if tracker is pruned
+// after configured number of consecutive failures of any torrent
+func trackerFailed(trackerURL string, infoHash string, message string) bool {
+	limit := config.Get().TrackerPruneFailures
+	if limit <= 0 || trackerURL == "" || infoHash == "" {
+		return false
+	}
+
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
 
-	infoHashes := make([][]byte, 0, len(torrents))
-	for _, torrent := range torrents {
-		bhash, _ := hex.DecodeString(torrent.InfoHash)
-		infoHashes = append(infoHashes, bhash)
+	if _, ok := prunedTrackers[trackerURL]; ok {
+		return false
 	}
 
-	for i := 0; i <= len(infoHashes)/maxScrapedHashes; i++ {
-		idx := i * maxScrapedHashes
-		max := idx + maxScrapedHashes
-		if max > len(infoHashes) {
-			max = len(infoHashes)
+	failure, ok := trackerFailures[trackerURL]
+	if !ok {
+		failure = &trackerFailure{torrents: map[string]int{}}
+		trackerFailures[trackerURL] = failure
+	}
+	failure.torrents[infoHash]++
+	if failure.torrents[infoHash] > failure.count {
+		failure.count = failure.torrents[infoHash]
+	}
+	failure.lastError = message
+	if failure.count < limit {
+		return false
+	}
+
+	log.Infof("Pruning tracker %s after %d failed announces: %s", trackerURL, failure.count, message)
+	prunedTrackers[trackerURL] = prunedTracker{
+		Failures:  failure.count,
+		LastError: message,
+		PrunedAt:  time.Now(),
+	}
+	delete(trackerFailures, trackerURL)
+
+	trackers := make([]string, 0, len(extraTrackers))
+	for _, tracker := range extraTrackers {
+		if tracker != trackerURL {
+			trackers = append(trackers, tracker)
 		}
-		entries = append(entries, tracker.doScrape(infoHashes[idx:max])...)
 	}
+	extraTrackers = trackers
 
-	return entries
+	if err := database.GetCache().SetCachedObject(database.CommonBucket, int(prunedTrackersTTL.Seconds()), prunedTrackersKey, prunedTrackers); err != nil {
+		log.Warningf("Could not save pruned trackers: %s", err)
+	}
+	return true
 }
 
-func (tracker *Tracker) String() string {
-	return tracker.URL.String()
+func loadPrunedTrackers() map[string]prunedTracker {
+	pruned := map[string]prunedTracker{}
+	if err := database.GetCache().GetCachedObject(database.CommonBucket, prunedTrackersKey, &pruned); err != nil || pruned == nil {
+		return map[string]prunedTracker{}
+	}
+
+	for tracker, p := range pruned {
+		if time.Since(p.PrunedAt) > prunedTrackersTTL {
+			delete(pruned, tracker)
+		}
+	}
+	return pruned
 }
 
-// UpdateDefaultTrackers fetches extra trackers from predefined page
-func UpdateDefaultTrackers() {
-	extraTrackers = []string{}
-	if config.Get().AddExtraTrackers != addExtraTrackersNone {
-		// add Minimum set by default
-		extraTrackers = append(extraTrackers, defaultTrackers...)
+// TrackerDecisions returns known extra trackers and pruned trackers with the reason, why each of them is kept or dropped
+func TrackerDecisions() []TrackerDecision {
+	trackersMu.RLock()
+	defer trackersMu.RUnlock()
 
-		if config.Get().AddExtraTrackers != addExtraTrackersMinimum {
-			finalExtraTrackersURL := fmt.Sprintf(extraTrackersURLTemplate, addExtraTrackersMap[config.Get().AddExtraTrackers])
-			resp, err := proxy.GetClient().Get(finalExtraTrackersURL)
-			if err != nil || resp == nil {
-				return
-			} else if err == nil && resp.StatusCode != 200 {
-				return
-			}
-			defer resp.Body.Close()
-
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			scanner := bufio.NewScanner(bytes.NewReader(bodyBytes))
-			for scanner.Scan() {
-				tracker := strings.TrimSpace(scanner.Text())
-				if !util.StringSliceContains(extraTrackers, tracker) {
-					extraTrackers = append(extraTrackers, tracker)
-				}
-			}
+	ret := []TrackerDecision{}
+	decide := func(trackerURL, source string) {
+		d := TrackerDecision{
+			URL:    trackerURL,
+			Source: source,
+			Kept:   true,
+		}
+		if f, ok := trackerFailures[trackerURL]; ok {
+			d.Failures = f.count
+			d.LastError = f.lastError
+		}
+
+		if p, ok := prunedTrackers[trackerURL]; ok {
+			d.Kept = false
+			d.Failures = p.Failures
+			d.LastError = p.LastError
+			d.PrunedAt = p.PrunedAt.Unix()
+			d.Reason = fmt.Sprintf("Pruned after %d failed announces in a row", p.Failures)
+		} else if rule := trackerBlocklist.match(trackerURL); rule != nil {
+			d.Kept = false
+			d.Reason = fmt.Sprintf("Blocked by rule %s", rule.rule)
+		} else if source == trackerSourceDefault {
+			d.Reason = "Default tracker"
+		} else {
+			d.Reason = fmt.Sprintf("Listed in %s", source)
+		}
+		ret = append(ret, d)
+	}
+
+	known := map[string]bool{}
+	for _, c := range trackerCandidates {
+		known[c.url] = true
+		decide(c.url, c.source)
+	}
+	// Trackers of torrents can be pruned too
+	for tracker := range prunedTrackers {
+		if !known[tracker] {
+			decide(tracker, "torrent")
 		}
 	}
+	return ret
 }
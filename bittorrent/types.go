@@ -56,6 +56,61 @@ var StatusStrings = []string{
 	"LOCALIZE[30631]",
 }
 
+// StatusNames are untranslated status names, used in JSON API responses
+var StatusNames = []string{
+	"queued",
+	"checking",
+	"finding",
+	"downloading",
+	"finished",
+	"seeding",
+	"allocating",
+	"stalled",
+	"paused",
+	"buffering",
+	"playing",
+}
+
+// StatusName returns untranslated status name for status code
+func StatusName(code int) string {
+	if code < 0 || code >= len(StatusNames) {
+		return ""
+	}
+	return StatusNames[code]
+}
+
+// Priority classes of torrents, deciding how bandwidth is shared while streaming
+const (
+	// PriorityClassStreaming is never throttled in favour of other torrents,
+	// own and category caps are lifted while it is read
+	PriorityClassStreaming = "streaming"
+	// PriorityClassNormal is only limited by own and category caps
+	PriorityClassNormal = "normal"
+	// PriorityClassBackground is throttled while other torrents are streamed
+	PriorityClassBackground = "background"
+)
+
+// PriorityClasses ...
+var PriorityClasses = []string{
+	PriorityClassStreaming,
+	PriorityClassNormal,
+	PriorityClassBackground,
+}
+
+// IsValidPriorityClass ...
+func IsValidPriorityClass(class string) bool {
+	for _, c := range PriorityClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultBackgroundRateLimit caps background torrents while any other torrent is streamed,
+// if no cap is configured
+const defaultBackgroundRateLimit = 100 * 1024
+
 const (
 	// Remove ...
 	Remove = iota
@@ -102,22 +157,19 @@ var (
 	addExtraTrackersMap      = map[int]string{
 		addExtraTrackersAll:  "all",
 		addExtraTrackersBest: "best"}
+	// defaultTrackers are public trackers, that are known to be alive for a long time,
+	// dead ones are pruned in runtime, if tracker_prune_failures is set
 	defaultTrackers = []string{
 		"http://bt4.t-ru.org/ann",
 		"http://retracker.mgts.by:80/announce",
-		"http://tracker.city9x.com:2710/announce",
-		"http://tracker.electro-torrent.pl:80/announce",
-		"http://tracker.internetwarriors.net:1337/announce",
-		"http://bt.svao-ix.ru/announce",
 
 		"udp://tracker.opentrackr.org:1337/announce",
-		"udp://tracker.coppersurfer.tk:6969/announce",
-		"udp://tracker.leechers-paradise.org:6969/announce",
-		"udp://tracker.openbittorrent.com:80/announce",
-		"udp://public.popcorn-tracker.org:6969/announce",
-		"udp://explodie.org:6969",
-		"udp://46.148.18.250:2710",
-		"udp://opentor.org:2710",
+		"udp://open.demonii.com:1337/announce",
+		"udp://open.stealth.si:80/announce",
+		"udp://tracker.torrent.eu.org:451/announce",
+		"udp://exodus.desync.com:6969/announce",
+		"udp://explodie.org:6969/announce",
+		"udp://tracker.openbittorrent.com:6969/announce",
 	}
 	extraTrackers = []string{}
 )
@@ -0,0 +1,246 @@
+package bittorrent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/radovskyb/watcher"
+
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/database"
+)
+
+const (
+	watchFolderProcessed = "processed"
+	watchFolderFailed    = "failed"
+
+	watchFolderPollInterval = 2 * time.Second
+	// watchFolderSettleTime is a time file should stay unmodified before it is imported,
+	// to avoid reading files that are still being written
+	watchFolderSettleTime = 3 * time.Second
+)
+
+var watchFolderFilesRe = regexp.MustCompile(`(?i)\.(torrent|magnet)$`)
+
+// startWatchFolders starts watching configured folders for new torrent and magnet files
+func (s *Service) startWatchFolders() {
+	if !s.config.WatchFoldersEnabled || len(s.config.WatchFolders) == 0 {
+		return
+	}
+
+	w := watcher.New()
+	w.FilterOps(watcher.Create, watcher.Write, watcher.Rename, watcher.Move)
+	w.AddFilterHook(watcher.RegexFilterHook(watchFolderFilesRe, false))
+
+	folders := []string{}
+	for _, folder := range s.config.WatchFolders {
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			log.Errorf("Could not create watch folder %s: %s", folder, err)
+			continue
+		}
+		if err := w.Add(folder); err != nil {
+			log.Errorf("Could not watch folder %s: %s", folder, err)
+			continue
+		}
+		folders = append(folders, folder)
+	}
+	if len(folders) == 0 {
+		return
+	}
+
+	s.muWatchFolders.Lock()
+	s.folderWatcher = w
+	s.muWatchFolders.Unlock()
+
+	go func() {
+		closing := s.Closer.C()
+
+		for {
+			select {
+			case event := <-w.Event:
+				if event.IsDir() {
+					continue
+				}
+				s.importWatchFolderFile(event.Path)
+			case err := <-w.Error:
+				log.Errorf("Watch folders error: %s", err)
+			case <-w.Closed:
+				return
+			case <-closing:
+				w.Close()
+				return
+			}
+		}
+	}()
+
+	go func() {
+		if err := w.Start(watchFolderPollInterval); err != nil {
+			log.Errorf("Error watching folders: %s", err)
+		}
+	}()
+
+	// Files that were dropped while we were not running
+	go func() {
+		for _, folder := range folders {
+			log.Infof("Watching folder %s for new torrents", folder)
+
+			files, err := os.ReadDir(folder)
+			if err != nil {
+				continue
+			}
+			for _, f := range files {
+				if !f.IsDir() && watchFolderFilesRe.MatchString(f.Name()) {
+					s.importWatchFolderFile(filepath.Join(folder, f.Name()))
+				}
+			}
+		}
+	}()
+}
+
+// stopWatchFolders stops watching folders, imports that are in progress are finished
+func (s *Service) stopWatchFolders() {
+	s.muWatchFolders.Lock()
+	defer s.muWatchFolders.Unlock()
+
+	if s.folderWatcher != nil {
+		s.folderWatcher.Close()
+		s.folderWatcher = nil
+	}
+}
+
+// importWatchFolderFile adds torrent from a dropped file in background,
+// skipping files that are already being imported
+func (s *Service) importWatchFolderFile(path string) {
+	s.muWatchFolders.Lock()
+	if s.watchFolderImports[path] {
+		s.muWatchFolders.Unlock()
+		return
+	}
+	s.watchFolderImports[path] = true
+	s.muWatchFolders.Unlock()
+
+	go func() {
+		defer func() {
+			s.muWatchFolders.Lock()
+			delete(s.watchFolderImports, path)
+			s.muWatchFolders.Unlock()
+		}()
+
+		// Wait until file is completely written
+		for {
+			fi, err := os.Stat(path)
+			if err != nil {
+				return
+			}
+			if wait := watchFolderSettleTime - time.Since(fi.ModTime()); wait > 0 {
+				select {
+				case <-s.Closer.C():
+					return
+				case <-time.After(wait):
+				}
+				continue
+			}
+			break
+		}
+
+		if s.Closer.IsSet() {
+			return
+		}
+
+		// Malformed torrent files can make parsing panic
+		defer func() {
+			if r := recover(); r != nil {
+				log.Errorf("Could not import %s from watch folder: %v", path, r)
+				moveWatchFolderFile(path, watchFolderFailed, fmt.Errorf("%v", r))
+			}
+		}()
+
+		err := s.addWatchFolderTorrent(path)
+		if err != nil {
+			log.Warningf("Could not import %s from watch folder: %s", path, err)
+			moveWatchFolderFile(path, watchFolderFailed, err)
+		} else {
+			moveWatchFolderFile(path, watchFolderProcessed, nil)
+		}
+	}()
+}
+
+func (s *Service) addWatchFolderTorrent(path string) error {
+	log.Infof("Importing %s from watch folder", path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	uri := path
+	torrent := NewTorrentFile("")
+	if strings.EqualFold(filepath.Ext(path), ".magnet") {
+		uri = strings.TrimSpace(string(data))
+		if !strings.HasPrefix(uri, "magnet:") {
+			return errors.New("File does not contain a magnet link")
+		}
+		torrent = NewTorrentFile(uri)
+	} else if err := torrent.LoadFromBytes(data); err != nil {
+		return fmt.Errorf("Could not parse torrent file: %s", err)
+	}
+
+	if torrent.InfoHash != "" && s.GetTorrentByHash(torrent.InfoHash) != nil {
+		log.Infof("Torrent %s from %s is already added", torrent.InfoHash, path)
+		return nil
+	}
+
+	category := s.config.WatchFoldersCategory
+	if category != "" && database.GetStorm().GetCategory(category) == nil {
+		log.Warningf("Default watch folder category %s does not exist", category)
+		category = ""
+	}
+
+	t, err := s.AddTorrentWithCategory(nil, uri, category, s.config.WatchFoldersPaused, config.StorageFile, true, time.Now())
+	if err != nil {
+		return err
+	} else if t == nil {
+		return errors.New("Torrent was not added")
+	}
+
+	database.GetStorm().UpdateBTItem(t.InfoHash(), 0, "", []string{}, t.Name(), 0, 0, 0)
+
+	t.DownloadAllFiles()
+	t.SaveDBFiles()
+
+	return nil
+}
+
+// moveWatchFolderFile moves imported file into a subfolder,
+// for failed imports error is written next to it into a file with .error extension
+func moveWatchFolderFile(path string, subfolder string, cause error) {
+	dir := filepath.Join(filepath.Dir(path), subfolder)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Errorf("Could not create folder %s: %s", dir, err)
+		return
+	}
+
+	name := filepath.Base(path)
+	dst := filepath.Join(dir, name)
+	if _, err := os.Stat(dst); err == nil {
+		ext := filepath.Ext(name)
+		dst = filepath.Join(dir, fmt.Sprintf("%s.%d%s", strings.TrimSuffix(name, ext), time.Now().Unix(), ext))
+	}
+
+	if err := os.Rename(path, dst); err != nil {
+		log.Errorf("Could not move %s to %s: %s", path, dst, err)
+		return
+	}
+
+	if cause != nil {
+		message := fmt.Sprintf("%s: %s\n", time.Now().Format(time.RFC3339), cause)
+		if err := os.WriteFile(dst+".error", []byte(message), 0644); err != nil {
+			log.Errorf("Could not write error for %s: %s", dst, err)
+		}
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/elgatito/elementum/xbmc"
 
 	"github.com/anacrolix/sync"
+	"github.com/anacrolix/tagflag"
 	"github.com/dustin/go-humanize"
 	"github.com/op/go-logging"
 	"github.com/pbnjay/memory"
@@ -113,6 +114,7 @@ type Configuration struct {
 	AutoAdjustMemorySize        bool
 	AutoMemorySizeStrategy      int
 	MemorySize                  int
+	SpilloverCacheSize          int
 	AutoAdjustBufferSize        bool
 	MinCandidateSize            int64
 	MinCandidateShowSize        int64
@@ -122,9 +124,16 @@ type Configuration struct {
 	KodiBufferSize              int
 	UploadRateLimit             int
 	DownloadRateLimit           int
+	BackgroundRateLimit         int
 	AutoloadTorrents            bool
 	AutoloadTorrentsPaused      bool
 	LimitAfterBuffering         bool
+	BandwidthScheduleEnabled    bool
+	BandwidthSchedule           []BandwidthWindow
+	WatchFoldersEnabled         bool
+	WatchFolders                []string
+	WatchFoldersPaused          bool
+	WatchFoldersCategory        string
 	ConnectionsLimit            int
 	ConnTrackerLimit            int
 	ConnTrackerLimitAuto        bool
@@ -159,6 +168,10 @@ type Configuration struct {
 	AddExtraTrackers         int
 	RemoveOriginalTrackers   bool
 	ModifyTrackersStrategy   int
+	ScrapeTrackers           bool
+	TrackerSources           []string
+	TrackerBlocklist         []string
+	TrackerPruneFailures     int
 	Scrobble                 bool
 
 	AutoScrapeEnabled        bool
@@ -169,6 +182,12 @@ type Configuration struct {
 	AutoScrapeLimitMovies    int
 	AutoScrapeInterval       int
 
+	FollowShowsEnabled    bool
+	FollowShowsSearchDays int
+
+	DLNAEnabled      bool
+	DLNAFriendlyName string
+
 	TraktAuthorized                bool
 	TraktUsername                  string
 	TraktToken                     string
@@ -234,6 +253,9 @@ type Configuration struct {
 	SortingModeShows            int
 	ResolutionPreferenceMovies  int
 	ResolutionPreferenceShows   int
+	QualityProfileMovies        string
+	QualityProfileShows         string
+	QualityUpgradeEnabled       bool
 	PercentageAdditionalSeeders int
 
 	CustomProviderTimeoutEnabled bool
@@ -311,6 +333,16 @@ var (
 		TorrentsPath string `help:"Custom path to addon downloads folder"`
 
 		ExportConfig string `help:"Export current configuration, taken from Kodi into a file. Should end with json or yml suffix"`
+
+		CreateTorrent   string        `help:"Create .torrent file from a local file or folder and exit"`
+		CreateOutput    string        `help:"Path of the created .torrent file (Torrent name with .torrent suffix in current folder by default)"`
+		CreatePieceSize tagflag.Bytes `help:"Piece size of the created torrent (Chosen by content size by default)"`
+		CreateTracker   []string      `help:"Tracker of the created torrent, can be repeated"`
+		CreateWebSeed   []string      `help:"Web seed of the created torrent, can be repeated"`
+		CreatePrivate   bool          `help:"Mark created torrent as private"`
+		CreateComment   string        `help:"Comment of the created torrent"`
+		CreateHybrid    bool          `help:"Create hybrid torrent with both v1 and v2 metadata"`
+		CreateSeed      bool          `help:"Keep running and seed created torrent (Content should be in the download path)"`
 	}{
 		DisableBackup: false,
 
@@ -534,6 +566,7 @@ func Reload() (ret *Configuration, err error) {
 		AutoAdjustMemorySize:        settings.ToBool("auto_adjust_memory_size"),
 		AutoMemorySizeStrategy:      settings.ToInt("auto_memory_size_strategy"),
 		MemorySize:                  settings.ToInt("memory_size") * 1024 * 1024,
+		SpilloverCacheSize:          settings.ToInt("spillover_cache_size") * 1024 * 1024,
 		AutoKodiBufferSize:          settings.ToBool("auto_kodi_buffer_size"),
 		AutoAdjustBufferSize:        settings.ToBool("auto_adjust_buffer_size"),
 		MinCandidateSize:            int64(settings.ToInt("min_candidate_size") * 1024 * 1024),
@@ -543,10 +576,15 @@ func Reload() (ret *Configuration, err error) {
 		EndBufferSize:               settings.ToInt("end_buffer_size") * 1024 * 1024,
 		UploadRateLimit:             settings.ToInt("max_upload_rate") * 1024,
 		DownloadRateLimit:           settings.ToInt("max_download_rate") * 1024,
+		BackgroundRateLimit:         settings.ToInt("background_streaming_rate") * 1024,
 		AutoloadTorrents:            settings.ToBool("autoload_torrents"),
 		AutoloadTorrentsPaused:      settings.ToBool("autoload_torrents_paused"),
 		SpoofUserAgent:              settings.ToInt("spoof_user_agent"),
 		LimitAfterBuffering:         settings.ToBool("limit_after_buffering"),
+		BandwidthScheduleEnabled:    settings.ToBool("bandwidth_schedule_enabled"),
+		WatchFoldersEnabled:         settings.ToBool("watch_folders_enabled"),
+		WatchFoldersPaused:          settings.ToBool("watch_folders_paused"),
+		WatchFoldersCategory:        settings.ToString("watch_folders_category"),
 		DownloadFileStrategy:        settings.ToInt("download_file_strategy"),
 		KeepDownloading:             settings.ToInt("keep_downloading"),
 		KeepFilesPlaying:            settings.ToInt("keep_files_playing"),
@@ -623,6 +661,8 @@ func Reload() (ret *Configuration, err error) {
 		AddExtraTrackers:            settings.ToInt("add_extra_trackers"),
 		RemoveOriginalTrackers:      settings.ToBool("remove_original_trackers"),
 		ModifyTrackersStrategy:      settings.ToInt("modify_trackers_strategy"),
+		ScrapeTrackers:              settings.ToBool("scrape_trackers"),
+		TrackerPruneFailures:        settings.ToInt("tracker_prune_failures"),
 		ConnectionsLimit:            settings.ToInt("connections_limit"),
 		ConnTrackerLimit:            settings.ToInt("conntracker_limit"),
 		ConnTrackerLimitAuto:        settings.ToBool("conntracker_limit_auto"),
@@ -637,6 +677,12 @@ func Reload() (ret *Configuration, err error) {
 		AutoScrapeLimitMovies:    settings.ToInt("autoscrape_limit_movies"),
 		AutoScrapeInterval:       settings.ToInt("autoscrape_interval"),
 
+		FollowShowsEnabled:    settings.ToBool("follow_shows_enabled"),
+		FollowShowsSearchDays: settings.ToInt("follow_shows_search_days"),
+
+		DLNAEnabled:      settings.ToBool("dlna_enabled"),
+		DLNAFriendlyName: settings.ToString("dlna_friendly_name"),
+
 		TraktUsername:                  settings.ToString("trakt_username"),
 		TraktToken:                     settings.ToString("trakt_token"),
 		TraktRefreshToken:              settings.ToString("trakt_refresh_token"),
@@ -701,6 +747,9 @@ func Reload() (ret *Configuration, err error) {
 		SortingModeShows:            settings.ToInt("sorting_mode_shows"),
 		ResolutionPreferenceMovies:  settings.ToInt("resolution_preference_movies"),
 		ResolutionPreferenceShows:   settings.ToInt("resolution_preference_shows"),
+		QualityProfileMovies:        settings.ToString("quality_profile_movies"),
+		QualityProfileShows:         settings.ToString("quality_profile_shows"),
+		QualityUpgradeEnabled:       settings.ToBool("quality_upgrade_enabled"),
 		PercentageAdditionalSeeders: settings.ToInt("percentage_additional_seeders"),
 
 		CustomProviderTimeoutEnabled: settings.ToBool("custom_provider_timeout_enabled"),
@@ -818,6 +867,36 @@ func Reload() (ret *Configuration, err error) {
 		newConfig.StrmLanguage = newConfig.Language
 	}
 
+	// Parse weekly schedule of alternate speed limits
+	if newConfig.BandwidthScheduleEnabled {
+		if windows, err := ParseBandwidthSchedule(settings.ToString("bandwidth_schedule")); err != nil {
+			log.Warningf("Could not parse bandwidth schedule: %s", err)
+		} else {
+			newConfig.BandwidthSchedule = windows
+		}
+	}
+
+	// Watch folders are separated by "|", trailing separator is added to translate folder itself
+	if newConfig.WatchFoldersEnabled {
+		for _, folder := range strings.Split(settings.ToString("watch_folders"), "|") {
+			if folder = strings.TrimRight(strings.TrimSpace(folder), `/\`); folder != "" {
+				newConfig.WatchFolders = append(newConfig.WatchFolders, filepath.Clean(TranslatePath(xbmcHost, folder+"/")))
+			}
+		}
+	}
+
+	// Tracker sources and blocklist rules are one per line, as "|" is a part of regexp rules
+	for _, source := range strings.Split(settings.ToString("tracker_sources"), "\n") {
+		if source = strings.TrimSpace(source); source != "" {
+			newConfig.TrackerSources = append(newConfig.TrackerSources, source)
+		}
+	}
+	for _, rule := range strings.Split(settings.ToString("tracker_blocklist"), "\n") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			newConfig.TrackerBlocklist = append(newConfig.TrackerBlocklist, rule)
+		}
+	}
+
 	if newConfig.SessionSave == 0 {
 		newConfig.SessionSave = 10
 	}
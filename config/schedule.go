@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BandwidthWindow is a weekly time window with alternate speed limits.
+// Window that ends before it starts continues into the next day.
+type BandwidthWindow struct {
+	Name              string        `json:"name"`
+	Days              [7]bool       `json:"days"`
+	Start             time.Duration `json:"-"`
+	End               time.Duration `json:"-"`
+	DownloadRateLimit int           `json:"download_rate_limit"`
+	UploadRateLimit   int           `json:"upload_rate_limit"`
+}
+
+var scheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseBandwidthSchedule parses windows, separated by ";" or new lines, each in a form of
+// "<days> <HH:MM>-<HH:MM> <download>/<upload>", where days is a comma separated list of
+// days or day ranges ("mon-fri,sun"), or "daily", and limits are in KB/s, 0 means unlimited.
+// Example: "mon-fri 18:00-23:00 512/64; daily 01:00-07:00 0/0".
+func ParseBandwidthSchedule(schedule string) ([]BandwidthWindow, error) {
+	ret := []BandwidthWindow{}
+
+	entries := strings.FieldsFunc(schedule, func(r rune) bool {
+		return r == ';' || r == '\n'
+	})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		w, err := parseBandwidthWindow(entry)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid schedule entry '%s': %s", entry, err)
+		}
+		ret = append(ret, w)
+	}
+
+	return ret, nil
+}
+
+func parseBandwidthWindow(entry string) (w BandwidthWindow, err error) {
+	fields := strings.Fields(entry)
+	if len(fields) != 3 {
+		return w, fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+
+	w.Name = strings.Join(fields, " ")
+	if w.Days, err = parseScheduleDays(strings.ToLower(fields[0])); err != nil {
+		return
+	}
+
+	times := strings.Split(fields[1], "-")
+	if len(times) != 2 {
+		return w, fmt.Errorf("time range should be HH:MM-HH:MM")
+	}
+	if w.Start, err = parseScheduleTime(times[0]); err != nil {
+		return
+	}
+	if w.End, err = parseScheduleTime(times[1]); err != nil {
+		return
+	}
+	if w.Start == w.End {
+		return w, fmt.Errorf("time range is empty")
+	}
+
+	limits := strings.Split(fields[2], "/")
+	if len(limits) != 2 {
+		return w, fmt.Errorf("limits should be download/upload")
+	}
+	download, err := strconv.Atoi(limits[0])
+	if err != nil || download < 0 {
+		return w, fmt.Errorf("wrong download limit: %s", limits[0])
+	}
+	upload, err := strconv.Atoi(limits[1])
+	if err != nil || upload < 0 {
+		return w, fmt.Errorf("wrong upload limit: %s", limits[1])
+	}
+	w.DownloadRateLimit = download * 1024
+	w.UploadRateLimit = upload * 1024
+
+	return w, nil
+}
+
+func parseScheduleDays(s string) (days [7]bool, err error) {
+	if s == "daily" || s == "*" {
+		for i := range days {
+			days[i] = true
+		}
+		return
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.Split(part, "-")
+		if len(bounds) > 2 {
+			return days, fmt.Errorf("wrong days range: %s", part)
+		}
+
+		from, ok := scheduleDays[bounds[0]]
+		if !ok {
+			return days, fmt.Errorf("unknown day: %s", bounds[0])
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, ok = scheduleDays[bounds[1]]; !ok {
+				return days, fmt.Errorf("unknown day: %s", bounds[1])
+			}
+		}
+
+		for d := from; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return
+}
+
+func parseScheduleTime(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if s == "24:00" {
+			return 24 * time.Hour, nil
+		}
+		return 0, fmt.Errorf("wrong time: %s", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains returns true if window is active at given time
+func (w BandwidthWindow) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	day := t.Weekday()
+
+	if w.Start < w.End {
+		return w.Days[day] && offset >= w.Start && offset < w.End
+	}
+
+	// Window crosses midnight, so it either started today or yesterday
+	yesterday := (day + 6) % 7
+	return (w.Days[day] && offset >= w.Start) || (w.Days[yesterday] && offset < w.End)
+}
+
+// ActiveBandwidthWindow returns first window that contains given time
+func ActiveBandwidthWindow(windows []BandwidthWindow, t time.Time) *BandwidthWindow {
+	for i := range windows {
+		if windows[i].Contains(t) {
+			return &windows[i]
+		}
+	}
+	return nil
+}
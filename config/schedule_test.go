@@ -0,0 +1,116 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBandwidthSchedule(t *testing.T) {
+	weekdays := [7]bool{false, true, true, true, true, true, false}
+	all := [7]bool{true, true, true, true, true, true, true}
+
+	tests := []struct {
+		name     string
+		schedule string
+		windows  []BandwidthWindow
+		err      string
+	}{
+		{"empty", " ; \n", []BandwidthWindow{}, ""},
+		{"day range", "mon-fri 18:00-23:00 512/64", []BandwidthWindow{
+			{Name: "mon-fri 18:00-23:00 512/64", Days: weekdays, Start: 18 * time.Hour, End: 23 * time.Hour, DownloadRateLimit: 512 * 1024, UploadRateLimit: 64 * 1024},
+		}, ""},
+		{"separators", "daily 01:00-07:00 0/0;\n * 00:30-24:00 1/2", []BandwidthWindow{
+			{Name: "daily 01:00-07:00 0/0", Days: all, Start: time.Hour, End: 7 * time.Hour},
+			{Name: "* 00:30-24:00 1/2", Days: all, Start: 30 * time.Minute, End: 24 * time.Hour, DownloadRateLimit: 1024, UploadRateLimit: 2048},
+		}, ""},
+		{"day list is case-insensitive", "Sat,SUN,wed 23:00-02:00 100/10", []BandwidthWindow{
+			{Name: "Sat,SUN,wed 23:00-02:00 100/10", Days: [7]bool{true, false, false, true, false, false, true}, Start: 23 * time.Hour, End: 2 * time.Hour, DownloadRateLimit: 100 * 1024, UploadRateLimit: 10 * 1024},
+		}, ""},
+		{"day range wraps the week", "fri-mon 10:00-11:00 1/1", []BandwidthWindow{
+			{Name: "fri-mon 10:00-11:00 1/1", Days: [7]bool{true, true, false, false, false, true, true}, Start: 10 * time.Hour, End: 11 * time.Hour, DownloadRateLimit: 1024, UploadRateLimit: 1024},
+		}, ""},
+		{"missing field", "mon 10:00-11:00", nil, "expected 3 fields"},
+		{"unknown day", "mon-fry 10:00-11:00 1/1", nil, "unknown day: fry"},
+		{"wrong day range", "mon-tue-wed 10:00-11:00 1/1", nil, "wrong days range"},
+		{"single time", "mon 10:00 1/1", nil, "HH:MM-HH:MM"},
+		{"wrong time", "mon 10:00-25:00 1/1", nil, "wrong time: 25:00"},
+		{"empty range", "mon 10:00-10:00 1/1", nil, "time range is empty"},
+		{"single limit", "mon 10:00-11:00 1", nil, "download/upload"},
+		{"negative limit", "mon 10:00-11:00 -1/1", nil, "wrong download limit"},
+		{"wrong upload limit", "mon 10:00-11:00 1/x", nil, "wrong upload limit"},
+		{"error in second entry", "mon 10:00-11:00 1/1; tue", nil, "'tue'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows, err := ParseBandwidthSchedule(tt.schedule)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ParseBandwidthSchedule err = %v, want %q", err, tt.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(windows) != len(tt.windows) {
+				t.Fatalf("got %d windows, want %d", len(windows), len(tt.windows))
+			}
+			for i := range windows {
+				if windows[i] != tt.windows[i] {
+					t.Errorf("window %d = %+v, want %+v", i, windows[i], tt.windows[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBandwidthWindowContains(t *testing.T) {
+	parse := func(entry string) BandwidthWindow {
+		windows, err := ParseBandwidthSchedule(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return windows[0]
+	}
+	// 2026-10-12 is Monday
+	at := func(day, hour, minute, second int) time.Time {
+		return time.Date(2026, time.October, 12+day, hour, minute, second, 0, time.Local)
+	}
+
+	evening := parse("mon-fri 18:00-23:00 1/1")
+	night := parse("fri 23:00-02:00 1/1")
+	allDay := parse("sun 00:00-24:00 1/1")
+
+	tests := []struct {
+		name   string
+		window BandwidthWindow
+		t      time.Time
+		want   bool
+	}{
+		{"start is included", evening, at(0, 18, 0, 0), true},
+		{"inside", evening, at(4, 22, 30, 0), true},
+		{"end is excluded", evening, at(0, 23, 0, 0), false},
+		{"last second", evening, at(0, 22, 59, 59), true},
+		{"before start", evening, at(0, 17, 59, 59), false},
+		{"other day", evening, at(5, 19, 0, 0), false},
+		{"before midnight", night, at(4, 23, 30, 0), true},
+		{"midnight continues into next weekday", night, at(5, 0, 0, 0), true},
+		{"after midnight", night, at(5, 1, 59, 59), true},
+		{"end after midnight is excluded", night, at(5, 2, 0, 0), false},
+		{"morning of window day", night, at(4, 1, 0, 0), false},
+		{"night of next day", night, at(5, 23, 30, 0), false},
+		{"whole day from midnight", allDay, at(6, 0, 0, 0), true},
+		{"whole day until midnight", allDay, at(6, 23, 59, 59), true},
+		{"whole day does not continue", allDay, at(7, 0, 0, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Contains(tt.t); got != tt.want {
+				t.Errorf("%s Contains(%s) = %v, want %v", tt.window.Name, tt.t.Format("Mon 15:04:05"), got, tt.want)
+			}
+		})
+	}
+}
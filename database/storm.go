@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"time"
 
@@ -331,6 +332,13 @@ func (d *StormDatabase) UpdateBTItem(infoHash string, mediaID int, mediaType str
 
 	var oldItem BTItem
 	if err := d.db.One("InfoHash", infoHash, &oldItem); err == nil {
+		item.Category = oldItem.Category
+		item.SavePath = oldItem.SavePath
+		item.DownloadRateLimit = oldItem.DownloadRateLimit
+		item.UploadRateLimit = oldItem.UploadRateLimit
+		item.PriorityClass = oldItem.PriorityClass
+		item.Trackers = oldItem.Trackers
+
 		d.db.DeleteStruct(&oldItem)
 	}
 	if err := d.db.Save(&item); err != nil {
@@ -340,6 +348,42 @@ func (d *StormDatabase) UpdateBTItem(infoHash string, mediaID int, mediaType str
 	return nil
 }
 
+// UpdateBTItemCategory sets category for BTItem, creating an item if it does not exist
+func (d *StormDatabase) UpdateBTItemCategory(infoHash string, category string) error {
+	defer perf.ScopeTimer()()
+
+	item := BTItem{}
+	if err := d.db.One("InfoHash", infoHash, &item); err != nil {
+		item = BTItem{
+			InfoHash: infoHash,
+			State:    StateActive,
+			Files:    []string{},
+			Category: category,
+		}
+		return d.db.Save(&item)
+	}
+
+	item.Category = category
+	return d.db.Save(&item)
+}
+
+// UpdateBTItemSavePath sets save path for BTItem, creating an item if it does not exist
+func (d *StormDatabase) UpdateBTItemSavePath(infoHash string, savePath string) error {
+	defer perf.ScopeTimer()()
+
+	item := BTItem{}
+	if err := d.db.One("InfoHash", infoHash, &item); err != nil {
+		item = BTItem{
+			InfoHash: infoHash,
+			State:    StateActive,
+			Files:    []string{},
+		}
+	}
+
+	item.SavePath = savePath
+	return d.db.Save(&item)
+}
+
 // UpdateBTItemFiles ...
 func (d *StormDatabase) UpdateBTItemFiles(infoHash string, files []string) error {
 	defer perf.ScopeTimer()()
@@ -360,6 +404,103 @@ func (d *StormDatabase) DeleteBTItem(infoHash string) error {
 	return d.db.Delete(BTItemBucket, infoHash)
 }
 
+// UpdateBTItemLimits sets per-torrent bandwidth caps and priority class for BTItem, creating an item if it does not exist
+func (d *StormDatabase) UpdateBTItemLimits(infoHash string, downloadRateLimit, uploadRateLimit int, priorityClass string) error {
+	defer perf.ScopeTimer()()
+
+	item := BTItem{}
+	if err := d.db.One("InfoHash", infoHash, &item); err != nil {
+		item = BTItem{
+			InfoHash: infoHash,
+			State:    StateActive,
+			Files:    []string{},
+		}
+	}
+
+	item.DownloadRateLimit = downloadRateLimit
+	item.UploadRateLimit = uploadRateLimit
+	item.PriorityClass = priorityClass
+	return d.db.Save(&item)
+}
+
+// UpdateBTItemTrackers sets user-defined tracker tiers for BTItem, creating an item if it does not exist
+func (d *StormDatabase) UpdateBTItemTrackers(infoHash string, trackers [][]string) error {
+	defer perf.ScopeTimer()()
+
+	item := BTItem{}
+	if err := d.db.One("InfoHash", infoHash, &item); err != nil {
+		item = BTItem{
+			InfoHash: infoHash,
+			State:    StateActive,
+			Files:    []string{},
+		}
+	}
+
+	item.Trackers = trackers
+	return d.db.Save(&item)
+}
+
+// GetCategories returns all categories, sorted by name
+func (d *StormDatabase) GetCategories() []Category {
+	defer perf.ScopeTimer()()
+
+	var categories []Category
+	if err := d.db.All(&categories); err != nil {
+		return []Category{}
+	}
+
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+	return categories
+}
+
+// GetCategory returns category by name
+func (d *StormDatabase) GetCategory(name string) *Category {
+	if name == "" {
+		return nil
+	}
+
+	defer perf.ScopeTimer()()
+
+	category := &Category{}
+	if err := d.db.One("Name", name, category); err != nil {
+		return nil
+	}
+
+	return category
+}
+
+// SaveCategory creates or replaces a category
+func (d *StormDatabase) SaveCategory(category *Category) error {
+	defer perf.ScopeTimer()()
+
+	if category == nil || category.Name == "" {
+		return errors.New("Category name is empty")
+	}
+
+	return d.db.Save(category)
+}
+
+// DeleteCategory removes a category and unassigns it from all BTItems
+func (d *StormDatabase) DeleteCategory(name string) error {
+	defer perf.ScopeTimer()()
+
+	if err := d.db.Delete(CategoryBucket, name); err != nil {
+		return err
+	}
+
+	var items []BTItem
+	if err := d.db.Find("Category", name, &items); err == nil {
+		for _, item := range items {
+			item.Category = ""
+			d.db.Save(&item)
+		}
+	}
+
+	return nil
+}
+
 // AddTorrentHistory saves last used torrent
 func (d *StormDatabase) AddTorrentHistory(infoHash, name string, b []byte) {
 	defer perf.ScopeTimer()()
@@ -400,6 +541,264 @@ func (d *StormDatabase) AddTorrentHistory(infoHash, name string, b []byte) {
 	d.db.ReIndex(&TorrentHistory{})
 }
 
+// HasTorrentHistory checks if torrent is in the history
+func (d *StormDatabase) HasTorrentHistory(infoHash string) bool {
+	defer perf.ScopeTimer()()
+
+	var th TorrentHistory
+	return d.db.One("InfoHash", infoHash, &th) == nil
+}
+
+// GetFeeds returns all feeds, sorted by name
+func (d *StormDatabase) GetFeeds() []Feed {
+	defer perf.ScopeTimer()()
+
+	var feeds []Feed
+	if err := d.db.All(&feeds); err != nil {
+		return []Feed{}
+	}
+
+	sort.Slice(feeds, func(i, j int) bool {
+		return feeds[i].Name < feeds[j].Name
+	})
+	return feeds
+}
+
+// GetFeed returns feed by name
+func (d *StormDatabase) GetFeed(name string) *Feed {
+	defer perf.ScopeTimer()()
+
+	feed := &Feed{}
+	if err := d.db.One("Name", name, feed); err != nil {
+		return nil
+	}
+
+	return feed
+}
+
+// SaveFeed creates or replaces a feed
+func (d *StormDatabase) SaveFeed(feed *Feed) error {
+	defer perf.ScopeTimer()()
+
+	if feed == nil || feed.Name == "" {
+		return errors.New("Feed name is empty")
+	}
+
+	return d.db.Save(feed)
+}
+
+// DeleteFeed removes a feed, grabs are kept to avoid downloading same torrents again
+func (d *StormDatabase) DeleteFeed(name string) error {
+	defer perf.ScopeTimer()()
+
+	return d.db.Delete(FeedBucket, name)
+}
+
+// HasFeedGrab checks if torrent was already added from any feed
+func (d *StormDatabase) HasFeedGrab(infoHash string) bool {
+	defer perf.ScopeTimer()()
+
+	var grab FeedGrab
+	return d.db.One("InfoHash", infoHash, &grab) == nil
+}
+
+// AddFeedGrab saves a record of torrent, added from a feed
+func (d *StormDatabase) AddFeedGrab(infoHash, feed, title string) error {
+	defer perf.ScopeTimer()()
+
+	return d.db.Save(&FeedGrab{
+		InfoHash: infoHash,
+		Feed:     feed,
+		Title:    title,
+		Dt:       time.Now(),
+	})
+}
+
+// GetFeedGrabs returns latest grabs of a feed
+func (d *StormDatabase) GetFeedGrabs(feed string, limit int) []FeedGrab {
+	defer perf.ScopeTimer()()
+
+	var grabs []FeedGrab
+	if err := d.db.Find("Feed", feed, &grabs); err != nil {
+		return []FeedGrab{}
+	}
+
+	sort.Slice(grabs, func(i, j int) bool {
+		return grabs[i].Dt.After(grabs[j].Dt)
+	})
+	if limit > 0 && len(grabs) > limit {
+		grabs = grabs[:limit]
+	}
+	return grabs
+}
+
+// GetFollowedShows returns all followed shows
+func (d *StormDatabase) GetFollowedShows() []FollowedShow {
+	defer perf.ScopeTimer()()
+
+	var shows []FollowedShow
+	if err := d.db.All(&shows); err != nil {
+		return []FollowedShow{}
+	}
+
+	return shows
+}
+
+// GetFollowedShow returns followed show by TMDB id
+func (d *StormDatabase) GetFollowedShow(showID int) *FollowedShow {
+	defer perf.ScopeTimer()()
+
+	show := &FollowedShow{}
+	if err := d.db.One("ID", showID, show); err != nil {
+		return nil
+	}
+
+	return show
+}
+
+// SaveFollowedShow creates or replaces a followed show
+func (d *StormDatabase) SaveFollowedShow(show *FollowedShow) error {
+	defer perf.ScopeTimer()()
+
+	if show == nil || show.ID == 0 {
+		return errors.New("Show id is empty")
+	}
+
+	return d.db.Save(show)
+}
+
+// UnfollowShow stops following a show, grabs are kept to avoid downloading same episodes again
+func (d *StormDatabase) UnfollowShow(showID int) error {
+	defer perf.ScopeTimer()()
+
+	return d.db.Delete(FollowedShowBucket, showID)
+}
+
+// HasEpisodeGrab checks if episode was already downloaded for a followed show
+func (d *StormDatabase) HasEpisodeGrab(showID, season, episode int) bool {
+	defer perf.ScopeTimer()()
+
+	var grab EpisodeGrab
+	return d.db.One("ID", episodeGrabID(showID, season, episode), &grab) == nil
+}
+
+// AddEpisodeGrab saves a record of episode, downloaded for a followed show
+func (d *StormDatabase) AddEpisodeGrab(showID, season, episode int, infoHash, title string) error {
+	defer perf.ScopeTimer()()
+
+	return d.db.Save(&EpisodeGrab{
+		ID:       episodeGrabID(showID, season, episode),
+		ShowID:   showID,
+		Season:   season,
+		Episode:  episode,
+		InfoHash: infoHash,
+		Title:    title,
+		Dt:       time.Now(),
+	})
+}
+
+// GetEpisodeGrabs returns downloaded episodes of a show, latest first
+func (d *StormDatabase) GetEpisodeGrabs(showID int) []EpisodeGrab {
+	defer perf.ScopeTimer()()
+
+	var grabs []EpisodeGrab
+	if err := d.db.Find("ShowID", showID, &grabs); err != nil {
+		return []EpisodeGrab{}
+	}
+
+	sort.Slice(grabs, func(i, j int) bool {
+		if grabs[i].Season != grabs[j].Season {
+			return grabs[i].Season > grabs[j].Season
+		}
+		return grabs[i].Episode > grabs[j].Episode
+	})
+	return grabs
+}
+
+func episodeGrabID(showID, season, episode int) string {
+	return fmt.Sprintf("%d_%d_%d", showID, season, episode)
+}
+
+// GetQualityProfiles returns all quality profiles, sorted by name
+func (d *StormDatabase) GetQualityProfiles() []QualityProfile {
+	defer perf.ScopeTimer()()
+
+	var profiles []QualityProfile
+	if err := d.db.All(&profiles); err != nil {
+		return []QualityProfile{}
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles
+}
+
+// GetQualityProfile returns quality profile by name
+func (d *StormDatabase) GetQualityProfile(name string) *QualityProfile {
+	defer perf.ScopeTimer()()
+
+	profile := &QualityProfile{}
+	if err := d.db.One("Name", name, profile); err != nil {
+		return nil
+	}
+
+	return profile
+}
+
+// SaveQualityProfile creates or replaces a quality profile
+func (d *StormDatabase) SaveQualityProfile(profile *QualityProfile) error {
+	defer perf.ScopeTimer()()
+
+	if profile == nil || profile.Name == "" {
+		return errors.New("Quality profile name is empty")
+	}
+
+	return d.db.Save(profile)
+}
+
+// DeleteQualityProfile removes a quality profile
+func (d *StormDatabase) DeleteQualityProfile(name string) error {
+	defer perf.ScopeTimer()()
+
+	return d.db.Delete(QualityProfileBucket, name)
+}
+
+// GetQualityUpgrades returns upgrades that are being downloaded
+func (d *StormDatabase) GetQualityUpgrades() []QualityUpgrade {
+	defer perf.ScopeTimer()()
+
+	var upgrades []QualityUpgrade
+	if err := d.db.All(&upgrades); err != nil {
+		return []QualityUpgrade{}
+	}
+
+	return upgrades
+}
+
+// IsQualityUpgrade checks if torrent is an upgrade, or is being replaced by one
+func (d *StormDatabase) IsQualityUpgrade(infoHash string) bool {
+	defer perf.ScopeTimer()()
+
+	var upgrade QualityUpgrade
+	return d.db.One("InfoHash", infoHash, &upgrade) == nil || d.db.One("Replaces", infoHash, &upgrade) == nil
+}
+
+// AddQualityUpgrade saves a torrent that should replace another torrent
+func (d *StormDatabase) AddQualityUpgrade(upgrade *QualityUpgrade) error {
+	defer perf.ScopeTimer()()
+
+	upgrade.Dt = time.Now()
+	return d.db.Save(upgrade)
+}
+
+// DeleteQualityUpgrade removes upgrade record
+func (d *StormDatabase) DeleteQualityUpgrade(infoHash string) error {
+	defer perf.ScopeTimer()()
+
+	return d.db.Delete(QualityUpgradeBucket, infoHash)
+}
+
 // Compress ...
 func (d *StormDatabase) Compress() (err error) {
 	d.mu.Lock()
@@ -65,6 +65,29 @@ type BTItem struct {
 	Season   int      `json:"season"`
 	Episode  int      `json:"episode"`
 	Query    string   `json:"query"`
+	Category string   `json:"category"`
+	// SavePath is a directory, torrent data was added to, it is kept when category changes
+	SavePath string `json:"save_path"`
+
+	DownloadRateLimit int    `json:"download_rate_limit"`
+	UploadRateLimit   int    `json:"upload_rate_limit"`
+	PriorityClass     string `json:"priority_class"`
+
+	// Trackers are tiers of tracker URLs, set by the user, nil if torrent trackers were not edited
+	Trackers [][]string `json:"trackers"`
+}
+
+// Category is a user-defined label for torrents, with own paths and limits.
+// Zero limits and empty paths mean that global settings are used.
+type Category struct {
+	Name               string `json:"name" storm:"id"`
+	DownloadPath       string `json:"download_path"`
+	CompletedPath      string `json:"completed_path"`
+	SeedTimeLimit      int    `json:"seed_time_limit"`
+	SeedTimeRatioLimit int    `json:"seed_time_ratio_limit"`
+	ShareRatioLimit    int    `json:"share_ratio_limit"`
+	DownloadRateLimit  int    `json:"download_rate_limit"`
+	UploadRateLimit    int    `json:"upload_rate_limit"`
 }
 
 // LibraryItem ...
@@ -105,6 +128,83 @@ type TorrentHistory struct {
 	Metadata []byte
 }
 
+// Feed is a subscription to RSS, Atom or Torznab feed with rules for automatic downloading.
+// Empty rules and zero limits are not applied.
+type Feed struct {
+	Name          string    `json:"name" storm:"id"`
+	URL           string    `json:"url"`
+	Enabled       bool      `json:"enabled"`
+	Interval      int       `json:"interval"`
+	Include       string    `json:"include"`
+	Exclude       string    `json:"exclude"`
+	MinResolution int       `json:"min_resolution"`
+	MaxResolution int       `json:"max_resolution"`
+	VideoCodecs   []int     `json:"video_codecs"`
+	RipTypes      []int     `json:"rip_types"`
+	MinSize       int64     `json:"min_size"`
+	MaxSize       int64     `json:"max_size"`
+	MinSeeds      int64     `json:"min_seeds"`
+	Category      string    `json:"category"`
+	Paused        bool      `json:"paused"`
+	LastChecked   time.Time `json:"last_checked"`
+	LastError     string    `json:"last_error"`
+}
+
+// FeedGrab is a record of a torrent, added from a feed
+type FeedGrab struct {
+	InfoHash string    `json:"info_hash" storm:"id"`
+	Feed     string    `json:"feed" storm:"index"`
+	Title    string    `json:"title"`
+	Dt       time.Time `json:"dt" storm:"index"`
+}
+
+// FollowedShow is a show with automatic downloading of new episodes.
+// Episodes that aired before Since are not downloaded.
+type FollowedShow struct {
+	ID          int       `json:"id" storm:"id"`
+	Title       string    `json:"title"`
+	Category    string    `json:"category"`
+	Since       time.Time `json:"since"`
+	LastChecked time.Time `json:"last_checked"`
+}
+
+// EpisodeGrab is a record of an episode, downloaded for a followed show
+type EpisodeGrab struct {
+	ID       string    `json:"id" storm:"id"`
+	ShowID   int       `json:"show_id" storm:"index"`
+	Season   int       `json:"season"`
+	Episode  int       `json:"episode"`
+	InfoHash string    `json:"info_hash"`
+	Title    string    `json:"title"`
+	Dt       time.Time `json:"dt"`
+}
+
+// QualityProfile is a named set of rules for choosing torrents.
+// Resolutions, codecs and languages are listed in order of preference, sizes are in bytes per minute of runtime.
+// Media below the cutoff resolution and rip type is replaced when a better release appears.
+type QualityProfile struct {
+	Name             string   `json:"name" storm:"id"`
+	Resolutions      []int    `json:"resolutions"`
+	VideoCodecs      []int    `json:"video_codecs"`
+	RipTypes         []int    `json:"rip_types"`
+	MinSizePerMinute int64    `json:"min_size_per_minute"`
+	MaxSizePerMinute int64    `json:"max_size_per_minute"`
+	Languages        []string `json:"languages"`
+	ExcludeNuked     bool     `json:"exclude_nuked"`
+	CutoffResolution int      `json:"cutoff_resolution"`
+	CutoffRipType    int      `json:"cutoff_rip_type"`
+}
+
+// QualityUpgrade is a torrent that replaces lower quality torrent of the same media, when it is downloaded
+type QualityUpgrade struct {
+	InfoHash  string    `json:"info_hash" storm:"id"`
+	Replaces  string    `json:"replaces" storm:"index"`
+	MediaType string    `json:"media_type"`
+	MediaID   int       `json:"media_id"`
+	Title     string    `json:"title"`
+	Dt        time.Time `json:"dt"`
+}
+
 var (
 	stormFileName         = "storm.db"
 	backupStormFileName   = "storm-backup.db"
@@ -149,6 +249,20 @@ var CacheBuckets = [][]byte{
 const (
 	// BTItemBucket ...
 	BTItemBucket = "BTItem"
+	// CategoryBucket ...
+	CategoryBucket = "Category"
+	// FeedBucket ...
+	FeedBucket = "Feed"
+	// FeedGrabBucket ...
+	FeedGrabBucket = "FeedGrab"
+	// FollowedShowBucket ...
+	FollowedShowBucket = "FollowedShow"
+	// EpisodeGrabBucket ...
+	EpisodeGrabBucket = "EpisodeGrab"
+	// QualityProfileBucket ...
+	QualityProfileBucket = "QualityProfile"
+	// QualityUpgradeBucket ...
+	QualityUpgradeBucket = "QualityUpgrade"
 
 	// TorrentHistoryBucket ...
 	TorrentHistoryBucket = "TorrentHistory"
@@ -0,0 +1,501 @@
+package dlna
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/util"
+)
+
+const (
+	rootID      = "0"
+	torrentsID  = "torrents"
+	downloadsID = "downloads"
+
+	classFolder = "object.container.storageFolder"
+	classVideo  = "object.item.videoItem"
+	classAudio  = "object.item.audioItem.musicTrack"
+	classImage  = "object.item.imageItem.photo"
+
+	didlHeader = `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" ` +
+		`xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/" xmlns:dlna="urn:schemas-dlna-org:metadata-1-0/">`
+	didlFooter = `</DIDL-Lite>`
+
+	// Streaming transfer with byte range seeking
+	dlnaFlags = "DLNA.ORG_OP=01;DLNA.ORG_CI=0;DLNA.ORG_FLAGS=01700000000000000000000000000000"
+)
+
+type mediaType struct {
+	mime  string
+	class string
+}
+
+// mimeTypes lists file extensions, that are shown to renderers
+var mimeTypes = map[string]mediaType{
+	".3gp":  {"video/3gpp", classVideo},
+	".avi":  {"video/x-msvideo", classVideo},
+	".divx": {"video/x-msvideo", classVideo},
+	".flv":  {"video/x-flv", classVideo},
+	".m2ts": {"video/mp2t", classVideo},
+	".m4v":  {"video/mp4", classVideo},
+	".mkv":  {"video/x-matroska", classVideo},
+	".mov":  {"video/quicktime", classVideo},
+	".mp4":  {"video/mp4", classVideo},
+	".mpeg": {"video/mpeg", classVideo},
+	".mpg":  {"video/mpeg", classVideo},
+	".mts":  {"video/mp2t", classVideo},
+	".ogv":  {"video/ogg", classVideo},
+	".ts":   {"video/mp2t", classVideo},
+	".vob":  {"video/mpeg", classVideo},
+	".webm": {"video/webm", classVideo},
+	".wmv":  {"video/x-ms-wmv", classVideo},
+
+	".aac":  {"audio/aac", classAudio},
+	".ac3":  {"audio/ac3", classAudio},
+	".flac": {"audio/flac", classAudio},
+	".m4a":  {"audio/mp4", classAudio},
+	".mka":  {"audio/x-matroska", classAudio},
+	".mp3":  {"audio/mpeg", classAudio},
+	".ogg":  {"audio/ogg", classAudio},
+	".opus": {"audio/ogg", classAudio},
+	".wav":  {"audio/wav", classAudio},
+	".wma":  {"audio/x-ms-wma", classAudio},
+
+	".bmp":  {"image/bmp", classImage},
+	".gif":  {"image/gif", classImage},
+	".jpeg": {"image/jpeg", classImage},
+	".jpg":  {"image/jpeg", classImage},
+	".png":  {"image/png", classImage},
+	".webp": {"image/webp", classImage},
+}
+
+// mimeExtensions returns known extensions in stable order
+func mimeExtensions() []string {
+	ret := make([]string, 0, len(mimeTypes))
+	for ext := range mimeTypes {
+		ret = append(ret, ext)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func protocolInfo(mime string) string {
+	return fmt.Sprintf("http-get:*:%s:%s", mime, dlnaFlags)
+}
+
+func mediaTypeOf(name string) (mediaType, bool) {
+	mt, ok := mimeTypes[strings.ToLower(filepath.Ext(name))]
+	return mt, ok
+}
+
+// object is a container or an item of the content directory
+type object struct {
+	id     string
+	parent string
+	title  string
+
+	container  bool
+	childCount int
+
+	class string
+	mime  string
+	path  string
+	size  int64
+}
+
+func (o *object) didl(base string) string {
+	var b strings.Builder
+	if o.container {
+		fmt.Fprintf(&b, `<container id="%s" parentID="%s" restricted="1" searchable="0" childCount="%d">`,
+			xmlEscape(o.id), xmlEscape(o.parent), o.childCount)
+		fmt.Fprintf(&b, `<dc:title>%s</dc:title><upnp:class>%s</upnp:class>`, xmlEscape(o.title), classFolder)
+		b.WriteString(`</container>`)
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, `<item id="%s" parentID="%s" restricted="1">`, xmlEscape(o.id), xmlEscape(o.parent))
+	fmt.Fprintf(&b, `<dc:title>%s</dc:title><upnp:class>%s</upnp:class>`, xmlEscape(o.title), o.class)
+	fmt.Fprintf(&b, `<res protocolInfo="%s" size="%d">%s</res>`, xmlEscape(protocolInfo(o.mime)), o.size, xmlEscape(base+o.path))
+	b.WriteString(`</item>`)
+	return b.String()
+}
+
+// Torrents lists active torrents, that are shown in the content directory
+type Torrents interface {
+	GetTorrents() []*bittorrent.Torrent
+	GetTorrentByHash(hash string) *bittorrent.Torrent
+}
+
+// contentDirectory exposes active torrents and the download directory as a tree of objects
+type contentDirectory struct {
+	s Torrents
+}
+
+func (cd *contentDirectory) handle(r *http.Request, action string, args map[string]string) ([]soapArg, error) {
+	switch action {
+	case "Browse":
+		return cd.browse(r, args)
+	case "GetSearchCapabilities":
+		return []soapArg{{"SearchCaps", ""}}, nil
+	case "GetSortCapabilities":
+		return []soapArg{{"SortCaps", ""}}, nil
+	case "GetSystemUpdateID":
+		return []soapArg{{"Id", strconv.FormatUint(uint64(cd.updateID()), 10)}}, nil
+	}
+
+	return nil, &soapError{upnpErrorInvalidAction, "Invalid action " + action}
+}
+
+func (cd *contentDirectory) browse(r *http.Request, args map[string]string) ([]soapArg, error) {
+	id := args["ObjectID"]
+	start, _ := strconv.Atoi(args["StartingIndex"])
+	count, _ := strconv.Atoi(args["RequestedCount"])
+	if start < 0 || count < 0 {
+		return nil, &soapError{upnpErrorInvalidArgs, "Invalid paging arguments"}
+	}
+
+	var objects []*object
+	total := 0
+	switch args["BrowseFlag"] {
+	case "BrowseMetadata":
+		o := cd.object(id)
+		if o == nil {
+			return nil, &soapError{upnpErrorNoSuchObject, "No such object " + id}
+		}
+		objects = []*object{o}
+		total = 1
+	case "BrowseDirectChildren":
+		children, ok := cd.children(id)
+		if !ok {
+			return nil, &soapError{upnpErrorNoSuchObject, "No such object " + id}
+		}
+
+		total = len(children)
+		if start > len(children) {
+			start = len(children)
+		}
+		children = children[start:]
+		if count > 0 && count < len(children) {
+			children = children[:count]
+		}
+		objects = children
+	default:
+		return nil, &soapError{upnpErrorInvalidArgs, "Invalid browse flag"}
+	}
+
+	base := baseURL(r)
+
+	var b bytes.Buffer
+	b.WriteString(didlHeader)
+	for _, o := range objects {
+		b.WriteString(o.didl(base))
+	}
+	b.WriteString(didlFooter)
+
+	return []soapArg{
+		{"Result", b.String()},
+		{"NumberReturned", strconv.Itoa(len(objects))},
+		{"TotalMatches", strconv.Itoa(total)},
+		{"UpdateID", strconv.FormatUint(uint64(cd.updateID()), 10)},
+	}, nil
+}
+
+// updateID changes whenever the list of active torrents changes
+func (cd *contentDirectory) updateID() uint32 {
+	hashes := []string{}
+	for _, t := range cd.s.GetTorrents() {
+		hashes = append(hashes, t.InfoHash())
+	}
+	sort.Strings(hashes)
+
+	return crc32.ChecksumIEEE([]byte(strings.Join(hashes, ",")))
+}
+
+// object returns metadata of a single object
+func (cd *contentDirectory) object(id string) *object {
+	switch id {
+	case rootID:
+		return &object{id: rootID, parent: "-1", title: FriendlyName(), container: true, childCount: 2}
+	case torrentsID:
+		children, _ := cd.children(torrentsID)
+		return &object{id: torrentsID, parent: rootID, title: "Torrents", container: true, childCount: len(children)}
+	case downloadsID:
+		children, _ := cd.children(downloadsID)
+		return &object{id: downloadsID, parent: rootID, title: "Downloads", container: true, childCount: len(children)}
+	}
+
+	if strings.HasPrefix(id, torrentsID+"/") {
+		parts := strings.Split(id, "/")
+		t := cd.s.GetTorrentByHash(parts[1])
+		if t == nil || !t.HasMetadata() {
+			return nil
+		}
+
+		if len(parts) == 2 {
+			return cd.torrentObject(t)
+		} else if len(parts) == 3 {
+			index, err := strconv.Atoi(parts[2])
+			if err != nil {
+				return nil
+			}
+			for _, f := range t.GetFiles() {
+				if f.Index == index {
+					return cd.torrentFileObject(t, f)
+				}
+			}
+		}
+		return nil
+	}
+
+	if strings.HasPrefix(id, downloadsID+"/") {
+		rel, ok := downloadPath(strings.TrimPrefix(id, downloadsID+"/"))
+		if !ok {
+			return nil
+		}
+
+		fi, err := os.Stat(filepath.Join(config.Get().DownloadPath, rel))
+		if err != nil {
+			return nil
+		}
+		return cd.downloadObject(rel, fi, cd.torrentPaths())
+	}
+
+	return nil
+}
+
+// children returns sorted children of a container, or false for unknown containers
+func (cd *contentDirectory) children(id string) ([]*object, bool) {
+	switch id {
+	case rootID:
+		return []*object{cd.object(torrentsID), cd.object(downloadsID)}, true
+	case torrentsID:
+		ret := []*object{}
+		for _, t := range cd.s.GetTorrents() {
+			if !t.HasMetadata() {
+				continue
+			}
+			if o := cd.torrentObject(t); o.childCount > 0 {
+				ret = append(ret, o)
+			}
+		}
+		sort.Slice(ret, func(i, j int) bool {
+			return strings.ToLower(ret[i].title) < strings.ToLower(ret[j].title)
+		})
+		return ret, true
+	case downloadsID:
+		return cd.downloadChildren("")
+	}
+
+	if strings.HasPrefix(id, torrentsID+"/") {
+		parts := strings.Split(id, "/")
+		if len(parts) != 2 {
+			return nil, false
+		}
+		t := cd.s.GetTorrentByHash(parts[1])
+		if t == nil || !t.HasMetadata() {
+			return nil, false
+		}
+		return cd.torrentChildren(t), true
+	}
+
+	if strings.HasPrefix(id, downloadsID+"/") {
+		rel, ok := downloadPath(strings.TrimPrefix(id, downloadsID+"/"))
+		if !ok {
+			return nil, false
+		}
+		return cd.downloadChildren(rel)
+	}
+
+	return nil, false
+}
+
+func (cd *contentDirectory) torrentObject(t *bittorrent.Torrent) *object {
+	return &object{
+		id:         torrentsID + "/" + t.InfoHash(),
+		parent:     torrentsID,
+		title:      t.Name(),
+		container:  true,
+		childCount: len(cd.torrentChildren(t)),
+	}
+}
+
+func (cd *contentDirectory) torrentChildren(t *bittorrent.Torrent) []*object {
+	ret := []*object{}
+	for _, f := range t.GetFiles() {
+		if o := cd.torrentFileObject(t, f); o != nil {
+			ret = append(ret, o)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return strings.ToLower(ret[i].title) < strings.ToLower(ret[j].title)
+	})
+	return ret
+}
+
+// torrentFileObject returns media file item, it is served by the torrents file server,
+// so that pieces are prioritized as the renderer reads the file
+func (cd *contentDirectory) torrentFileObject(t *bittorrent.Torrent, f *bittorrent.File) *object {
+	mt, ok := mediaTypeOf(f.Name)
+	if !ok {
+		return nil
+	}
+
+	return &object{
+		id:     fmt.Sprintf("%s/%s/%d", torrentsID, t.InfoHash(), f.Index),
+		parent: torrentsID + "/" + t.InfoHash(),
+		title:  f.Name,
+		class:  mt.class,
+		mime:   mt.mime,
+		path:   "/files/" + util.EncodeFileURL(f.Path),
+		size:   f.Size,
+	}
+}
+
+// torrentPaths maps absolute paths of active torrents files to their file server paths
+func (cd *contentDirectory) torrentPaths() map[string]string {
+	ret := map[string]string{}
+	for _, t := range cd.s.GetTorrents() {
+		if t.IsMemoryStorage() {
+			continue
+		}
+		for _, f := range t.GetFiles() {
+			ret[filepath.Join(t.GetSavePath(), f.Path)] = "/files/" + util.EncodeFileURL(f.Path)
+		}
+	}
+	return ret
+}
+
+func (cd *contentDirectory) downloadChildren(rel string) ([]*object, bool) {
+	root := config.Get().DownloadPath
+	if root == "" {
+		return []*object{}, true
+	}
+
+	entries, err := os.ReadDir(filepath.Join(root, rel))
+	if err != nil {
+		if rel == "" {
+			return []*object{}, true
+		}
+		return nil, false
+	}
+
+	torrentPaths := cd.torrentPaths()
+	dirs := []*object{}
+	files := []*object{}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		o := cd.downloadObject(filepath.Join(rel, e.Name()), fi, torrentPaths)
+		if o == nil {
+			continue
+		} else if o.container {
+			dirs = append(dirs, o)
+		} else {
+			files = append(files, o)
+		}
+	}
+
+	for _, list := range [][]*object{dirs, files} {
+		sort.Slice(list, func(i, j int) bool {
+			return strings.ToLower(list[i].title) < strings.ToLower(list[j].title)
+		})
+	}
+	return append(dirs, files...), true
+}
+
+// downloadObject returns object for a path, relative to the download directory,
+// files of active torrents are served through the torrents file server
+func (cd *contentDirectory) downloadObject(rel string, fi os.FileInfo, torrentPaths map[string]string) *object {
+	id := downloadsID + "/" + filepath.ToSlash(rel)
+	parent := downloadsID
+	if dir := filepath.Dir(rel); dir != "." {
+		parent = downloadsID + "/" + filepath.ToSlash(dir)
+	}
+
+	if fi.IsDir() {
+		count := 0
+		if entries, err := os.ReadDir(filepath.Join(config.Get().DownloadPath, rel)); err == nil {
+			for _, e := range entries {
+				if _, ok := mediaTypeOf(e.Name()); (ok || e.IsDir()) && !strings.HasPrefix(e.Name(), ".") {
+					count++
+				}
+			}
+		}
+		return &object{id: id, parent: parent, title: fi.Name(), container: true, childCount: count}
+	}
+
+	mt, ok := mediaTypeOf(fi.Name())
+	if !ok || !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	p, ok := torrentPaths[filepath.Join(config.Get().DownloadPath, rel)]
+	if !ok {
+		p = "/dlna/downloads/" + util.EncodeFileURL(rel)
+	}
+
+	return &object{
+		id:     id,
+		parent: parent,
+		title:  fi.Name(),
+		class:  mt.class,
+		mime:   mt.mime,
+		path:   p,
+		size:   fi.Size(),
+	}
+}
+
+// downloadPath converts object path into relative file path, that does not leave the download directory
+func downloadPath(p string) (string, bool) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if cleaned == "" || cleaned != p {
+		return "", false
+	}
+	for _, part := range strings.Split(cleaned, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", false
+		}
+	}
+	return filepath.FromSlash(cleaned), true
+}
+
+// serveDownload serves files from the download directory, that do not belong to active torrents
+func serveDownload(w http.ResponseWriter, r *http.Request) {
+	rel, ok := downloadPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	name := filepath.Join(config.Get().DownloadPath, rel)
+	mt, ok := mediaTypeOf(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if fi, err := os.Stat(name); err != nil || !fi.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", mt.mime)
+	w.Header().Set("transferMode.dlna.org", "Streaming")
+	w.Header().Set("contentFeatures.dlna.org", dlnaFlags)
+	http.ServeFile(w, r, name)
+}
@@ -0,0 +1,225 @@
+package dlna
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/broadcast"
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/util/event"
+)
+
+const (
+	deviceType = "urn:schemas-upnp-org:device:MediaServer:1"
+
+	contentDirectoryType  = "urn:schemas-upnp-org:service:ContentDirectory:1"
+	connectionManagerType = "urn:schemas-upnp-org:service:ConnectionManager:1"
+
+	checkInterval = time.Minute
+)
+
+var (
+	log = logging.MustGetLogger("dlna")
+
+	closer = event.Event{}
+
+	// mu protects running SSDP server
+	mu     sync.Mutex
+	server *ssdpServer
+)
+
+// Stop stops announcing media server and sends byebye messages
+func Stop() {
+	closer.Set()
+}
+
+// Start announces media server on the local network, while it is enabled in settings
+func Start(s *bittorrent.Service) {
+	closing := closer.C()
+	globalCloser := broadcast.Closer.C()
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	defer stopServer()
+	update(s)
+
+	for {
+		select {
+		case <-globalCloser:
+			log.Info("Closing DLNA server...")
+			return
+		case <-closing:
+			log.Info("Closing DLNA server...")
+			return
+		case <-ticker.C:
+			update(s)
+		}
+	}
+}
+
+// update starts or stops SSDP server, following settings and network interfaces changes
+func update(s *bittorrent.Service) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	enabled := config.Get().DLNAEnabled && !s.Closer.IsSet()
+	addrs := interfaceAddrs()
+
+	if server != nil && (!enabled || server.signature != addrsSignature(addrs)) {
+		server.close()
+		server = nil
+	}
+	if !enabled {
+		return
+	}
+
+	if server == nil {
+		if len(addrs) == 0 {
+			log.Warning("No network interfaces to announce DLNA server on")
+			return
+		}
+
+		var err error
+		if server, err = newSSDPServer(DeviceUUID(), config.Args.LocalPort, addrs); err != nil {
+			log.Warningf("Could not start DLNA server: %s", err)
+			return
+		}
+		log.Infof("Started DLNA server %s on %s", FriendlyName(), server.signature)
+		return
+	}
+
+	if time.Since(server.lastNotify) >= notifyInterval {
+		server.notify(ssdpAlive)
+	}
+}
+
+func stopServer() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if server != nil {
+		server.close()
+		server = nil
+	}
+}
+
+// Handler serves device descriptions, control requests and files from the download directory,
+// files of active torrents are served by the torrents file server
+func Handler(s Torrents) http.Handler {
+	cd := &contentDirectory{s: s}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dlna/device.xml", deviceDescription)
+	mux.HandleFunc("/dlna/ContentDirectory.xml", serveDescription(contentDirectorySCPD))
+	mux.HandleFunc("/dlna/ConnectionManager.xml", serveDescription(connectionManagerSCPD))
+	mux.HandleFunc("/dlna/control/ContentDirectory", serveControl(contentDirectoryType, cd.handle))
+	mux.HandleFunc("/dlna/control/ConnectionManager", serveControl(connectionManagerType, handleConnectionManager))
+	mux.HandleFunc("/dlna/event/", serveEvents)
+	mux.Handle("/dlna/downloads/", http.StripPrefix("/dlna/downloads/", http.HandlerFunc(serveDownload)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.Get().DLNAEnabled {
+			http.NotFound(w, r)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// DeviceUUID returns device UUID, that stays the same between restarts on the same host
+func DeviceUUID() string {
+	hostname, _ := os.Hostname()
+	sum := md5.Sum([]byte(fmt.Sprintf("elementum:%s:%d", hostname, config.Args.LocalPort)))
+
+	// Name based UUID version 3
+	sum[6] = sum[6]&0x0f | 0x30
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// FriendlyName returns server name, that is shown on devices
+func FriendlyName() string {
+	if name := strings.TrimSpace(config.Get().DLNAFriendlyName); name != "" {
+		return name
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		return "Elementum"
+	}
+	return fmt.Sprintf("Elementum (%s)", hostname)
+}
+
+// interfaceAddr is an IPv4 address of a multicast capable interface
+type interfaceAddr struct {
+	iface net.Interface
+	ipnet *net.IPNet
+}
+
+// interfaceAddrs returns addresses, that HTTP server is reachable on
+func interfaceAddrs() []interfaceAddr {
+	listen := net.ParseIP(config.Args.LocalHost)
+	if listen != nil && listen.IsLoopback() {
+		return nil
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		log.Warningf("Could not list network interfaces: %s", err)
+		return nil
+	}
+
+	ret := []interfaceAddr{}
+	for _, i := range ifaces {
+		if i.Flags&net.FlagUp == 0 || i.Flags&net.FlagMulticast == 0 || i.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := i.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil {
+				continue
+			}
+			if listen != nil && !listen.IsUnspecified() && !listen.Equal(ipnet.IP) {
+				continue
+			}
+
+			ret = append(ret, interfaceAddr{iface: i, ipnet: &net.IPNet{IP: ipnet.IP.To4(), Mask: ipnet.Mask}})
+			break
+		}
+	}
+
+	return ret
+}
+
+func addrsSignature(addrs []interfaceAddr) string {
+	ret := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		ret = append(ret, fmt.Sprintf("%s/%s", a.iface.Name, a.ipnet.IP))
+	}
+	sort.Strings(ret)
+	return strings.Join(ret, ", ")
+}
+
+// baseURL returns server URL, reachable from the remote address
+func baseURL(r *http.Request) string {
+	if r.Host != "" {
+		return "http://" + r.Host
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d", config.Args.LocalPort)
+}
@@ -0,0 +1,187 @@
+package dlna
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Limits size of control requests, they only carry a few arguments
+const maxSOAPRequestSize = 64 * 1024
+
+// UPnP error codes, returned in SOAP faults
+const (
+	upnpErrorInvalidAction = 401
+	upnpErrorInvalidArgs   = 402
+	upnpErrorActionFailed  = 501
+	upnpErrorNoSuchObject  = 701
+)
+
+// soapError is an action failure, that is reported to control point as SOAP fault
+type soapError struct {
+	code int
+	msg  string
+}
+
+func (e *soapError) Error() string {
+	return fmt.Sprintf("%d: %s", e.code, e.msg)
+}
+
+// soapArg is a single output argument, arguments are written in order, defined in service description
+type soapArg struct {
+	name  string
+	value string
+}
+
+// soapAction handles a single action with input arguments and returns output arguments
+type soapAction func(r *http.Request, action string, args map[string]string) ([]soapArg, error)
+
+// serveControl parses SOAP request for a service and writes action response
+func serveControl(serviceType string, handle soapAction) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxSOAPRequestSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		action, args, err := parseSOAPRequest(body)
+		if err != nil {
+			writeSOAPFault(w, &soapError{upnpErrorInvalidArgs, err.Error()})
+			return
+		}
+
+		// SOAPACTION header is "urn:schemas-upnp-org:service:ContentDirectory:1#Browse"
+		if header := strings.Trim(r.Header.Get("SOAPACTION"), `"`); header != "" {
+			if i := strings.LastIndex(header, "#"); i >= 0 {
+				action = header[i+1:]
+			}
+		}
+
+		out, err := handle(r, action, args)
+		if err != nil {
+			log.Debugf("DLNA action %s failed: %s", action, err)
+			writeSOAPFault(w, err)
+			return
+		}
+
+		var b bytes.Buffer
+		b.WriteString(xml.Header)
+		b.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+		fmt.Fprintf(&b, `<u:%sResponse xmlns:u="%s">`, action, serviceType)
+		for _, a := range out {
+			fmt.Fprintf(&b, "<%s>%s</%s>", a.name, xmlEscape(a.value), a.name)
+		}
+		fmt.Fprintf(&b, `</u:%sResponse>`, action)
+		b.WriteString(`</s:Body></s:Envelope>`)
+
+		w.Header().Set("Content-Type", xmlContentType)
+		w.Header().Set("Ext", "")
+		w.Header().Set("Server", serverHeader())
+		_, _ = w.Write(b.Bytes())
+	}
+}
+
+// parseSOAPRequest returns name of the action element and its arguments
+func parseSOAPRequest(body []byte) (action string, args map[string]string, err error) {
+	args = map[string]string{}
+	dec := xml.NewDecoder(bytes.NewReader(body))
+
+	depth := 0
+	arg := ""
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			// Envelope > Body > Action > Argument
+			if depth == 3 {
+				action = t.Name.Local
+			} else if depth == 4 {
+				arg = t.Name.Local
+				args[arg] = ""
+			}
+		case xml.EndElement:
+			depth--
+			arg = ""
+		case xml.CharData:
+			if depth == 4 && arg != "" {
+				args[arg] += string(t)
+			}
+		}
+	}
+
+	if action == "" {
+		return "", nil, fmt.Errorf("no action in request")
+	}
+	return action, args, nil
+}
+
+func writeSOAPFault(w http.ResponseWriter, err error) {
+	se, ok := err.(*soapError)
+	if !ok {
+		se = &soapError{upnpErrorActionFailed, err.Error()}
+	}
+
+	w.Header().Set("Content-Type", xmlContentType)
+	w.Header().Set("Server", serverHeader())
+	w.WriteHeader(http.StatusInternalServerError)
+
+	fmt.Fprintf(w, `%s<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`+
+		`<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>`+
+		`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError>`+
+		`</detail></s:Fault></s:Body></s:Envelope>`, xml.Header, se.code, xmlEscape(se.msg))
+}
+
+// handleConnectionManager implements ConnectionManager service, that only reports supported formats
+func handleConnectionManager(r *http.Request, action string, args map[string]string) ([]soapArg, error) {
+	switch action {
+	case "GetProtocolInfo":
+		protocols := make([]string, 0, len(mimeTypes))
+		seen := map[string]bool{}
+		for _, ext := range mimeExtensions() {
+			mime := mimeTypes[ext].mime
+			if seen[mime] {
+				continue
+			}
+			seen[mime] = true
+			protocols = append(protocols, protocolInfo(mime))
+		}
+
+		return []soapArg{
+			{"Source", strings.Join(protocols, ",")},
+			{"Sink", ""},
+		}, nil
+	case "GetCurrentConnectionIDs":
+		return []soapArg{{"ConnectionIDs", "0"}}, nil
+	case "GetCurrentConnectionInfo":
+		if args["ConnectionID"] != "0" {
+			return nil, &soapError{upnpErrorInvalidArgs, "Invalid connection reference"}
+		}
+		return []soapArg{
+			{"RcsID", "-1"},
+			{"AVTransportID", "-1"},
+			{"ProtocolInfo", ""},
+			{"PeerConnectionManager", ""},
+			{"PeerConnectionID", "-1"},
+			{"Direction", "Output"},
+			{"Status", "OK"},
+		}, nil
+	}
+
+	return nil, &soapError{upnpErrorInvalidAction, "Invalid action " + action}
+}
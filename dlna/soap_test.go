@@ -0,0 +1,195 @@
+package dlna
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/config"
+)
+
+// noTorrents is a service without active torrents, so only the download directory is browsed
+type noTorrents struct{}
+
+func (noTorrents) GetTorrents() []*bittorrent.Torrent               { return nil }
+func (noTorrents) GetTorrentByHash(hash string) *bittorrent.Torrent { return nil }
+
+type browseResponse struct {
+	Result         string `xml:"Body>BrowseResponse>Result"`
+	NumberReturned int    `xml:"Body>BrowseResponse>NumberReturned"`
+	TotalMatches   int    `xml:"Body>BrowseResponse>TotalMatches"`
+	ErrorCode      int    `xml:"Body>Fault>detail>UPnPError>errorCode"`
+}
+
+type didlLite struct {
+	Containers []struct {
+		ID         string `xml:"id,attr"`
+		ChildCount int    `xml:"childCount,attr"`
+		Title      string `xml:"title"`
+	} `xml:"container"`
+	Items []struct {
+		ID    string `xml:"id,attr"`
+		Title string `xml:"title"`
+		Res   string `xml:"res"`
+	} `xml:"item"`
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "Show"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range map[string]string{
+		"Movie.mkv":          "movie",
+		"Show/S01E01.mp4":    "episode",
+		"notes.txt":          "not a media file",
+		".hidden/Secret.mkv": "hidden",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := config.Get()
+	enabled, downloadPath := conf.DLNAEnabled, conf.DownloadPath
+	conf.DLNAEnabled, conf.DownloadPath = true, dir
+	t.Cleanup(func() {
+		conf.DLNAEnabled, conf.DownloadPath = enabled, downloadPath
+	})
+
+	srv := httptest.NewServer(Handler(noTorrents{}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func browse(t *testing.T, srv *httptest.Server, id, flag string) (*http.Response, browseResponse) {
+	t.Helper()
+
+	body := fmt.Sprintf(`<?xml version="1.0"?>`+
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`+
+		`<u:Browse xmlns:u="%s"><ObjectID>%s</ObjectID><BrowseFlag>%s</BrowseFlag><Filter>*</Filter>`+
+		`<StartingIndex>0</StartingIndex><RequestedCount>0</RequestedCount><SortCriteria></SortCriteria></u:Browse>`+
+		`</s:Body></s:Envelope>`, contentDirectoryType, id, flag)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/dlna/control/ContentDirectory", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPACTION", `"`+contentDirectoryType+`#Browse"`)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var ret browseResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&ret); err != nil {
+		t.Fatalf("Could not decode Browse response: %s", err)
+	}
+	return resp, ret
+}
+
+func TestBrowseRoot(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, ret := browse(t, srv, rootID, "BrowseDirectChildren")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if ret.NumberReturned != 2 || ret.TotalMatches != 2 {
+		t.Fatalf("NumberReturned = %d, TotalMatches = %d, want 2", ret.NumberReturned, ret.TotalMatches)
+	}
+
+	var didl didlLite
+	if err := xml.Unmarshal([]byte(ret.Result), &didl); err != nil {
+		t.Fatalf("Could not decode DIDL-Lite: %s", err)
+	}
+	if len(didl.Containers) != 2 || didl.Containers[0].ID != torrentsID || didl.Containers[1].ID != downloadsID {
+		t.Fatalf("containers = %+v", didl.Containers)
+	}
+	if didl.Containers[1].ChildCount != 2 {
+		t.Errorf("downloads childCount = %d, want 2", didl.Containers[1].ChildCount)
+	}
+}
+
+func TestBrowseDownloads(t *testing.T) {
+	srv := newTestServer(t)
+
+	_, ret := browse(t, srv, downloadsID, "BrowseDirectChildren")
+
+	var didl didlLite
+	if err := xml.Unmarshal([]byte(ret.Result), &didl); err != nil {
+		t.Fatalf("Could not decode DIDL-Lite: %s", err)
+	}
+	if len(didl.Containers) != 1 || didl.Containers[0].ID != downloadsID+"/Show" {
+		t.Fatalf("containers = %+v, want only Show directory", didl.Containers)
+	}
+	if len(didl.Items) != 1 || didl.Items[0].Title != "Movie.mkv" {
+		t.Fatalf("items = %+v, want only Movie.mkv", didl.Items)
+	}
+
+	// Resource URL of the item is served by the same handler
+	res := didl.Items[0].Res
+	if !strings.HasPrefix(res, srv.URL+"/dlna/downloads/") {
+		t.Fatalf("res = %q", res)
+	}
+	resp, err := http.Get(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "movie" {
+		t.Errorf("GET %s = %d %q", res, resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "video/x-matroska" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	_, ret = browse(t, srv, downloadsID+"/Show/S01E01.mp4", "BrowseMetadata")
+	if ret.NumberReturned != 1 || !strings.Contains(ret.Result, "S01E01.mp4") {
+		t.Errorf("BrowseMetadata = %+v", ret)
+	}
+}
+
+func TestBrowseNoSuchObject(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, id := range []string{"unknown", downloadsID + "/.hidden", downloadsID + "/../etc"} {
+		resp, ret := browse(t, srv, id, "BrowseDirectChildren")
+		if resp.StatusCode != http.StatusInternalServerError || ret.ErrorCode != upnpErrorNoSuchObject {
+			t.Errorf("Browse %s = %d, error code %d, want %d", id, resp.StatusCode, ret.ErrorCode, upnpErrorNoSuchObject)
+		}
+	}
+}
+
+func TestHandlerDisabled(t *testing.T) {
+	srv := newTestServer(t)
+	config.Get().DLNAEnabled = false
+
+	resp, err := http.Get(srv.URL + "/dlna/device.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 while DLNA is disabled", resp.StatusCode)
+	}
+}
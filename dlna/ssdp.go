@@ -0,0 +1,248 @@
+package dlna
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv4"
+
+	"github.com/elgatito/elementum/util/ident"
+)
+
+const (
+	ssdpAddr   = "239.255.255.250:1900"
+	ssdpMaxAge = 1800
+
+	ssdpAlive  = "ssdp:alive"
+	ssdpByeBye = "ssdp:byebye"
+
+	// Alive notifications are repeated well before max-age expires on clients
+	notifyInterval = 10 * time.Minute
+
+	maxSearchDelay = time.Second
+)
+
+// ssdpConn is a multicast connection, bound to a single network interface
+type ssdpConn struct {
+	addr interfaceAddr
+	conn *net.UDPConn
+	pc   *ipv4.PacketConn
+}
+
+// ssdpServer answers M-SEARCH requests and sends NOTIFY messages on all interfaces
+type ssdpServer struct {
+	uuid      string
+	port      int
+	signature string
+	conns     []*ssdpConn
+	group     *net.UDPAddr
+
+	lastNotify time.Time
+
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func newSSDPServer(uuid string, port int, addrs []interfaceAddr) (*ssdpServer, error) {
+	group, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &ssdpServer{
+		uuid:      uuid,
+		port:      port,
+		signature: addrsSignature(addrs),
+		group:     group,
+	}
+
+	for _, a := range addrs {
+		iface := a.iface
+		conn, err := net.ListenMulticastUDP("udp4", &iface, group)
+		if err != nil {
+			log.Warningf("Could not listen for SSDP on %s: %s", a.iface.Name, err)
+			continue
+		}
+
+		pc := ipv4.NewPacketConn(conn)
+		if err := pc.SetMulticastInterface(&iface); err != nil {
+			log.Debugf("Could not set multicast interface %s: %s", a.iface.Name, err)
+		}
+		_ = pc.SetMulticastTTL(2)
+		_ = pc.SetMulticastLoopback(true)
+
+		srv.conns = append(srv.conns, &ssdpConn{addr: a, conn: conn, pc: pc})
+	}
+	if len(srv.conns) == 0 {
+		return nil, fmt.Errorf("no interfaces available for SSDP")
+	}
+
+	for _, c := range srv.conns {
+		srv.wg.Add(1)
+		go srv.serve(c)
+	}
+
+	// Notifications are sent a few times, as UDP messages can be lost
+	srv.notify(ssdpByeBye)
+	srv.notify(ssdpAlive)
+	go func() {
+		time.Sleep(time.Second)
+		srv.notify(ssdpAlive)
+	}()
+
+	return srv, nil
+}
+
+func (srv *ssdpServer) close() {
+	srv.closeOnce.Do(func() {
+		srv.notify(ssdpByeBye)
+		for _, c := range srv.conns {
+			c.conn.Close()
+		}
+		srv.wg.Wait()
+	})
+}
+
+// targets returns notification types and unique service names, that are announced
+func (srv *ssdpServer) targets() [][2]string {
+	device := "uuid:" + srv.uuid
+	return [][2]string{
+		{"upnp:rootdevice", device + "::upnp:rootdevice"},
+		{device, device},
+		{deviceType, device + "::" + deviceType},
+		{contentDirectoryType, device + "::" + contentDirectoryType},
+		{connectionManagerType, device + "::" + connectionManagerType},
+	}
+}
+
+func (srv *ssdpServer) location(c *ssdpConn) string {
+	return fmt.Sprintf("http://%s:%d/dlna/device.xml", c.addr.ipnet.IP, srv.port)
+}
+
+func serverHeader() string {
+	return fmt.Sprintf("%s/%s UPnP/1.0 Elementum/%s", runtime.GOOS, runtime.GOARCH, ident.GetVersion())
+}
+
+func (srv *ssdpServer) notify(nts string) {
+	srv.lastNotify = time.Now()
+
+	for _, c := range srv.conns {
+		for _, t := range srv.targets() {
+			var b bytes.Buffer
+			b.WriteString("NOTIFY * HTTP/1.1\r\n")
+			fmt.Fprintf(&b, "HOST: %s\r\n", ssdpAddr)
+			fmt.Fprintf(&b, "NT: %s\r\n", t[0])
+			fmt.Fprintf(&b, "NTS: %s\r\n", nts)
+			fmt.Fprintf(&b, "USN: %s\r\n", t[1])
+			if nts == ssdpAlive {
+				fmt.Fprintf(&b, "CACHE-CONTROL: max-age=%d\r\n", ssdpMaxAge)
+				fmt.Fprintf(&b, "LOCATION: %s\r\n", srv.location(c))
+				fmt.Fprintf(&b, "SERVER: %s\r\n", serverHeader())
+			}
+			b.WriteString("\r\n")
+
+			if _, err := c.pc.WriteTo(b.Bytes(), nil, srv.group); err != nil {
+				log.Debugf("Could not send SSDP notification on %s: %s", c.addr.iface.Name, err)
+				break
+			}
+		}
+	}
+}
+
+func (srv *ssdpServer) serve(c *ssdpConn) {
+	defer srv.wg.Done()
+
+	buf := make([]byte, 2048)
+	for {
+		n, remote, err := c.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return
+		}
+
+		req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(buf[:n])))
+		if err != nil || req.Method != "M-SEARCH" || req.Header.Get("MAN") != `"ssdp:discover"` {
+			continue
+		}
+		if !srv.responsible(c, remote.IP) {
+			continue
+		}
+
+		st := req.Header.Get("ST")
+		responses := srv.matchTargets(st)
+		if len(responses) == 0 {
+			continue
+		}
+
+		delay := maxSearchDelay
+		if mx, err := strconv.Atoi(req.Header.Get("MX")); err == nil && mx >= 0 && time.Duration(mx)*time.Second < delay {
+			delay = time.Duration(mx) * time.Second
+		}
+
+		go srv.reply(c, remote, responses, delay)
+	}
+}
+
+// responsible tells whether this connection should answer the remote address,
+// so that a request, received on several interfaces, is answered only once
+func (srv *ssdpServer) responsible(c *ssdpConn, ip net.IP) bool {
+	if c.addr.ipnet.Contains(ip) {
+		return true
+	}
+	for _, other := range srv.conns {
+		if other.addr.ipnet.Contains(ip) {
+			return false
+		}
+	}
+	return c == srv.conns[0]
+}
+
+// matchTargets returns targets, that match search target of the request
+func (srv *ssdpServer) matchTargets(st string) [][2]string {
+	all := srv.targets()
+	if st == "ssdp:all" {
+		return all
+	}
+
+	for _, t := range all {
+		if strings.EqualFold(t[0], st) {
+			return [][2]string{{st, t[1]}}
+		}
+	}
+	return nil
+}
+
+func (srv *ssdpServer) reply(c *ssdpConn, remote *net.UDPAddr, targets [][2]string, delay time.Duration) {
+	if delay > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(delay))))
+	}
+
+	for _, t := range targets {
+		var b bytes.Buffer
+		b.WriteString("HTTP/1.1 200 OK\r\n")
+		fmt.Fprintf(&b, "CACHE-CONTROL: max-age=%d\r\n", ssdpMaxAge)
+		fmt.Fprintf(&b, "DATE: %s\r\n", time.Now().UTC().Format(http.TimeFormat))
+		b.WriteString("EXT:\r\n")
+		fmt.Fprintf(&b, "LOCATION: %s\r\n", srv.location(c))
+		fmt.Fprintf(&b, "SERVER: %s\r\n", serverHeader())
+		fmt.Fprintf(&b, "ST: %s\r\n", t[0])
+		fmt.Fprintf(&b, "USN: %s\r\n", t[1])
+		b.WriteString("\r\n")
+
+		if _, err := c.conn.WriteToUDP(b.Bytes(), remote); err != nil {
+			log.Debugf("Could not answer SSDP search from %s: %s", remote, err)
+			return
+		}
+	}
+}
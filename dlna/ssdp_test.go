@@ -0,0 +1,142 @@
+package dlna
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+const testUUID = "01234567-89ab-3cde-8f01-23456789abcd"
+
+// newLoopbackSSDPServer serves M-SEARCH requests on a unicast loopback socket,
+// as multicast is not available everywhere tests run
+func newLoopbackSSDPServer(t *testing.T) (*ssdpServer, *net.UDPAddr) {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("Could not listen on loopback: %s", err)
+	}
+
+	group, _ := net.ResolveUDPAddr("udp4", ssdpAddr)
+	c := &ssdpConn{
+		addr: interfaceAddr{
+			iface: net.Interface{Name: "lo"},
+			ipnet: &net.IPNet{IP: net.IPv4(127, 0, 0, 1).To4(), Mask: net.CIDRMask(8, 32)},
+		},
+		conn: conn,
+		pc:   ipv4.NewPacketConn(conn),
+	}
+	srv := &ssdpServer{
+		uuid:  testUUID,
+		port:  65220,
+		conns: []*ssdpConn{c},
+		group: group,
+	}
+
+	srv.wg.Add(1)
+	go srv.serve(c)
+	t.Cleanup(func() {
+		conn.Close()
+		srv.wg.Wait()
+	})
+
+	return srv, conn.LocalAddr().(*net.UDPAddr)
+}
+
+func search(t *testing.T, addr *net.UDPAddr, st string, expected int) []*http.Response {
+	t.Helper()
+
+	client, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	req := fmt.Sprintf("M-SEARCH * HTTP/1.1\r\nHOST: %s\r\nMAN: \"ssdp:discover\"\r\nMX: 0\r\nST: %s\r\n\r\n", ssdpAddr, st)
+	if _, err := client.WriteToUDP([]byte(req), addr); err != nil {
+		t.Fatal(err)
+	}
+
+	ret := []*http.Response{}
+	buf := make([]byte, 2048)
+	for {
+		timeout := time.Second
+		if len(ret) >= expected {
+			// Wait a bit more to catch unexpected extra responses
+			timeout = 200 * time.Millisecond
+		}
+		_ = client.SetReadDeadline(time.Now().Add(timeout))
+
+		n, _, err := client.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			t.Fatalf("Could not parse SSDP response: %s", err)
+		}
+		ret = append(ret, resp)
+	}
+	return ret
+}
+
+func TestMSearchDevice(t *testing.T) {
+	_, addr := newLoopbackSSDPServer(t)
+
+	responses := search(t, addr, deviceType, 1)
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+
+	resp := responses[0]
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+	if st := resp.Header.Get("ST"); st != deviceType {
+		t.Errorf("ST = %q", st)
+	}
+	if usn := resp.Header.Get("USN"); usn != "uuid:"+testUUID+"::"+deviceType {
+		t.Errorf("USN = %q", usn)
+	}
+	if location := resp.Header.Get("LOCATION"); location != "http://127.0.0.1:65220/dlna/device.xml" {
+		t.Errorf("LOCATION = %q", location)
+	}
+	if _, ok := resp.Header["Ext"]; !ok {
+		t.Error("EXT header is missing")
+	}
+}
+
+func TestMSearchAll(t *testing.T) {
+	srv, addr := newLoopbackSSDPServer(t)
+
+	responses := search(t, addr, "ssdp:all", len(srv.targets()))
+	if len(responses) != len(srv.targets()) {
+		t.Fatalf("got %d responses, want %d", len(responses), len(srv.targets()))
+	}
+
+	usns := map[string]bool{}
+	for _, resp := range responses {
+		usns[resp.Header.Get("USN")] = true
+	}
+	for _, target := range srv.targets() {
+		if !usns[target[1]] {
+			t.Errorf("no response for %s", target[1])
+		}
+	}
+}
+
+func TestMSearchUnknownTarget(t *testing.T) {
+	_, addr := newLoopbackSSDPServer(t)
+
+	if responses := search(t, addr, "urn:schemas-upnp-org:device:MediaRenderer:1", 0); len(responses) != 0 {
+		t.Errorf("got %d responses for unknown target", len(responses))
+	}
+}
@@ -37,7 +37,7 @@ var (
 	simultaneousConnections = 25
 )
 
-var rl = util.NewRateLimiter(burstRate, burstTime, simultaneousConnections)
+var rl = util.NewRateLimiter("fanart", burstRate, burstTime, simultaneousConnections)
 
 // Movie ...
 type Movie struct {
@@ -0,0 +1,293 @@
+package feeds
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/broadcast"
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/proxy"
+	"github.com/elgatito/elementum/util/event"
+	"github.com/elgatito/elementum/xbmc"
+)
+
+const (
+	// DefaultInterval is used for feeds without check interval, in minutes
+	DefaultInterval = 15
+	// MinInterval is the lowest allowed check interval, in minutes
+	MinInterval = 5
+
+	maxFeedSize    = 10 * 1024 * 1024
+	updateInterval = time.Minute
+)
+
+var (
+	log = logging.MustGetLogger("feeds")
+
+	closer = event.Event{}
+
+	// mu guards adding, so concurrent checks do not add same torrents
+	mu     sync.Mutex
+	adding = map[string]bool{}
+	// resolved keeps infohashes of feed items that needed torrent download to know it
+	resolved sync.Map
+
+	// setupTorrent selects files of the added torrent, tests replace it, as they can not create torrents
+	setupTorrent = func(torrent *bittorrent.Torrent) {
+		database.GetStorm().UpdateBTItem(torrent.InfoHash(), 0, "", []string{}, torrent.Name(), 0, 0, 0)
+
+		torrent.DownloadAllFiles()
+		torrent.SaveDBFiles()
+	}
+)
+
+// Service finds active torrents and adds torrents of feed items, it is implemented by bittorrent.Service
+type Service interface {
+	GetTorrentByHash(hash string) *bittorrent.Torrent
+	AddTorrentWithCategory(xbmcHost *xbmc.XBMCHost, uri string, categoryName string, paused bool, downloadStorage int, firstTime bool, addedTime time.Time) (*bittorrent.Torrent, error)
+}
+
+// Result describes what happened with a single feed item
+type Result struct {
+	Title    string `json:"title"`
+	InfoHash string `json:"info_hash"`
+	URI      string `json:"uri"`
+	Matched  bool   `json:"matched"`
+	Reason   string `json:"reason,omitempty"`
+	Added    bool   `json:"added"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Stop stops checking feeds
+func Stop() {
+	closer.Set()
+}
+
+// Start checks enabled feeds, when their interval passes
+func Start(s *bittorrent.Service) {
+	ticker := time.NewTicker(updateInterval)
+	defer ticker.Stop()
+
+	closing := closer.C()
+	globalCloser := broadcast.Closer.C()
+
+	for {
+		select {
+		case <-globalCloser:
+			log.Info("Closing feeds updater...")
+			return
+		case <-closing:
+			log.Info("Closing feeds updater...")
+			return
+		case <-ticker.C:
+			if s.Closer.IsSet() {
+				continue
+			}
+			checkFeeds(s)
+		}
+	}
+}
+
+func checkFeeds(s *bittorrent.Service) {
+	now := time.Now()
+	for _, feed := range database.GetStorm().GetFeeds() {
+		if !feed.Enabled || now.Sub(feed.LastChecked) < GetInterval(&feed) {
+			continue
+		}
+		if closer.IsSet() || s.Closer.IsSet() {
+			return
+		}
+
+		feed := feed
+		if _, err := Check(s, &feed, false); err != nil {
+			log.Warningf("Could not check feed %s: %s", feed.Name, err)
+		}
+	}
+}
+
+// GetInterval returns how often feed should be checked
+func GetInterval(feed *database.Feed) time.Duration {
+	interval := feed.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	} else if interval < MinInterval {
+		interval = MinInterval
+	}
+	return time.Duration(interval) * time.Minute
+}
+
+// Fetch downloads and parses the feed
+func Fetch(url string) ([]*Item, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := proxy.GetClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Feed returned status %d", resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseBytes(b)
+}
+
+// Check fetches the feed and adds torrents that match feed rules and were not added before.
+// With dryRun nothing is added or saved, results show which items would be added.
+func Check(s Service, feed *database.Feed, dryRun bool) ([]*Result, error) {
+	filter, err := NewFilter(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Checking feed %s", feed.Name)
+	items, err := Fetch(feed.URL)
+
+	if !dryRun {
+		feed.LastChecked = time.Now()
+		feed.LastError = ""
+		if err != nil {
+			feed.LastError = err.Error()
+		}
+		if errSave := database.GetStorm().SaveFeed(feed); errSave != nil {
+			log.Warningf("Could not save feed %s: %s", feed.Name, errSave)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*Result, 0, len(items))
+	for _, item := range items {
+		if closer.IsSet() {
+			break
+		}
+
+		results = append(results, processItem(s, feed, filter, item, dryRun))
+	}
+
+	return results, nil
+}
+
+func processItem(s Service, feed *database.Feed, filter *Filter, item *Item, dryRun bool) (r *Result) {
+	r = &Result{
+		Title: item.Title,
+		URI:   item.URI,
+	}
+	if item.URI == "" {
+		r.Reason = "No torrent link"
+		return
+	}
+
+	t := item.TorrentFile(feed.Name)
+	if r.Matched, r.Reason = filter.Match(item, t); !r.Matched {
+		return
+	}
+
+	if t.InfoHash == "" {
+		if v, ok := resolved.Load(item.URI); ok {
+			t.InfoHash = v.(string)
+		}
+	}
+	if t.InfoHash != "" && isKnown(s, t.InfoHash) {
+		r.InfoHash = t.InfoHash
+		r.Reason = "Already added"
+		return
+	}
+	if dryRun {
+		r.InfoHash = t.InfoHash
+		return
+	}
+
+	// Malformed torrent files can make parsing panic
+	defer func() {
+		if p := recover(); p != nil {
+			r.Error = fmt.Sprintf("%v", p)
+			log.Warningf("Could not add %s from feed %s: %v", item.Title, feed.Name, p)
+		}
+	}()
+
+	if t.InfoHash == "" {
+		if err := t.Resolve(); err != nil {
+			r.Error = err.Error()
+			return
+		}
+		resolved.Store(item.URI, t.InfoHash)
+	}
+	r.InfoHash = t.InfoHash
+
+	if !claim(s, t.InfoHash) {
+		r.Reason = "Already added"
+		return
+	}
+	defer release(t.InfoHash)
+
+	if err := add(s, feed, t); err != nil {
+		r.Error = err.Error()
+		log.Warningf("Could not add %s from feed %s: %s", item.Title, feed.Name, err)
+		return
+	}
+
+	r.Added = true
+	log.Infof("Added %s from feed %s", item.Title, feed.Name)
+	return
+}
+
+// claim marks torrent as being added, it fails for known torrents and torrents, that another check adds
+func claim(s Service, infoHash string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if adding[infoHash] || isKnown(s, infoHash) {
+		return false
+	}
+	adding[infoHash] = true
+	return true
+}
+
+func release(infoHash string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	delete(adding, infoHash)
+}
+
+// isKnown checks whether torrent is active or was added before, from feeds or manually
+func isKnown(s Service, infoHash string) bool {
+	db := database.GetStorm()
+	return s.GetTorrentByHash(infoHash) != nil ||
+		db.HasFeedGrab(infoHash) ||
+		db.HasTorrentHistory(infoHash) ||
+		db.GetBTItem(infoHash) != nil
+}
+
+func add(s Service, feed *database.Feed, t *bittorrent.TorrentFile) error {
+	// Resolved torrents are already saved locally, others are downloaded while adding
+	torrent, err := s.AddTorrentWithCategory(nil, t.URI, feed.Category, feed.Paused, config.StorageFile, true, time.Now())
+	if err != nil {
+		return err
+	} else if torrent == nil {
+		return errors.New("Torrent was not added")
+	}
+
+	setupTorrent(torrent)
+
+	return database.GetStorm().AddFeedGrab(t.InfoHash, feed.Name, t.Name)
+}
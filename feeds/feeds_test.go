@@ -0,0 +1,199 @@
+package feeds
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/xbmc"
+)
+
+const (
+	rssHash     = "0123456789abcdef0123456789abcdef01234567"
+	torznabHash = "89abcdef0123456789abcdef0123456789abcdef"
+)
+
+type addCall struct {
+	uri      string
+	category string
+	paused   bool
+}
+
+// fakeService has no active torrents and records added ones
+type fakeService struct {
+	mu    sync.Mutex
+	added []addCall
+	err   error
+}
+
+func (s *fakeService) GetTorrentByHash(hash string) *bittorrent.Torrent { return nil }
+
+func (s *fakeService) AddTorrentWithCategory(xbmcHost *xbmc.XBMCHost, uri string, categoryName string, paused bool, downloadStorage int, firstTime bool, addedTime time.Time) (*bittorrent.Torrent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.added = append(s.added, addCall{uri: uri, category: categoryName, paused: paused})
+	return &bittorrent.Torrent{}, nil
+}
+
+// newFeedServer serves testdata fixtures, /status/500 fails with HTTP error
+func newFeedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.Handle("/feeds/", http.StripPrefix("/feeds/", http.FileServer(http.Dir("testdata"))))
+	mux.HandleFunc("/status/500", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func initDatabase(t *testing.T) {
+	t.Helper()
+
+	db, err := database.InitStormDB(&config.Configuration{Info: &xbmc.AddonInfo{Profile: t.TempDir()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+
+	setup := setupTorrent
+	setupTorrent = func(torrent *bittorrent.Torrent) {}
+	t.Cleanup(func() { setupTorrent = setup })
+}
+
+func TestCheckAdd(t *testing.T) {
+	initDatabase(t)
+	srv := newFeedServer(t)
+	s := &fakeService{}
+
+	feed := &database.Feed{Name: "rss", URL: srv.URL + "/feeds/rss.xml", Exclude: "HDTV", Category: "Shows", Paused: true}
+	results, err := Check(s, feed, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	if r := results[0]; !r.Matched || !r.Added || r.InfoHash != rssHash || r.Error != "" {
+		t.Errorf("first result = %+v, want added", r)
+	}
+	if r := results[1]; r.Matched || r.Added || !strings.Contains(r.Reason, "exclude rule") {
+		t.Errorf("second result = %+v, want skipped by exclude rule", r)
+	}
+
+	if len(s.added) != 1 || s.added[0] != (addCall{uri: "magnet:?xt=urn:btih:" + rssHash, category: "Shows", paused: true}) {
+		t.Fatalf("added = %+v", s.added)
+	}
+	if !database.GetStorm().HasFeedGrab(rssHash) {
+		t.Error("added torrent should be recorded as feed grab")
+	}
+	if saved := database.GetStorm().GetFeed("rss"); saved == nil || saved.LastChecked.IsZero() || saved.LastError != "" {
+		t.Errorf("saved feed = %+v", saved)
+	}
+
+	// Second check sees the grab and adds nothing
+	results, err = Check(s, feed, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := results[0]; r.Added || r.Reason != "Already added" {
+		t.Errorf("first result = %+v, want duplicate", r)
+	}
+	if len(s.added) != 1 {
+		t.Errorf("duplicate was added again: %+v", s.added)
+	}
+}
+
+func TestCheckDuplicates(t *testing.T) {
+	initDatabase(t)
+	srv := newFeedServer(t)
+	s := &fakeService{}
+
+	conf := config.Get()
+	conf.UseTorrentHistory, conf.TorrentHistorySize = true, 10
+	defer func() { conf.UseTorrentHistory, conf.TorrentHistorySize = false, 0 }()
+
+	database.GetStorm().AddTorrentHistory(torznabHash, "Movie", nil)
+
+	results, err := Check(s, &database.Feed{Name: "torznab", URL: srv.URL + "/feeds/torznab.xml"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].Matched || results[0].Added || results[0].Reason != "Already added" {
+		t.Fatalf("results = %+v, want torrent from history skipped", results)
+	}
+	if len(s.added) != 0 {
+		t.Errorf("added = %+v", s.added)
+	}
+}
+
+func TestCheckDryRun(t *testing.T) {
+	initDatabase(t)
+	srv := newFeedServer(t)
+	s := &fakeService{}
+
+	results, err := Check(s, &database.Feed{Name: "torznab", URL: srv.URL + "/feeds/torznab.xml"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].Matched || results[0].Added || results[0].InfoHash != torznabHash {
+		t.Fatalf("results = %+v, want matched but not added", results)
+	}
+	if len(s.added) != 0 || database.GetStorm().HasFeedGrab(torznabHash) {
+		t.Error("dry run should not add torrents")
+	}
+	if database.GetStorm().GetFeed("torznab") != nil {
+		t.Error("dry run should not save the feed")
+	}
+}
+
+func TestCheckAddError(t *testing.T) {
+	initDatabase(t)
+	srv := newFeedServer(t)
+	s := &fakeService{err: errors.New("Could not add torrent")}
+
+	results, err := Check(s, &database.Feed{Name: "torznab", URL: srv.URL + "/feeds/torznab.xml"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Added || results[0].Error != "Could not add torrent" {
+		t.Fatalf("results = %+v, want add error", results)
+	}
+	if database.GetStorm().HasFeedGrab(torznabHash) {
+		t.Error("failed torrent should not be recorded as feed grab")
+	}
+}
+
+func TestCheckFeedErrors(t *testing.T) {
+	initDatabase(t)
+	srv := newFeedServer(t)
+
+	tests := map[string]string{
+		"/status/500":      "status 500",
+		"/feeds/error.xml": "Indexer returned an error",
+	}
+	for path, want := range tests {
+		feed := &database.Feed{Name: path, URL: srv.URL + path}
+		if _, err := Check(&fakeService{}, feed, false); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Check(%s) err = %v, want %q", path, err, want)
+		}
+		if saved := database.GetStorm().GetFeed(path); saved == nil || !strings.Contains(saved.LastError, want) {
+			t.Errorf("saved feed %s = %+v, want last error", path, saved)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package feeds
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/util"
+)
+
+// Filter is a compiled set of feed rules
+type Filter struct {
+	feed    *database.Feed
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// NewFilter compiles feed rules, regular expressions are case-insensitive
+func NewFilter(feed *database.Feed) (*Filter, error) {
+	f := &Filter{feed: feed}
+
+	var err error
+	if feed.Include != "" {
+		if f.include, err = regexp.Compile("(?i)" + feed.Include); err != nil {
+			return nil, fmt.Errorf("Invalid include rule: %s", err)
+		}
+	}
+	if feed.Exclude != "" {
+		if f.exclude, err = regexp.Compile("(?i)" + feed.Exclude); err != nil {
+			return nil, fmt.Errorf("Invalid exclude rule: %s", err)
+		}
+	}
+
+	return f, nil
+}
+
+// Match checks torrent against feed rules, returning the reason if it does not match
+func (f *Filter) Match(item *Item, t *bittorrent.TorrentFile) (bool, string) {
+	feed := f.feed
+
+	if f.include != nil && !f.include.MatchString(item.Title) {
+		return false, "Does not match include rule"
+	}
+	if f.exclude != nil && f.exclude.MatchString(item.Title) {
+		return false, "Matches exclude rule"
+	}
+
+	if feed.MinResolution > 0 && t.Resolution < feed.MinResolution {
+		return false, fmt.Sprintf("Resolution %s is lower than %s", bittorrent.Resolutions[t.Resolution], bittorrent.Resolutions[feed.MinResolution])
+	}
+	if feed.MaxResolution > 0 && t.Resolution > feed.MaxResolution {
+		return false, fmt.Sprintf("Resolution %s is higher than %s", bittorrent.Resolutions[t.Resolution], bittorrent.Resolutions[feed.MaxResolution])
+	}
+	if len(feed.VideoCodecs) > 0 && !util.IntSliceContains(feed.VideoCodecs, t.VideoCodec) {
+		return false, fmt.Sprintf("Video codec %s is not allowed", bittorrent.Codecs[t.VideoCodec])
+	}
+	if len(feed.RipTypes) > 0 && !util.IntSliceContains(feed.RipTypes, t.RipType) {
+		return false, fmt.Sprintf("Rip type %s is not allowed", bittorrent.Rips[t.RipType])
+	}
+
+	// Size and seeds are only checked when feed reports them
+	if item.Size > 0 {
+		if feed.MinSize > 0 && item.Size < feed.MinSize {
+			return false, fmt.Sprintf("Size %s is lower than %s", humanize.Bytes(uint64(item.Size)), humanize.Bytes(uint64(feed.MinSize)))
+		}
+		if feed.MaxSize > 0 && item.Size > feed.MaxSize {
+			return false, fmt.Sprintf("Size %s is higher than %s", humanize.Bytes(uint64(item.Size)), humanize.Bytes(uint64(feed.MaxSize)))
+		}
+	}
+	if item.HasSeeds && feed.MinSeeds > 0 && item.Seeds < feed.MinSeeds {
+		return false, fmt.Sprintf("Seeds %d are lower than %d", item.Seeds, feed.MinSeeds)
+	}
+
+	return true, ""
+}
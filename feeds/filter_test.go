@@ -0,0 +1,65 @@
+package feeds
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/database"
+)
+
+func TestNewFilterInvalidRule(t *testing.T) {
+	if _, err := NewFilter(&database.Feed{Include: "(unclosed"}); err == nil {
+		t.Error("NewFilter should fail on invalid include rule")
+	}
+	if _, err := NewFilter(&database.Feed{Exclude: "[a-"}); err == nil {
+		t.Error("NewFilter should fail on invalid exclude rule")
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	items := parseFixture(t, "rss.xml")
+	first, second := items[0], items[1]
+
+	webDL := &bittorrent.TorrentFile{Resolution: bittorrent.Resolution1080p, RipType: bittorrent.RipWeb}
+	hdtv := &bittorrent.TorrentFile{Resolution: bittorrent.Resolution720p, RipType: bittorrent.RipHDTV}
+
+	tests := []struct {
+		name   string
+		feed   database.Feed
+		item   *Item
+		t      *bittorrent.TorrentFile
+		match  bool
+		reason string
+	}{
+		{"no rules", database.Feed{}, first, webDL, true, ""},
+		{"include is case-insensitive", database.Feed{Include: `show\.name\.s01`}, first, webDL, true, ""},
+		{"include does not match", database.Feed{Include: `Other\.Show`}, first, webDL, false, "include rule"},
+		{"exclude matches", database.Feed{Exclude: `hdtv`}, second, hdtv, false, "exclude rule"},
+		{"min resolution", database.Feed{MinResolution: bittorrent.Resolution1080p}, second, hdtv, false, "lower than"},
+		{"max resolution", database.Feed{MaxResolution: bittorrent.Resolution720p}, first, webDL, false, "higher than"},
+		{"rip types", database.Feed{RipTypes: []int{bittorrent.RipWeb}}, second, hdtv, false, "not allowed"},
+		{"min size", database.Feed{MinSize: 2 * 1024 * 1024 * 1024}, first, webDL, false, "Size"},
+		{"max size", database.Feed{MaxSize: 100 * 1024 * 1024}, second, hdtv, false, "Size"},
+		{"size is not reported", database.Feed{MinSize: 1}, &Item{Title: "No size"}, webDL, true, ""},
+		{"min seeds", database.Feed{MinSeeds: 100}, first, webDL, false, "Seeds"},
+		{"seeds are not reported", database.Feed{MinSeeds: 100}, second, hdtv, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(&tt.feed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			match, reason := f.Match(tt.item, tt.t)
+			if match != tt.match {
+				t.Errorf("Match = %v (%s), want %v", match, reason, tt.match)
+			}
+			if !strings.Contains(reason, tt.reason) {
+				t.Errorf("reason = %q, want it to contain %q", reason, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/elgatito/elementum/bittorrent"
+)
+
+// Item is a single torrent, announced in a feed
+type Item struct {
+	GUID      string    `json:"guid"`
+	Title     string    `json:"title"`
+	URI       string    `json:"uri"`
+	InfoHash  string    `json:"info_hash"`
+	Size      int64     `json:"size"`
+	Seeds     int64     `json:"seeds"`
+	Peers     int64     `json:"peers"`
+	HasSeeds  bool      `json:"-"`
+	Published time.Time `json:"published"`
+}
+
+// attr is a Torznab/Newznab extension attribute, like <torznab:attr name="seeders" value="10"/>
+type attr struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type enclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int64  `xml:"length,attr"`
+}
+
+type rssItem struct {
+	Title     string    `xml:"title"`
+	Link      string    `xml:"link"`
+	GUID      string    `xml:"guid"`
+	PubDate   string    `xml:"pubDate"`
+	Size      int64     `xml:"size"`
+	Enclosure enclosure `xml:"enclosure"`
+	Attrs     []attr    `xml:"attr"`
+
+	// Elements of "torrent" namespace, as used by many public trackers
+	InfoHash      string `xml:"infoHash"`
+	MagnetURI     string `xml:"magnetURI"`
+	ContentLength int64  `xml:"contentLength"`
+	Seeds         string `xml:"seeds"`
+	Peers         string `xml:"peers"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length int64  `xml:"length,attr"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Links     []atomLink `xml:"link"`
+	Attrs     []attr     `xml:"attr"`
+}
+
+type document struct {
+	XMLName xml.Name
+	Items   []rssItem   `xml:"channel>item"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// Parse reads RSS, Atom or Torznab document
+func Parse(r io.Reader) ([]*Item, error) {
+	var doc document
+
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charsetReader
+	decoder.Strict = false
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	ret := []*Item{}
+	switch strings.ToLower(doc.XMLName.Local) {
+	case "rss":
+		for _, i := range doc.Items {
+			ret = append(ret, i.toItem())
+		}
+	case "feed":
+		for _, e := range doc.Entries {
+			ret = append(ret, e.toItem())
+		}
+	case "error":
+		// Torznab indexers report errors as <error code="..." description="..."/>
+		return nil, errors.New("Indexer returned an error")
+	default:
+		return nil, errors.New("Unknown feed format: " + doc.XMLName.Local)
+	}
+
+	return ret, nil
+}
+
+// ParseBytes ...
+func ParseBytes(b []byte) ([]*Item, error) {
+	return Parse(bytes.NewReader(b))
+}
+
+func (i rssItem) toItem() *Item {
+	ret := &Item{
+		GUID:     strings.TrimSpace(i.GUID),
+		Title:    strings.TrimSpace(i.Title),
+		InfoHash: strings.ToLower(strings.TrimSpace(i.InfoHash)),
+		Size:     i.Size,
+	}
+	if ret.Size == 0 {
+		ret.Size = i.ContentLength
+	}
+	if ret.Size == 0 {
+		ret.Size = i.Enclosure.Length
+	}
+	if seeds, err := strconv.ParseInt(strings.TrimSpace(i.Seeds), 10, 64); err == nil {
+		ret.Seeds = seeds
+		ret.HasSeeds = true
+	}
+	if peers, err := strconv.ParseInt(strings.TrimSpace(i.Peers), 10, 64); err == nil {
+		ret.Peers = peers
+	}
+	ret.Published = parseDate(i.PubDate)
+
+	link := strings.TrimSpace(i.Link)
+	switch {
+	case strings.HasPrefix(i.MagnetURI, "magnet:"):
+		ret.URI = strings.TrimSpace(i.MagnetURI)
+	case strings.HasPrefix(link, "magnet:"):
+		ret.URI = link
+	case i.Enclosure.URL != "":
+		ret.URI = strings.TrimSpace(i.Enclosure.URL)
+	default:
+		ret.URI = link
+	}
+
+	ret.applyAttrs(i.Attrs)
+	return ret
+}
+
+func (e atomEntry) toItem() *Item {
+	ret := &Item{
+		GUID:  strings.TrimSpace(e.ID),
+		Title: strings.TrimSpace(e.Title),
+	}
+	ret.Published = parseDate(e.Published)
+	if ret.Published.IsZero() {
+		ret.Published = parseDate(e.Updated)
+	}
+
+	for _, l := range e.Links {
+		href := strings.TrimSpace(l.Href)
+		switch {
+		case strings.HasPrefix(href, "magnet:"):
+			ret.URI = href
+		case l.Rel == "enclosure" || l.Type == "application/x-bittorrent":
+			if !strings.HasPrefix(ret.URI, "magnet:") {
+				ret.URI = href
+			}
+			if ret.Size == 0 {
+				ret.Size = l.Length
+			}
+		case ret.URI == "" && (l.Rel == "" || l.Rel == "alternate"):
+			ret.URI = href
+		}
+	}
+
+	ret.applyAttrs(e.Attrs)
+	return ret
+}
+
+func (i *Item) applyAttrs(attrs []attr) {
+	for _, a := range attrs {
+		value := strings.TrimSpace(a.Value)
+
+		switch strings.ToLower(a.Name) {
+		case "magneturl":
+			if strings.HasPrefix(value, "magnet:") {
+				i.URI = value
+			}
+		case "infohash":
+			i.InfoHash = strings.ToLower(value)
+		case "size":
+			if size, err := strconv.ParseInt(value, 10, 64); err == nil {
+				i.Size = size
+			}
+		case "seeders":
+			if seeds, err := strconv.ParseInt(value, 10, 64); err == nil {
+				i.Seeds = seeds
+				i.HasSeeds = true
+			}
+		case "peers":
+			if peers, err := strconv.ParseInt(value, 10, 64); err == nil {
+				i.Peers = peers
+			}
+		}
+	}
+}
+
+// TorrentFile converts item to TorrentFile, parsing quality tags from the title
+func (i *Item) TorrentFile(provider string) *bittorrent.TorrentFile {
+	size := ""
+	if i.Size > 0 {
+		size = strconv.FormatInt(i.Size, 10) + " B"
+	}
+
+	t := bittorrent.NewTorrentFileWithName(i.URI, i.Title, size)
+	if t.InfoHash == "" {
+		t.InfoHash = i.InfoHash
+	}
+	t.Seeds = i.Seeds
+	t.Peers = i.Peers
+	t.Provider = provider
+
+	return t
+}
+
+// charsetReader converts single-byte Latin encodings to UTF-8, which is the only one supported by encoding/xml
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(label) {
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252", "us-ascii", "ascii":
+	default:
+		return nil, errors.New("Unsupported feed encoding: " + label)
+	}
+
+	b, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, len(b))
+	for _, c := range b {
+		buf = utf8.AppendRune(buf, rune(c))
+	}
+	return bytes.NewReader(buf), nil
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02 15:04:05",
+}
+
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,144 @@
+package feeds
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func parseFixture(t *testing.T, name string) []*Item {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	items, err := Parse(f)
+	if err != nil {
+		t.Fatalf("Parse(%s): %s", name, err)
+	}
+	return items
+}
+
+func TestParseRSS(t *testing.T) {
+	items := parseFixture(t, "rss.xml")
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+
+	i := items[0]
+	if i.Title != "Show.Name.S01E02.1080p.WEB-DL.x264" {
+		t.Errorf("Title = %q", i.Title)
+	}
+	if i.GUID != "https://tracker.example.com/torrent/1" {
+		t.Errorf("GUID = %q", i.GUID)
+	}
+	if i.URI != "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("URI = %q, magnet should take precedence over link", i.URI)
+	}
+	if i.InfoHash != "0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("InfoHash = %q", i.InfoHash)
+	}
+	if i.Size != 1073741824 {
+		t.Errorf("Size = %d", i.Size)
+	}
+	if !i.HasSeeds || i.Seeds != 42 || i.Peers != 7 {
+		t.Errorf("Seeds = %d (%v), Peers = %d", i.Seeds, i.HasSeeds, i.Peers)
+	}
+	if want := time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC); !i.Published.Equal(want) {
+		t.Errorf("Published = %s, want %s", i.Published, want)
+	}
+
+	i = items[1]
+	if i.URI != "https://tracker.example.com/download/2.torrent" {
+		t.Errorf("URI = %q, enclosure should take precedence over link", i.URI)
+	}
+	if i.Size != 524288000 {
+		t.Errorf("Size = %d", i.Size)
+	}
+	if i.HasSeeds {
+		t.Error("HasSeeds should be false, if feed does not report seeds")
+	}
+	if !i.Published.IsZero() {
+		t.Errorf("Published = %s, want zero time", i.Published)
+	}
+}
+
+func TestParseTorznab(t *testing.T) {
+	items := parseFixture(t, "torznab.xml")
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+
+	i := items[0]
+	if i.URI != "magnet:?xt=urn:btih:89abcdef0123456789abcdef0123456789abcdef" {
+		t.Errorf("URI = %q, magneturl attribute should take precedence over enclosure", i.URI)
+	}
+	if i.InfoHash != "89abcdef0123456789abcdef0123456789abcdef" {
+		t.Errorf("InfoHash = %q", i.InfoHash)
+	}
+	if i.Size != 4294967296 {
+		t.Errorf("Size = %d", i.Size)
+	}
+	if !i.HasSeeds || i.Seeds != 120 || i.Peers != 130 {
+		t.Errorf("Seeds = %d (%v), Peers = %d", i.Seeds, i.HasSeeds, i.Peers)
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	items := parseFixture(t, "atom.xml")
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+
+	i := items[0]
+	if i.GUID != "urn:uuid:1" {
+		t.Errorf("GUID = %q", i.GUID)
+	}
+	if i.URI != "https://example.com/get/1.torrent" {
+		t.Errorf("URI = %q, enclosure link should take precedence over alternate", i.URI)
+	}
+	if i.Size != 2048 {
+		t.Errorf("Size = %d", i.Size)
+	}
+	if want := time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC); !i.Published.Equal(want) {
+		t.Errorf("Published = %s, want updated date %s", i.Published, want)
+	}
+}
+
+func TestParseIndexerError(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "error.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := Parse(f); err == nil {
+		t.Error("Parse should fail on indexer error document")
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	if _, err := ParseBytes([]byte(`<html><body></body></html>`)); err == nil {
+		t.Error("ParseBytes should fail on unknown document")
+	}
+}
+
+func TestParseLatin1(t *testing.T) {
+	doc := []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+		"<rss><channel><item><title>Caf\xe9 S01E01</title><link>magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567</link></item></channel></rss>")
+
+	items, err := ParseBytes(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Title != "Café S01E01" {
+		t.Fatalf("got %+v", items)
+	}
+	if items[0].URI != "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("URI = %q, magnet link should be used", items[0].URI)
+	}
+}
@@ -0,0 +1,291 @@
+package follow
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/broadcast"
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/providers"
+	"github.com/elgatito/elementum/tmdb"
+	"github.com/elgatito/elementum/util"
+	"github.com/elgatito/elementum/util/event"
+	"github.com/elgatito/elementum/xbmc"
+)
+
+const (
+	episodeType = "episode"
+
+	// DefaultSearchDays is how long aired episode is searched for, if not set in settings
+	DefaultSearchDays = 7
+
+	startDelay    = 2 * time.Minute
+	checkInterval = time.Hour
+)
+
+var (
+	log = logging.MustGetLogger("follow")
+
+	closer = event.Event{}
+
+	// mu prevents concurrent checks from downloading same episodes
+	mu sync.Mutex
+)
+
+// Result describes what happened with a single aired episode
+type Result struct {
+	Season   int    `json:"season"`
+	Episode  int    `json:"episode"`
+	AirDate  string `json:"air_date"`
+	Title    string `json:"title,omitempty"`
+	InfoHash string `json:"info_hash,omitempty"`
+	Added    bool   `json:"added"`
+	Reason   string `json:"reason,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Stop stops checking followed shows
+func Stop() {
+	closer.Set()
+}
+
+// Start periodically checks followed shows for new episodes
+func Start(s *bittorrent.Service) {
+	closing := closer.C()
+	globalCloser := broadcast.Closer.C()
+	next := time.After(startDelay)
+
+	for {
+		select {
+		case <-globalCloser:
+			log.Info("Closing followed shows updater...")
+			return
+		case <-closing:
+			log.Info("Closing followed shows updater...")
+			return
+		case <-next:
+			next = time.After(checkInterval)
+			if !config.Get().FollowShowsEnabled || s.Closer.IsSet() {
+				continue
+			}
+			checkShows(s)
+		}
+	}
+}
+
+func checkShows(s *bittorrent.Service) {
+	for _, show := range database.GetStorm().GetFollowedShows() {
+		if closer.IsSet() || s.Closer.IsSet() {
+			return
+		}
+
+		show := show
+		if _, err := Check(s, &show); err != nil {
+			log.Warningf("Could not check followed show %s: %s", show.Title, err)
+		}
+	}
+}
+
+// Follow starts following a show, only episodes that air from now on are downloaded
+func Follow(showID int, category string) (*database.FollowedShow, error) {
+	if category != "" && database.GetStorm().GetCategory(category) == nil {
+		return nil, fmt.Errorf("Category %s not found", category)
+	}
+
+	show := tmdb.GetShow(showID, config.Get().Language)
+	if show == nil {
+		return nil, fmt.Errorf("Show %d not found", showID)
+	}
+
+	followed := database.GetStorm().GetFollowedShow(showID)
+	if followed == nil {
+		followed = &database.FollowedShow{
+			ID:    showID,
+			Since: util.UTCBod(),
+		}
+	}
+	followed.Title = show.Name
+	if followed.Title == "" {
+		followed.Title = show.OriginalName
+	}
+	followed.Category = category
+
+	if err := database.GetStorm().SaveFollowedShow(followed); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Following show %s", followed.Title)
+	return followed, nil
+}
+
+// Check searches for aired episodes of a followed show, that were not downloaded yet,
+// and downloads best candidate for each of them
+func Check(s *bittorrent.Service, followed *database.FollowedShow) ([]*Result, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	show := tmdb.GetShow(followed.ID, config.Get().Language)
+	if show == nil {
+		return nil, fmt.Errorf("Show %d not found", followed.ID)
+	}
+
+	followed.LastChecked = time.Now()
+	if err := database.GetStorm().SaveFollowedShow(followed); err != nil {
+		log.Warningf("Could not save followed show %s: %s", followed.Title, err)
+	}
+
+	results := []*Result{}
+	withAuth := true
+	for _, episode := range airedEpisodes(show, followed) {
+		if closer.IsSet() || s.Closer.IsSet() {
+			break
+		}
+
+		r := &Result{
+			Season:  episode.SeasonNumber,
+			Episode: episode.EpisodeNumber,
+			AirDate: episode.AirDate,
+		}
+		results = append(results, r)
+
+		if database.GetStorm().HasEpisodeGrab(followed.ID, episode.SeasonNumber, episode.EpisodeNumber) {
+			r.Reason = "Already downloaded"
+			continue
+		}
+
+		log.Infof("Searching for %s S%02dE%02d", followed.Title, episode.SeasonNumber, episode.EpisodeNumber)
+		candidate, err := search(show, episode, withAuth)
+		withAuth = false
+		if err != nil {
+			r.Error = err.Error()
+			return results, err
+		} else if candidate == nil {
+			r.Reason = "No candidates found"
+			continue
+		}
+		r.Title = candidate.Name
+
+		if r.InfoHash, err = download(s, followed, episode, candidate); err != nil {
+			r.Error = err.Error()
+			log.Warningf("Could not download %s S%02dE%02d: %s", followed.Title, episode.SeasonNumber, episode.EpisodeNumber, err)
+			continue
+		}
+
+		r.Added = true
+		log.Infof("Downloading %s S%02dE%02d from %s", followed.Title, episode.SeasonNumber, episode.EpisodeNumber, candidate.Name)
+	}
+
+	return results, nil
+}
+
+// airedEpisodes returns episodes that aired after show was followed and are still searched for
+func airedEpisodes(show *tmdb.Show, followed *database.FollowedShow) []*tmdb.Episode {
+	searchDays := config.Get().FollowShowsSearchDays
+	if searchDays <= 0 {
+		searchDays = DefaultSearchDays
+	}
+
+	cutoff := util.UTCBod().AddDate(0, 0, -searchDays)
+	if since := util.Bod(followed.Since.UTC()); since.After(cutoff) {
+		cutoff = since
+	}
+
+	ret := []*tmdb.Episode{}
+	for i := len(show.Seasons) - 1; i >= 0; i-- {
+		s := show.Seasons[i]
+		if s == nil || s.Season == 0 || s.AirDate == "" {
+			continue
+		}
+
+		seasonAired, notAired := util.AirDateWithExpireCheck(s.AirDate, true)
+		if notAired {
+			continue
+		}
+
+		season := tmdb.GetSeason(show.ID, s.Season, config.Get().Language, len(show.Seasons))
+		if season != nil {
+			for _, episode := range season.Episodes {
+				if episode == nil || episode.AirDate == "" {
+					continue
+				}
+
+				aired, notAired := util.AirDateWithExpireCheck(episode.AirDate, config.Get().ShowEpisodesOnReleaseDay)
+				if notAired || aired.Before(cutoff) {
+					continue
+				}
+
+				ret = append(ret, episode)
+			}
+		}
+
+		// Earlier seasons have only older episodes
+		if seasonAired.Before(cutoff) {
+			break
+		}
+	}
+
+	return ret
+}
+
+// search runs silent episode search and returns the best candidate,
+// links are already sorted by configured sorting and resolution preference
+func search(show *tmdb.Show, episode *tmdb.Episode, withAuth bool) (*bittorrent.TorrentFile, error) {
+	xbmcHost, err := xbmc.GetLocalXBMCHost()
+	if err != nil || xbmcHost == nil {
+		return nil, errors.New("Kodi is not available")
+	}
+
+	searchers := providers.GetEpisodeSearchers(xbmcHost, "")
+	if len(searchers) == 0 {
+		return nil, errors.New("No providers are enabled")
+	}
+
+	torrents := providers.SearchEpisodeSilent(xbmcHost, searchers, show, episode, withAuth)
+	if len(torrents) == 0 {
+		return nil, nil
+	}
+
+	// Prefer links that look like a single episode, over season packs and mismatches
+	for _, t := range torrents {
+		choices := []*bittorrent.CandidateFile{{Filename: t.Name}}
+		if _, found := bittorrent.MatchEpisodeFilename(episode.SeasonNumber, episode.EpisodeNumber, false, 0, nil, nil, nil, choices); found > 0 {
+			return t, nil
+		}
+	}
+
+	return torrents[0], nil
+}
+
+func download(s *bittorrent.Service, followed *database.FollowedShow, episode *tmdb.Episode, candidate *bittorrent.TorrentFile) (string, error) {
+	var t *bittorrent.Torrent
+	if candidate.InfoHash != "" {
+		t = s.GetTorrentByHash(candidate.InfoHash)
+	}
+
+	if t == nil {
+		var err error
+		t, err = s.AddTorrentWithCategory(nil, candidate.URI, followed.Category, false, config.StorageFile, true, time.Now())
+		if err != nil {
+			return "", err
+		} else if t == nil {
+			return "", errors.New("Torrent was not added")
+		}
+	}
+
+	if f := t.ChooseEpisodeFile(episode.SeasonNumber, episode.EpisodeNumber); f != nil {
+		t.DownloadFile(f)
+	} else {
+		t.DownloadAllFiles()
+	}
+
+	database.GetStorm().UpdateBTItem(t.InfoHash(), episode.ID, episodeType, t.SyncSelectedFiles(), candidate.Name, followed.ID, episode.SeasonNumber, episode.EpisodeNumber)
+	t.SaveDBFiles()
+
+	return t.InfoHash(), database.GetStorm().AddEpisodeGrab(followed.ID, episode.SeasonNumber, episode.EpisodeNumber, t.InfoHash(), candidate.Name)
+}
@@ -0,0 +1,297 @@
+package hls
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	h264NalSPS = 7
+	h264NalPPS = 8
+	h264NalAUD = 9
+
+	hevcNalVPS = 32
+	hevcNalSPS = 33
+	hevcNalPPS = 34
+	hevcNalAUD = 35
+
+	// Number of PCM samples in an AAC frame
+	aacFrameSamples = 1024
+	// Maximal frame length, that fits into 13 bits of ADTS header
+	adtsMaxFrameLength = 1<<13 - 1 - 7
+)
+
+var (
+	startCode = []byte{0, 0, 0, 1}
+	h264AUD   = []byte{0, 0, 0, 1, 0x09, 0xf0}
+	hevcAUD   = []byte{0, 0, 0, 1, 0x46, 0x01, 0x50}
+
+	aacSampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+	errUnsupportedAAC = errors.New("Unsupported AAC configuration")
+)
+
+// parseAVCC reads NAL length size and parameter sets from AVCDecoderConfigurationRecord
+func parseAVCC(t *track, data []byte) error {
+	if len(data) < 7 {
+		return errCorrupt
+	}
+
+	t.nalLength = int(data[4]&0x03) + 1
+	t.paramSets = nil
+
+	p := 5
+	for _, mask := range []byte{0x1f, 0xff} {
+		if p >= len(data) {
+			return errCorrupt
+		}
+		count := int(data[p] & mask)
+		p++
+
+		for i := 0; i < count; i++ {
+			set, next, err := readParamSet(data, p)
+			if err != nil {
+				return err
+			}
+			t.paramSets = append(t.paramSets, set)
+			p = next
+		}
+	}
+
+	return nil
+}
+
+// parseHVCC reads NAL length size and parameter sets from HEVCDecoderConfigurationRecord
+func parseHVCC(t *track, data []byte) error {
+	if len(data) < 23 {
+		return errCorrupt
+	}
+
+	t.nalLength = int(data[21]&0x03) + 1
+	t.paramSets = nil
+
+	arrays := int(data[22])
+	p := 23
+	for i := 0; i < arrays; i++ {
+		if p+3 > len(data) {
+			return errCorrupt
+		}
+		count := int(be16(data[p+1:]))
+		p += 3
+
+		for j := 0; j < count; j++ {
+			set, next, err := readParamSet(data, p)
+			if err != nil {
+				return err
+			}
+			t.paramSets = append(t.paramSets, set)
+			p = next
+		}
+	}
+
+	return nil
+}
+
+func readParamSet(data []byte, p int) ([]byte, int, error) {
+	if p+2 > len(data) {
+		return nil, 0, errCorrupt
+	}
+	size := int(be16(data[p:]))
+	p += 2
+	if p+size > len(data) {
+		return nil, 0, errCorrupt
+	}
+	return data[p : p+size], p + size, nil
+}
+
+// annexB converts length prefixed NAL units into start code prefixed access unit with delimiter,
+// parameter sets are repeated on keyframes, since any segment can be the first one for a player
+func (t *track) annexB(data []byte, key bool) ([]byte, error) {
+	var nals [][]byte
+	hasParams := false
+
+	for p := 0; p < len(data); {
+		if p+t.nalLength > len(data) {
+			return nil, errCorrupt
+		}
+		size := 0
+		for i := 0; i < t.nalLength; i++ {
+			size = size<<8 | int(data[p+i])
+		}
+		p += t.nalLength
+		if size > len(data)-p {
+			return nil, errCorrupt
+		}
+
+		nal := data[p : p+size]
+		p += size
+		if len(nal) == 0 {
+			continue
+		}
+
+		if t.isAUD(nal) {
+			continue
+		} else if t.isParamSet(nal) {
+			hasParams = true
+		}
+		nals = append(nals, nal)
+	}
+
+	out := make([]byte, 0, len(data)+len(nals)*4+256)
+	if t.codec == codecHEVC {
+		out = append(out, hevcAUD...)
+	} else {
+		out = append(out, h264AUD...)
+	}
+	if key && !hasParams {
+		for _, set := range t.paramSets {
+			out = append(out, startCode...)
+			out = append(out, set...)
+		}
+	}
+	for _, nal := range nals {
+		out = append(out, startCode...)
+		out = append(out, nal...)
+	}
+
+	return out, nil
+}
+
+func (t *track) nalType(nal []byte) int {
+	if t.codec == codecHEVC {
+		return int(nal[0]>>1) & 0x3f
+	}
+	return int(nal[0]) & 0x1f
+}
+
+func (t *track) isAUD(nal []byte) bool {
+	if t.codec == codecHEVC {
+		return t.nalType(nal) == hevcNalAUD
+	}
+	return t.nalType(nal) == h264NalAUD
+}
+
+func (t *track) isParamSet(nal []byte) bool {
+	typ := t.nalType(nal)
+	if t.codec == codecHEVC {
+		return typ == hevcNalVPS || typ == hevcNalSPS || typ == hevcNalPPS
+	}
+	return typ == h264NalSPS || typ == h264NalPPS
+}
+
+// aacConfig is a part of AudioSpecificConfig, that is needed for ADTS headers
+type aacConfig struct {
+	objectType int
+	freqIndex  int
+	channels   int
+}
+
+// parseAudioSpecificConfig reads AAC configuration, explicitly signalled SBR and PS
+// are replaced by the core AAC, as ADTS can only carry them implicitly
+func parseAudioSpecificConfig(data []byte) (*aacConfig, error) {
+	br := &bitReader{data: data}
+
+	objectType := func() int {
+		if ot := br.read(5); ot != 31 {
+			return ot
+		}
+		return 32 + br.read(6)
+	}
+	freqIndex := func() int {
+		if fi := br.read(4); fi != 15 {
+			return fi
+		}
+		return aacFreqIndex(br.read(24))
+	}
+
+	c := &aacConfig{}
+	c.objectType = objectType()
+	c.freqIndex = freqIndex()
+	c.channels = br.read(4)
+	if c.objectType == 5 || c.objectType == 29 {
+		freqIndex()
+		c.objectType = objectType()
+	}
+
+	if br.err != nil {
+		return nil, errCorrupt
+	}
+	return c, c.validate()
+}
+
+// aacConfigFromCodec creates AAC configuration for Matroska tracks without CodecPrivate,
+// profile is stored in codec id, like A_AAC/MPEG4/LC/SBR
+func aacConfigFromCodec(codecID string, rate float64, channels int) (*aacConfig, error) {
+	c := &aacConfig{
+		objectType: 2,
+		channels:   channels,
+	}
+	switch {
+	case strings.Contains(codecID, "/MAIN"):
+		c.objectType = 1
+	case strings.Contains(codecID, "/SSR"):
+		c.objectType = 3
+	case strings.Contains(codecID, "/LTP"):
+		c.objectType = 4
+	}
+	if strings.HasSuffix(codecID, "/SBR") {
+		// Output sample rate is stored, while ADTS needs the core one
+		rate /= 2
+	}
+	c.freqIndex = aacFreqIndex(int(rate))
+
+	return c, c.validate()
+}
+
+func (c *aacConfig) validate() error {
+	if c.objectType < 1 || c.objectType > 4 || c.freqIndex < 0 || c.freqIndex >= len(aacSampleRates) || c.channels < 1 || c.channels > 7 {
+		return errUnsupportedAAC
+	}
+	return nil
+}
+
+func (c *aacConfig) frameDuration() int64 {
+	return aacFrameSamples * clockRate / int64(aacSampleRates[c.freqIndex])
+}
+
+// adts returns ADTS header for a raw AAC frame
+func (c *aacConfig) adts(frameLength int) []byte {
+	l := frameLength + 7
+	return []byte{
+		0xff,
+		0xf1,
+		byte((c.objectType-1)<<6 | c.freqIndex<<2 | c.channels>>2),
+		byte((c.channels&0x03)<<6 | l>>11),
+		byte(l >> 3),
+		byte((l&0x07)<<5 | 0x1f),
+		0xfc,
+	}
+}
+
+func aacFreqIndex(rate int) int {
+	for i, r := range aacSampleRates {
+		if r == rate {
+			return i
+		}
+	}
+	return -1
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (br *bitReader) read(n int) (ret int) {
+	for i := 0; i < n; i++ {
+		if br.pos >= len(br.data)*8 {
+			br.err = errCorrupt
+			return 0
+		}
+		bit := (br.data[br.pos/8] >> (7 - uint(br.pos%8))) & 1
+		ret = ret<<1 | int(bit)
+		br.pos++
+	}
+	return
+}
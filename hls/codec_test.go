@@ -0,0 +1,118 @@
+package hls
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseAVCC(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		nalLength int
+		sets      int
+		err       error
+	}{
+		{"sps and pps", testAVCC, 4, 2, nil},
+		{"2 bytes NAL length", append([]byte{0x01, 0x64, 0x00, 0x1f, 0xfd}, testAVCC[5:]...), 2, 2, nil},
+		{"no parameter sets", []byte{0x01, 0x64, 0x00, 0x1f, 0xff, 0xe0, 0x00}, 4, 0, nil},
+		{"short", testAVCC[:6], 0, 0, errCorrupt},
+		{"truncated sps", testAVCC[:10], 0, 0, errCorrupt},
+		{"no pps count", testAVCC[:12], 0, 0, errCorrupt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &track{}
+			if err := parseAVCC(tr, tt.data); err != tt.err {
+				t.Fatalf("parseAVCC err = %v, want %v", err, tt.err)
+			} else if err != nil {
+				return
+			}
+			if tr.nalLength != tt.nalLength || len(tr.paramSets) != tt.sets {
+				t.Errorf("NAL length %d and %d parameter sets, want %d and %d", tr.nalLength, len(tr.paramSets), tt.nalLength, tt.sets)
+			}
+		})
+	}
+}
+
+func TestParseAudioSpecificConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		config aacConfig
+		err    error
+	}{
+		{"lc", testASC, aacConfig{objectType: 2, freqIndex: 4, channels: 2}, nil},
+		{"explicit sbr is replaced by core", []byte{0x2b, 0x11, 0x88}, aacConfig{objectType: 2, freqIndex: 6, channels: 2}, nil},
+		{"explicit frequency", []byte{0x17, 0x80, 0x5d, 0xc0, 0x10}, aacConfig{objectType: 2, freqIndex: 3, channels: 2}, nil},
+		{"unsupported object type", []byte{0x3a, 0x10}, aacConfig{}, errUnsupportedAAC},
+		{"unknown frequency", []byte{0x17, 0x80, 0x00, 0x00, 0x90}, aacConfig{}, errUnsupportedAAC},
+		{"short", []byte{0x12}, aacConfig{}, errCorrupt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseAudioSpecificConfig(tt.data)
+			if err != tt.err {
+				t.Fatalf("parseAudioSpecificConfig err = %v, want %v", err, tt.err)
+			} else if err == nil && *c != tt.config {
+				t.Errorf("config = %+v, want %+v", *c, tt.config)
+			}
+		})
+	}
+}
+
+func TestAACConfigFromCodec(t *testing.T) {
+	tests := []struct {
+		codecID string
+		rate    float64
+		config  aacConfig
+	}{
+		{"A_AAC/MPEG4/LC", 48000, aacConfig{objectType: 2, freqIndex: 3, channels: 2}},
+		{"A_AAC/MPEG2/MAIN", 44100, aacConfig{objectType: 1, freqIndex: 4, channels: 2}},
+		{"A_AAC/MPEG4/LC/SBR", 48000, aacConfig{objectType: 2, freqIndex: 6, channels: 2}},
+	}
+
+	for _, tt := range tests {
+		c, err := aacConfigFromCodec(tt.codecID, tt.rate, 2)
+		if err != nil {
+			t.Errorf("%s: %s", tt.codecID, err)
+		} else if *c != tt.config {
+			t.Errorf("%s config = %+v, want %+v", tt.codecID, *c, tt.config)
+		}
+	}
+}
+
+func TestAnnexB(t *testing.T) {
+	tr := &track{codec: codecH264, nalLength: 4, paramSets: [][]byte{{0x67, 0x64}, {0x68, 0xeb}}}
+	aud := []byte{0x00, 0x00, 0x00, 0x02, 0x09, 0xf0}
+	sps := []byte{0x00, 0x00, 0x00, 0x02, 0x67, 0x01}
+	idr := []byte{0x00, 0x00, 0x00, 0x02, 0x65, 0x88}
+
+	tests := []struct {
+		name string
+		data []byte
+		key  bool
+		want []byte
+		err  error
+	}{
+		{"keyframe gets parameter sets", idr, true, join(h264AUD, startCode, []byte{0x67, 0x64}, startCode, []byte{0x68, 0xeb}, startCode, []byte{0x65, 0x88}), nil},
+		{"frame keeps own parameter sets", join(sps, idr), true, join(h264AUD, startCode, []byte{0x67, 0x01}, startCode, []byte{0x65, 0x88}), nil},
+		{"delimiter is not repeated", join(aud, idr), false, join(h264AUD, startCode, []byte{0x65, 0x88}), nil},
+		{"empty NAL units are dropped", join([]byte{0, 0, 0, 0}, idr), false, join(h264AUD, startCode, []byte{0x65, 0x88}), nil},
+		{"NAL size over data", []byte{0x00, 0x00, 0x00, 0x03, 0x65}, false, nil, errCorrupt},
+		{"truncated NAL size", []byte{0x00, 0x00}, false, nil, errCorrupt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := tr.annexB(tt.data, tt.key)
+			if err != tt.err {
+				t.Fatalf("annexB err = %v, want %v", err, tt.err)
+			} else if !bytes.Equal(out, tt.want) {
+				t.Errorf("annexB = %x, want %x", out, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+// Package hls remuxes Matroska and MP4 files into MPEG-TS segments, described by HLS playlist.
+// Streams are copied as is, without transcoding, so only codecs, that MPEG-TS can carry, are supported.
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// TargetDuration is a desired segment length in seconds, segments are cut on video keyframes only
+	TargetDuration = 6
+
+	// PlaylistContentType is a content type of HLS playlist
+	PlaylistContentType = "application/vnd.apple.mpegurl"
+	// SegmentContentType is a content type of MPEG-TS segment
+	SegmentContentType = "video/mp2t"
+
+	clockRate = 90000
+
+	readerBufferSize = 64 * 1024
+	// Gaps up to this size are read through, instead of seeking the torrent reader
+	readerMaxDiscard = 256 * 1024
+	// Limits allocations for header elements and samples, that are read into memory
+	maxElementSize = 256 * 1024 * 1024
+)
+
+var (
+	// ErrUnsupportedContainer is returned for files that are neither Matroska nor MP4
+	ErrUnsupportedContainer = errors.New("Unsupported container")
+	// ErrUnsupportedCodec is returned when there is no video track, that can be put into MPEG-TS
+	ErrUnsupportedCodec = errors.New("No video track with supported codec")
+	// ErrNoIndex is returned when keyframe positions are not stored in the file, like Matroska without Cues
+	ErrNoIndex = errors.New("File has no keyframe index")
+	// ErrSegmentNotFound is returned for segment index out of playlist
+	ErrSegmentNotFound = errors.New("Segment not found")
+
+	errCorrupt = errors.New("File is corrupted")
+)
+
+type codec int
+
+const (
+	codecUnknown codec = iota
+	codecH264
+	codecHEVC
+	codecAAC
+	codecAC3
+	codecEAC3
+	codecMP3
+)
+
+// track is a single elementary stream of the container, selected for remuxing
+type track struct {
+	number int
+	video  bool
+	codec  codec
+
+	// Length of NAL unit size prefix and parameter sets, for H.264 and HEVC
+	nalLength int
+	paramSets [][]byte
+
+	aac *aacConfig
+
+	// Frame duration in 90kHz units, if container knows it
+	frameDuration int64
+}
+
+// sample is a single frame of a track, timestamps are in 90kHz units
+type sample struct {
+	video bool
+	key   bool
+	pts   int64
+	dts   int64
+	data  []byte
+}
+
+// keyframe is a seekable video frame, index is a sample number for containers, that have sample tables
+type keyframe struct {
+	pts    int64
+	offset int64
+	index  int
+}
+
+type demuxer interface {
+	tracks() (video, audio *track)
+	keyframes() []keyframe
+	duration() int64
+	readSegment(r *reader, seg *Segment) ([]*sample, error)
+}
+
+// Segment is a part of media between two keyframes
+type Segment struct {
+	// Start and End are presentation times in 90kHz units
+	Start int64
+	End   int64
+
+	// Offset and Length are approximate byte range of the segment data in the file
+	Offset int64
+	Length int64
+
+	// Keyframes range [from, to) of the segment
+	from int
+	to   int
+}
+
+// Duration returns segment length in seconds
+func (s *Segment) Duration() float64 {
+	return float64(s.End-s.Start) / clockRate
+}
+
+// Media is a parsed file index, that is enough to create a playlist and remux any of its segments
+type Media struct {
+	Size     int64
+	Segments []Segment
+
+	demuxer demuxer
+}
+
+// Open detects container of the file and reads its index
+func Open(rs io.ReadSeeker, size int64) (*Media, error) {
+	r, err := newReader(rs)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := r.peek(12)
+	if err != nil {
+		return nil, err
+	}
+
+	var d demuxer
+	switch {
+	case bytes.Equal(head[:4], ebmlMagic):
+		d, err = openMatroska(r, size)
+	case isMP4(head):
+		d, err = openMP4(r, size)
+	default:
+		return nil, ErrUnsupportedContainer
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if video, _ := d.tracks(); video == nil {
+		return nil, ErrUnsupportedCodec
+	} else if len(d.keyframes()) == 0 {
+		return nil, ErrNoIndex
+	}
+
+	m := &Media{
+		Size:    size,
+		demuxer: d,
+	}
+	m.split()
+
+	return m, nil
+}
+
+// split groups keyframes into segments of at least TargetDuration
+func (m *Media) split() {
+	keys := m.demuxer.keyframes()
+	target := int64(TargetDuration * clockRate)
+
+	from := 0
+	for i := 1; i <= len(keys); i++ {
+		if i < len(keys) && keys[i].pts-keys[from].pts < target {
+			continue
+		}
+
+		seg := Segment{
+			Start:  keys[from].pts,
+			Offset: keys[from].offset,
+			from:   from,
+			to:     i,
+		}
+		if i < len(keys) {
+			seg.End = keys[i].pts
+			seg.Length = keys[i].offset - seg.Offset
+		} else {
+			seg.End = m.demuxer.duration()
+			seg.Length = m.Size - seg.Offset
+		}
+		if seg.End <= seg.Start {
+			seg.End = seg.Start + target
+		}
+		if seg.Length < 0 {
+			seg.Length = 0
+		}
+
+		m.Segments = append(m.Segments, seg)
+		from = i
+	}
+
+	// First segment also takes audio, that starts before the first keyframe
+	if len(m.Segments) > 0 && m.Segments[0].Start > 0 {
+		m.Segments[0].Start = 0
+	}
+}
+
+// Duration returns media duration in seconds
+func (m *Media) Duration() float64 {
+	return float64(m.demuxer.duration()) / clockRate
+}
+
+// SegmentName returns file name of a segment, as it is used in the playlist
+func SegmentName(index int) string {
+	return fmt.Sprintf("%d.ts", index)
+}
+
+// Playlist returns VOD media playlist, segment URIs are relative to the playlist URI
+func (m *Media) Playlist() []byte {
+	target := 0
+	for i := range m.Segments {
+		if d := int(m.Segments[i].Duration() + 0.999); d > target {
+			target = d
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", target)
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	for i := range m.Segments {
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", m.Segments[i].Duration(), SegmentName(i))
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+
+	return b.Bytes()
+}
+
+// WriteSegment reads segment samples and writes them as MPEG-TS
+func (m *Media) WriteSegment(w io.Writer, rs io.ReadSeeker, index int) error {
+	if index < 0 || index >= len(m.Segments) {
+		return ErrSegmentNotFound
+	}
+
+	r, err := newReader(rs)
+	if err != nil {
+		return err
+	}
+
+	// Segment is read completely before writing, so that read errors can still be reported
+	samples, err := m.demuxer.readSegment(r, &m.Segments[index])
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].dts < samples[j].dts
+	})
+
+	video, audio := m.demuxer.tracks()
+	mux := newMuxer(w, video, audio)
+	for _, s := range samples {
+		if err := mux.writeSample(s); err != nil {
+			return err
+		}
+	}
+
+	return mux.close()
+}
+
+// reader tracks position of buffered reads and avoids seeking underlying reader for short gaps,
+// as every seek of a torrent reader changes pieces prioritization
+type reader struct {
+	rs  io.ReadSeeker
+	br  *bufio.Reader
+	pos int64
+}
+
+func newReader(rs io.ReadSeeker) (*reader, error) {
+	pos, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reader{
+		rs:  rs,
+		br:  bufio.NewReaderSize(rs, readerBufferSize),
+		pos: pos,
+	}, nil
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.br.Read(p)
+	r.pos += int64(n)
+	return n, err
+}
+
+func (r *reader) readByte() (byte, error) {
+	b, err := r.br.ReadByte()
+	if err == nil {
+		r.pos++
+	}
+	return b, readError(err)
+}
+
+func (r *reader) peek(n int) ([]byte, error) {
+	b, err := r.br.Peek(n)
+	return b, readError(err)
+}
+
+// readFull grows the buffer while reading, so that sizes of corrupted elements
+// do not allocate memory for data, that is not in the file
+func (r *reader) readFull(n int64) ([]byte, error) {
+	if n < 0 || n > maxElementSize {
+		return nil, errCorrupt
+	}
+
+	var buf bytes.Buffer
+	if n <= readerBufferSize {
+		buf.Grow(int(n))
+	}
+	if _, err := buf.ReadFrom(io.LimitReader(r, n)); err != nil {
+		return nil, readError(err)
+	} else if int64(buf.Len()) < n {
+		return nil, errCorrupt
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *reader) seek(pos int64) error {
+	if pos == r.pos {
+		return nil
+	}
+
+	if gap := pos - r.pos; gap > 0 && (gap <= int64(r.br.Buffered()) || gap <= readerMaxDiscard) {
+		n, err := r.br.Discard(int(gap))
+		r.pos += int64(n)
+		return readError(err)
+	}
+
+	if _, err := r.rs.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	r.br.Reset(r.rs)
+	r.pos = pos
+	return nil
+}
+
+// readError reports file, that ends before its elements, as corrupted
+func readError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errCorrupt
+	}
+	return err
+}
+
+func be16(b []byte) uint16 {
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+func be32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func be64(b []byte) uint64 {
+	return uint64(be32(b))<<32 | uint64(be32(b[4:]))
+}
+
+// rescale converts timestamp from one clock rate into another, without overflow for long files
+func rescale(ts, from, to int64) int64 {
+	if from == to || from == 0 {
+		return ts
+	}
+	return ts/from*to + ts%from*to/from
+}
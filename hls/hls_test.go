@@ -0,0 +1,258 @@
+package hls
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Test media has 25fps video with keyframe every 2 seconds, and AAC LC audio
+const (
+	testFrameRate   = 25
+	testDuration    = 20
+	testFrames      = testFrameRate * testDuration
+	testKeyInterval = 2 * testFrameRate
+	testSampleRate  = 44100
+	testAudioFrames = testDuration * testSampleRate / aacFrameSamples
+)
+
+var (
+	// High profile with 4 bytes NAL length, a single SPS and PPS
+	testAVCC = []byte{0x01, 0x64, 0x00, 0x1f, 0xff, 0xe1, 0x00, 0x04, 0x67, 0x64, 0x00, 0x1f, 0x01, 0x00, 0x02, 0x68, 0xeb}
+	// AAC LC, 44.1kHz, stereo
+	testASC = []byte{0x12, 0x10}
+)
+
+// testVideoFrame returns a length prefixed NAL unit, IDR slice for keyframes
+func testVideoFrame(i int) []byte {
+	nal := []byte{0x41, 0x9a, byte(i >> 8), byte(i)}
+	if i%testKeyInterval == 0 {
+		nal = []byte{0x65, 0x88, byte(i >> 8), byte(i)}
+	}
+	return append([]byte{0, 0, 0, byte(len(nal))}, nal...)
+}
+
+func testAudioFrame(i int) []byte {
+	return []byte{0x21, 0x10, byte(i >> 8), byte(i), 0x00, 0x00}
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+type testMedia struct {
+	name string
+	data []byte
+	// File offsets of keyframes
+	keys []int64
+	// Composition offset of video frames in 90kHz units
+	cto int64
+}
+
+func testMedias() []testMedia {
+	mkv, mkvKeys := newMatroska()
+	mp4, mp4Keys := newMP4()
+
+	return []testMedia{
+		{name: "matroska", data: mkv, keys: mkvKeys},
+		{name: "mp4", data: mp4, keys: mp4Keys, cto: clockRate / testFrameRate},
+	}
+}
+
+func TestOpen(t *testing.T) {
+	keyDuration := int64(testKeyInterval * clockRate / testFrameRate)
+
+	for _, tm := range testMedias() {
+		t.Run(tm.name, func(t *testing.T) {
+			m, err := Open(bytes.NewReader(tm.data), int64(len(tm.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if m.Duration() != testDuration {
+				t.Errorf("Duration = %v, want %v", m.Duration(), testDuration)
+			}
+
+			// Segments are cut at every third keyframe, first segment starts at zero, last ends with media
+			want := []struct {
+				key        int
+				start, end int64
+			}{
+				{0, 0, 3*keyDuration + tm.cto},
+				{3, 3*keyDuration + tm.cto, 6*keyDuration + tm.cto},
+				{6, 6*keyDuration + tm.cto, 9*keyDuration + tm.cto},
+				{9, 9*keyDuration + tm.cto, testDuration * clockRate},
+			}
+			if len(m.Segments) != len(want) {
+				t.Fatalf("got %d segments, want %d", len(m.Segments), len(want))
+			}
+			for i, w := range want {
+				seg := m.Segments[i]
+				length := int64(len(tm.data)) - seg.Offset
+				if w.key+3 < len(tm.keys) {
+					length = tm.keys[w.key+3] - seg.Offset
+				}
+				if seg.Start != w.start || seg.End != w.end || seg.Offset != tm.keys[w.key] || seg.Length != length {
+					t.Errorf("segment %d = %+v, want start %d, end %d, offset %d, length %d", i, seg, w.start, w.end, tm.keys[w.key], length)
+				}
+			}
+
+			for i, offset := range tm.keys {
+				if o := m.KeyframeOffset(float64(i*testKeyInterval/testFrameRate) + 0.5); o != offset {
+					t.Errorf("KeyframeOffset of keyframe %d = %d, want %d", i, o, offset)
+				}
+			}
+
+			// Target duration is the longest segment, rounded up
+			target := fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", (want[0].end+clockRate-1)/clockRate)
+			playlist := string(m.Playlist())
+			if !strings.Contains(playlist, target) || strings.Count(playlist, "#EXTINF:") != len(want) || !strings.HasSuffix(playlist, "3.ts\n#EXT-X-ENDLIST\n") {
+				t.Errorf("unexpected playlist:\n%s", playlist)
+			}
+		})
+	}
+}
+
+func TestWriteSegment(t *testing.T) {
+	for _, tm := range testMedias() {
+		t.Run(tm.name, func(t *testing.T) {
+			m, err := Open(bytes.NewReader(tm.data), int64(len(tm.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, seg := range m.Segments {
+				var b bytes.Buffer
+				if err := m.WriteSegment(&b, bytes.NewReader(tm.data), i); err != nil {
+					t.Fatalf("segment %d: %s", i, err)
+				}
+
+				types, packets := parseTS(t, b.Bytes())
+				if !bytes.Equal(types, []byte{0x1b, 0x0f}) {
+					t.Errorf("segment %d stream types = %x, want H.264 and AAC", i, types)
+				}
+
+				var video, audio []*tsPES
+				for _, p := range packets {
+					if p.pid == pidVideo {
+						video = append(video, p)
+					} else {
+						audio = append(audio, p)
+					}
+				}
+
+				frames := 3 * testKeyInterval
+				if i == len(m.Segments)-1 {
+					frames = testFrames - 9*testKeyInterval
+				}
+				if len(video) != frames {
+					t.Fatalf("segment %d has %d video frames, want %d", i, len(video), frames)
+				}
+				if !video[0].key || !bytes.Contains(video[0].payload, []byte{0, 0, 0, 1, 0x67}) {
+					t.Errorf("segment %d should start with keyframe with parameter sets", i)
+				}
+				for j, p := range video {
+					if p.pts < p.dts {
+						t.Errorf("segment %d frame %d has PTS %d before DTS %d", i, j, p.pts, p.dts)
+					}
+					if j > 0 && p.dts <= video[j-1].dts {
+						t.Errorf("segment %d frame %d has DTS %d, not after %d", i, j, p.dts, video[j-1].dts)
+					}
+				}
+
+				if len(audio) == 0 {
+					t.Fatalf("segment %d has no audio", i)
+				}
+				for j, p := range audio {
+					if p.pts < seg.Start+tsOffset || p.pts >= seg.End+tsOffset {
+						t.Errorf("segment %d audio PTS %d is out of segment", i, p.pts-tsOffset)
+					}
+					if j > 0 && p.pts <= audio[j-1].pts {
+						t.Errorf("segment %d audio PTS %d is not after %d", i, p.pts, audio[j-1].pts)
+					}
+				}
+			}
+
+			if err := m.WriteSegment(io.Discard, bytes.NewReader(tm.data), len(m.Segments)); err != ErrSegmentNotFound {
+				t.Errorf("WriteSegment err = %v, want %v", err, ErrSegmentNotFound)
+			}
+		})
+	}
+}
+
+func TestOpenUnsupported(t *testing.T) {
+	tests := map[string][]byte{
+		"empty":   nil,
+		"short":   []byte("RIFF"),
+		"unknown": []byte("RIFF\x00\x00\x00\x00AVI LIST"),
+	}
+
+	for name, data := range tests {
+		if _, err := Open(bytes.NewReader(data), int64(len(data))); err == nil {
+			t.Errorf("Open of %s file should fail", name)
+		}
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	for _, tm := range testMedias() {
+		t.Run(tm.name, func(t *testing.T) {
+			// Index is stored after media data, so any truncated file has no complete index
+			for n := 0; n < len(tm.data); n += 7 {
+				data := tm.data[:n]
+				if _, err := Open(bytes.NewReader(data), int64(n)); err != errCorrupt && err != ErrUnsupportedContainer {
+					t.Fatalf("Open of %d bytes err = %v, want %v", n, err, errCorrupt)
+				}
+			}
+
+			m, err := Open(bytes.NewReader(tm.data), int64(len(tm.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, seg := range m.Segments {
+				data := tm.data[:seg.Offset+10]
+				if err := m.WriteSegment(io.Discard, bytes.NewReader(data), i); err != errCorrupt {
+					t.Errorf("segment %d of truncated file err = %v, want %v", i, err, errCorrupt)
+				}
+			}
+		})
+	}
+}
+
+// TestCorrupted changes bytes of the file, parsing and remuxing should fail or succeed, but never panic.
+// Every byte of headers and start of the index is changed, only some bytes of media data and sample tables.
+func TestCorrupted(t *testing.T) {
+	for _, tm := range testMedias() {
+		t.Run(tm.name, func(t *testing.T) {
+			index, _, err := LocateIndex(bytes.NewReader(tm.data), int64(len(tm.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			step := func(i int) int {
+				if int64(i) >= tm.keys[0] && (int64(i) < index || int64(i) >= index+256) {
+					return 127
+				}
+				return 1
+			}
+
+			data := make([]byte, len(tm.data))
+			for i := 0; i < len(tm.data); i += step(i) {
+				for _, v := range []byte{0x00, 0xff, tm.data[i] ^ 0x80} {
+					copy(data, tm.data)
+					data[i] = v
+
+					m, err := Open(bytes.NewReader(data), int64(len(data)))
+					if err != nil {
+						continue
+					}
+					for j := range m.Segments {
+						m.WriteSegment(io.Discard, bytes.NewReader(data), j)
+					}
+				}
+			}
+		})
+	}
+}
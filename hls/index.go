@@ -0,0 +1,188 @@
+package hls
+
+import (
+	"bytes"
+	"io"
+	"sort"
+)
+
+const (
+	// Index range is limited, when its end is not known from the header
+	maxIndexSize = 32 * 1024 * 1024
+	// Header elements are looked for only at the beginning of the file,
+	// so that locating the index does not wait for pieces in the middle of the file
+	maxHeaderScan = 4 * 1024 * 1024
+)
+
+// LocateIndex reads only the beginning of the file and returns byte range of the keyframe index,
+// which is Cues for Matroska and the movie box for MP4. Players read the index before playback starts
+// and on every seek, so it should be downloaded together with the start of the file.
+func LocateIndex(rs io.ReadSeeker, size int64) (offset, length int64, err error) {
+	r, err := newReader(rs)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	head, err := r.peek(12)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch {
+	case bytes.Equal(head[:4], ebmlMagic):
+		offset, length, err = locateMatroskaIndex(r, size)
+	case isMP4(head):
+		offset, length, err = locateMP4Index(r, size)
+	default:
+		return 0, 0, ErrUnsupportedContainer
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if offset+length > size {
+		length = size - offset
+	}
+	if length > maxIndexSize {
+		length = maxIndexSize
+	}
+	return offset, length, nil
+}
+
+// locateMatroskaIndex looks for Cues in the header, or for Cues position in the SeekHead,
+// in which case Cues end at the next element, that SeekHead points to, or at the end of segment
+func locateMatroskaIndex(r *reader, size int64) (int64, int64, error) {
+	id, length, err := readElementHeader(r)
+	if err != nil {
+		return 0, 0, err
+	} else if id != mkvEBML || length < 0 {
+		return 0, 0, ErrUnsupportedContainer
+	}
+	if err := r.seek(r.pos + length); err != nil {
+		return 0, 0, err
+	}
+
+	id, length, err = readElementHeader(r)
+	if err != nil {
+		return 0, 0, err
+	} else if id != mkvSegment {
+		return 0, 0, errCorrupt
+	}
+
+	segmentStart := r.pos
+	segmentEnd := size
+	if length >= 0 && segmentStart+length < size {
+		segmentEnd = segmentStart + length
+	}
+
+	d := &mkvDemuxer{}
+	positions := map[uint64][]int64{}
+	for pos := r.pos; pos < segmentEnd && pos < maxHeaderScan; {
+		id, length, err := readElementHeader(r)
+		if err != nil {
+			return 0, 0, err
+		} else if id == mkvCluster {
+			break
+		} else if length < 0 {
+			return 0, 0, errCorrupt
+		}
+
+		switch id {
+		case mkvCues:
+			return pos, r.pos + length - pos, nil
+		case mkvSeekHead:
+			body, err := r.readFull(length)
+			if err != nil {
+				return 0, 0, err
+			}
+			if err := d.parseSeekHead(body, positions); err != nil {
+				return 0, 0, err
+			}
+		default:
+			if err := r.seek(r.pos + length); err != nil {
+				return 0, 0, err
+			}
+		}
+		pos = r.pos
+	}
+
+	if len(positions[mkvCues]) == 0 {
+		return 0, 0, ErrNoIndex
+	}
+
+	cues := segmentStart + positions[mkvCues][0]
+	end := segmentEnd
+	for _, list := range positions {
+		for _, pos := range list {
+			if p := segmentStart + pos; p > cues && p < end {
+				end = p
+			}
+		}
+	}
+
+	return cues, end - cues, nil
+}
+
+// locateMP4Index walks top level boxes up to the movie box, when media data goes first,
+// the movie box is expected right after it and is not read
+func locateMP4Index(r *reader, size int64) (int64, int64, error) {
+	for pos := int64(0); pos+8 <= size && pos < maxHeaderScan; {
+		if err := r.seek(pos); err != nil {
+			return 0, 0, err
+		}
+		header, err := r.readFull(8)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		boxSize := int64(be32(header))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = size - pos
+		case 1:
+			ext, err := r.readFull(8)
+			if err != nil {
+				return 0, 0, err
+			}
+			boxSize = int64(be64(ext))
+			headerSize = 16
+		}
+		if boxSize < headerSize {
+			return 0, 0, errCorrupt
+		}
+
+		switch string(header[4:8]) {
+		case "moov":
+			return pos, boxSize, nil
+		case "moof":
+			return 0, 0, errFragmented
+		case "mdat":
+			if pos+boxSize >= size {
+				return 0, 0, ErrNoIndex
+			}
+			return pos + boxSize, size - pos - boxSize, nil
+		}
+
+		pos += boxSize
+	}
+
+	return 0, 0, ErrNoIndex
+}
+
+// KeyframeOffset returns file offset of the last keyframe, that is not later than given time in seconds
+func (m *Media) KeyframeOffset(seconds float64) int64 {
+	keys := m.demuxer.keyframes()
+	if len(keys) == 0 {
+		return 0
+	}
+
+	pts := int64(seconds * clockRate)
+	i := sort.Search(len(keys), func(i int) bool {
+		return keys[i].pts > pts
+	})
+	if i > 0 {
+		i--
+	}
+	return keys[i].offset
+}
@@ -0,0 +1,901 @@
+package hls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// Matroska element IDs, that are needed for remuxing
+const (
+	mkvEBML     = 0x1a45dfa3
+	mkvDocType  = 0x4282
+	mkvSegment  = 0x18538067
+	mkvSeekHead = 0x114d9b74
+	mkvSeek     = 0x4dbb
+	mkvSeekID   = 0x53ab
+	mkvSeekPos  = 0x53ac
+
+	mkvInfo          = 0x1549a966
+	mkvTimecodeScale = 0x2ad7b1
+	mkvDuration      = 0x4489
+
+	mkvTracks          = 0x1654ae6b
+	mkvTrackEntry      = 0xae
+	mkvTrackNumber     = 0xd7
+	mkvTrackType       = 0x83
+	mkvFlagEnabled     = 0xb9
+	mkvFlagDefault     = 0x88
+	mkvCodecID         = 0x86
+	mkvCodecPrivate    = 0x63a2
+	mkvDefaultDuration = 0x23e383
+	mkvAudio           = 0xe1
+	mkvSamplingFreq    = 0xb5
+	mkvChannels        = 0x9f
+
+	mkvContentEncodings    = 0x6d80
+	mkvContentEncoding     = 0x6240
+	mkvContentCompression  = 0x5034
+	mkvContentCompAlgo     = 0x4254
+	mkvContentCompSettings = 0x4255
+	mkvContentEncryption   = 0x5035
+
+	mkvCues              = 0x1c53bb6b
+	mkvCuePoint          = 0xbb
+	mkvCueTime           = 0xb3
+	mkvCueTrackPositions = 0xb7
+	mkvCueTrack          = 0xf7
+	mkvCueClusterPos     = 0xf1
+
+	mkvCluster        = 0x1f43b675
+	mkvTimecode       = 0xe7
+	mkvSimpleBlock    = 0xa3
+	mkvBlockGroup     = 0xa0
+	mkvBlock          = 0xa1
+	mkvReferenceBlock = 0xfb
+
+	mkvTags        = 0x1254c367
+	mkvChapters    = 0x1043a770
+	mkvAttachments = 0x1941a469
+
+	mkvTrackVideo = 1
+	mkvTrackAudio = 2
+
+	// Header stripping is the only content encoding, that can be undone without decompression
+	mkvCompHeaderStripping = 3
+
+	// Used to generate DTS for video with unknown frame rate
+	mkvDefaultFrameDuration = clockRate / 24
+	// Decoding delay in frames, that covers usual B-frames pyramids
+	mkvReorderFrames = 2
+)
+
+var ebmlMagic = []byte{0x1a, 0x45, 0xdf, 0xa3}
+
+type mkvTrack struct {
+	*track
+
+	trackType   int
+	enabled     bool
+	isDefault   bool
+	codecID     string
+	private     []byte
+	rate        float64
+	channels    int
+	stripped    []byte
+	unsupported bool
+}
+
+type mkvDemuxer struct {
+	segmentStart int64
+	segmentEnd   int64
+	scale        int64
+	length       int64
+
+	video *mkvTrack
+	audio *mkvTrack
+	keys  []keyframe
+
+	cues    []byte
+	visited map[int64]bool
+}
+
+// openMatroska reads header elements until the first cluster, and elements,
+// that are stored after clusters and referenced from SeekHead, like Cues
+func openMatroska(r *reader, size int64) (*mkvDemuxer, error) {
+	id, length, err := readElementHeader(r)
+	if err != nil {
+		return nil, err
+	} else if id != mkvEBML || length < 0 {
+		return nil, ErrUnsupportedContainer
+	}
+	header, err := r.readFull(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := eachElement(header, func(id uint64, body []byte) error {
+		if id == mkvDocType && string(body) != "matroska" && string(body) != "webm" {
+			return ErrUnsupportedContainer
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	id, length, err = readElementHeader(r)
+	if err != nil {
+		return nil, err
+	} else if id != mkvSegment {
+		return nil, errCorrupt
+	}
+
+	d := &mkvDemuxer{
+		segmentStart: r.pos,
+		segmentEnd:   size,
+		scale:        1000000,
+		visited:      map[int64]bool{},
+	}
+	if length >= 0 && d.segmentStart+length < size {
+		d.segmentEnd = d.segmentStart + length
+	}
+	truncated := length >= 0 && d.segmentStart+length > size
+
+	var tracks []*mkvTrack
+	positions := map[uint64][]int64{}
+	parse := func(id uint64, body []byte) error {
+		switch id {
+		case mkvSeekHead:
+			return d.parseSeekHead(body, positions)
+		case mkvInfo:
+			return d.parseInfo(body)
+		case mkvTracks:
+			var err error
+			tracks, err = parseTracks(body)
+			return err
+		case mkvCues:
+			d.cues = body
+		}
+		return nil
+	}
+
+	clusters := false
+	for pos := r.pos; pos < d.segmentEnd; {
+		id, length, err := readElementHeader(r)
+		if err != nil {
+			return nil, err
+		} else if id == mkvCluster {
+			clusters = true
+			break
+		} else if length < 0 {
+			return nil, errCorrupt
+		}
+
+		d.visited[pos-d.segmentStart] = true
+		switch id {
+		case mkvSeekHead, mkvInfo, mkvTracks, mkvCues:
+			body, err := r.readFull(length)
+			if err != nil {
+				return nil, err
+			}
+			if err := parse(id, body); err != nil {
+				return nil, err
+			}
+		default:
+			if err := r.seek(r.pos + length); err != nil {
+				return nil, err
+			}
+		}
+		pos = r.pos
+	}
+	// Header elements are followed by clusters, unless the file ends before them
+	if !clusters && truncated {
+		return nil, errCorrupt
+	}
+
+	// SeekHead can point to another SeekHead at the end of the file
+	for _, id := range []uint64{mkvSeekHead, mkvInfo, mkvTracks, mkvSeekHead, mkvCues} {
+		for _, pos := range positions[id] {
+			if d.visited[pos] {
+				continue
+			}
+			d.visited[pos] = true
+
+			if err := r.seek(d.segmentStart + pos); err != nil {
+				return nil, err
+			}
+			eid, length, err := readElementHeader(r)
+			if err != nil {
+				return nil, err
+			} else if eid != id || length < 0 {
+				continue
+			}
+			body, err := r.readFull(length)
+			if err != nil {
+				return nil, err
+			}
+			if err := parse(id, body); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	d.selectTracks(tracks)
+	if d.video == nil {
+		return nil, ErrUnsupportedCodec
+	} else if d.cues == nil {
+		return nil, ErrNoIndex
+	}
+	if err := d.parseCues(d.cues); err != nil {
+		return nil, err
+	}
+	d.cues = nil
+
+	if d.length == 0 && len(d.keys) > 0 {
+		d.length = d.keys[len(d.keys)-1].pts + TargetDuration*clockRate
+	}
+
+	return d, nil
+}
+
+func (d *mkvDemuxer) tracks() (video, audio *track) {
+	if d.video != nil {
+		video = d.video.track
+	}
+	if d.audio != nil {
+		audio = d.audio.track
+	}
+	return
+}
+
+func (d *mkvDemuxer) keyframes() []keyframe {
+	return d.keys
+}
+
+func (d *mkvDemuxer) duration() int64 {
+	return d.length
+}
+
+// toClock converts timestamp in Matroska ticks into 90kHz units
+func (d *mkvDemuxer) toClock(ticks int64) int64 {
+	return rescale(ticks*d.scale, 1000000000, clockRate)
+}
+
+func (d *mkvDemuxer) parseSeekHead(data []byte, positions map[uint64][]int64) error {
+	return eachElement(data, func(id uint64, body []byte) error {
+		if id != mkvSeek {
+			return nil
+		}
+
+		var seekID uint64
+		pos := int64(-1)
+		if err := eachElement(body, func(id uint64, body []byte) error {
+			switch id {
+			case mkvSeekID:
+				seekID = readUint(body)
+			case mkvSeekPos:
+				pos = int64(readUint(body))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if pos >= 0 {
+			positions[seekID] = append(positions[seekID], pos)
+		}
+		return nil
+	})
+}
+
+func (d *mkvDemuxer) parseInfo(data []byte) error {
+	var duration float64
+	err := eachElement(data, func(id uint64, body []byte) error {
+		switch id {
+		case mkvTimecodeScale:
+			if scale := int64(readUint(body)); scale > 0 {
+				d.scale = scale
+			}
+		case mkvDuration:
+			duration = readFloat(body)
+		}
+		return nil
+	})
+
+	d.length = rescale(int64(duration*float64(d.scale)), 1000000000, clockRate)
+	return err
+}
+
+func parseTracks(data []byte) ([]*mkvTrack, error) {
+	var tracks []*mkvTrack
+
+	err := eachElement(data, func(id uint64, body []byte) error {
+		if id != mkvTrackEntry {
+			return nil
+		}
+
+		t := &mkvTrack{
+			track:     &track{},
+			enabled:   true,
+			isDefault: true,
+			channels:  1,
+			rate:      8000,
+		}
+		tracks = append(tracks, t)
+
+		return eachElement(body, func(id uint64, body []byte) error {
+			switch id {
+			case mkvTrackNumber:
+				t.number = int(readUint(body))
+			case mkvTrackType:
+				t.trackType = int(readUint(body))
+			case mkvFlagEnabled:
+				t.enabled = readUint(body) != 0
+			case mkvFlagDefault:
+				t.isDefault = readUint(body) != 0
+			case mkvCodecID:
+				t.codecID = string(bytes.TrimRight(body, "\x00"))
+			case mkvCodecPrivate:
+				t.private = body
+			case mkvDefaultDuration:
+				t.frameDuration = rescale(int64(readUint(body)), 1000000000, clockRate)
+			case mkvAudio:
+				return eachElement(body, func(id uint64, body []byte) error {
+					switch id {
+					case mkvSamplingFreq:
+						t.rate = readFloat(body)
+					case mkvChannels:
+						t.channels = int(readUint(body))
+					}
+					return nil
+				})
+			case mkvContentEncodings:
+				return t.parseEncodings(body)
+			}
+			return nil
+		})
+	})
+
+	return tracks, err
+}
+
+// parseEncodings reads header stripping settings, other encodings are not supported
+func (t *mkvTrack) parseEncodings(data []byte) error {
+	return eachElement(data, func(id uint64, body []byte) error {
+		if id != mkvContentEncoding {
+			return nil
+		}
+
+		return eachElement(body, func(id uint64, body []byte) error {
+			switch id {
+			case mkvContentEncryption:
+				t.unsupported = true
+			case mkvContentCompression:
+				algo := uint64(0)
+				var settings []byte
+				if err := eachElement(body, func(id uint64, body []byte) error {
+					switch id {
+					case mkvContentCompAlgo:
+						algo = readUint(body)
+					case mkvContentCompSettings:
+						settings = body
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+
+				if algo == mkvCompHeaderStripping {
+					t.stripped = settings
+				} else {
+					t.unsupported = true
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// setup detects codec of the track, returning false if track can't be remuxed
+func (t *mkvTrack) setup() bool {
+	if !t.enabled || t.unsupported {
+		return false
+	}
+
+	var err error
+	switch {
+	case t.codecID == "V_MPEG4/ISO/AVC":
+		t.codec = codecH264
+		err = parseAVCC(t.track, t.private)
+	case t.codecID == "V_MPEGH/ISO/HEVC":
+		t.codec = codecHEVC
+		err = parseHVCC(t.track, t.private)
+	case t.codecID == "A_AAC" && len(t.private) > 0:
+		t.codec = codecAAC
+		t.aac, err = parseAudioSpecificConfig(t.private)
+	case strings.HasPrefix(t.codecID, "A_AAC"):
+		t.codec = codecAAC
+		t.aac, err = aacConfigFromCodec(t.codecID, t.rate, t.channels)
+	case t.codecID == "A_AC3":
+		t.codec = codecAC3
+	case t.codecID == "A_EAC3":
+		t.codec = codecEAC3
+	case t.codecID == "A_MPEG/L3":
+		t.codec = codecMP3
+	}
+	if err != nil || t.codec == codecUnknown {
+		return false
+	}
+
+	if t.codec == codecAAC && t.frameDuration == 0 {
+		t.frameDuration = t.aac.frameDuration()
+	}
+	return true
+}
+
+// selectTracks takes first video track and default audio track, or first audio track if there is no default
+func (d *mkvDemuxer) selectTracks(tracks []*mkvTrack) {
+	for _, t := range tracks {
+		switch t.trackType {
+		case mkvTrackVideo:
+			t.video = true
+			if d.video == nil && t.setup() {
+				d.video = t
+			}
+		case mkvTrackAudio:
+			if (d.audio == nil || (!d.audio.isDefault && t.isDefault)) && t.setup() {
+				d.audio = t
+			}
+		}
+	}
+}
+
+// parseCues collects cluster positions of video keyframes
+func (d *mkvDemuxer) parseCues(data []byte) error {
+	err := eachElement(data, func(id uint64, body []byte) error {
+		if id != mkvCuePoint {
+			return nil
+		}
+
+		var cueTime uint64
+		var positions []int64
+		if err := eachElement(body, func(id uint64, body []byte) error {
+			switch id {
+			case mkvCueTime:
+				cueTime = readUint(body)
+			case mkvCueTrackPositions:
+				track, pos := 0, int64(-1)
+				if err := eachElement(body, func(id uint64, body []byte) error {
+					switch id {
+					case mkvCueTrack:
+						track = int(readUint(body))
+					case mkvCueClusterPos:
+						pos = int64(readUint(body))
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+				if track == d.video.number && pos >= 0 {
+					positions = append(positions, pos)
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if len(positions) > 0 {
+			d.keys = append(d.keys, keyframe{
+				pts:    d.toClock(int64(cueTime)),
+				offset: d.segmentStart + positions[0],
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(d.keys, func(i, j int) bool {
+		return d.keys[i].pts < d.keys[j].pts
+	})
+	return nil
+}
+
+// segmentReader holds state of reading clusters of a single segment
+type segmentReader struct {
+	d   *mkvDemuxer
+	seg *Segment
+
+	keyPTS  int64
+	last    bool
+	started bool
+	ended   bool
+
+	samples []*sample
+}
+
+// readSegment reads clusters from the segment keyframe, until the next segment keyframe.
+// Video is taken in decoding order between keyframes, audio by its timestamps.
+func (d *mkvDemuxer) readSegment(r *reader, seg *Segment) ([]*sample, error) {
+	sr := &segmentReader{
+		d:      d,
+		seg:    seg,
+		keyPTS: d.keys[seg.from].pts,
+		last:   seg.to >= len(d.keys),
+	}
+
+	for pos := seg.Offset; pos < d.segmentEnd; {
+		if err := r.seek(pos); err != nil {
+			return nil, err
+		}
+		id, length, err := readElementHeader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if id != mkvCluster {
+			if length < 0 {
+				break
+			}
+			pos = r.pos + length
+			continue
+		}
+
+		end := d.segmentEnd
+		if length >= 0 {
+			end = r.pos + length
+		}
+		next, done, err := sr.readCluster(r, end)
+		if err != nil {
+			return nil, err
+		} else if done {
+			break
+		}
+		pos = next
+	}
+
+	sr.generateDTS()
+	return sr.samples, nil
+}
+
+// readCluster returns position of the next element, and if segment is completely read
+func (sr *segmentReader) readCluster(r *reader, end int64) (int64, bool, error) {
+	var timecode int64
+
+	for r.pos < end {
+		start := r.pos
+		id, length, err := readElementHeader(r)
+		if err != nil {
+			return 0, false, err
+		}
+
+		switch id {
+		case mkvCluster, mkvCues, mkvTags, mkvChapters, mkvAttachments, mkvSeekHead:
+			// Cluster of unknown size ends with next top level element
+			return start, false, nil
+		}
+		if length < 0 {
+			return 0, false, errCorrupt
+		}
+
+		switch id {
+		case mkvTimecode:
+			body, err := r.readFull(length)
+			if err != nil {
+				return 0, false, err
+			}
+			timecode = int64(readUint(body))
+			if !sr.last && sr.started && sr.d.toClock(timecode) >= sr.seg.End {
+				return 0, true, nil
+			}
+		case mkvSimpleBlock:
+			body, err := r.readFull(length)
+			if err != nil {
+				return 0, false, err
+			}
+			if err := sr.addBlock(body, timecode, true, false); err != nil {
+				return 0, false, err
+			}
+		case mkvBlockGroup:
+			body, err := r.readFull(length)
+			if err != nil {
+				return 0, false, err
+			}
+			var block []byte
+			key := true
+			if err := eachElement(body, func(id uint64, body []byte) error {
+				switch id {
+				case mkvBlock:
+					block = body
+				case mkvReferenceBlock:
+					key = false
+				}
+				return nil
+			}); err != nil {
+				return 0, false, err
+			}
+			if block != nil {
+				if err := sr.addBlock(block, timecode, false, key); err != nil {
+					return 0, false, err
+				}
+			}
+		default:
+			if err := r.seek(r.pos + length); err != nil {
+				return 0, false, err
+			}
+		}
+	}
+
+	return r.pos, false, nil
+}
+
+func (sr *segmentReader) addBlock(data []byte, timecode int64, simple, key bool) error {
+	number, n := readVint(data, false)
+	if n == 0 || len(data) < n+3 {
+		return errCorrupt
+	}
+
+	var t *mkvTrack
+	switch int(number) {
+	case sr.d.video.number:
+		t = sr.d.video
+	default:
+		if sr.d.audio == nil || int(number) != sr.d.audio.number {
+			return nil
+		}
+		t = sr.d.audio
+	}
+
+	flags := data[n+2]
+	if simple {
+		key = flags&0x80 != 0
+	}
+	frames, err := unlace(data[n+3:], (flags>>1)&0x03)
+	if err != nil {
+		return err
+	}
+
+	pts := sr.d.toClock(timecode + int64(int16(be16(data[n:]))))
+	for i, frame := range frames {
+		s := &sample{
+			video: t.video,
+			key:   key && i == 0,
+			pts:   pts + int64(i)*t.frameDuration,
+		}
+		s.dts = s.pts
+
+		if t.video {
+			if s.key && s.pts >= sr.keyPTS {
+				if sr.started && !sr.last && s.pts >= sr.seg.End {
+					sr.ended = true
+				}
+				sr.started = true
+			}
+			if !sr.started || sr.ended {
+				continue
+			}
+		} else if s.pts < sr.seg.Start || (!sr.last && s.pts >= sr.seg.End) {
+			continue
+		}
+
+		if len(t.stripped) > 0 {
+			s.data = make([]byte, 0, len(t.stripped)+len(frame))
+			s.data = append(append(s.data, t.stripped...), frame...)
+		} else {
+			s.data = frame
+		}
+		sr.samples = append(sr.samples, s)
+	}
+
+	return nil
+}
+
+// generateDTS assigns sorted presentation times to video frames in decoding order,
+// shifted by a constant delay, as Matroska stores presentation times only
+func (sr *segmentReader) generateDTS() {
+	var video []*sample
+	var times []int64
+	for _, s := range sr.samples {
+		if s.video {
+			video = append(video, s)
+			times = append(times, s.pts)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i] < times[j]
+	})
+
+	frameDuration := sr.d.video.frameDuration
+	if frameDuration <= 0 {
+		frameDuration = mkvDefaultFrameDuration
+	}
+	delay := mkvReorderFrames * frameDuration
+
+	prev := int64(math.MinInt64)
+	for i, s := range video {
+		dts := times[i] - delay
+		if dts > s.pts {
+			dts = s.pts
+		}
+		if dts <= prev {
+			dts = prev + 1
+		}
+		s.dts = dts
+		prev = dts
+	}
+}
+
+// unlace splits block data into frames, according to Xiph, EBML or fixed-size lacing
+func unlace(data []byte, lacing byte) ([][]byte, error) {
+	if lacing == 0 {
+		return [][]byte{data}, nil
+	} else if len(data) == 0 {
+		return nil, errCorrupt
+	}
+
+	count := int(data[0]) + 1
+	data = data[1:]
+	sizes := make([]int, count)
+
+	switch lacing {
+	case 1:
+		for i := 0; i < count-1; i++ {
+			for {
+				if len(data) == 0 {
+					return nil, errCorrupt
+				}
+				b := data[0]
+				data = data[1:]
+				sizes[i] += int(b)
+				if b != 0xff {
+					break
+				}
+			}
+		}
+	case 3:
+		for i := 0; i < count-1; i++ {
+			v, n := readVint(data, false)
+			if n == 0 {
+				return nil, errCorrupt
+			}
+			data = data[n:]
+			if i == 0 {
+				sizes[i] = int(v)
+			} else {
+				sizes[i] = sizes[i-1] + int(int64(v)-(int64(1)<<(7*uint(n)-1)-1))
+			}
+		}
+	case 2:
+		if len(data)%count != 0 {
+			return nil, errCorrupt
+		}
+		for i := range sizes {
+			sizes[i] = len(data) / count
+		}
+	}
+
+	if lacing != 2 {
+		sum := 0
+		for _, size := range sizes[:count-1] {
+			// Compared one by one, as a sum of corrupted sizes can overflow
+			if size < 0 || size > len(data)-sum {
+				return nil, errCorrupt
+			}
+			sum += size
+		}
+		sizes[count-1] = len(data) - sum
+	}
+
+	frames := make([][]byte, count)
+	for i, size := range sizes {
+		frames[i] = data[:size]
+		data = data[size:]
+	}
+	return frames, nil
+}
+
+// readElementHeader reads element ID and data size, unknown size is returned as -1
+func readElementHeader(r *reader) (uint64, int64, error) {
+	id, _, err := readReaderVint(r, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	size, n, err := readReaderVint(r, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	if size == 1<<(7*uint(n))-1 {
+		return id, -1, nil
+	}
+	if size > math.MaxInt64 {
+		return 0, 0, errCorrupt
+	}
+	return id, int64(size), nil
+}
+
+func readReaderVint(r *reader, keepMarker bool) (uint64, int, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	n := bits.LeadingZeros8(b) + 1
+	if n > 8 {
+		return 0, 0, errCorrupt
+	}
+	v := uint64(b)
+	if !keepMarker {
+		v &= 0xff >> uint(n)
+	}
+	for i := 1; i < n; i++ {
+		if b, err = r.readByte(); err != nil {
+			return 0, 0, err
+		}
+		v = v<<8 | uint64(b)
+	}
+	return v, n, nil
+}
+
+// readVint reads variable size integer from a buffer, returning its value and length, zero length means an error
+func readVint(data []byte, keepMarker bool) (uint64, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+
+	n := bits.LeadingZeros8(data[0]) + 1
+	if n > 8 || n > len(data) {
+		return 0, 0
+	}
+	v := uint64(data[0])
+	if !keepMarker {
+		v &= 0xff >> uint(n)
+	}
+	for i := 1; i < n; i++ {
+		v = v<<8 | uint64(data[i])
+	}
+	return v, n
+}
+
+// eachElement calls fn for every child element of a master element, that is read into memory
+func eachElement(data []byte, fn func(id uint64, body []byte) error) error {
+	for len(data) > 0 {
+		id, n := readVint(data, true)
+		if n == 0 {
+			return errCorrupt
+		}
+		data = data[n:]
+
+		size, n := readVint(data, false)
+		if n == 0 || size > uint64(len(data)-n) {
+			return errCorrupt
+		}
+		data = data[n:]
+
+		if err := fn(id, data[:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+func readUint(data []byte) (v uint64) {
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return
+}
+
+func readFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
@@ -0,0 +1,201 @@
+package hls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// Void element is not used by the demuxer
+const mkvVoid = 0xec
+
+func ebmlID(id uint64) []byte {
+	var b []byte
+	for ; id > 0; id >>= 8 {
+		b = append([]byte{byte(id)}, b...)
+	}
+	return b
+}
+
+// mkvElement encodes sizes with 8 bytes, so that element length does not depend on values
+func mkvElement(id uint64, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(body)))
+	size[0] = 0x01
+	return append(append(ebmlID(id), size...), body...)
+}
+
+func mkvUint(id uint64, v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return mkvElement(id, b)
+}
+
+func mkvFloat(id uint64, v float64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, math.Float64bits(v))
+	return mkvElement(id, b)
+}
+
+func simpleBlock(track int, rel int64, key bool, data []byte) []byte {
+	flags := byte(0)
+	if key {
+		flags = 0x80
+	}
+	return mkvElement(mkvSimpleBlock, []byte{0x80 | byte(track), byte(rel >> 8), byte(rel), flags}, data)
+}
+
+// newMatroska creates a file with a cluster for every keyframe and Cues after clusters,
+// returning file offsets of keyframe clusters
+func newMatroska() ([]byte, []int64) {
+	header := mkvElement(mkvEBML, mkvElement(mkvDocType, []byte("matroska")))
+
+	info := mkvElement(mkvInfo,
+		mkvUint(mkvTimecodeScale, 1000000),
+		mkvFloat(mkvDuration, testDuration*1000),
+	)
+	tracks := mkvElement(mkvTracks,
+		mkvElement(mkvTrackEntry,
+			mkvUint(mkvTrackNumber, 1),
+			mkvUint(mkvTrackType, mkvTrackVideo),
+			mkvElement(mkvCodecID, []byte("V_MPEG4/ISO/AVC")),
+			mkvElement(mkvCodecPrivate, testAVCC),
+			mkvUint(mkvDefaultDuration, 1000000000/testFrameRate),
+		),
+		mkvElement(mkvTrackEntry,
+			mkvUint(mkvTrackNumber, 2),
+			mkvUint(mkvTrackType, mkvTrackAudio),
+			mkvElement(mkvCodecID, []byte("A_AAC")),
+			mkvElement(mkvCodecPrivate, testASC),
+			mkvElement(mkvAudio, mkvFloat(mkvSamplingFreq, testSampleRate), mkvUint(mkvChannels, 2)),
+		),
+	)
+
+	var clusters [][]byte
+	audio := 0
+	for k := 0; k < testFrames/testKeyInterval; k++ {
+		timecode := int64(k * testKeyInterval * 1000 / testFrameRate)
+		end := timecode + testKeyInterval*1000/testFrameRate
+
+		blocks := [][]byte{mkvUint(mkvTimecode, uint64(timecode))}
+		for i := k * testKeyInterval; i < (k+1)*testKeyInterval; i++ {
+			ms := int64(i * 1000 / testFrameRate)
+			for ; audioTime(audio) < ms && audio < testAudioFrames; audio++ {
+				blocks = append(blocks, simpleBlock(2, audioTime(audio)-timecode, true, testAudioFrame(audio)))
+			}
+			blocks = append(blocks, simpleBlock(1, ms-timecode, i%testKeyInterval == 0, testVideoFrame(i)))
+		}
+		for ; audioTime(audio) < end && audio < testAudioFrames; audio++ {
+			blocks = append(blocks, simpleBlock(2, audioTime(audio)-timecode, true, testAudioFrame(audio)))
+		}
+		clusters = append(clusters, mkvElement(mkvCluster, blocks...))
+	}
+
+	// SeekHead has the same length for any position, as numbers are fixed size
+	seekHead := func(cues int) []byte {
+		return mkvElement(mkvSeekHead, mkvElement(mkvSeek, mkvElement(mkvSeekID, ebmlID(mkvCues)), mkvUint(mkvSeekPos, uint64(cues))))
+	}
+	pos := len(seekHead(0)) + len(info) + len(tracks)
+
+	var positions []int
+	var cuePoints [][]byte
+	for k, cluster := range clusters {
+		positions = append(positions, pos)
+		cuePoints = append(cuePoints, mkvElement(mkvCuePoint,
+			mkvUint(mkvCueTime, uint64(k*testKeyInterval*1000/testFrameRate)),
+			mkvElement(mkvCueTrackPositions, mkvUint(mkvCueTrack, 1), mkvUint(mkvCueClusterPos, uint64(pos))),
+		))
+		pos += len(cluster)
+	}
+
+	body := append([][]byte{seekHead(pos), info, tracks}, clusters...)
+	body = append(body, mkvElement(mkvCues, cuePoints...))
+	segment := mkvElement(mkvSegment, body...)
+
+	segmentStart := int64(len(header) + 12)
+	offsets := make([]int64, len(positions))
+	for i, p := range positions {
+		offsets[i] = segmentStart + int64(p)
+	}
+	return join(header, segment), offsets
+}
+
+// audioTime returns Matroska timecode of audio frame in milliseconds
+func audioTime(i int) int64 {
+	return int64(i) * aacFrameSamples * 1000 / testSampleRate
+}
+
+func TestOpenMatroskaErrors(t *testing.T) {
+	data, _ := newMatroska()
+	header := mkvElement(mkvEBML, mkvElement(mkvDocType, []byte("matroska")))
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"webm doc type", join(mkvElement(mkvEBML, mkvElement(mkvDocType, []byte("webm"))), data[len(header):]), nil},
+		{"unknown doc type", join(mkvElement(mkvEBML, mkvElement(mkvDocType, []byte("other"))), data[len(header):]), ErrUnsupportedContainer},
+		{"no segment", join(header, mkvElement(mkvVoid)), errCorrupt},
+		{"no cues", join(header, mkvElement(mkvSegment, mkvElement(mkvTracks, mkvElement(mkvTrackEntry,
+			mkvUint(mkvTrackNumber, 1),
+			mkvUint(mkvTrackType, mkvTrackVideo),
+			mkvElement(mkvCodecID, []byte("V_MPEG4/ISO/AVC")),
+			mkvElement(mkvCodecPrivate, testAVCC),
+		)))), ErrNoIndex},
+		{"no video", join(header, mkvElement(mkvSegment, mkvElement(mkvTracks, mkvElement(mkvTrackEntry,
+			mkvUint(mkvTrackNumber, 1),
+			mkvUint(mkvTrackType, mkvTrackVideo),
+			mkvElement(mkvCodecID, []byte("V_VP9")),
+		)))), ErrUnsupportedCodec},
+		{"child bigger than parent", join(header, mkvElement(mkvSegment, mkvElement(mkvTracks, []byte{mkvTrackEntry, 0x90, 0x00}))), errCorrupt},
+		{"invalid vint", join(header, mkvElement(mkvSegment, []byte{0x00, 0x00})), errCorrupt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Open(bytes.NewReader(tt.data), int64(len(tt.data))); err != tt.err {
+				t.Errorf("Open err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestUnlace(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		lacing byte
+		frames []int
+		err    error
+	}{
+		{"no lacing", []byte{1, 2, 3}, 0, []int{3}, nil},
+		{"xiph", append([]byte{2, 0xff, 0x01, 2}, make([]byte, 256+2+3)...), 1, []int{256, 2, 3}, nil},
+		{"ebml", append([]byte{2, 0x82, 0xbf + 1}, make([]byte, 2+3+4)...), 3, []int{2, 3, 4}, nil},
+		{"fixed", append([]byte{2}, make([]byte, 9)...), 2, []int{3, 3, 3}, nil},
+		{"fixed uneven", append([]byte{2}, make([]byte, 8)...), 2, nil, errCorrupt},
+		{"empty", nil, 1, nil, errCorrupt},
+		{"xiph sizes over data", []byte{1, 10, 1, 2}, 1, nil, errCorrupt},
+		{"ebml negative size", []byte{2, 0x82, 0x80, 1}, 3, nil, errCorrupt},
+		{"ebml sizes overflow", append(append([]byte{0xff, 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, bytes.Repeat([]byte{0xbf}, 254)...), 1, 2, 3), 3, nil, errCorrupt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, err := unlace(tt.data, tt.lacing)
+			if err != tt.err {
+				t.Fatalf("unlace err = %v, want %v", err, tt.err)
+			}
+			if len(frames) != len(tt.frames) {
+				t.Fatalf("got %d frames, want %d", len(frames), len(tt.frames))
+			}
+			for i, f := range frames {
+				if len(f) != tt.frames[i] {
+					t.Errorf("frame %d has %d bytes, want %d", i, len(f), tt.frames[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,687 @@
+package hls
+
+import (
+	"errors"
+	"sort"
+)
+
+// Sample tables with constant sample size do not list samples, so their count is limited
+// to more than a day of 60fps video, instead of allocating memory for any corrupted number
+const maxSamples = 8 * 1024 * 1024
+
+var (
+	errFragmented = errors.New("Fragmented MP4 is not supported")
+
+	mp4TopLevel = map[string]bool{
+		"ftyp": true,
+		"moov": true,
+		"mdat": true,
+		"free": true,
+		"skip": true,
+		"wide": true,
+		"pdin": true,
+	}
+)
+
+type mp4Sample struct {
+	offset int64
+	dts    int64
+	size   uint32
+	cto    int32
+	key    bool
+}
+
+type mp4Track struct {
+	*track
+
+	handler   string
+	timescale int64
+	shift     int64
+	samples   []mp4Sample
+}
+
+type mp4Demuxer struct {
+	timescale int64
+	length    int64
+
+	video *mp4Track
+	audio *mp4Track
+	keys  []keyframe
+}
+
+func isMP4(head []byte) bool {
+	return mp4TopLevel[string(head[4:8])]
+}
+
+// openMP4 looks for the movie box, that can be stored before or after media data
+func openMP4(r *reader, size int64) (*mp4Demuxer, error) {
+	d := &mp4Demuxer{}
+
+	for pos := int64(0); pos+8 <= size; {
+		if err := r.seek(pos); err != nil {
+			return nil, err
+		}
+		header, err := r.readFull(8)
+		if err != nil {
+			return nil, err
+		}
+
+		boxSize := int64(be32(header))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = size - pos
+		case 1:
+			ext, err := r.readFull(8)
+			if err != nil {
+				return nil, err
+			}
+			boxSize = int64(be64(ext))
+			headerSize = 16
+		}
+		if boxSize < headerSize {
+			return nil, errCorrupt
+		}
+
+		switch string(header[4:8]) {
+		case "moov":
+			body, err := r.readFull(boxSize - headerSize)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.parseMoov(body); err != nil {
+				return nil, err
+			}
+			return d, nil
+		case "moof":
+			return nil, errFragmented
+		}
+
+		pos += boxSize
+	}
+
+	return nil, errCorrupt
+}
+
+func (d *mp4Demuxer) tracks() (video, audio *track) {
+	if d.video != nil {
+		video = d.video.track
+	}
+	if d.audio != nil {
+		audio = d.audio.track
+	}
+	return
+}
+
+func (d *mp4Demuxer) keyframes() []keyframe {
+	return d.keys
+}
+
+func (d *mp4Demuxer) duration() int64 {
+	return d.length
+}
+
+func (d *mp4Demuxer) parseMoov(data []byte) error {
+	err := eachBox(data, func(typ string, body []byte) error {
+		switch typ {
+		case "mvhd":
+			timescale, duration, err := parseMediaHeader(body)
+			if err != nil {
+				return err
+			}
+			d.timescale = timescale
+			d.length = rescale(duration, timescale, clockRate)
+		case "mvex":
+			return errFragmented
+		case "trak":
+			t, err := d.parseTrak(body)
+			if err != nil || t == nil {
+				return err
+			}
+			if t.video && d.video == nil {
+				d.video = t
+			} else if !t.video && d.audio == nil {
+				d.audio = t
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	} else if d.video == nil {
+		return ErrUnsupportedCodec
+	}
+
+	for i, s := range d.video.samples {
+		if s.key {
+			d.keys = append(d.keys, keyframe{
+				pts:    s.dts + int64(s.cto),
+				offset: s.offset,
+				index:  i,
+			})
+		}
+	}
+	sort.SliceStable(d.keys, func(i, j int) bool {
+		return d.keys[i].pts < d.keys[j].pts
+	})
+
+	return nil
+}
+
+// parseTrak returns track with sample table, or nil for tracks that can't be remuxed
+func (d *mp4Demuxer) parseTrak(data []byte) (*mp4Track, error) {
+	t := &mp4Track{track: &track{}}
+	tables := map[string][]byte{}
+
+	err := eachBox(data, func(typ string, body []byte) error {
+		switch typ {
+		case "tkhd":
+			if len(body) < 24 {
+				return errCorrupt
+			}
+			if body[0] == 1 {
+				t.number = int(be32(body[20:]))
+			} else {
+				t.number = int(be32(body[12:]))
+			}
+		case "edts":
+			return eachBox(body, func(typ string, body []byte) error {
+				if typ == "elst" {
+					tables[typ] = body
+				}
+				return nil
+			})
+		case "mdia":
+			return eachBox(body, func(typ string, body []byte) error {
+				switch typ {
+				case "mdhd":
+					timescale, _, err := parseMediaHeader(body)
+					t.timescale = timescale
+					return err
+				case "hdlr":
+					if len(body) < 12 {
+						return errCorrupt
+					}
+					t.handler = string(body[8:12])
+				case "minf":
+					return eachBox(body, func(typ string, body []byte) error {
+						if typ == "stbl" {
+							return eachBox(body, func(typ string, body []byte) error {
+								tables[typ] = body
+								return nil
+							})
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if t.handler != "vide" && t.handler != "soun" {
+		return nil, nil
+	} else if t.timescale <= 0 {
+		return nil, errCorrupt
+	}
+
+	t.video = t.handler == "vide"
+	if ok, err := t.parseSampleDescription(tables["stsd"]); !ok || err != nil {
+		return nil, err
+	}
+	if err := t.parseEditList(tables["elst"], d.timescale); err != nil {
+		return nil, err
+	}
+	if err := t.buildSamples(tables); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// parseSampleDescription detects codec from the first sample entry
+func (t *mp4Track) parseSampleDescription(data []byte) (bool, error) {
+	if len(data) < 16 {
+		return false, errCorrupt
+	}
+
+	entry := data[8:]
+	size := int(be32(entry))
+	if size < 16 || size > len(entry) {
+		return false, errCorrupt
+	}
+	format := string(entry[4:8])
+	entry = entry[8:size]
+
+	var err error
+	switch format {
+	case "avc1", "avc3", "hvc1", "hev1":
+		if len(entry) < 78 {
+			return false, errCorrupt
+		}
+		err = eachBox(entry[78:], func(typ string, body []byte) error {
+			switch typ {
+			case "avcC":
+				t.codec = codecH264
+				return parseAVCC(t.track, body)
+			case "hvcC":
+				t.codec = codecHEVC
+				return parseHVCC(t.track, body)
+			}
+			return nil
+		})
+	case "mp4a":
+		if len(entry) < 28 {
+			return false, errCorrupt
+		}
+		// QuickTime sound description versions have extra fields
+		skip := 28
+		switch be16(entry[8:]) {
+		case 1:
+			skip += 16
+		case 2:
+			skip += 36
+		}
+		if skip > len(entry) {
+			return false, errCorrupt
+		}
+		err = t.parseAudioBoxes(entry[skip:])
+	case "ac-3":
+		t.codec = codecAC3
+	case "ec-3":
+		t.codec = codecEAC3
+	case ".mp3":
+		t.codec = codecMP3
+	}
+
+	return t.codec != codecUnknown && err == nil, err
+}
+
+func (t *mp4Track) parseAudioBoxes(data []byte) error {
+	return eachBox(data, func(typ string, body []byte) error {
+		switch typ {
+		case "wave":
+			return t.parseAudioBoxes(body)
+		case "esds":
+			if len(body) < 4 {
+				return errCorrupt
+			}
+			return t.parseDescriptors(body[4:])
+		}
+		return nil
+	})
+}
+
+// parseDescriptors reads codec from ES_Descriptor and its DecoderConfigDescriptor
+func (t *mp4Track) parseDescriptors(data []byte) error {
+	for len(data) >= 2 {
+		tag := data[0]
+		size, p := 0, 1
+		for i := 0; i < 4 && p < len(data); i++ {
+			b := data[p]
+			p++
+			size = size<<7 | int(b&0x7f)
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		if p+size > len(data) {
+			return errCorrupt
+		}
+		body := data[p : p+size]
+		data = data[p+size:]
+
+		switch tag {
+		case 0x03:
+			if len(body) < 3 {
+				return errCorrupt
+			}
+			flags := body[2]
+			skip := 3
+			if flags&0x80 != 0 {
+				skip += 2
+			}
+			if flags&0x40 != 0 && skip < len(body) {
+				skip += 1 + int(body[skip])
+			}
+			if flags&0x20 != 0 {
+				skip += 2
+			}
+			if skip > len(body) {
+				return errCorrupt
+			}
+			return t.parseDescriptors(body[skip:])
+		case 0x04:
+			if len(body) < 13 {
+				return errCorrupt
+			}
+			switch body[0] {
+			case 0x40, 0x66, 0x67, 0x68:
+				t.codec = codecAAC
+			case 0x69, 0x6b:
+				t.codec = codecMP3
+				return nil
+			default:
+				return nil
+			}
+			return t.parseDescriptors(body[13:])
+		case 0x05:
+			if t.codec == codecAAC {
+				c, err := parseAudioSpecificConfig(body)
+				if err != nil {
+					t.codec = codecUnknown
+					return nil
+				}
+				t.aac = c
+				t.frameDuration = c.frameDuration()
+			}
+			return nil
+		}
+	}
+
+	if t.codec == codecAAC && t.aac == nil {
+		t.codec = codecUnknown
+	}
+	return nil
+}
+
+// parseEditList calculates timestamp shift, that is usually used to compensate B-frames delay
+func (t *mp4Track) parseEditList(data []byte, movieTimescale int64) error {
+	if len(data) < 8 {
+		return nil
+	}
+
+	version := data[0]
+	count := int(be32(data[4:]))
+	entrySize := 12
+	if version == 1 {
+		entrySize = 20
+	}
+	data = data[8:]
+	if count > len(data)/entrySize {
+		return errCorrupt
+	}
+
+	for i := 0; i < count; i++ {
+		e := data[i*entrySize:]
+		var duration, mediaTime int64
+		if version == 1 {
+			duration, mediaTime = int64(be64(e)), int64(be64(e[8:]))
+		} else {
+			duration, mediaTime = int64(be32(e)), int64(int32(be32(e[4:])))
+		}
+
+		if mediaTime == -1 {
+			t.shift += rescale(duration, movieTimescale, clockRate)
+			continue
+		}
+		t.shift -= rescale(mediaTime, t.timescale, clockRate)
+		break
+	}
+
+	return nil
+}
+
+// buildSamples combines sample tables into offset, size, timestamps and sync flag of every sample
+func (t *mp4Track) buildSamples(tables map[string][]byte) error {
+	sizes, err := parseSampleSizes(tables)
+	if err != nil {
+		return err
+	}
+	count := len(sizes)
+	t.samples = make([]mp4Sample, count)
+	for i, size := range sizes {
+		t.samples[i].size = size
+	}
+
+	var chunks []int64
+	if data, ok := tables["co64"]; ok {
+		err = eachEntry(data, 8, func(e []byte) {
+			chunks = append(chunks, int64(be64(e)))
+		})
+	} else {
+		err = eachEntry(tables["stco"], 4, func(e []byte) {
+			chunks = append(chunks, int64(be32(e)))
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	type chunkRun struct {
+		first   int
+		samples int
+	}
+	var runs []chunkRun
+	if err := eachEntry(tables["stsc"], 12, func(e []byte) {
+		runs = append(runs, chunkRun{first: int(be32(e)) - 1, samples: int(be32(e[4:]))})
+	}); err != nil {
+		return err
+	}
+
+	index := 0
+	for i, run := range runs {
+		last := len(chunks)
+		if i+1 < len(runs) && runs[i+1].first < last {
+			last = runs[i+1].first
+		}
+		for c := run.first; c >= 0 && c < last; c++ {
+			offset := chunks[c]
+			for j := 0; j < run.samples && index < count; j++ {
+				t.samples[index].offset = offset
+				offset += int64(t.samples[index].size)
+				index++
+			}
+		}
+	}
+	if index < count {
+		return errCorrupt
+	}
+
+	dts := int64(0)
+	index = 0
+	if err := eachEntry(tables["stts"], 8, func(e []byte) {
+		n, delta := int(be32(e)), int64(be32(e[4:]))
+		for j := 0; j < n && index < count; j++ {
+			t.samples[index].dts = rescale(dts, t.timescale, clockRate) + t.shift
+			dts += delta
+			index++
+		}
+	}); err != nil {
+		return err
+	}
+
+	index = 0
+	if err := eachEntry(tables["ctts"], 8, func(e []byte) {
+		n, offset := int(be32(e)), int64(int32(be32(e[4:])))
+		cto := int32(rescale(offset, t.timescale, clockRate))
+		for j := 0; j < n && index < count; j++ {
+			t.samples[index].cto = cto
+			index++
+		}
+	}); err != nil {
+		return err
+	}
+
+	if data, ok := tables["stss"]; ok {
+		return eachEntry(data, 4, func(e []byte) {
+			if n := int(be32(e)) - 1; n >= 0 && n < count {
+				t.samples[n].key = true
+			}
+		})
+	}
+	for i := range t.samples {
+		t.samples[i].key = true
+	}
+
+	return nil
+}
+
+func parseSampleSizes(tables map[string][]byte) ([]uint32, error) {
+	if data, ok := tables["stz2"]; ok {
+		if len(data) < 12 {
+			return nil, errCorrupt
+		}
+		fieldSize := int(data[7])
+		count := int(be32(data[8:]))
+		data = data[12:]
+		if fieldSize != 4 && fieldSize != 8 && fieldSize != 16 || count > len(data)*8/fieldSize {
+			return nil, errCorrupt
+		}
+
+		sizes := make([]uint32, count)
+		for i := range sizes {
+			switch fieldSize {
+			case 4:
+				sizes[i] = uint32(data[i/2]>>(4*uint(1-i%2))) & 0x0f
+			case 8:
+				sizes[i] = uint32(data[i])
+			case 16:
+				sizes[i] = uint32(be16(data[i*2:]))
+			}
+		}
+		return sizes, nil
+	}
+
+	data := tables["stsz"]
+	if len(data) < 12 {
+		return nil, errCorrupt
+	}
+	size := be32(data[4:])
+	count := int(be32(data[8:]))
+	data = data[12:]
+	if size == 0 && count > len(data)/4 || count > maxSamples {
+		return nil, errCorrupt
+	}
+
+	sizes := make([]uint32, count)
+	for i := range sizes {
+		if size != 0 {
+			sizes[i] = size
+		} else {
+			sizes[i] = be32(data[i*4:])
+		}
+	}
+	return sizes, nil
+}
+
+func (d *mp4Demuxer) readSegment(r *reader, seg *Segment) ([]*sample, error) {
+	type ref struct {
+		t *mp4Track
+		s *mp4Sample
+	}
+	refs := []ref{}
+
+	video := d.video.samples
+	from, to := d.keys[seg.from].index, len(video)
+	if seg.to < len(d.keys) {
+		to = d.keys[seg.to].index
+	}
+	for i := from; i < to; i++ {
+		refs = append(refs, ref{d.video, &video[i]})
+	}
+
+	if d.audio != nil {
+		last := seg.to >= len(d.keys)
+		audio := d.audio.samples
+		i := sort.Search(len(audio), func(i int) bool {
+			return audio[i].dts >= seg.Start
+		})
+		for ; i < len(audio) && (last || audio[i].dts < seg.End); i++ {
+			refs = append(refs, ref{d.audio, &audio[i]})
+		}
+	}
+
+	// Samples are read in file order, to read interleaved tracks without going back
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].s.offset < refs[j].s.offset
+	})
+
+	ret := make([]*sample, 0, len(refs))
+	for _, ref := range refs {
+		if err := r.seek(ref.s.offset); err != nil {
+			return nil, err
+		}
+		data, err := r.readFull(int64(ref.s.size))
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, &sample{
+			video: ref.t.video,
+			key:   ref.s.key,
+			pts:   ref.s.dts + int64(ref.s.cto),
+			dts:   ref.s.dts,
+			data:  data,
+		})
+	}
+
+	return ret, nil
+}
+
+// parseMediaHeader returns timescale and duration from mvhd or mdhd box
+func parseMediaHeader(data []byte) (int64, int64, error) {
+	if len(data) < 4 {
+		return 0, 0, errCorrupt
+	}
+	if data[0] == 1 {
+		if len(data) < 32 {
+			return 0, 0, errCorrupt
+		}
+		return int64(be32(data[20:])), int64(be64(data[24:])), nil
+	}
+	if len(data) < 20 {
+		return 0, 0, errCorrupt
+	}
+	return int64(be32(data[12:])), int64(be32(data[16:])), nil
+}
+
+// eachBox calls fn for every child box of a container box
+func eachBox(data []byte, fn func(typ string, body []byte) error) error {
+	for len(data) >= 8 {
+		size := uint64(be32(data))
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return errCorrupt
+			}
+			size = be64(data[8:])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return errCorrupt
+		}
+
+		if err := fn(string(data[4:8]), data[header:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// eachEntry calls fn for every entry of a full box table with entry count
+func eachEntry(data []byte, size int, fn func(e []byte)) error {
+	if data == nil {
+		return nil
+	} else if len(data) < 8 {
+		return errCorrupt
+	}
+
+	count := int(be32(data[4:]))
+	data = data[8:]
+	if count > len(data)/size {
+		return errCorrupt
+	}
+	for i := 0; i < count; i++ {
+		fn(data[i*size : (i+1)*size])
+	}
+	return nil
+}
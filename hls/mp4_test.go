@@ -0,0 +1,175 @@
+package hls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"testing"
+)
+
+// Video timescale differs from 90kHz, so that timestamps are rescaled
+const testVideoTimescale = 12800
+
+func mp4Box(typ string, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+	b := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(b, uint32(8+len(body)))
+	copy(b[4:], typ)
+	return append(b, body...)
+}
+
+func u32s(values ...uint32) []byte {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(b[i*4:], v)
+	}
+	return b
+}
+
+// mp4Table creates full box with entry count and given entries
+func mp4Table(typ string, entries ...[]uint32) []byte {
+	fields := []uint32{0, uint32(len(entries))}
+	for _, e := range entries {
+		fields = append(fields, e...)
+	}
+	return mp4Box(typ, u32s(fields...))
+}
+
+func mp4MediaHeader(typ string, timescale, duration uint32) []byte {
+	return mp4Box(typ, u32s(0, 0, 0, timescale, duration, 0))
+}
+
+func mp4Trak(id uint32, handler string, timescale uint32, entry []byte, tables ...[]byte) []byte {
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[12:], id)
+
+	return mp4Box("trak",
+		mp4Box("tkhd", tkhd),
+		mp4Box("mdia",
+			mp4MediaHeader("mdhd", timescale, 0),
+			mp4Box("hdlr", u32s(0, 0), []byte(handler), make([]byte, 13)),
+			mp4Box("minf", mp4Box("stbl", append([][]byte{mp4Box("stsd", u32s(0, 1), entry)}, tables...)...)),
+		),
+	)
+}
+
+// testESDS describes AAC LC with testASC, as ES_Descriptor with DecoderConfigDescriptor
+func testESDS() []byte {
+	config := append([]byte{0x40, 0x15, 0, 0, 0}, make([]byte, 8)...)
+	config = append(config, 0x05, byte(len(testASC)))
+	config = append(config, testASC...)
+
+	es := []byte{0x00, 0x01, 0x00, 0x04, byte(len(config))}
+	es = append(es, config...)
+	es = append(es, 0x06, 0x01, 0x02)
+
+	return mp4Box("esds", u32s(0), []byte{0x03, byte(len(es))}, es)
+}
+
+// newMP4 creates a file with interleaved samples and the movie box after media data,
+// every sample is stored in its own chunk. Video frames have composition offset of one frame.
+// It returns file offsets of video keyframes.
+func newMP4() ([]byte, []int64) {
+	type chunk struct {
+		video bool
+		time  float64
+		data  []byte
+	}
+	var chunks []chunk
+	for i := 0; i < testFrames; i++ {
+		chunks = append(chunks, chunk{true, float64(i) / testFrameRate, testVideoFrame(i)})
+	}
+	for i := 0; i < testAudioFrames; i++ {
+		chunks = append(chunks, chunk{false, float64(i*aacFrameSamples) / testSampleRate, testAudioFrame(i)})
+	}
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].time < chunks[j].time
+	})
+
+	ftyp := mp4Box("ftyp", []byte("isom"), u32s(0), []byte("isom"))
+	offset := uint32(len(ftyp) + 8)
+
+	var mdat [][]byte
+	var videoSizes, videoOffsets, audioSizes, audioOffsets []uint32
+	var keys []int64
+	var sync [][]uint32
+	for _, c := range chunks {
+		if c.video {
+			if len(videoSizes)%testKeyInterval == 0 {
+				keys = append(keys, int64(offset))
+				sync = append(sync, []uint32{uint32(len(videoSizes) + 1)})
+			}
+			videoSizes = append(videoSizes, uint32(len(c.data)))
+			videoOffsets = append(videoOffsets, offset)
+		} else {
+			audioSizes = append(audioSizes, uint32(len(c.data)))
+			audioOffsets = append(audioOffsets, offset)
+		}
+		mdat = append(mdat, c.data)
+		offset += uint32(len(c.data))
+	}
+
+	offsets := func(list []uint32) [][]uint32 {
+		ret := make([][]uint32, len(list))
+		for i, o := range list {
+			ret[i] = []uint32{o}
+		}
+		return ret
+	}
+	frameDuration := uint32(testVideoTimescale / testFrameRate)
+
+	video := mp4Trak(1, "vide", testVideoTimescale,
+		mp4Box("avc1", make([]byte, 78), mp4Box("avcC", testAVCC)),
+		mp4Table("stts", []uint32{testFrames, frameDuration}),
+		mp4Table("ctts", []uint32{testFrames, frameDuration}),
+		mp4Table("stss", sync...),
+		mp4Box("stsz", u32s(append([]uint32{0, 0, uint32(len(videoSizes))}, videoSizes...)...)),
+		mp4Table("stsc", []uint32{1, 1, 1}),
+		mp4Table("stco", offsets(videoOffsets)...),
+	)
+	audio := mp4Trak(2, "soun", testSampleRate,
+		mp4Box("mp4a", make([]byte, 28), testESDS()),
+		mp4Table("stts", []uint32{testAudioFrames, aacFrameSamples}),
+		mp4Box("stsz", u32s(append([]uint32{0, 0, uint32(len(audioSizes))}, audioSizes...)...)),
+		mp4Table("stsc", []uint32{1, 1, 1}),
+		mp4Table("stco", offsets(audioOffsets)...),
+	)
+	moov := mp4Box("moov", mp4MediaHeader("mvhd", 1000, testDuration*1000), video, audio)
+
+	return join(ftyp, mp4Box("mdat", mdat...), moov), keys
+}
+
+func TestOpenMP4Errors(t *testing.T) {
+	ftyp := mp4Box("ftyp", []byte("isom"), u32s(0), []byte("isom"))
+	trak := func(entry []byte, tables ...[]byte) []byte {
+		return mp4Trak(1, "vide", testVideoTimescale, entry, tables...)
+	}
+	avc1 := mp4Box("avc1", make([]byte, 78), mp4Box("avcC", testAVCC))
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"no movie box", join(ftyp, mp4Box("mdat", make([]byte, 16))), errCorrupt},
+		{"fragmented", join(ftyp, mp4Box("moof")), errFragmented},
+		{"movie extends", join(ftyp, mp4Box("moov", mp4Box("mvex"))), errFragmented},
+		{"no video", join(ftyp, mp4Box("moov", mp4MediaHeader("mvhd", 1000, 0))), ErrUnsupportedCodec},
+		{"unsupported video", join(ftyp, mp4Box("moov", trak(mp4Box("vp09", make([]byte, 78))))), ErrUnsupportedCodec},
+		{"box smaller than header", join(ftyp, u32s(4), []byte("moov")), errCorrupt},
+		{"child bigger than parent", join(ftyp, mp4Box("moov", u32s(64), []byte("trak"))), errCorrupt},
+		{"short media header", join(ftyp, mp4Box("moov", mp4Box("mvhd", u32s(0)))), errCorrupt},
+		{"short sample entry", join(ftyp, mp4Box("moov", trak(mp4Box("avc1", make([]byte, 20))))), errCorrupt},
+		{"sample count over table", join(ftyp, mp4Box("moov", trak(avc1, mp4Box("stsz", u32s(0, 0, 100, 1))))), errCorrupt},
+		{"too many samples", join(ftyp, mp4Box("moov", trak(avc1, mp4Box("stsz", u32s(0, 1, maxSamples+1))))), errCorrupt},
+		{"samples without chunks", join(ftyp, mp4Box("moov", trak(avc1, mp4Box("stsz", u32s(0, 1, 10))))), errCorrupt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Open(bytes.NewReader(tt.data), int64(len(tt.data))); err != tt.err {
+				t.Errorf("Open err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package hls
+
+import (
+	"bufio"
+	"io"
+)
+
+const (
+	tsPacketSize  = 188
+	tsPayloadSize = tsPacketSize - 4
+
+	pidPAT   = 0x0000
+	pidPMT   = 0x1000
+	pidVideo = 0x0100
+	pidAudio = 0x0101
+
+	streamIDVideo   = 0xe0
+	streamIDAudio   = 0xc0
+	streamIDPrivate = 0xbd
+
+	// All timestamps are shifted, so that DTS of reordered video never gets negative
+	tsOffset = clockRate
+	tsMask   = 1<<33 - 1
+
+	// Audio frames are grouped into PES packets of this duration, to reduce overhead of small frames
+	audioPESDuration = clockRate / 5
+	audioPESSize     = 32 * 1024
+)
+
+var crcTable = func() (t [256]uint32) {
+	for i := range t {
+		c := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04c11db7
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return
+}()
+
+// muxer writes single MPEG-TS segment, each segment starts with PAT and PMT,
+// so that it can be decoded independently
+type muxer struct {
+	w     *bufio.Writer
+	video *track
+	audio *track
+
+	counters map[uint16]byte
+	pkt      [tsPacketSize]byte
+
+	audioBuf []byte
+	audioPTS int64
+}
+
+func newMuxer(w io.Writer, video, audio *track) *muxer {
+	return &muxer{
+		w:        bufio.NewWriterSize(w, 64*1024),
+		video:    video,
+		audio:    audio,
+		counters: map[uint16]byte{},
+	}
+}
+
+func (m *muxer) writeSample(s *sample) error {
+	if len(m.counters) == 0 {
+		if err := m.writeTables(); err != nil {
+			return err
+		}
+	}
+
+	if s.video {
+		data, err := m.video.annexB(s.data, s.key)
+		if err != nil {
+			return err
+		}
+		return m.writePES(pidVideo, streamIDVideo, s.pts, s.dts, s.key, data)
+	}
+
+	if m.audio == nil {
+		return nil
+	}
+
+	frame := s.data
+	if m.audio.codec == codecAAC {
+		if len(frame) > adtsMaxFrameLength {
+			return nil
+		}
+		frame = append(m.audio.aac.adts(len(frame)), frame...)
+	}
+
+	if len(m.audioBuf) > 0 && (s.pts-m.audioPTS >= audioPESDuration || len(m.audioBuf)+len(frame) > audioPESSize) {
+		if err := m.flushAudio(); err != nil {
+			return err
+		}
+	}
+	if len(m.audioBuf) == 0 {
+		m.audioPTS = s.pts
+	}
+	m.audioBuf = append(m.audioBuf, frame...)
+
+	return nil
+}
+
+func (m *muxer) flushAudio() error {
+	if len(m.audioBuf) == 0 {
+		return nil
+	}
+
+	streamID := byte(streamIDAudio)
+	if m.audio.codec == codecAC3 || m.audio.codec == codecEAC3 {
+		streamID = streamIDPrivate
+	}
+
+	err := m.writePES(pidAudio, streamID, m.audioPTS, m.audioPTS, false, m.audioBuf)
+	m.audioBuf = m.audioBuf[:0]
+	return err
+}
+
+func (m *muxer) close() error {
+	if err := m.flushAudio(); err != nil {
+		return err
+	}
+	return m.w.Flush()
+}
+
+func (m *muxer) writeTables() error {
+	pat := []byte{
+		0x00, 0x00, 0x00, // table id, section length
+		0x00, 0x01, // transport stream id
+		0xc1, 0x00, 0x00, // version, section numbers
+		0x00, 0x01, // program number
+		0xe0 | pidPMT>>8, pidPMT & 0xff,
+	}
+	if err := m.writeSection(pidPAT, pat); err != nil {
+		return err
+	}
+
+	pmt := []byte{
+		0x02, 0x00, 0x00, // table id, section length
+		0x00, 0x01, // program number
+		0xc1, 0x00, 0x00, // version, section numbers
+		0xe0 | pidVideo>>8, pidVideo & 0xff, // PCR PID
+		0xf0, 0x00, // program info length
+	}
+	pmt = append(pmt, streamType(m.video.codec), 0xe0|pidVideo>>8, pidVideo&0xff, 0xf0, 0x00)
+	if m.audio != nil {
+		pmt = append(pmt, streamType(m.audio.codec), 0xe0|pidAudio>>8, pidAudio&0xff, 0xf0, 0x00)
+	}
+
+	return m.writeSection(pidPMT, pmt)
+}
+
+// writeSection sets section length, appends CRC and writes section as a single packet
+func (m *muxer) writeSection(pid uint16, section []byte) error {
+	length := len(section) - 3 + 4
+	section[1] = 0xb0 | byte(length>>8)
+	section[2] = byte(length)
+
+	crc := uint32(0xffffffff)
+	for _, b := range section {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+	}
+	section = append(section, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+
+	pkt := m.pkt[:]
+	pkt[0] = 0x47
+	pkt[1] = 0x40 | byte(pid>>8)
+	pkt[2] = byte(pid)
+	pkt[3] = 0x10 | m.nextCounter(pid)
+	pkt[4] = 0x00 // pointer field
+	n := copy(pkt[5:], section)
+	for i := 5 + n; i < tsPacketSize; i++ {
+		pkt[i] = 0xff
+	}
+
+	_, err := m.w.Write(pkt)
+	return err
+}
+
+// writePES splits PES packet into transport packets, first packet of video frames carries PCR
+func (m *muxer) writePES(pid uint16, streamID byte, pts, dts int64, key bool, payload []byte) error {
+	pts = (pts + tsOffset) & tsMask
+	dts = (dts + tsOffset) & tsMask
+	withDTS := pid == pidVideo && dts != pts
+
+	header := []byte{0x00, 0x00, 0x01, streamID, 0x00, 0x00, 0x80, 0x80, 0x05}
+	if pid == pidVideo {
+		// Data alignment, as every PES holds complete access unit
+		header[6] |= 0x04
+	}
+	if withDTS {
+		header[7] = 0xc0
+		header[8] = 0x0a
+		header = appendTimestamp(header, 0x03, pts)
+		header = appendTimestamp(header, 0x01, dts)
+	} else {
+		header = appendTimestamp(header, 0x02, pts)
+	}
+
+	// Video PES length is left unbounded, as frames can be bigger than the field allows
+	if length := len(header) - 6 + len(payload); pid != pidVideo && length <= 0xffff {
+		header[4] = byte(length >> 8)
+		header[5] = byte(length)
+	}
+
+	data := append(header, payload...)
+	withPCR := pid == pidVideo
+	first := true
+	for len(data) > 0 {
+		pkt := m.pkt[:]
+		pkt[0] = 0x47
+		pkt[1] = byte(pid>>8) & 0x1f
+		if first {
+			pkt[1] |= 0x40
+		}
+		pkt[2] = byte(pid)
+		counter := m.nextCounter(pid)
+
+		fields := first && (withPCR || key)
+		adaptation := 0
+		if fields {
+			adaptation = 2
+			if withPCR {
+				adaptation += 6
+			}
+		}
+		if space := tsPayloadSize - adaptation; len(data) < space {
+			adaptation += space - len(data)
+		}
+
+		if adaptation > 0 {
+			pkt[3] = 0x30 | counter
+			pkt[4] = byte(adaptation - 1)
+			i := 5
+			if adaptation > 1 {
+				pkt[5] = 0x00
+				if fields && key {
+					pkt[5] |= 0x40
+				}
+				if fields && withPCR {
+					pkt[5] |= 0x10
+					pcr := dts
+					pkt[6] = byte(pcr >> 25)
+					pkt[7] = byte(pcr >> 17)
+					pkt[8] = byte(pcr >> 9)
+					pkt[9] = byte(pcr >> 1)
+					pkt[10] = byte(pcr&0x01)<<7 | 0x7e
+					pkt[11] = 0x00
+					i = 12
+				} else {
+					i = 6
+				}
+			}
+			for ; i < 4+adaptation; i++ {
+				pkt[i] = 0xff
+			}
+		} else {
+			pkt[3] = 0x10 | counter
+		}
+
+		n := copy(pkt[4+adaptation:], data)
+		data = data[n:]
+		first = false
+
+		if _, err := m.w.Write(pkt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *muxer) nextCounter(pid uint16) byte {
+	c := m.counters[pid]
+	m.counters[pid] = (c + 1) & 0x0f
+	return c
+}
+
+func appendTimestamp(b []byte, prefix byte, ts int64) []byte {
+	return append(b,
+		prefix<<4|byte(ts>>29)&0x0e|0x01,
+		byte(ts>>22),
+		byte(ts>>14)|0x01,
+		byte(ts>>7),
+		byte(ts<<1)|0x01,
+	)
+}
+
+func streamType(c codec) byte {
+	switch c {
+	case codecH264:
+		return 0x1b
+	case codecHEVC:
+		return 0x24
+	case codecAAC:
+		return 0x0f
+	case codecMP3:
+		return 0x03
+	case codecAC3:
+		return 0x81
+	case codecEAC3:
+		return 0x87
+	}
+	return 0x00
+}
@@ -0,0 +1,172 @@
+package hls
+
+import (
+	"bytes"
+	"testing"
+)
+
+// tsPES is a PES packet, collected from transport packets of a single PID
+type tsPES struct {
+	pid     uint16
+	key     bool
+	pts     int64
+	dts     int64
+	payload []byte
+}
+
+// parseTS checks packet structure, continuity counters and tables of a segment,
+// and returns stream types from PMT and PES packets in the order of their start
+func parseTS(t *testing.T, data []byte) ([]byte, []*tsPES) {
+	t.Helper()
+
+	if len(data) == 0 || len(data)%tsPacketSize != 0 {
+		t.Fatalf("segment length %d is not a multiple of packet size", len(data))
+	}
+
+	var types []byte
+	var packets []*tsPES
+	current := map[uint16]*tsPES{}
+	counters := map[uint16]byte{}
+
+	for i := 0; i < len(data); i += tsPacketSize {
+		pkt := data[i : i+tsPacketSize]
+		if pkt[0] != 0x47 {
+			t.Fatalf("packet %d has no sync byte", i/tsPacketSize)
+		}
+
+		pid := uint16(pkt[1]&0x1f)<<8 | uint16(pkt[2])
+		start := pkt[1]&0x40 != 0
+		counter := pkt[3] & 0x0f
+		if c, ok := counters[pid]; ok && counter != (c+1)&0x0f {
+			t.Fatalf("packet %d of PID %#x has counter %d after %d", i/tsPacketSize, pid, counter, c)
+		}
+		counters[pid] = counter
+
+		switch {
+		case i == 0 && pid != pidPAT:
+			t.Fatalf("segment starts with PID %#x instead of PAT", pid)
+		case i == tsPacketSize && pid != pidPMT:
+			t.Fatalf("PAT is followed by PID %#x instead of PMT", pid)
+		}
+
+		payload := pkt[4:]
+		random := false
+		if pkt[3]&0x20 != 0 {
+			length := int(pkt[4])
+			if length > 0 {
+				random = pkt[5]&0x40 != 0
+			}
+			payload = pkt[5+length:]
+		}
+
+		switch pid {
+		case pidPAT, pidPMT:
+			section := payload[1+payload[0]:]
+			length := int(be16(section[1:])&0x0fff) + 3
+			crc := uint32(0xffffffff)
+			for _, b := range section[:length] {
+				crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+			}
+			if crc != 0 {
+				t.Fatalf("section of PID %#x has invalid CRC", pid)
+			}
+			if pid == pidPMT {
+				for p := 12 + int(be16(section[10:])&0x0fff); p < length-4; p += 5 + int(be16(section[p+3:])&0x0fff) {
+					types = append(types, section[p])
+				}
+			}
+		default:
+			if start {
+				current[pid] = &tsPES{pid: pid, key: random}
+				packets = append(packets, current[pid])
+			} else if current[pid] == nil {
+				t.Fatalf("PID %#x continues PES, that was not started", pid)
+			}
+			current[pid].payload = append(current[pid].payload, payload...)
+		}
+	}
+
+	for _, p := range packets {
+		if !bytes.HasPrefix(p.payload, []byte{0x00, 0x00, 0x01}) {
+			t.Fatalf("PES of PID %#x has no start code", p.pid)
+		}
+		flags := p.payload[7]
+		p.pts = readTimestamp(p.payload[9:])
+		p.dts = p.pts
+		if flags&0x40 != 0 {
+			p.dts = readTimestamp(p.payload[14:])
+		}
+		p.payload = p.payload[9+int(p.payload[8]):]
+	}
+
+	return types, packets
+}
+
+func readTimestamp(b []byte) int64 {
+	return int64(b[0]>>1&0x07)<<30 | int64(b[1])<<22 | int64(b[2]>>1)<<15 | int64(b[3])<<7 | int64(b[4]>>1)
+}
+
+func TestMuxer(t *testing.T) {
+	video := &track{video: true, codec: codecH264, nalLength: 4, paramSets: [][]byte{{0x67, 0x64}, {0x68, 0xeb}}}
+	audio := &track{codec: codecAAC, aac: &aacConfig{objectType: 2, freqIndex: 4, channels: 2}}
+
+	var b bytes.Buffer
+	m := newMuxer(&b, video, audio)
+	samples := []*sample{
+		{video: true, key: true, pts: 3600, dts: 0, data: testVideoFrame(0)},
+		{pts: 0, dts: 0, data: make([]byte, 300)},
+		{pts: 2090, dts: 2090, data: make([]byte, 300)},
+		{video: true, pts: 10800, dts: 3600, data: testVideoFrame(1)},
+		{video: true, pts: 7200, dts: 7200, data: make([]byte, 1000)},
+		{pts: audioPESDuration, dts: audioPESDuration, data: make([]byte, 300)},
+	}
+	for _, s := range samples {
+		if err := m.writeSample(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	types, packets := parseTS(t, b.Bytes())
+	if !bytes.Equal(types, []byte{0x1b, 0x0f}) {
+		t.Errorf("stream types = %x, want H.264 and AAC", types)
+	}
+
+	want := []struct {
+		pid      uint16
+		key      bool
+		pts, dts int64
+	}{
+		{pidVideo, true, 3600, 0},
+		{pidVideo, false, 10800, 3600},
+		{pidVideo, false, 7200, 7200},
+		{pidAudio, false, 0, 0},
+		{pidAudio, false, audioPESDuration, audioPESDuration},
+	}
+	if len(packets) != len(want) {
+		t.Fatalf("got %d PES packets, want %d", len(packets), len(want))
+	}
+	for i, w := range want {
+		p := packets[i]
+		if p.pid != w.pid || p.key != w.key || p.pts != w.pts+tsOffset || p.dts != w.dts+tsOffset {
+			t.Errorf("PES %d = PID %#x key %v PTS %d DTS %d, want %+v shifted by %d", i, p.pid, p.key, p.pts, p.dts, w, tsOffset)
+		}
+	}
+
+	// Keyframe gets delimiter and parameter sets before the frame
+	key := []byte{0, 0, 0, 1, 0x09, 0xf0, 0, 0, 0, 1, 0x67, 0x64, 0, 0, 0, 1, 0x68, 0xeb, 0, 0, 0, 1, 0x65}
+	if !bytes.HasPrefix(packets[0].payload, key) {
+		t.Errorf("keyframe starts with %x, want %x", packets[0].payload[:len(key)], key)
+	}
+	// Corrupted NAL size fails the sample
+	if err := m.writeSample(&sample{video: true, data: []byte{0, 0, 1, 0, 0x41}}); err != errCorrupt {
+		t.Errorf("writeSample err = %v, want %v", err, errCorrupt)
+	}
+
+	// Audio frames within PES duration are grouped with ADTS headers
+	if n := bytes.Count(packets[3].payload, []byte{0xff, 0xf1}); n != 2 {
+		t.Errorf("first audio PES has %d ADTS frames, want 2", n)
+	}
+}
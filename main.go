@@ -26,9 +26,14 @@ import (
 	"github.com/elgatito/elementum/broadcast"
 	"github.com/elgatito/elementum/config"
 	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/dlna"
 	"github.com/elgatito/elementum/exit"
+	"github.com/elgatito/elementum/feeds"
+	"github.com/elgatito/elementum/follow"
 	"github.com/elgatito/elementum/library"
 	"github.com/elgatito/elementum/lockfile"
+	"github.com/elgatito/elementum/metainfo"
+	"github.com/elgatito/elementum/quality"
 	"github.com/elgatito/elementum/repository"
 	"github.com/elgatito/elementum/scrape"
 	"github.com/elgatito/elementum/trakt"
@@ -157,6 +162,18 @@ func main() {
 
 	log.Infof("Addon: %s v%s", conf.Info.ID, conf.Info.Version)
 
+	var created *bittorrent.TorrentFile
+	if config.Args.CreateTorrent != "" {
+		if created, err = createTorrent(); err != nil {
+			log.Errorf("Could not create torrent: %s", err)
+			exit.Exit(exit.ExitCodeError)
+			return
+		} else if !config.Args.CreateSeed {
+			exit.Exit(exit.ExitCodeSuccess)
+			return
+		}
+	}
+
 	lock, err := ensureSingleInstance(conf)
 	if err != nil {
 		log.Warningf("Unable to acquire lock %q: %v, exiting...", lock.File, err)
@@ -182,6 +199,12 @@ func main() {
 
 	s := bittorrent.NewService()
 
+	if created != nil {
+		if _, err := s.SeedTorrentFile(created, config.Args.CreateTorrent); err != nil {
+			log.Errorf("Could not seed created torrent: %s", err)
+		}
+	}
+
 	var shutdown = func(code int) {
 		if s == nil || s.Closer.IsSet() {
 			return
@@ -194,6 +217,10 @@ func main() {
 
 		log.Infof("Shutting down with code %d ...", code)
 		scrape.Stop()
+		feeds.Stop()
+		follow.Stop()
+		quality.Stop()
+		dlna.Stop()
 		library.CloseLibrary()
 		s.Close(true)
 
@@ -248,6 +275,8 @@ func main() {
 		handler := http.StripPrefix("/files/", http.FileServer(bittorrent.NewTorrentFS(s, r.Method)))
 		handler.ServeHTTP(w, r)
 	}))
+	http.Handle("/dav/", bittorrent.NewTorrentDAVHandler(s, "/dav"))
+	http.Handle("/dlna/", dlna.Handler(s))
 
 	if config.Get().GreetingEnabled {
 		if xbmcHost, _ := xbmc.GetLocalXBMCHost(); xbmcHost != nil {
@@ -301,6 +330,10 @@ func main() {
 	go db.MaintenanceRefreshHandler()
 	go cacheDB.MaintenanceRefreshHandler()
 	go scrape.Start()
+	go feeds.Start(s)
+	go follow.Start(s)
+	go quality.Start(s)
+	go dlna.Start(s)
 	go util.FreeMemoryGC()
 
 	localAddress := fmt.Sprintf("%s:%d", config.Args.LocalHost, config.Args.LocalPort)
@@ -320,3 +353,29 @@ func main() {
 		os.Exit(exit.Code)
 	}
 }
+
+// createTorrent creates .torrent file from the path, given in cli arguments
+func createTorrent() (*bittorrent.TorrentFile, error) {
+	tf, b, err := bittorrent.CreateTorrentFile(config.Args.CreateTorrent, metainfo.Options{
+		PieceLength: config.Args.CreatePieceSize.Int64(),
+		Trackers:    config.Args.CreateTracker,
+		WebSeeds:    config.Args.CreateWebSeed,
+		Private:     config.Args.CreatePrivate,
+		Comment:     config.Args.CreateComment,
+		Hybrid:      config.Args.CreateHybrid,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	output := config.Args.CreateOutput
+	if output == "" {
+		output = tf.Name + ".torrent"
+	}
+	if err := os.WriteFile(output, b, 0644); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Saved torrent %s to %s", tf.InfoHash, output)
+	return tf, nil
+}
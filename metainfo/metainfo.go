@@ -0,0 +1,487 @@
+// Package metainfo creates .torrent files from local files, as v1 torrents, or hybrid ones,
+// that have both v1 and v2 metadata (BEP 3, BEP 47, BEP 52).
+package metainfo
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeebo/bencode"
+)
+
+const (
+	// BlockSize is the size of v2 merkle tree leaves
+	BlockSize = 16 * 1024
+
+	// MinPieceLength and MaxPieceLength limit piece length, used for created torrents
+	MinPieceLength = BlockSize
+	MaxPieceLength = 16 * 1024 * 1024
+
+	// Automatic piece length is chosen to have about this number of pieces
+	targetPieces = 1500
+)
+
+var (
+	// ErrNoFiles is returned when path has no files with data
+	ErrNoFiles = errors.New("No files to create torrent from")
+	// ErrInvalidPieceLength is returned for piece length, that is not a power of two, or out of limits
+	ErrInvalidPieceLength = fmt.Errorf("Piece length should be a power of two from %d to %d", MinPieceLength, MaxPieceLength)
+	// ErrFileChanged is returned when file is modified while it is hashed
+	ErrFileChanged = errors.New("File was changed while hashing")
+)
+
+// Options describe torrent, created from local files
+type Options struct {
+	// PieceLength is chosen by total size of files, if not set
+	PieceLength int64
+	Trackers    []string
+	WebSeeds    []string
+	Private     bool
+	Comment     string
+	CreatedBy   string
+	// Hybrid adds v2 metadata, files are aligned to pieces with pad files for that
+	Hybrid bool
+}
+
+type fileEntry struct {
+	path  string
+	parts []string
+	size  int64
+}
+
+type segment struct {
+	file   *fileEntry
+	offset int64
+	length int64
+}
+
+// pieceJob describes data of a single piece, hybrid torrent pieces always belong to a single file
+type pieceJob struct {
+	index    int
+	segments []segment
+	// Piece is followed by pad file, so its v1 hash is taken with zeros up to piece length
+	padded bool
+	// Number of v2 leaves the piece subtree has
+	leaves int
+}
+
+// Create hashes files at path in parallel, and returns bencoded torrent
+func Create(path string, opts Options) ([]byte, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(path)
+	files, single, err := collectFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	total := int64(0)
+	for _, f := range files {
+		total += f.size
+	}
+	if total == 0 {
+		return nil, ErrNoFiles
+	}
+
+	pieceLength := opts.PieceLength
+	if pieceLength == 0 {
+		pieceLength = PieceLength(total)
+	} else if pieceLength < MinPieceLength || pieceLength > MaxPieceLength || pieceLength&(pieceLength-1) != 0 {
+		return nil, ErrInvalidPieceLength
+	}
+
+	jobs := layoutPieces(files, pieceLength, opts.Hybrid)
+	v1, v2, err := hashPieces(jobs, pieceLength, opts.Hybrid)
+	if err != nil {
+		return nil, err
+	}
+
+	pieces := make([]byte, 0, len(v1)*sha1.Size)
+	for _, h := range v1 {
+		pieces = append(pieces, h[:]...)
+	}
+
+	info := map[string]interface{}{
+		"name":         name,
+		"piece length": pieceLength,
+		"pieces":       string(pieces),
+	}
+	if opts.Private {
+		info["private"] = 1
+	}
+
+	if single {
+		info["length"] = files[0].size
+	} else {
+		list := []interface{}{}
+		for i, f := range files {
+			list = append(list, map[string]interface{}{
+				"length": f.size,
+				"path":   f.parts,
+			})
+
+			// Pad files keep every file aligned to pieces, as v2 pieces never span files
+			if pad := padLength(f.size, pieceLength); opts.Hybrid && pad > 0 && i < len(files)-1 {
+				list = append(list, map[string]interface{}{
+					"attr":   "p",
+					"length": pad,
+					"path":   []string{".pad", strconv.FormatInt(pad, 10)},
+				})
+			}
+		}
+		info["files"] = list
+	}
+
+	torrent := map[string]interface{}{
+		"creation date": time.Now().Unix(),
+	}
+
+	if opts.Hybrid {
+		tree, layers := fileTree(files, v2, pieceLength)
+		info["meta version"] = 2
+		info["file tree"] = tree
+		if len(layers) > 0 {
+			torrent["piece layers"] = layers
+		}
+	}
+	torrent["info"] = info
+
+	if len(opts.Trackers) > 0 {
+		torrent["announce"] = opts.Trackers[0]
+	}
+	if len(opts.Trackers) > 1 {
+		tiers := [][]string{}
+		for _, tracker := range opts.Trackers {
+			tiers = append(tiers, []string{tracker})
+		}
+		torrent["announce-list"] = tiers
+	}
+	if len(opts.WebSeeds) > 0 {
+		torrent["url-list"] = opts.WebSeeds
+	}
+	if opts.Comment != "" {
+		torrent["comment"] = opts.Comment
+	}
+	if opts.CreatedBy != "" {
+		torrent["created by"] = opts.CreatedBy
+	}
+
+	return bencode.EncodeBytes(torrent)
+}
+
+// PieceLength returns power of two piece length, that gives about targetPieces pieces for total size
+func PieceLength(total int64) int64 {
+	ret := int64(MinPieceLength)
+	for ret < MaxPieceLength && total/ret > targetPieces {
+		ret *= 2
+	}
+	return ret
+}
+
+// collectFiles lists regular files at path, sorted the same way as v2 file tree keys
+func collectFiles(path string) (files []*fileEntry, single bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if info.Mode().IsRegular() {
+		return []*fileEntry{{path: path, parts: []string{info.Name()}, size: info.Size()}}, true, nil
+	} else if !info.IsDir() {
+		return nil, false, ErrNoFiles
+	}
+
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, &fileEntry{
+			path:  p,
+			parts: strings.Split(filepath.ToSlash(rel), "/"),
+			size:  fi.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(files) == 0 {
+		return nil, false, ErrNoFiles
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i].parts, files[j].parts
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return files, false, nil
+}
+
+// layoutPieces splits files into pieces. Pieces of v1 torrents span file boundaries,
+// while pieces of hybrid torrents are aligned to files.
+func layoutPieces(files []*fileEntry, pieceLength int64, hybrid bool) []*pieceJob {
+	jobs := []*pieceJob{}
+
+	if hybrid {
+		for i, f := range files {
+			leaves := int(pieceLength / BlockSize)
+			if f.size <= pieceLength {
+				leaves = nextPowerOfTwo(int((f.size + BlockSize - 1) / BlockSize))
+			}
+
+			for offset := int64(0); offset < f.size; offset += pieceLength {
+				length := pieceLength
+				if offset+length > f.size {
+					length = f.size - offset
+				}
+
+				jobs = append(jobs, &pieceJob{
+					index:    len(jobs),
+					segments: []segment{{file: f, offset: offset, length: length}},
+					padded:   length < pieceLength && i < len(files)-1,
+					leaves:   leaves,
+				})
+			}
+		}
+		return jobs
+	}
+
+	job := &pieceJob{}
+	left := pieceLength
+	for _, f := range files {
+		for offset := int64(0); offset < f.size; {
+			length := f.size - offset
+			if length > left {
+				length = left
+			}
+
+			job.segments = append(job.segments, segment{file: f, offset: offset, length: length})
+			offset += length
+			left -= length
+
+			if left == 0 {
+				jobs = append(jobs, job)
+				job = &pieceJob{index: len(jobs)}
+				left = pieceLength
+			}
+		}
+	}
+	if len(job.segments) > 0 {
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// hashPieces reads pieces with a worker per CPU, and returns v1 hashes, and roots of v2 piece subtrees for hybrid torrents
+func hashPieces(jobs []*pieceJob, pieceLength int64, hybrid bool) (v1 [][sha1.Size]byte, v2 [][sha256.Size]byte, err error) {
+	v1 = make([][sha1.Size]byte, len(jobs))
+	if hybrid {
+		v2 = make([][sha256.Size]byte, len(jobs))
+	}
+
+	queue := make(chan *pieceJob)
+	zeros := make([]byte, pieceLength)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := make(chan struct{})
+
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			buf := make([]byte, pieceLength)
+			for job := range queue {
+				n, readErr := readPiece(job, buf)
+				if readErr != nil {
+					mu.Lock()
+					if err == nil {
+						err = readErr
+						close(failed)
+					}
+					mu.Unlock()
+					continue
+				}
+
+				h := sha1.New()
+				h.Write(buf[:n])
+				if job.padded {
+					h.Write(zeros[:pieceLength-int64(n)])
+				}
+				copy(v1[job.index][:], h.Sum(nil))
+
+				if hybrid {
+					v2[job.index] = pieceRoot(buf[:n], job.leaves)
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, job := range jobs {
+		select {
+		case queue <- job:
+		case <-failed:
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return v1, v2, nil
+}
+
+// readPiece reads piece data into buf and returns its length
+func readPiece(job *pieceJob, buf []byte) (int, error) {
+	n := 0
+	for _, s := range job.segments {
+		f, err := os.Open(s.file.path)
+		if err != nil {
+			return 0, err
+		}
+
+		read, err := f.ReadAt(buf[n:n+int(s.length)], s.offset)
+		f.Close()
+		if err == io.EOF && int64(read) < s.length {
+			return 0, ErrFileChanged
+		} else if err != nil && err != io.EOF {
+			return 0, err
+		}
+		n += read
+	}
+	return n, nil
+}
+
+// pieceRoot returns root of v2 merkle subtree of the piece, leaves beyond the data are zero hashes
+func pieceRoot(data []byte, leaves int) [sha256.Size]byte {
+	hashes := make([][sha256.Size]byte, 0, leaves)
+	for offset := 0; offset < len(data); offset += BlockSize {
+		end := offset + BlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		hashes = append(hashes, sha256.Sum256(data[offset:end]))
+	}
+
+	return merkleRoot(hashes, leaves, [sha256.Size]byte{})
+}
+
+// merkleRoot pads hashes with pad hash up to count, which is a power of two, and reduces them to a root
+func merkleRoot(hashes [][sha256.Size]byte, count int, pad [sha256.Size]byte) [sha256.Size]byte {
+	layer := make([][sha256.Size]byte, count)
+	copy(layer, hashes)
+	for i := len(hashes); i < count; i++ {
+		layer[i] = pad
+	}
+
+	for len(layer) > 1 {
+		next := make([][sha256.Size]byte, len(layer)/2)
+		for i := range next {
+			next[i] = sha256.Sum256(append(layer[2*i][:], layer[2*i+1][:]...))
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+// fileTree builds v2 file tree, and piece layers of files, that are longer than a piece
+func fileTree(files []*fileEntry, roots [][sha256.Size]byte, pieceLength int64) (map[string]interface{}, map[string]interface{}) {
+	tree := map[string]interface{}{}
+	layers := map[string]interface{}{}
+
+	// Pieces, that are beyond the end of the file, are roots of subtrees with zero leaves
+	pad := merkleRoot(nil, int(pieceLength/BlockSize), [sha256.Size]byte{})
+
+	piece := 0
+	for _, f := range files {
+		node := tree
+		for _, part := range f.parts[:len(f.parts)-1] {
+			node = dirNode(node, part)
+		}
+
+		entry := map[string]interface{}{
+			"length": f.size,
+		}
+		if f.size > 0 {
+			count := int((f.size + pieceLength - 1) / pieceLength)
+			hashes := roots[piece : piece+count]
+			piece += count
+
+			root := hashes[0]
+			if count > 1 {
+				root = merkleRoot(hashes, nextPowerOfTwo(count), pad)
+
+				layer := make([]byte, 0, count*sha256.Size)
+				for _, h := range hashes {
+					layer = append(layer, h[:]...)
+				}
+				layers[string(root[:])] = string(layer)
+			}
+			entry["pieces root"] = string(root[:])
+		}
+
+		node[f.parts[len(f.parts)-1]] = map[string]interface{}{"": entry}
+	}
+
+	return tree, layers
+}
+
+func dirNode(node map[string]interface{}, name string) map[string]interface{} {
+	if child, ok := node[name].(map[string]interface{}); ok {
+		return child
+	}
+
+	child := map[string]interface{}{}
+	node[name] = child
+	return child
+}
+
+func padLength(size, pieceLength int64) int64 {
+	if rem := size % pieceLength; rem > 0 {
+		return pieceLength - rem
+	}
+	return 0
+}
+
+func nextPowerOfTwo(n int) int {
+	ret := 1
+	for ret < n {
+		ret *= 2
+	}
+	return ret
+}
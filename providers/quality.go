@@ -0,0 +1,134 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/util"
+)
+
+// GetQualityProfile returns profile, selected in settings for movies or shows,
+// nil means that sorting modes are used instead
+func GetQualityProfile(sortType int) *database.QualityProfile {
+	name := config.Get().QualityProfileMovies
+	if sortType == SortShows {
+		name = config.Get().QualityProfileShows
+	}
+	if name == "" {
+		return nil
+	}
+
+	profile := database.GetStorm().GetQualityProfile(name)
+	if profile == nil {
+		log.Warningf("Quality profile %s does not exist", name)
+	}
+	return profile
+}
+
+// AllowedByProfile checks torrent against profile restrictions, returning the reason if it is not allowed.
+// Runtime is in minutes, sizes are not checked without runtime or torrent size.
+func AllowedByProfile(p *database.QualityProfile, t *bittorrent.TorrentFile, runtime int) (bool, string) {
+	if len(p.Resolutions) > 0 && !util.IntSliceContains(p.Resolutions, t.Resolution) {
+		return false, fmt.Sprintf("Resolution %s is not allowed", bittorrent.Resolutions[t.Resolution])
+	}
+	if len(p.RipTypes) > 0 && !util.IntSliceContains(p.RipTypes, t.RipType) {
+		return false, fmt.Sprintf("Rip type %s is not allowed", bittorrent.Rips[t.RipType])
+	}
+	if p.ExcludeNuked && t.SceneRating == bittorrent.RatingNuked {
+		return false, "Release is nuked"
+	}
+
+	if runtime > 0 && t.SizeParsed > 0 {
+		perMinute := int64(t.SizeParsed) / int64(runtime)
+		if p.MinSizePerMinute > 0 && perMinute < p.MinSizePerMinute {
+			return false, "Size is too small for the runtime"
+		}
+		if p.MaxSizePerMinute > 0 && perMinute > p.MaxSizePerMinute {
+			return false, "Size is too big for the runtime"
+		}
+	}
+
+	return true, ""
+}
+
+// FilterByProfile removes torrents that are not allowed by the profile
+func FilterByProfile(p *database.QualityProfile, torrents []*bittorrent.TorrentFile, runtime int) []*bittorrent.TorrentFile {
+	ret := make([]*bittorrent.TorrentFile, 0, len(torrents))
+	for _, t := range torrents {
+		if allowed, reason := AllowedByProfile(p, t, runtime); !allowed {
+			log.Debugf("Skipping %s by quality profile %s: %s", t.Name, p.Name, reason)
+			continue
+		}
+		ret = append(ret, t)
+	}
+	return ret
+}
+
+// SortByProfile sorts torrents by profile preferences: resolutions, video codecs, languages,
+// then by rip type, proper releases first, and by seeds
+func SortByProfile(p *database.QualityProfile, torrents []*bittorrent.TorrentFile) {
+	resolutionRank := func(t *bittorrent.TorrentFile) int {
+		if len(p.Resolutions) == 0 {
+			return -t.Resolution
+		}
+		return preferenceRank(len(p.Resolutions), func(i int) bool { return p.Resolutions[i] == t.Resolution })
+	}
+	codecRank := func(t *bittorrent.TorrentFile) int {
+		return preferenceRank(len(p.VideoCodecs), func(i int) bool { return p.VideoCodecs[i] == t.VideoCodec })
+	}
+	languageRank := func(t *bittorrent.TorrentFile) int {
+		return preferenceRank(len(p.Languages), func(i int) bool { return strings.EqualFold(p.Languages[i], t.Language) })
+	}
+
+	sort.SliceStable(torrents, func(i, j int) bool {
+		a, b := torrents[i], torrents[j]
+
+		if ra, rb := resolutionRank(a), resolutionRank(b); ra != rb {
+			return ra < rb
+		}
+		if ra, rb := codecRank(a), codecRank(b); ra != rb {
+			return ra < rb
+		}
+		if ra, rb := languageRank(a), languageRank(b); ra != rb {
+			return ra < rb
+		}
+		if a.RipType != b.RipType {
+			return a.RipType > b.RipType
+		}
+		if pa, pb := a.SceneRating == bittorrent.RatingProper, b.SceneRating == bittorrent.RatingProper; pa != pb {
+			return pa
+		}
+		return a.Seeds > b.Seeds
+	})
+}
+
+// preferenceRank returns index of the first matching preference, or number of preferences if nothing matches
+func preferenceRank(count int, match func(int) bool) int {
+	for i := 0; i < count; i++ {
+		if match(i) {
+			return i
+		}
+	}
+	return count
+}
+
+// MeetsCutoff checks if quality is good enough to stop looking for upgrades,
+// profile without cutoff never upgrades
+func MeetsCutoff(p *database.QualityProfile, t *bittorrent.TorrentFile) bool {
+	if p.CutoffResolution == 0 && p.CutoffRipType == 0 {
+		return true
+	}
+	return t.Resolution >= p.CutoffResolution && t.RipType >= p.CutoffRipType
+}
+
+// IsBetterQuality checks if candidate has higher resolution, or same resolution and better rip type
+func IsBetterQuality(candidate, current *bittorrent.TorrentFile) bool {
+	if candidate.Resolution != current.Resolution {
+		return candidate.Resolution > current.Resolution
+	}
+	return candidate.RipType > current.RipType
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/elgatito/elementum/tmdb"
 	"github.com/elgatito/elementum/util"
 	"github.com/elgatito/elementum/util/event"
+	"github.com/elgatito/elementum/util/metrics"
 	"github.com/elgatito/elementum/xbmc"
 )
 
@@ -50,8 +51,23 @@ const (
 var (
 	trackerTimeout = 6000 * time.Millisecond
 	log            = logging.MustGetLogger("linkssearch")
+
+	searchDuration       = metrics.NewSummary("elementum_provider_search_duration_seconds", "Time spent collecting and resolving provider links.", "type")
+	searchResults        = metrics.NewCounter("elementum_provider_search_results_total", "Number of links received from providers.", "type")
+	searchUniqueResults  = metrics.NewCounter("elementum_provider_search_unique_results_total", "Number of unique links after merging provider results.", "type")
+	searchLastResults    = metrics.NewGauge("elementum_provider_search_last_results", "Number of unique links in the last search.", "type")
+	providerCallDuration = metrics.NewSummary("elementum_provider_call_duration_seconds", "Time spent waiting for a provider addon to respond.", "provider", "method")
+	providerCallResults  = metrics.NewCounter("elementum_provider_call_results_total", "Number of links returned by a provider addon.", "provider", "method")
+	providerCallTimeouts = metrics.NewCounter("elementum_provider_call_timeouts_total", "Number of provider addon calls that timed out.", "provider", "method")
 )
 
+func searchTypeName(sortType int) string {
+	if sortType == SortShows {
+		return "shows"
+	}
+	return "movies"
+}
+
 // Search ...
 func Search(xbmcHost *xbmc.XBMCHost, searchers []Searcher, query string) []*bittorrent.TorrentFile {
 	torrentsChan := make(chan *bittorrent.TorrentFile)
@@ -70,7 +86,7 @@ func Search(xbmcHost *xbmc.XBMCHost, searchers []Searcher, query string) []*bitt
 		close(torrentsChan)
 	}()
 
-	return processLinks(xbmcHost, torrentsChan, SortMovies, false)
+	return processLinks(xbmcHost, torrentsChan, SortMovies, 0, false)
 }
 
 // SearchMovie ...
@@ -91,7 +107,7 @@ func SearchMovie(xbmcHost *xbmc.XBMCHost, searchers []MovieSearcher, movie *tmdb
 		close(torrentsChan)
 	}()
 
-	return processLinks(xbmcHost, torrentsChan, SortMovies, false)
+	return processLinks(xbmcHost, torrentsChan, SortMovies, movieRuntime(movie), false)
 }
 
 // SearchMovieSilent ...
@@ -112,7 +128,7 @@ func SearchMovieSilent(xbmcHost *xbmc.XBMCHost, searchers []MovieSearcher, movie
 		close(torrentsChan)
 	}()
 
-	return processLinks(xbmcHost, torrentsChan, SortMovies, true)
+	return processLinks(xbmcHost, torrentsChan, SortMovies, movieRuntime(movie), true)
 }
 
 // SearchSeason ...
@@ -133,7 +149,7 @@ func SearchSeason(xbmcHost *xbmc.XBMCHost, searchers []SeasonSearcher, show *tmd
 		close(torrentsChan)
 	}()
 
-	return processLinks(xbmcHost, torrentsChan, SortShows, false)
+	return processLinks(xbmcHost, torrentsChan, SortShows, 0, false)
 }
 
 // SearchEpisode ...
@@ -154,14 +170,39 @@ func SearchEpisode(xbmcHost *xbmc.XBMCHost, searchers []EpisodeSearcher, show *t
 		close(torrentsChan)
 	}()
 
-	return processLinks(xbmcHost, torrentsChan, SortShows, false)
+	return processLinks(xbmcHost, torrentsChan, SortShows, episodeRuntime(show), false)
 }
 
-func processLinks(xbmcHost *xbmc.XBMCHost, torrentsChan chan *bittorrent.TorrentFile, sortType int, isSilent bool) []*bittorrent.TorrentFile {
+// SearchEpisodeSilent ...
+func SearchEpisodeSilent(xbmcHost *xbmc.XBMCHost, searchers []EpisodeSearcher, show *tmdb.Show, episode *tmdb.Episode, withAuth bool) []*bittorrent.TorrentFile {
+	torrentsChan := make(chan *bittorrent.TorrentFile)
+	go func() {
+		wg := sync.WaitGroup{}
+		for _, searcher := range searchers {
+			wg.Add(1)
+			go func(searcher EpisodeSearcher) {
+				defer wg.Done()
+				for _, torrent := range searcher.SearchEpisodeLinksSilent(show, episode, withAuth) {
+					torrentsChan <- torrent
+				}
+			}(searcher)
+		}
+		wg.Wait()
+		close(torrentsChan)
+	}()
+
+	return processLinks(xbmcHost, torrentsChan, SortShows, episodeRuntime(show), true)
+}
+
+// processLinks resolves, merges and sorts links, runtime is in minutes and is used by quality profiles
+func processLinks(xbmcHost *xbmc.XBMCHost, torrentsChan chan *bittorrent.TorrentFile, sortType int, runtime int, isSilent bool) []*bittorrent.TorrentFile {
 	torrentsMap := map[string]*bittorrent.TorrentFile{}
 
 	torrents := make([]*bittorrent.TorrentFile, 0)
 
+	searchStarted := time.Now()
+	searchType := searchTypeName(sortType)
+
 	log.Info("Resolving torrent files...")
 	progress := 0
 	progressTotal := 1
@@ -245,6 +286,8 @@ func processLinks(xbmcHost *xbmc.XBMCHost, torrentsChan chan *bittorrent.Torrent
 
 	wg.Wait()
 
+	searchResults.Add(float64(len(torrents)), searchType)
+
 	if !isSilent && dialogProgressBG != nil {
 		dialogProgressBG.Update(100, "Elementum", "LOCALIZE[30117]")
 	}
@@ -309,6 +352,10 @@ func processLinks(xbmcHost *xbmc.XBMCHost, torrentsChan chan *bittorrent.Torrent
 
 	log.Infof("Received %d unique links.", len(torrents))
 
+	searchDuration.Observe(time.Since(searchStarted).Seconds(), searchType)
+	searchUniqueResults.Add(float64(len(torrents)), searchType)
+	searchLastResults.Set(float64(len(torrents)), searchType)
+
 	if len(torrents) == 0 {
 		if !isSilent && dialogProgressBG != nil {
 			dialogProgressBG.Close()
@@ -368,6 +415,17 @@ func processLinks(xbmcHost *xbmc.XBMCHost, torrentsChan chan *bittorrent.Torrent
 
 	}
 
+	// Seeds from providers can be outdated, so they are replaced with numbers from trackers before sorting
+	bittorrent.ScrapeTorrents(torrents, trackerTimeout)
+
+	if profile := GetQualityProfile(sortType); profile != nil {
+		torrents = FilterByProfile(profile, torrents, runtime)
+		SortByProfile(profile, torrents)
+
+		log.Infof("Sorted %d links by quality profile %s.", len(torrents), profile.Name)
+		return torrents
+	}
+
 	// Sorting resulting list of torrents
 	conf := config.Get()
 	sortMode := conf.SortingModeMovies
@@ -427,3 +485,25 @@ func processLinks(xbmcHost *xbmc.XBMCHost, torrentsChan chan *bittorrent.Torrent
 
 	return torrents
 }
+
+// movieRuntime returns movie runtime in minutes
+func movieRuntime(movie *tmdb.Movie) int {
+	if movie == nil {
+		return 0
+	}
+	return movie.Runtime
+}
+
+// episodeRuntime returns the shortest episode runtime of a show, in minutes
+func episodeRuntime(show *tmdb.Show) (runtime int) {
+	if show == nil {
+		return
+	}
+
+	for _, r := range show.EpisodeRunTime {
+		if r > 0 && (runtime == 0 || r < runtime) {
+			runtime = r
+		}
+	}
+	return
+}
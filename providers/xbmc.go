@@ -306,6 +306,15 @@ func (as *AddonSearcher) GetSeasonSearchObject(show *tmdb.Show, season *tmdb.Sea
 	return sObject
 }
 
+// GetEpisodeSearchSilentObject ...
+func (as *AddonSearcher) GetEpisodeSearchSilentObject(show *tmdb.Show, episode *tmdb.Episode, withAuth bool) *EpisodeSearchObject {
+	o := as.GetEpisodeSearchObject(show, episode)
+	o.Silent = true
+	o.SkipAuth = !withAuth
+
+	return o
+}
+
 // GetEpisodeSearchObject ...
 func (as *AddonSearcher) GetEpisodeSearchObject(show *tmdb.Show, episode *tmdb.Episode) *EpisodeSearchObject {
 	year, _ := strconv.Atoi(strings.Split(episode.AirDate, "-")[0])
@@ -400,6 +409,12 @@ func (as *AddonSearcher) call(method string, searchObject interface{}) []*bittor
 		SearchObject: searchObject,
 	}
 
+	started := time.Now()
+	defer func() {
+		providerCallDuration.Observe(time.Since(started).Seconds(), as.addonID, method)
+		providerCallResults.Add(float64(len(torrents)), as.addonID, method)
+	}()
+
 	as.xbmcHost.ExecuteAddon(as.addonID, payload.String())
 
 	timeout := providerTimeout()
@@ -410,6 +425,7 @@ func (as *AddonSearcher) call(method string, searchObject interface{}) []*bittor
 	select {
 	case <-time.After(timeout):
 		as.log.Warningf("Provider %s was too slow. Ignored.", as.addonID)
+		providerCallTimeouts.Inc(as.addonID, method)
 		RemoveCallback(cid)
 	case result := <-c:
 		if err := json.Unmarshal(result, &torrents); err != nil {
@@ -460,3 +476,12 @@ func (as *AddonSearcher) SearchEpisodeLinks(show *tmdb.Show, episode *tmdb.Episo
 
 	return as.call("search_episode", as.GetEpisodeSearchObject(show, episode))
 }
+
+// SearchEpisodeLinksSilent ...
+func (as *AddonSearcher) SearchEpisodeLinksSilent(show *tmdb.Show, episode *tmdb.Episode, withAuth bool) []*bittorrent.TorrentFile {
+	if show == nil || episode == nil {
+		return []*bittorrent.TorrentFile{}
+	}
+
+	return as.call("search_episode", as.GetEpisodeSearchSilentObject(show, episode, withAuth))
+}
@@ -0,0 +1,269 @@
+package quality
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+
+	"github.com/elgatito/elementum/bittorrent"
+	"github.com/elgatito/elementum/broadcast"
+	"github.com/elgatito/elementum/config"
+	"github.com/elgatito/elementum/database"
+	"github.com/elgatito/elementum/library"
+	"github.com/elgatito/elementum/providers"
+	"github.com/elgatito/elementum/tmdb"
+	"github.com/elgatito/elementum/util/event"
+	"github.com/elgatito/elementum/xbmc"
+)
+
+const (
+	movieType   = "movie"
+	episodeType = "episode"
+
+	startDelay      = 5 * time.Minute
+	upgradeInterval = 6 * time.Hour
+)
+
+var (
+	log = logging.MustGetLogger("quality")
+
+	closer = event.Event{}
+
+	// mu prevents concurrent runs from adding same upgrades
+	mu sync.Mutex
+)
+
+// Stop stops looking for upgrades
+func Stop() {
+	closer.Set()
+}
+
+// Start periodically looks for better releases of downloaded media, that is below profile cutoff
+func Start(s *bittorrent.Service) {
+	closing := closer.C()
+	globalCloser := broadcast.Closer.C()
+	next := time.After(startDelay)
+
+	for {
+		select {
+		case <-globalCloser:
+			log.Info("Closing quality upgrader...")
+			return
+		case <-closing:
+			log.Info("Closing quality upgrader...")
+			return
+		case <-next:
+			next = time.After(upgradeInterval)
+			if !config.Get().QualityUpgradeEnabled || s.Closer.IsSet() {
+				continue
+			}
+			Run(s)
+		}
+	}
+}
+
+// Run replaces finished upgrades and searches upgrades for torrents below profile cutoff
+func Run(s *bittorrent.Service) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	finishUpgrades(s)
+
+	withAuth := true
+	for _, t := range s.GetTorrents() {
+		if closer.IsSet() || s.Closer.IsSet() {
+			return
+		}
+		if t == nil || t.Closer.IsSet() || !t.HasMetadata() || t.IsMemoryStorage() {
+			continue
+		}
+
+		item := database.GetStorm().GetBTItem(t.InfoHash())
+		if item == nil || item.ID == 0 || (item.Type != movieType && item.Type != episodeType) {
+			continue
+		}
+		if database.GetStorm().IsQualityUpgrade(t.InfoHash()) {
+			continue
+		}
+
+		if err := upgrade(s, t, item, withAuth); err != nil {
+			log.Warningf("Could not upgrade %s: %s", t.Name(), err)
+		}
+		withAuth = false
+	}
+}
+
+// finishUpgrades removes torrents that were replaced with downloaded upgrades
+func finishUpgrades(s *bittorrent.Service) {
+	for _, u := range database.GetStorm().GetQualityUpgrades() {
+		t := s.GetTorrentByHash(u.InfoHash)
+		if t == nil {
+			// Upgrade was removed, so we keep current torrent
+			database.GetStorm().DeleteQualityUpgrade(u.InfoHash)
+			continue
+		}
+		if t.GetProgress() < 100 {
+			continue
+		}
+
+		if old := s.GetTorrentByHash(u.Replaces); old != nil {
+			replace(s, old, t)
+		}
+		database.GetStorm().DeleteQualityUpgrade(u.InfoHash)
+	}
+}
+
+// replace removes old torrent, if it holds only the upgraded media,
+// otherwise only the file of the upgraded episode is deselected and deleted,
+// so that other episodes of a season pack are kept
+func replace(s *bittorrent.Service, old, upgrade *bittorrent.Torrent) {
+	item := upgrade.GetDBItem()
+	if item == nil {
+		item = upgrade.FetchDBItem()
+	}
+
+	var file *bittorrent.File
+	if item != nil && item.Type == episodeType {
+		file = old.ChooseEpisodeFile(item.Season, item.Episode)
+	} else {
+		for _, f := range old.GetFiles() {
+			if file == nil || f.Size > file.Size {
+				file = f
+			}
+		}
+	}
+
+	others := 0
+	for _, f := range old.GetFiles() {
+		if f != file && f.Size >= config.Get().MinCandidateSize {
+			others++
+		}
+	}
+
+	if others == 0 {
+		log.Infof("Replacing %s with downloaded upgrade %s", old.Name(), upgrade.Name())
+		s.RemoveTorrent(nil, old, true, true, false)
+		return
+	} else if file == nil {
+		log.Warningf("Keeping %s, as upgraded file could not be found among its %d files", old.Name(), others)
+		return
+	}
+
+	log.Infof("Replacing %s in %s with downloaded upgrade %s", file.Path, old.Name(), upgrade.Name())
+	old.UndownloadFiles([]*bittorrent.File{file})
+	old.SaveDBFiles()
+
+	path := filepath.Join(old.GetSavePath(), file.Path)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Warningf("Could not delete replaced file %s: %s", path, err)
+	}
+}
+
+func upgrade(s *bittorrent.Service, t *bittorrent.Torrent, item *database.BTItem, withAuth bool) error {
+	sortType := providers.SortMovies
+	if item.Type == episodeType {
+		sortType = providers.SortShows
+	}
+
+	profile := providers.GetQualityProfile(sortType)
+	if profile == nil {
+		return nil
+	}
+
+	// Quality of the current download is parsed from its name, same as for search results
+	current := bittorrent.NewTorrentFileWithName("", t.Name(), "")
+	if providers.MeetsCutoff(profile, current) {
+		return nil
+	}
+
+	xbmcHost, err := xbmc.GetLocalXBMCHost()
+	if err != nil || xbmcHost == nil {
+		return errors.New("Kodi is not available")
+	}
+
+	var candidates []*bittorrent.TorrentFile
+	if item.Type == movieType {
+		if !library.IsInLibrary(item.ID, library.MovieType) {
+			return nil
+		}
+
+		movie := tmdb.GetMovieByID(strconv.Itoa(item.ID), config.Get().Language)
+		if movie == nil {
+			return fmt.Errorf("Movie %d not found", item.ID)
+		}
+
+		log.Infof("Searching upgrade for %s", movie.Title)
+		candidates = providers.SearchMovieSilent(xbmcHost, providers.GetMovieSearchers(xbmcHost, ""), movie, withAuth)
+	} else {
+		if !library.IsInLibrary(item.ShowID, library.ShowType) {
+			return nil
+		}
+
+		show := tmdb.GetShow(item.ShowID, config.Get().Language)
+		if show == nil {
+			return fmt.Errorf("Show %d not found", item.ShowID)
+		}
+		season := tmdb.GetSeason(item.ShowID, item.Season, config.Get().Language, len(show.Seasons))
+		if season == nil || season.GetEpisode(item.Episode) == nil {
+			return fmt.Errorf("Episode S%02dE%02d of %s not found", item.Season, item.Episode, show.Name)
+		}
+
+		log.Infof("Searching upgrade for %s S%02dE%02d", show.Name, item.Season, item.Episode)
+		candidates = providers.SearchEpisodeSilent(xbmcHost, providers.GetEpisodeSearchers(xbmcHost, ""), show, season.GetEpisode(item.Episode), withAuth)
+	}
+
+	// Candidates are already filtered and sorted by the profile
+	for _, c := range candidates {
+		if c.InfoHash == t.InfoHash() || !providers.IsBetterQuality(c, current) {
+			continue
+		}
+
+		return download(s, t, item, c)
+	}
+
+	return nil
+}
+
+func download(s *bittorrent.Service, old *bittorrent.Torrent, item *database.BTItem, candidate *bittorrent.TorrentFile) error {
+	log.Infof("Upgrading %s to %s", old.Name(), candidate.Name)
+
+	t, err := s.AddTorrentWithCategory(nil, candidate.URI, old.GetCategoryName(), false, config.StorageFile, true, time.Now())
+	if err != nil {
+		return err
+	} else if t == nil {
+		return errors.New("Torrent was not added")
+	}
+
+	var file *bittorrent.File
+	if item.Type == episodeType {
+		file = t.ChooseEpisodeFile(item.Season, item.Episode)
+	} else {
+		for _, f := range t.GetFiles() {
+			if file == nil || f.Size > file.Size {
+				file = f
+			}
+		}
+	}
+	if file != nil {
+		t.DownloadFile(file)
+	} else {
+		t.DownloadAllFiles()
+	}
+
+	database.GetStorm().UpdateBTItem(t.InfoHash(), item.ID, item.Type, t.SyncSelectedFiles(), candidate.Name, item.ShowID, item.Season, item.Episode)
+	t.SaveDBFiles()
+
+	return database.GetStorm().AddQualityUpgrade(&database.QualityUpgrade{
+		InfoHash:  t.InfoHash(),
+		Replaces:  old.InfoHash(),
+		MediaType: item.Type,
+		MediaID:   item.ID,
+		Title:     candidate.Name,
+	})
+}
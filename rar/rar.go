@@ -0,0 +1,476 @@
+// Package rar reads headers of RAR archives to locate data of files, that are stored without compression.
+// Such files are laid out in volumes as is, so they can be read directly, without extraction.
+package rar
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Headers are small, so anything bigger means the archive is corrupted
+	maxHeaderSize = 2 * 1024 * 1024
+)
+
+var (
+	// ErrNotArchive is returned for volumes without RAR signature
+	ErrNotArchive = errors.New("Not a RAR archive")
+	// ErrEncrypted is returned for archives with encrypted headers
+	ErrEncrypted = errors.New("Archive headers are encrypted")
+	// ErrUnexpectedVolume is returned for volume, that does not continue files of previous volumes
+	ErrUnexpectedVolume = errors.New("Volume does not continue the archive")
+
+	errCorrupt = errors.New("Archive is corrupted")
+
+	signature4 = []byte("Rar!\x1a\x07\x00")
+	signature5 = []byte("Rar!\x1a\x07\x01\x00")
+
+	// New style volumes are named like "name.part01.rar", old style like "name.rar", "name.r00", "name.s00"
+	partVolumeRegex  = regexp.MustCompile(`(?i)^(.+)\.part(\d+)\.rar$`)
+	firstVolumeRegex = regexp.MustCompile(`(?i)^(.+)\.rar$`)
+	oldVolumeRegex   = regexp.MustCompile(`(?i)^(.+)\.([r-z])(\d{2,3})$`)
+)
+
+// Segment is a part of file data, stored in a single volume
+type Segment struct {
+	// Volume is an index of the volume in the order, they were added to the archive
+	Volume int
+	// Offset of the data in the volume
+	Offset int64
+	Size   int64
+}
+
+// File is a file of the archive, data of which can be split between volumes
+type File struct {
+	Name      string
+	Size      int64
+	Stored    bool
+	Encrypted bool
+	Segments  []Segment
+}
+
+// Streamable tells whether file data is stored as is and all of its parts are found
+func (f *File) Streamable() bool {
+	if !f.Stored || f.Encrypted || len(f.Segments) == 0 {
+		return false
+	}
+
+	size := int64(0)
+	for _, s := range f.Segments {
+		size += s.Size
+	}
+	return size == f.Size
+}
+
+// Archive collects files from volumes, which should be added in the volume order
+type Archive struct {
+	Files []*File
+
+	volumes int
+	last    bool
+	split   *File
+}
+
+// entry is a file header of a single volume
+type entry struct {
+	name      string
+	size      int64
+	offset    int64
+	packed    int64
+	stored    bool
+	encrypted bool
+	dir       bool
+
+	splitBefore bool
+	splitAfter  bool
+}
+
+// AddVolume reads headers of the next volume of the archive
+func (a *Archive) AddVolume(rs io.ReadSeeker, size int64) error {
+	if a.last {
+		return ErrUnexpectedVolume
+	}
+
+	sig := make([]byte, len(signature5))
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(rs, sig); err != nil {
+		return ErrNotArchive
+	}
+
+	var entries []*entry
+	var last bool
+	var err error
+	switch {
+	case string(sig) == string(signature5):
+		entries, last, err = readVolume5(rs, size)
+	case string(sig[:len(signature4)]) == string(signature4):
+		entries, last, err = readVolume4(rs, size)
+	default:
+		return ErrNotArchive
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.dir {
+			continue
+		}
+
+		var f *File
+		if e.splitBefore {
+			if a.split == nil || a.split.Name != e.name {
+				return ErrUnexpectedVolume
+			}
+			f = a.split
+		} else {
+			if a.split != nil {
+				return ErrUnexpectedVolume
+			}
+			f = &File{
+				Name:      e.name,
+				Size:      e.size,
+				Stored:    e.stored,
+				Encrypted: e.encrypted,
+			}
+			a.Files = append(a.Files, f)
+		}
+
+		f.Segments = append(f.Segments, Segment{
+			Volume: a.volumes,
+			Offset: e.offset,
+			Size:   e.packed,
+		})
+
+		a.split = nil
+		if e.splitAfter {
+			a.split = f
+		}
+	}
+
+	a.volumes++
+	a.last = last && a.split == nil
+	return nil
+}
+
+// Complete tells whether the last volume of the archive is added
+func (a *Archive) Complete() bool {
+	return a.last
+}
+
+// Volumes returns number of added volumes
+func (a *Archive) Volumes() int {
+	return a.volumes
+}
+
+// VolumeNumber parses volume file name and returns archive name with volume number, starting from 0
+func VolumeNumber(name string) (base string, number int, ok bool) {
+	if m := partVolumeRegex.FindStringSubmatch(name); m != nil {
+		n, err := strconv.Atoi(m[2])
+		if err != nil || n < 1 {
+			return "", 0, false
+		}
+		return m[1], n - 1, true
+	}
+	if m := firstVolumeRegex.FindStringSubmatch(name); m != nil {
+		return m[1], 0, true
+	}
+	if m := oldVolumeRegex.FindStringSubmatch(name); m != nil {
+		n, err := strconv.Atoi(m[3])
+		if err != nil {
+			return "", 0, false
+		}
+		letter := int(strings.ToLower(m[2])[0] - 'r')
+		return m[1], letter*100 + n + 1, true
+	}
+	return "", 0, false
+}
+
+// readVolume4 reads block headers of RAR 1.5-4.x volume
+func readVolume4(rs io.ReadSeeker, size int64) (entries []*entry, last bool, err error) {
+	const (
+		blockMain = 0x73
+		blockFile = 0x74
+		blockEnd  = 0x7b
+
+		flagLongBlock     = 0x8000
+		mainVolume        = 0x0001
+		mainEncrypted     = 0x0080
+		fileSplitBefore   = 0x0001
+		fileSplitAfter    = 0x0002
+		fileEncrypted     = 0x0004
+		fileDirectoryMask = 0x00e0
+		fileLargeSize     = 0x0100
+		fileUnicode       = 0x0200
+		endNextVolume     = 0x0001
+		methodStore       = 0x30
+	)
+
+	isVolume := false
+	for pos := int64(len(signature4)); pos+7 <= size; {
+		header, err := readAt(rs, pos, 7)
+		if err != nil {
+			return nil, false, err
+		}
+
+		kind := header[2]
+		flags := binary.LittleEndian.Uint16(header[3:5])
+		headSize := int64(binary.LittleEndian.Uint16(header[5:7]))
+		if headSize < 7 || pos+headSize > size {
+			return nil, false, errCorrupt
+		}
+
+		header, err = readAt(rs, pos, headSize)
+		if err != nil {
+			return nil, false, err
+		}
+		if uint16(crc32.ChecksumIEEE(header[2:])) != binary.LittleEndian.Uint16(header[0:2]) {
+			return nil, false, errCorrupt
+		}
+
+		body := header[7:]
+		dataSize := int64(0)
+		if flags&flagLongBlock != 0 {
+			if len(body) < 4 {
+				return nil, false, errCorrupt
+			}
+			dataSize = int64(binary.LittleEndian.Uint32(body[0:4]))
+		}
+
+		switch kind {
+		case blockMain:
+			if flags&mainEncrypted != 0 {
+				return nil, false, ErrEncrypted
+			}
+			isVolume = flags&mainVolume != 0
+
+		case blockFile:
+			if len(body) < 25 {
+				return nil, false, errCorrupt
+			}
+
+			packed := int64(binary.LittleEndian.Uint32(body[0:4]))
+			unpacked := int64(binary.LittleEndian.Uint32(body[4:8]))
+			nameSize := int(binary.LittleEndian.Uint16(body[19:21]))
+			off := 25
+			if flags&fileLargeSize != 0 {
+				if len(body) < 33 {
+					return nil, false, errCorrupt
+				}
+				packed |= int64(binary.LittleEndian.Uint32(body[25:29])) << 32
+				unpacked |= int64(binary.LittleEndian.Uint32(body[29:33])) << 32
+				off = 33
+			}
+			if off+nameSize > len(body) {
+				return nil, false, errCorrupt
+			}
+
+			name := body[off : off+nameSize]
+			// Unicode names are stored after zero byte, the part before it is enough to match volumes
+			if i := strings.IndexByte(string(name), 0); flags&fileUnicode != 0 && i >= 0 {
+				name = name[:i]
+			}
+
+			dataSize = packed
+			entries = append(entries, &entry{
+				name:        strings.ReplaceAll(string(name), "\\", "/"),
+				size:        unpacked,
+				offset:      pos + headSize,
+				packed:      packed,
+				stored:      body[18] == methodStore,
+				encrypted:   flags&fileEncrypted != 0,
+				dir:         flags&fileDirectoryMask == fileDirectoryMask,
+				splitBefore: flags&fileSplitBefore != 0,
+				splitAfter:  flags&fileSplitAfter != 0,
+			})
+
+		case blockEnd:
+			return entries, flags&endNextVolume == 0, nil
+		}
+
+		pos += headSize + dataSize
+	}
+
+	// Old archives have no end block, so only single volume archives are known to be complete
+	return entries, !isVolume, nil
+}
+
+// readVolume5 reads headers of RAR 5 volume
+func readVolume5(rs io.ReadSeeker, size int64) (entries []*entry, last bool, err error) {
+	const (
+		headerMain       = 1
+		headerFile       = 2
+		headerEncryption = 4
+		headerEnd        = 5
+
+		flagExtra       = 0x0001
+		flagData        = 0x0002
+		flagSplitBefore = 0x0008
+		flagSplitAfter  = 0x0010
+
+		fileDirectory = 0x0001
+		fileTime      = 0x0002
+		fileCRC       = 0x0004
+
+		extraEncryption = 1
+		endNextVolume   = 0x0001
+	)
+
+	for pos := int64(len(signature5)); pos+5 <= size; {
+		prefixSize := int64(4 + 10)
+		if pos+prefixSize > size {
+			prefixSize = size - pos
+		}
+		prefix, err := readAt(rs, pos, prefixSize)
+		if err != nil {
+			return nil, false, err
+		}
+
+		v := &vintReader{b: prefix[4:]}
+		headSize := int64(v.read())
+		if v.err != nil || headSize <= 0 || headSize > maxHeaderSize {
+			return nil, false, errCorrupt
+		}
+
+		start := 4 + int64(v.pos)
+		if pos+start+headSize > size {
+			return nil, false, errCorrupt
+		}
+		header, err := readAt(rs, pos, start+headSize)
+		if err != nil {
+			return nil, false, err
+		}
+		if crc32.ChecksumIEEE(header[4:]) != binary.LittleEndian.Uint32(header[0:4]) {
+			return nil, false, errCorrupt
+		}
+
+		v = &vintReader{b: header[start:]}
+		kind := v.read()
+		flags := v.read()
+		extraSize := uint64(0)
+		if flags&flagExtra != 0 {
+			extraSize = v.read()
+		}
+		dataSize := int64(0)
+		if flags&flagData != 0 {
+			dataSize = int64(v.read())
+		}
+		if v.err != nil || dataSize < 0 || extraSize > uint64(headSize) {
+			return nil, false, errCorrupt
+		}
+
+		switch kind {
+		case headerEncryption:
+			return nil, false, ErrEncrypted
+
+		case headerFile:
+			fileFlags := v.read()
+			unpacked := int64(v.read())
+			v.read() // attributes
+			if fileFlags&fileTime != 0 {
+				v.skip(4)
+			}
+			if fileFlags&fileCRC != 0 {
+				v.skip(4)
+			}
+			compression := v.read()
+			v.read() // host OS
+			name := v.bytes(int(v.read()))
+			if v.err != nil {
+				return nil, false, errCorrupt
+			}
+
+			encrypted := false
+			extra := header[int64(len(header))-int64(extraSize):]
+			for e := (&vintReader{b: extra}); e.pos < len(extra) && e.err == nil; {
+				recordSize := int(e.read())
+				recordStart := e.pos
+				if e.read() == extraEncryption {
+					encrypted = true
+				}
+				e.pos = recordStart
+				e.skip(recordSize)
+			}
+
+			entries = append(entries, &entry{
+				name:        string(name),
+				size:        unpacked,
+				offset:      pos + start + headSize,
+				packed:      dataSize,
+				stored:      (compression>>7)&0x7 == 0,
+				encrypted:   encrypted,
+				dir:         fileFlags&fileDirectory != 0,
+				splitBefore: flags&flagSplitBefore != 0,
+				splitAfter:  flags&flagSplitAfter != 0,
+			})
+
+		case headerEnd:
+			return entries, v.read()&endNextVolume == 0, nil
+		}
+
+		pos += start + headSize + dataSize
+	}
+
+	return entries, true, nil
+}
+
+func readAt(rs io.ReadSeeker, pos, size int64) ([]byte, error) {
+	if _, err := rs.Seek(pos, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(rs, buf); err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return nil, errCorrupt
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// vintReader reads variable length integers of RAR 5 headers, errors are kept until the end of header
+type vintReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (v *vintReader) read() (ret uint64) {
+	for shift := uint(0); v.err == nil; shift += 7 {
+		if v.pos >= len(v.b) || shift > 63 {
+			v.err = errCorrupt
+			return 0
+		}
+
+		c := v.b[v.pos]
+		v.pos++
+		ret |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return ret
+		}
+	}
+	return 0
+}
+
+func (v *vintReader) bytes(n int) []byte {
+	if v.err != nil || n < 0 || v.pos+n > len(v.b) {
+		v.err = errCorrupt
+		return nil
+	}
+
+	ret := v.b[v.pos : v.pos+n]
+	v.pos += n
+	return ret
+}
+
+func (v *vintReader) skip(n int) {
+	v.bytes(n)
+}
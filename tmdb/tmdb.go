@@ -408,7 +408,7 @@ var (
 	WarmingUp = event.Event{}
 )
 
-var rl = util.NewRateLimiter(burstRate, burstTime, simultaneousConnections)
+var rl = util.NewRateLimiter("tmdb", burstRate, burstTime, simultaneousConnections)
 
 // CheckAPIKey ...
 func CheckAPIKey() {
@@ -0,0 +1,185 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zeebo/bencode"
+)
+
+const (
+	// Info hashes are sent in the query, so their number is limited by URL length
+	maxHTTPScrapedHashes = 50
+	maxHTTPResponseSize  = 1024 * 1024
+)
+
+type httpScrapeResponse struct {
+	FailureReason string `bencode:"failure reason"`
+	Files         map[string]struct {
+		Complete   int32 `bencode:"complete"`
+		Downloaded int32 `bencode:"downloaded"`
+		Incomplete int32 `bencode:"incomplete"`
+	} `bencode:"files"`
+}
+
+type httpAnnounceResponse struct {
+	FailureReason string `bencode:"failure reason"`
+	Interval      int32  `bencode:"interval"`
+	Complete      int32  `bencode:"complete"`
+	Incomplete    int32  `bencode:"incomplete"`
+}
+
+// HTTPTracker is a client of HTTP(S) tracker
+type HTTPTracker struct {
+	URL     *url.URL
+	Timeout time.Duration
+
+	client *http.Client
+}
+
+// NewHTTPTracker creates tracker, that uses the client for requests, or the default one if it is nil
+func NewHTTPTracker(u *url.URL, client *http.Client, timeout time.Duration) *HTTPTracker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &HTTPTracker{
+		URL:     u,
+		Timeout: timeout,
+		client:  client,
+	}
+}
+
+// ScrapeURL returns scrape URL, derived from announce URL by convention: last path element,
+// starting with "announce", is replaced with "scrape"
+func (tracker *HTTPTracker) ScrapeURL() (*url.URL, bool) {
+	dir, file := path.Split(tracker.URL.Path)
+	if !strings.HasPrefix(file, "announce") {
+		return nil, false
+	}
+
+	u := *tracker.URL
+	u.Path = dir + "scrape" + strings.TrimPrefix(file, "announce")
+	u.RawPath = ""
+	return &u, true
+}
+
+func (tracker *HTTPTracker) get(u *url.URL, params string, out interface{}) error {
+	query := u.RawQuery
+	if query != "" && params != "" {
+		query += "&"
+	}
+	query += params
+
+	target := *u
+	target.RawQuery = query
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracker.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := tracker.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Tracker responded with status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseSize))
+	if err != nil {
+		return err
+	}
+	return bencode.DecodeBytes(body, out)
+}
+
+// Scrape ...
+func (tracker *HTTPTracker) Scrape(infoHashes [][20]byte) (map[[20]byte]ScrapeResponseEntry, error) {
+	scrapeURL, ok := tracker.ScrapeURL()
+	if !ok {
+		return nil, ErrScrapeNotSupported
+	}
+
+	ret := make(map[[20]byte]ScrapeResponseEntry, len(infoHashes))
+	for idx := 0; idx < len(infoHashes); idx += maxHTTPScrapedHashes {
+		max := idx + maxHTTPScrapedHashes
+		if max > len(infoHashes) {
+			max = len(infoHashes)
+		}
+
+		params := make([]string, 0, max-idx)
+		for _, h := range infoHashes[idx:max] {
+			params = append(params, "info_hash="+url.QueryEscape(string(h[:])))
+		}
+
+		resp := &httpScrapeResponse{}
+		if err := tracker.get(scrapeURL, strings.Join(params, "&"), resp); err != nil {
+			return ret, err
+		} else if resp.FailureReason != "" {
+			return ret, errors.New(resp.FailureReason)
+		}
+
+		for _, h := range infoHashes[idx:max] {
+			if f, ok := resp.Files[string(h[:])]; ok {
+				ret[h] = ScrapeResponseEntry{
+					Seeders:   f.Complete,
+					Completed: f.Downloaded,
+					Leechers:  f.Incomplete,
+				}
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// Announce ...
+func (tracker *HTTPTracker) Announce(req *AnnounceRequest) (*AnnounceResponse, error) {
+	params := url.Values{}
+	params.Set("peer_id", string(req.PeerID[:]))
+	params.Set("port", strconv.Itoa(int(req.Port)))
+	params.Set("uploaded", strconv.FormatInt(req.Uploaded, 10))
+	params.Set("downloaded", strconv.FormatInt(req.Downloaded, 10))
+	params.Set("left", strconv.FormatInt(req.Left, 10))
+	params.Set("numwant", strconv.Itoa(int(req.NumWant)))
+	params.Set("compact", "1")
+	if event, ok := eventNames[req.Event]; ok {
+		params.Set("event", event)
+	}
+
+	resp := &httpAnnounceResponse{}
+	if err := tracker.get(tracker.URL, "info_hash="+url.QueryEscape(string(req.InfoHash[:]))+"&"+params.Encode(), resp); err != nil {
+		return nil, err
+	} else if resp.FailureReason != "" {
+		return nil, errors.New(resp.FailureReason)
+	}
+
+	return &AnnounceResponse{
+		Interval: resp.Interval,
+		Leechers: resp.Incomplete,
+		Seeders:  resp.Complete,
+	}, nil
+}
+
+// Close ...
+func (tracker *HTTPTracker) Close() error {
+	return nil
+}
+
+func (tracker *HTTPTracker) String() string {
+	return tracker.URL.String()
+}
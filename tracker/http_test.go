@@ -0,0 +1,162 @@
+package tracker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zeebo/bencode"
+)
+
+// newHTTPTracker serves announce and scrape of a fixed set of info hashes
+func newHTTPTracker(t *testing.T, swarm map[[20]byte]ScrapeResponseEntry, failure string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/announce", func(w http.ResponseWriter, r *http.Request) {
+		if failure != "" {
+			bencode.NewEncoder(w).Encode(map[string]interface{}{"failure reason": failure})
+			return
+		}
+
+		var h [20]byte
+		copy(h[:], r.URL.Query().Get("info_hash"))
+		if r.URL.Query().Get("peer_id") == "" || r.URL.Query().Get("compact") != "1" {
+			http.Error(w, "Missing parameters", http.StatusBadRequest)
+			return
+		}
+
+		entry := swarm[h]
+		bencode.NewEncoder(w).Encode(map[string]interface{}{
+			"interval":   1800,
+			"complete":   entry.Seeders,
+			"incomplete": entry.Leechers,
+			"peers":      "",
+		})
+	})
+	mux.HandleFunc("/scrape", func(w http.ResponseWriter, r *http.Request) {
+		if failure != "" {
+			bencode.NewEncoder(w).Encode(map[string]interface{}{"failure reason": failure})
+			return
+		}
+
+		files := map[string]interface{}{}
+		for _, hash := range r.URL.Query()["info_hash"] {
+			var h [20]byte
+			copy(h[:], hash)
+			if entry, ok := swarm[h]; ok {
+				files[hash] = map[string]interface{}{
+					"complete":   entry.Seeders,
+					"downloaded": entry.Completed,
+					"incomplete": entry.Leechers,
+				}
+			}
+		}
+		bencode.NewEncoder(w).Encode(map[string]interface{}{"files": files})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPScrapeURL(t *testing.T) {
+	tests := map[string]string{
+		"http://tracker.example.com/announce":            "http://tracker.example.com/scrape",
+		"http://tracker.example.com/x/announce.php?k=v":  "http://tracker.example.com/x/scrape.php?k=v",
+		"https://tracker.example.com:8443/announce/pass": "",
+		"http://tracker.example.com/a":                   "",
+	}
+
+	for announce, want := range tests {
+		u, _ := url.Parse(announce)
+		scrape, ok := NewHTTPTracker(u, nil, time.Second).ScrapeURL()
+		if want == "" {
+			if ok {
+				t.Errorf("%s should not have scrape URL, got %s", announce, scrape)
+			}
+			continue
+		}
+		if !ok || scrape.String() != want {
+			t.Errorf("ScrapeURL(%s) = %v, want %s", announce, scrape, want)
+		}
+	}
+}
+
+func TestHTTPScrape(t *testing.T) {
+	known := [20]byte{1, '&', '=', 0xff}
+	unknown := [20]byte{2}
+	srv := newHTTPTracker(t, map[[20]byte]ScrapeResponseEntry{
+		known: {Seeders: 10, Completed: 100, Leechers: 5},
+	}, "")
+
+	tracker, err := New(srv.URL+"/announce", srv.Client(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := tracker.Scrape([][20]byte{known, unknown})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ret) != 1 {
+		t.Fatalf("got %d entries, want only the known hash", len(ret))
+	}
+	if e := ret[known]; e.Seeders != 10 || e.Completed != 100 || e.Leechers != 5 {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestHTTPAnnounce(t *testing.T) {
+	hash := [20]byte{3}
+	srv := newHTTPTracker(t, map[[20]byte]ScrapeResponseEntry{
+		hash: {Seeders: 7, Leechers: 3},
+	}, "")
+
+	tracker, err := New(srv.URL+"/announce", srv.Client(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := tracker.Announce(&AnnounceRequest{InfoHash: hash, PeerID: [20]byte{'-', 'E', 'L'}, Event: EventStarted, Port: 6881})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Interval != 1800 || resp.Seeders != 7 || resp.Leechers != 3 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestHTTPFailureReason(t *testing.T) {
+	srv := newHTTPTracker(t, nil, "unregistered torrent")
+
+	tracker, err := New(srv.URL+"/announce", srv.Client(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tracker.Scrape([][20]byte{{1}}); err == nil || err.Error() != "unregistered torrent" {
+		t.Errorf("Scrape err = %v, want failure reason", err)
+	}
+	if _, err := tracker.Announce(&AnnounceRequest{InfoHash: [20]byte{1}, PeerID: [20]byte{1}}); err == nil || err.Error() != "unregistered torrent" {
+		t.Errorf("Announce err = %v, want failure reason", err)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	tracker, _ := New(srv.URL+"/announce", srv.Client(), time.Second)
+	if _, err := tracker.Scrape([][20]byte{{1}}); err == nil {
+		t.Error("Scrape should fail on HTTP error status")
+	}
+}
+
+func TestNewUnsupportedScheme(t *testing.T) {
+	if _, err := New("wss://tracker.example.com/announce", nil, 0); err != ErrUnsupportedScheme {
+		t.Errorf("err = %v, want ErrUnsupportedScheme", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Package tracker implements clients for UDP (BEP 15) and HTTP(S) trackers,
+// used to check swarm health of torrents without adding them to the session.
+package tracker
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// DefaultTimeout limits a single request to a tracker
+	DefaultTimeout = 3 * time.Second
+)
+
+var (
+	// ErrUnsupportedScheme is returned for trackers, that are neither UDP nor HTTP(S)
+	ErrUnsupportedScheme = errors.New("Only UDP and HTTP trackers are supported")
+	// ErrScrapeNotSupported is returned by HTTP trackers, that have no scrape URL
+	ErrScrapeNotSupported = errors.New("Tracker does not support scrape")
+)
+
+// Event is announced to a tracker
+type Event int32
+
+const (
+	// EventNone is a regular announce
+	EventNone Event = iota
+	// EventCompleted ...
+	EventCompleted
+	// EventStarted ...
+	EventStarted
+	// EventStopped ...
+	EventStopped
+)
+
+var eventNames = map[Event]string{
+	EventCompleted: "completed",
+	EventStarted:   "started",
+	EventStopped:   "stopped",
+}
+
+// ScrapeResponseEntry is a swarm state of a single info hash
+type ScrapeResponseEntry struct {
+	Seeders   int32
+	Completed int32
+	Leechers  int32
+}
+
+// AnnounceRequest describes a client of a single torrent
+type AnnounceRequest struct {
+	InfoHash   [20]byte
+	PeerID     [20]byte
+	Downloaded int64
+	Left       int64
+	Uploaded   int64
+	Event      Event
+	NumWant    int32
+	Port       uint16
+}
+
+// AnnounceResponse is a swarm state, returned for announce, peers are not kept
+type AnnounceResponse struct {
+	Interval int32
+	Leechers int32
+	Seeders  int32
+}
+
+// Tracker is a client of a single tracker
+type Tracker interface {
+	// Scrape returns swarm states of info hashes, hashes, missing in the tracker, are not returned
+	Scrape(infoHashes [][20]byte) (map[[20]byte]ScrapeResponseEntry, error)
+	Announce(req *AnnounceRequest) (*AnnounceResponse, error)
+	Close() error
+	String() string
+}
+
+// New creates client for the tracker URL, client is used for HTTP trackers
+func New(trackerURL string, client *http.Client, timeout time.Duration) (Tracker, error) {
+	u, err := url.Parse(trackerURL)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	switch u.Scheme {
+	case "udp":
+		return NewUDPTracker(u, timeout), nil
+	case "http", "https":
+		return NewHTTPTracker(u, client, timeout), nil
+	}
+	return nil, ErrUnsupportedScheme
+}
@@ -0,0 +1,258 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	connectionRequestInitialID int64 = 0x041727101980
+	// Connection ID is valid for a minute, it is renewed a bit earlier
+	connectionIDTimeout = 50 * time.Second
+	defaultBufferSize   = 2048 // must be bigger than MTU, which is 1500 most of the time
+	maxScrapedHashes    = 70
+)
+
+const (
+	// ActionConnect ...
+	ActionConnect Action = iota
+	// ActionAnnounce ...
+	ActionAnnounce
+	// ActionScrape ...
+	ActionScrape
+	// ActionError ...
+	ActionError
+	// Some trackers send error action in LittleEndian(3)
+	actionErrorLittleEndian = 50331648
+)
+
+// Action ...
+type Action int32
+
+// TrackerRequest ...
+type TrackerRequest struct {
+	ConnectionID  int64
+	Action        Action
+	TransactionID int32
+}
+
+// TrackerResponse ...
+type TrackerResponse struct {
+	Action        Action
+	TransactionID int32
+}
+
+// udpAnnounceRequest is announce request in BEP 15 wire format
+type udpAnnounceRequest struct {
+	InfoHash   [20]byte
+	PeerID     [20]byte
+	Downloaded int64
+	Left       int64
+	Uploaded   int64
+	Event      int32
+	IPAddress  int32
+	Key        int32
+	NumWant    int32
+	Port       uint16
+}
+
+// UDPTracker is a client of BEP 15 tracker
+type UDPTracker struct {
+	URL     *url.URL
+	Timeout time.Duration
+
+	connection   net.Conn
+	connectionID int64
+	connected    time.Time
+}
+
+// NewUDPTracker ...
+func NewUDPTracker(u *url.URL, timeout time.Duration) *UDPTracker {
+	return &UDPTracker{
+		URL:          u,
+		Timeout:      timeout,
+		connectionID: connectionRequestInitialID,
+	}
+}
+
+// request sends request and returns reader of the response payload. Every response comes in a single datagram,
+// so datagrams of other transactions, that are late, are skipped.
+func (tracker *UDPTracker) request(action Action, payload []byte) (*bytes.Reader, error) {
+	trackerRequest := TrackerRequest{
+		ConnectionID:  tracker.connectionID,
+		Action:        action,
+		TransactionID: rand.Int31(),
+	}
+
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, trackerRequest)
+	buf.Write(payload)
+
+	tracker.connection.SetDeadline(time.Now().Add(tracker.Timeout))
+	if _, err := tracker.connection.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	packet := make([]byte, defaultBufferSize)
+	for {
+		n, err := tracker.connection.Read(packet)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return nil, errors.New("Request timed out")
+			}
+			return nil, err
+		}
+
+		r := bytes.NewReader(packet[:n])
+		trackerResponse := TrackerResponse{}
+		if err := binary.Read(r, binary.BigEndian, &trackerResponse); err != nil {
+			return nil, err
+		}
+		if trackerResponse.TransactionID != trackerRequest.TransactionID {
+			continue
+		}
+
+		if trackerResponse.Action == ActionError || trackerResponse.Action == actionErrorLittleEndian {
+			msg := make([]byte, r.Len())
+			r.Read(msg)
+			return nil, errors.New(strings.TrimRight(string(msg), "\x00"))
+		} else if trackerResponse.Action != action {
+			return nil, errors.New("Request/Response action missmatch")
+		}
+		return r, nil
+	}
+}
+
+// Connect ...
+func (tracker *UDPTracker) Connect() error {
+	if tracker.connection != nil && time.Since(tracker.connected) < connectionIDTimeout {
+		return nil
+	}
+
+	if tracker.connection == nil {
+		host := tracker.URL.Host
+		if tracker.URL.Port() == "" {
+			host = net.JoinHostPort(tracker.URL.Hostname(), "80")
+		}
+
+		var err error
+		tracker.connection, err = net.DialTimeout("udp", host, tracker.Timeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	tracker.connectionID = connectionRequestInitialID
+	r, err := tracker.request(ActionConnect, nil)
+	if err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.BigEndian, &tracker.connectionID); err != nil {
+		return err
+	}
+
+	tracker.connected = time.Now()
+	return nil
+}
+
+func (tracker *UDPTracker) doScrape(infoHashes [][20]byte) ([]ScrapeResponseEntry, error) {
+	payload := make([]byte, 0, len(infoHashes)*20)
+	for _, h := range infoHashes {
+		payload = append(payload, h[:]...)
+	}
+
+	r, err := tracker.request(ActionScrape, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some trackers answer only for the first hashes, so only entries, that are received, are returned
+	count := r.Len() / binary.Size(ScrapeResponseEntry{})
+	if count > len(infoHashes) {
+		count = len(infoHashes)
+	}
+
+	entries := make([]ScrapeResponseEntry, count)
+	if err := binary.Read(r, binary.BigEndian, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Scrape requests info hashes in batches of maxScrapedHashes, as response should fit a datagram
+func (tracker *UDPTracker) Scrape(infoHashes [][20]byte) (map[[20]byte]ScrapeResponseEntry, error) {
+	ret := make(map[[20]byte]ScrapeResponseEntry, len(infoHashes))
+
+	for idx := 0; idx < len(infoHashes); idx += maxScrapedHashes {
+		max := idx + maxScrapedHashes
+		if max > len(infoHashes) {
+			max = len(infoHashes)
+		}
+
+		if err := tracker.Connect(); err != nil {
+			return ret, err
+		}
+		entries, err := tracker.doScrape(infoHashes[idx:max])
+		if err != nil {
+			return ret, err
+		}
+		for i, entry := range entries {
+			ret[infoHashes[idx+i]] = entry
+		}
+	}
+
+	return ret, nil
+}
+
+// Announce ...
+func (tracker *UDPTracker) Announce(req *AnnounceRequest) (*AnnounceResponse, error) {
+	if err := tracker.Connect(); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, udpAnnounceRequest{
+		InfoHash:   req.InfoHash,
+		PeerID:     req.PeerID,
+		Downloaded: req.Downloaded,
+		Left:       req.Left,
+		Uploaded:   req.Uploaded,
+		Event:      int32(req.Event),
+		Key:        rand.Int31(),
+		NumWant:    req.NumWant,
+		Port:       req.Port,
+	})
+
+	r, err := tracker.request(ActionAnnounce, buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &AnnounceResponse{}
+	if err := binary.Read(r, binary.BigEndian, resp); err != nil {
+		return nil, fmt.Errorf("Could not read announce response: %s", err)
+	}
+	return resp, nil
+}
+
+// Close ...
+func (tracker *UDPTracker) Close() error {
+	if tracker.connection == nil {
+		return nil
+	}
+
+	err := tracker.connection.Close()
+	tracker.connection = nil
+	return err
+}
+
+func (tracker *UDPTracker) String() string {
+	return tracker.URL.String()
+}
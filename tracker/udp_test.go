@@ -0,0 +1,202 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const testConnectionID int64 = 0x1122334455667788
+
+// udpResponder is a local BEP 15 tracker, that knows a fixed set of info hashes
+type udpResponder struct {
+	conn  *net.UDPConn
+	swarm map[[20]byte]ScrapeResponseEntry
+	// maxEntries limits scrape response, as some trackers answer only for the first hashes
+	maxEntries int
+	// failure is sent as error action for announce and scrape
+	failure string
+}
+
+func newUDPResponder(t *testing.T, swarm map[[20]byte]ScrapeResponseEntry) *udpResponder {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("Could not listen on loopback: %s", err)
+	}
+
+	r := &udpResponder{conn: conn, swarm: swarm}
+	go r.serve()
+	t.Cleanup(func() { conn.Close() })
+	return r
+}
+
+func (r *udpResponder) url() *url.URL {
+	return &url.URL{Scheme: "udp", Host: r.conn.LocalAddr().String(), Path: "/announce"}
+}
+
+func (r *udpResponder) serve() {
+	packet := make([]byte, defaultBufferSize)
+	for {
+		n, remote, err := r.conn.ReadFromUDP(packet)
+		if err != nil {
+			return
+		}
+
+		in := bytes.NewReader(packet[:n])
+		req := TrackerRequest{}
+		if err := binary.Read(in, binary.BigEndian, &req); err != nil {
+			continue
+		}
+
+		out := &bytes.Buffer{}
+		switch {
+		case req.Action == ActionConnect && req.ConnectionID == connectionRequestInitialID:
+			binary.Write(out, binary.BigEndian, TrackerResponse{ActionConnect, req.TransactionID})
+			binary.Write(out, binary.BigEndian, testConnectionID)
+		case req.ConnectionID != testConnectionID:
+			continue
+		case r.failure != "":
+			binary.Write(out, binary.BigEndian, TrackerResponse{ActionError, req.TransactionID})
+			out.WriteString(r.failure)
+		case req.Action == ActionScrape:
+			binary.Write(out, binary.BigEndian, TrackerResponse{ActionScrape, req.TransactionID})
+			for i := 0; in.Len() >= 20 && (r.maxEntries == 0 || i < r.maxEntries); i++ {
+				var h [20]byte
+				in.Read(h[:])
+				binary.Write(out, binary.BigEndian, r.swarm[h])
+			}
+		case req.Action == ActionAnnounce:
+			announce := udpAnnounceRequest{}
+			if err := binary.Read(in, binary.BigEndian, &announce); err != nil {
+				continue
+			}
+			entry := r.swarm[announce.InfoHash]
+			binary.Write(out, binary.BigEndian, TrackerResponse{ActionAnnounce, req.TransactionID})
+			binary.Write(out, binary.BigEndian, AnnounceResponse{Interval: 1800, Leechers: entry.Leechers, Seeders: entry.Seeders})
+		default:
+			continue
+		}
+
+		r.conn.WriteToUDP(out.Bytes(), remote)
+	}
+}
+
+func TestUDPScrape(t *testing.T) {
+	known := [20]byte{1}
+	unknown := [20]byte{2}
+	r := newUDPResponder(t, map[[20]byte]ScrapeResponseEntry{
+		known: {Seeders: 10, Completed: 100, Leechers: 5},
+	})
+
+	tracker := NewUDPTracker(r.url(), time.Second)
+	defer tracker.Close()
+
+	ret, err := tracker.Scrape([][20]byte{known, unknown})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ret) != 2 {
+		t.Fatalf("got %d entries, want 2", len(ret))
+	}
+	if e := ret[known]; e.Seeders != 10 || e.Completed != 100 || e.Leechers != 5 {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestUDPScrapeBatches(t *testing.T) {
+	hashes := make([][20]byte, maxScrapedHashes+5)
+	swarm := map[[20]byte]ScrapeResponseEntry{}
+	for i := range hashes {
+		hashes[i][0] = byte(i)
+		hashes[i][1] = 1
+		swarm[hashes[i]] = ScrapeResponseEntry{Seeders: int32(i)}
+	}
+	r := newUDPResponder(t, swarm)
+
+	tracker := NewUDPTracker(r.url(), time.Second)
+	defer tracker.Close()
+
+	ret, err := tracker.Scrape(hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ret) != len(hashes) {
+		t.Fatalf("got %d entries, want %d", len(ret), len(hashes))
+	}
+	for i, h := range hashes {
+		if ret[h].Seeders != int32(i) {
+			t.Errorf("hash %d has %d seeders", i, ret[h].Seeders)
+		}
+	}
+}
+
+func TestUDPScrapeShortResponse(t *testing.T) {
+	first := [20]byte{1}
+	second := [20]byte{2}
+	r := newUDPResponder(t, map[[20]byte]ScrapeResponseEntry{
+		first:  {Seeders: 1},
+		second: {Seeders: 2},
+	})
+	r.maxEntries = 1
+
+	tracker := NewUDPTracker(r.url(), time.Second)
+	defer tracker.Close()
+
+	ret, err := tracker.Scrape([][20]byte{first, second})
+	if err != nil {
+		t.Fatalf("short response should not fail: %s", err)
+	}
+	if len(ret) != 1 || ret[first].Seeders != 1 {
+		t.Errorf("got %+v, want only the first hash", ret)
+	}
+}
+
+func TestUDPAnnounce(t *testing.T) {
+	hash := [20]byte{3}
+	r := newUDPResponder(t, map[[20]byte]ScrapeResponseEntry{
+		hash: {Seeders: 7, Leechers: 3},
+	})
+
+	tracker := NewUDPTracker(r.url(), time.Second)
+	defer tracker.Close()
+
+	resp, err := tracker.Announce(&AnnounceRequest{InfoHash: hash, Event: EventStarted, NumWant: -1, Port: 6881})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Interval != 1800 || resp.Seeders != 7 || resp.Leechers != 3 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestUDPError(t *testing.T) {
+	r := newUDPResponder(t, nil)
+	r.failure = "unregistered torrent"
+
+	tracker := NewUDPTracker(r.url(), time.Second)
+	defer tracker.Close()
+
+	if _, err := tracker.Scrape([][20]byte{{1}}); err == nil || err.Error() != "unregistered torrent" {
+		t.Errorf("err = %v, want tracker failure", err)
+	}
+}
+
+func TestUDPTimeout(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("Could not listen on loopback: %s", err)
+	}
+	defer conn.Close()
+
+	tracker := NewUDPTracker(&url.URL{Scheme: "udp", Host: conn.LocalAddr().String()}, 100*time.Millisecond)
+	defer tracker.Close()
+
+	if err := tracker.Connect(); err == nil {
+		t.Error("Connect should time out, if tracker does not answer")
+	}
+}
@@ -64,7 +64,7 @@ var (
 	ErrLocked = errors.New("Account is locked")
 )
 
-var rl = util.NewRateLimiter(burstRate, burstTime, simultaneousConnections)
+var rl = util.NewRateLimiter("trakt", burstRate, burstTime, simultaneousConnections)
 
 // Object ...
 type Object struct {
@@ -0,0 +1,253 @@
+// Package metrics implements a minimal registry of counters, gauges and summaries,
+// exported in Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/anacrolix/sync"
+)
+
+// Metric types, as used in the "# TYPE" line
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+	TypeSummary = "summary"
+)
+
+// Labels is a set of label names and values of a single sample
+type Labels map[string]string
+
+// Sample is a single value of a metric with its labels
+type Sample struct {
+	Labels Labels
+	Value  float64
+}
+
+// Vec is a metric family with fixed label names, values are stored per label values combination
+type Vec struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*value
+}
+
+type value struct {
+	labels []string
+	value  float64
+	sum    float64
+	count  uint64
+}
+
+var (
+	mu       sync.Mutex
+	registry = map[string]*Vec{}
+)
+
+// NewCounter registers a counter family
+func NewCounter(name, help string, labelNames ...string) *Vec {
+	return register(name, help, TypeCounter, labelNames)
+}
+
+// NewGauge registers a gauge family
+func NewGauge(name, help string, labelNames ...string) *Vec {
+	return register(name, help, TypeGauge, labelNames)
+}
+
+// NewSummary registers a summary family, which only tracks sum and count of observations
+func NewSummary(name, help string, labelNames ...string) *Vec {
+	return register(name, help, TypeSummary, labelNames)
+}
+
+func register(name, help, kind string, labelNames []string) *Vec {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if v, ok := registry[name]; ok {
+		return v
+	}
+
+	v := &Vec{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		values:     map[string]*value{},
+	}
+	registry[name] = v
+	return v
+}
+
+func (v *Vec) get(labelValues []string) *value {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	val, ok := v.values[key]
+	if !ok {
+		val = &value{labels: append([]string{}, labelValues...)}
+		v.values[key] = val
+	}
+	return val
+}
+
+// Add increments the value for given label values
+func (v *Vec) Add(delta float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.get(labelValues).value += delta
+}
+
+// Inc increments the value for given label values by one
+func (v *Vec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Set sets the value for given label values
+func (v *Vec) Set(val float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.get(labelValues).value = val
+}
+
+// Observe adds an observation to a summary
+func (v *Vec) Observe(val float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	entry := v.get(labelValues)
+	entry.sum += val
+	entry.count++
+}
+
+func (v *Vec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if v.kind == TypeSummary {
+		sums := make([]Sample, 0, len(keys))
+		counts := make([]Sample, 0, len(keys))
+		for _, k := range keys {
+			val := v.values[k]
+			labels := v.labelsOf(val)
+			sums = append(sums, Sample{Labels: labels, Value: val.sum})
+			counts = append(counts, Sample{Labels: labels, Value: float64(val.count)})
+		}
+
+		writeHeader(w, v.name, v.help, v.kind)
+		writeSamples(w, v.name+"_sum", sums)
+		writeSamples(w, v.name+"_count", counts)
+		return
+	}
+
+	samples := make([]Sample, 0, len(keys))
+	for _, k := range keys {
+		val := v.values[k]
+		samples = append(samples, Sample{Labels: v.labelsOf(val), Value: val.value})
+	}
+	WriteFamily(w, v.name, v.help, v.kind, samples)
+}
+
+func (v *Vec) labelsOf(val *value) Labels {
+	labels := Labels{}
+	for i, name := range v.labelNames {
+		labels[name] = val.labels[i]
+	}
+	return labels
+}
+
+// WriteRegistered writes all registered metric families
+func WriteRegistered(w io.Writer) {
+	mu.Lock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	mu.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		mu.Lock()
+		v := registry[name]
+		mu.Unlock()
+
+		v.write(w)
+	}
+}
+
+// WriteFamily writes a metric family that is collected on demand
+func WriteFamily(w io.Writer, name, help, kind string, samples []Sample) {
+	writeHeader(w, name, help, kind)
+	writeSamples(w, name, samples)
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+func writeSamples(w io.Writer, name string, samples []Sample) {
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(s.Labels), formatValue(s.Value))
+	}
+}
+
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, name, escapeLabel(labels[name])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
@@ -9,16 +9,25 @@ import (
 
 	"github.com/anacrolix/sync"
 	"github.com/op/go-logging"
+
+	"github.com/elgatito/elementum/util/metrics"
 )
 
 var log = logging.MustGetLogger("ratelimit")
 
+var (
+	rateLimiterWaits       = metrics.NewCounter("elementum_ratelimiter_waits_total", "Number of times a call had to wait for the rate limit.", "limiter")
+	rateLimiterWaitSeconds = metrics.NewCounter("elementum_ratelimiter_wait_seconds_total", "Total time spent waiting for the rate limit.", "limiter")
+	rateLimiterCoolDowns   = metrics.NewCounter("elementum_ratelimiter_cooldowns_total", "Number of cooldowns requested by remote API with Retry-After header.", "limiter")
+)
+
 // A RateLimiter limits the rate at which an action can be performed.  It
 // applies neither smoothing (like one could achieve in a token bucket system)
 // nor does it offer any conception of warmup, wherein the rate of actions
 // granted are steadily increased until a steady throughput equilibrium is
 // reached.
 type RateLimiter struct {
+	name         string
 	limit        int
 	interval     time.Duration
 	mtx          sync.Mutex
@@ -34,9 +43,11 @@ var (
 	ErrHTTP     = errors.New("HTTP error")
 )
 
-// NewRateLimiter creates a new rate limiter for the limit and interval.
-func NewRateLimiter(limit int, interval time.Duration, parallelCount int) *RateLimiter {
+// NewRateLimiter creates a new rate limiter for the limit and interval,
+// name is used to distinguish limiters in metrics.
+func NewRateLimiter(name string, limit int, interval time.Duration, parallelCount int) *RateLimiter {
 	lim := &RateLimiter{
+		name:         name,
 		limit:        limit,
 		interval:     interval,
 		parallelChan: make(chan bool, parallelCount),
@@ -49,12 +60,19 @@ func NewRateLimiter(limit int, interval time.Duration, parallelCount int) *RateL
 // of fairness for multiple actors if the allowed rate has been temporarily
 // exhausted.
 func (r *RateLimiter) Wait() {
+	var waited time.Duration
 	for {
 		ok, remaining := r.Try()
 		if ok {
 			break
 		}
 		time.Sleep(remaining)
+		waited += remaining
+	}
+
+	if waited > 0 {
+		rateLimiterWaits.Inc(r.name)
+		rateLimiterWaitSeconds.Add(waited.Seconds(), r.name)
 	}
 }
 
@@ -105,6 +123,8 @@ func (r *RateLimiter) CoolDown(headers http.Header) {
 			return
 		}
 
+		rateLimiterCoolDowns.Inc(r.name)
+
 		r.mtx.Lock()
 		log.Debugf("Met a cooldown, sleeping for %#v seconds. Headers: %#v", coolDown, headers)
 